@@ -0,0 +1,73 @@
+// Parsing and building account ACLs
+
+package swift
+
+import (
+	"encoding/json"
+)
+
+// AccountACL is a parsed X-Account-Access-Control header.
+//
+// Each list holds the users given that level of access to the whole
+// account, eg "project:user" or "project:*".  Users with Admin access
+// can do anything the account owner can apart from changing the
+// account ACL.  Users with ReadWrite access can read and write all
+// the containers.  Users with ReadOnly access can read and list all
+// the containers.
+//
+// Only the account owner can see or set the account ACL.
+type AccountACL struct {
+	Admin     []string `json:"admin,omitempty"`      // Users with admin access
+	ReadOnly  []string `json:"read-only,omitempty"`  // Users with read only access
+	ReadWrite []string `json:"read-write,omitempty"` // Users with read and write access
+}
+
+// ParseAccountACL parses an X-Account-Access-Control header.  An
+// empty header gives an empty ACL.
+func ParseAccountACL(s string) (*AccountACL, error) {
+	acl := new(AccountACL)
+	if s == "" {
+		return acl, nil
+	}
+	err := json.Unmarshal([]byte(s), acl)
+	if err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// Empty returns whether the ACL gives no one access.
+func (acl *AccountACL) Empty() bool {
+	return len(acl.Admin) == 0 && len(acl.ReadOnly) == 0 && len(acl.ReadWrite) == 0
+}
+
+// String returns the ACL in the form used in the
+// X-Account-Access-Control header.  An empty ACL returns "" which
+// removes the header.
+func (acl *AccountACL) String() string {
+	if acl.Empty() {
+		return ""
+	}
+	data, err := canonicalJson(acl)
+	if err != nil {
+		// Can't happen as the ACL is only strings
+		return ""
+	}
+	return string(data)
+}
+
+// AccountACL reads the ACL of the account.
+func (c *Connection) AccountACL() (*AccountACL, error) {
+	_, headers, err := c.Account()
+	if err != nil {
+		return nil, err
+	}
+	return ParseAccountACL(headers["X-Account-Access-Control"])
+}
+
+// AccountSetACL sets the ACL of the account, replacing the existing
+// one.  An empty ACL removes all access to the account by other
+// users.
+func (c *Connection) AccountSetACL(acl *AccountACL) error {
+	return c.AccountUpdate(Headers{"X-Account-Access-Control": acl.String()})
+}
@@ -0,0 +1,37 @@
+// Tests for account ACLs
+package swift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccountACL(t *testing.T) {
+	acl, err := ParseAccountACL("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acl.Empty() || acl.String() != "" {
+		t.Errorf("Expecting empty ACL got %+v", acl)
+	}
+
+	in := `{"read-write":["project:writer"],"admin":["project:admin","other:*"]}`
+	acl, err = ParseAccountACL(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AccountACL{
+		Admin:     []string{"project:admin", "other:*"},
+		ReadWrite: []string{"project:writer"},
+	}
+	if !reflect.DeepEqual(*acl, want) {
+		t.Errorf("want %+v got %+v", want, *acl)
+	}
+	if got := acl.String(); got != `{"admin":["project:admin","other:*"],"read-write":["project:writer"]}` {
+		t.Errorf("Bad ACL %s", got)
+	}
+
+	if _, err = ParseAccountACL("project:user"); err == nil {
+		t.Error("Expecting error")
+	}
+}
@@ -0,0 +1,65 @@
+// Typed access to the settings of an account
+
+package swift
+
+import (
+	"strconv"
+)
+
+// AccountSettings holds the settings of an account which Swift and
+// its middleware keep in the account's headers.
+//
+// Read them with Account or Headers.AccountSettings and change them
+// with AccountConfigure.  Zero values mean the setting isn't in use,
+// eg a QuotaBytes of 0 means there is no quota.
+type AccountSettings struct {
+	QuotaBytes    int64       // X-Account-Meta-Quota-Bytes - maximum bytes in the account - only a reseller admin can set this
+	TempUrlKeys   TempUrlKeys // X-Account-Meta-Temp-Url-Key and X-Account-Meta-Temp-Url-Key-2 - keys for temporary URLs and form posts
+	AccessControl string      // X-Account-Access-Control - account ACL as JSON - only the account owner can see or set this
+}
+
+// AccountSettings reads the settings of an account from the headers
+// returned by Account.
+//
+// Values which can't be parsed are treated as unset.
+func (h Headers) AccountSettings() *AccountSettings {
+	quotaBytes, _ := strconv.ParseInt(h["X-Account-Meta-Quota-Bytes"], 10, 64)
+	return &AccountSettings{
+		QuotaBytes:    quotaBytes,
+		TempUrlKeys:   readTempUrlKeys("Account", h),
+		AccessControl: h["X-Account-Access-Control"],
+	}
+}
+
+// Headers returns the headers which set the account to s.
+//
+// Settings which aren't in use have empty values so the headers
+// remove them.
+func (s *AccountSettings) Headers() Headers {
+	h := s.TempUrlKeys.headers("Account")
+	h["X-Account-Meta-Quota-Bytes"] = formatQuota(s.QuotaBytes)
+	h["X-Account-Access-Control"] = s.AccessControl
+	return h
+}
+
+// AccountConfigure sets all the settings of the account to s,
+// removing any which aren't in use in s.
+//
+// To change some of the settings read them with Account first and
+// modify them.  Any user metadata other than that used by the
+// settings is left alone.
+//
+// Swift rejects changes to the quota from anyone but a reseller admin
+// so the quota is only sent if it differs from the account's current
+// quota.  This costs a HEAD of the account.
+func (c *Connection) AccountConfigure(s *AccountSettings) error {
+	_, headers, err := c.Account()
+	if err != nil {
+		return err
+	}
+	h := s.Headers()
+	if headers.AccountSettings().QuotaBytes == s.QuotaBytes {
+		delete(h, "X-Account-Meta-Quota-Bytes")
+	}
+	return c.AccountUpdate(h)
+}
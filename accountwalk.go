@@ -0,0 +1,123 @@
+// Walking all the objects in an account
+
+package swift
+
+import (
+	"sync"
+)
+
+// DefaultAccountWalkWorkers is the number of containers
+// AccountObjectsWalk lists at once if AccountObjectsOpts.Workers
+// isn't set
+const DefaultAccountWalkWorkers = 4
+
+// AccountObjectsOpts is options for AccountObjectsWalk
+type AccountObjectsOpts struct {
+	Workers    int             // Number of containers to list at once - 0 for DefaultAccountWalkWorkers
+	Containers *ContainersOpts // Which containers to walk - nil for all of them
+	Objects    *ObjectsOpts    // Options for listing the objects in each container - can be nil
+}
+
+// AccountObjectsWalkFn is called by AccountObjectsWalk with each
+// object and the name of the container it is in
+type AccountObjectsWalkFn func(container string, object *Object) error
+
+// accountObject is an object found by one of the AccountObjectsWalk
+// workers or the error it got
+type accountObject struct {
+	container string
+	object    Object
+	err       error
+}
+
+// AccountObjectsWalk calls fn for every object in every container of
+// the account.
+//
+// Workers containers are listed at once, a page at a time, so the
+// objects of different containers are interleaved but the objects of
+// each container are passed in listing order.  fn is only called
+// from one go routine at once so it doesn't need to do any locking.
+//
+// Containers which are deleted while the walk is in progress are
+// skipped.
+//
+// If fn returns an error the walk stops and the error is returned,
+// unless it is StopWalk in which case nil is returned.  If listing a
+// container fails then the walk stops and that error is returned.
+//
+// opts may be nil for the defaults.
+func (c *Connection) AccountObjectsWalk(opts *AccountObjectsOpts, fn AccountObjectsWalkFn) error {
+	workers := DefaultAccountWalkWorkers
+	var containersOpts *ContainersOpts
+	var objectsOpts *ObjectsOpts
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		containersOpts = opts.Containers
+		objectsOpts = opts.Objects
+	}
+	containers, err := c.ContainerNamesAll(containersOpts)
+	if err != nil {
+		return err
+	}
+
+	in := make(chan string)
+	out := make(chan accountObject)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for container := range in {
+				err := c.ObjectsEach(container, objectsOpts, func(object *Object) error {
+					select {
+					case out <- accountObject{container: container, object: *object}:
+						return nil
+					case <-done:
+						return StopWalk
+					}
+				})
+				if err != nil && err != ContainerNotFound {
+					select {
+					case out <- accountObject{err: err}:
+					case <-done:
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(in)
+		for _, container := range containers {
+			select {
+			case in <- container:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	// Read all the results, stopping the workers on the first error
+	for item := range out {
+		if err != nil {
+			continue
+		}
+		err = item.err
+		if err == nil {
+			err = fn(item.container, &item.object)
+		}
+		if err != nil {
+			close(done)
+		}
+	}
+	if err == StopWalk {
+		err = nil
+	}
+	return err
+}
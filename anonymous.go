@@ -0,0 +1,61 @@
+// Reading public containers without authenticating
+
+package swift
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// anonymousAuth is an Authenticator which doesn't authenticate, for
+// use with public containers
+type anonymousAuth struct {
+	storageUrl string
+}
+
+// Request returns nil as there is nothing to request
+func (auth *anonymousAuth) Request(c *Connection) (*http.Request, error) {
+	return nil, nil
+}
+
+// Response is never called as there is no request
+func (auth *anonymousAuth) Response(resp *http.Response) error {
+	return nil
+}
+
+// StorageUrl returns the storage URL the Connection was made with
+func (auth *anonymousAuth) StorageUrl(Internal bool) string {
+	return auth.storageUrl
+}
+
+// Token returns "" so no X-Auth-Token is sent
+func (auth *anonymousAuth) Token() string {
+	return ""
+}
+
+// CdnUrl returns "" as there is no CDN
+func (auth *anonymousAuth) CdnUrl() string {
+	return ""
+}
+
+// NewAnonymousConnection makes a Connection which doesn't
+// authenticate, for reading public containers, ie those with a
+// X-Container-Read ACL of ".r:*" (and ".rlistings" to list them).
+//
+// storageUrl is the URL of the account, eg
+// "https://swift.example.com/v1/AUTH_test", which is the URL of a
+// public container without the container name.
+//
+// GET and HEAD requests for objects in public containers and listings
+// of them work as normal without an auth token.  Anything else will
+// return an error, usually AuthorizationFailed, as the server would
+// for any other anonymous request.
+func NewAnonymousConnection(storageUrl string) *Connection {
+	storageUrl = strings.TrimSuffix(storageUrl, "/")
+	return &Connection{
+		StorageUrl: storageUrl,
+		Auth:       &anonymousAuth{storageUrl: storageUrl},
+		authLock:   &sync.Mutex{},
+	}
+}
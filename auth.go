@@ -31,6 +31,26 @@ type Expireser interface {
 	Expires() time.Time
 }
 
+// CatalogEndpoint is an endpoint of a service in the service catalog
+type CatalogEndpoint struct {
+	Region    string       // Region of the endpoint
+	Interface EndpointType // Interface - public, internal or admin
+	Url       string       // URL of the endpoint
+}
+
+// CatalogService is a service in the service catalog returned by v2
+// and v3 auth
+type CatalogService struct {
+	Name      string            // Name of the service eg "swift"
+	Type      string            // Type of the service eg "object-store"
+	Endpoints []CatalogEndpoint // Endpoints of the service
+}
+
+// Cataloger is an optional interface to read the service catalog
+type Cataloger interface {
+	ServiceCatalog() []CatalogService
+}
+
 type CustomEndpointAuthenticator interface {
 	StorageUrlForEndpoint(endpointType EndpointType) string
 }
@@ -255,6 +275,30 @@ func (auth *v2Auth) Expires() time.Time {
 	return t
 }
 
+// v2 Authentication - read service catalog
+func (auth *v2Auth) ServiceCatalog() []CatalogService {
+	var services []CatalogService
+	for _, catalog := range auth.Auth.Access.ServiceCatalog {
+		service := CatalogService{
+			Name: catalog.Name,
+			Type: catalog.Type,
+		}
+		for _, endpoint := range catalog.Endpoints {
+			for _, e := range []CatalogEndpoint{
+				{endpoint.Region, EndpointTypePublic, endpoint.PublicUrl},
+				{endpoint.Region, EndpointTypeInternal, endpoint.InternalUrl},
+				{endpoint.Region, EndpointTypeAdmin, endpoint.AdminUrl},
+			} {
+				if e.Url != "" {
+					service.Endpoints = append(service.Endpoints, e)
+				}
+			}
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
 // v2 Authentication - read cdn url
 func (auth *v2Auth) CdnUrl() string {
 	return auth.endpointUrl("rax:object-cdn", EndpointTypePublic)
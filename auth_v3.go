@@ -95,8 +95,8 @@ type v3AuthResponse struct {
 		}
 
 		Catalog []struct {
-			Id, Namem, Type string
-			Endpoints       []struct {
+			Id, Name, Type string
+			Endpoints      []struct {
 				Id, Region_Id, Url, Region string
 				Interface                  EndpointType
 			}
@@ -295,6 +295,25 @@ func (auth *v3Auth) Expires() time.Time {
 	return t
 }
 
+func (auth *v3Auth) ServiceCatalog() []CatalogService {
+	var services []CatalogService
+	for _, catalog := range auth.Auth.Token.Catalog {
+		service := CatalogService{
+			Name: catalog.Name,
+			Type: catalog.Type,
+		}
+		for _, endpoint := range catalog.Endpoints {
+			service.Endpoints = append(service.Endpoints, CatalogEndpoint{
+				Region:    endpoint.Region,
+				Interface: endpoint.Interface,
+				Url:       endpoint.Url,
+			})
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
 func (auth *v3Auth) CdnUrl() string {
 	return ""
 }
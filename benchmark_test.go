@@ -0,0 +1,163 @@
+// Benchmarks against the fake Swift server
+//
+// Run these with
+//
+//	go test -run XXX -bench .
+
+package swift_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ncw/swift"
+)
+
+// makeBenchConnection makes an authenticated connection with an empty
+// container.  Call the returned function to remove the container and
+// its objects.
+func makeBenchConnection(b *testing.B) (*swift.Connection, func()) {
+	c, rollback := makeConnection(nil)
+	err := c.Authenticate()
+	if err != nil {
+		rollback()
+		b.Fatal("Auth failed", err)
+	}
+	err = c.ContainerCreate(CONTAINER, nil)
+	if err != nil {
+		rollback()
+		b.Fatal(err)
+	}
+	return c, func() {
+		names, err := c.ObjectNamesAll(CONTAINER, nil)
+		if err != nil {
+			b.Error(err)
+		}
+		for _, name := range names {
+			err = c.ObjectDelete(CONTAINER, name)
+			if err != nil {
+				b.Error(err)
+			}
+		}
+		err = c.ContainerDelete(CONTAINER)
+		if err != nil {
+			b.Error(err)
+		}
+		rollback()
+	}
+}
+
+// benchSizes are the object sizes used in the transfer benchmarks
+var benchSizes = []struct {
+	name string
+	size int
+}{
+	{"1K", 1 << 10},
+	{"64K", 64 << 10},
+	{"1M", 1 << 20},
+	{"16M", 16 << 20},
+}
+
+func BenchmarkObjectPut(b *testing.B) {
+	c, rollback := makeBenchConnection(b)
+	defer rollback()
+	for _, bs := range benchSizes {
+		data := make([]byte, bs.size)
+		b.Run(bs.name, func(b *testing.B) {
+			b.SetBytes(int64(bs.size))
+			for i := 0; i < b.N; i++ {
+				_, err := c.ObjectPut(CONTAINER, OBJECT, bytes.NewReader(data), true, "", "", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkObjectGet(b *testing.B) {
+	c, rollback := makeBenchConnection(b)
+	defer rollback()
+	for _, bs := range benchSizes {
+		err := c.ObjectPutBytes(CONTAINER, OBJECT, make([]byte, bs.size), "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bs.name, func(b *testing.B) {
+			b.SetBytes(int64(bs.size))
+			for i := 0; i < b.N; i++ {
+				_, err := c.ObjectGet(CONTAINER, OBJECT, ioutil.Discard, true, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkObjectsListing(b *testing.B) {
+	c, rollback := makeBenchConnection(b)
+	defer rollback()
+	const objects = 1000
+	for i := 0; i < objects; i++ {
+		err := c.ObjectPutString(CONTAINER, fmt.Sprintf("dir%d/object%04d", i%10, i), CONTENTS, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.Run("Objects", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			objs, err := c.Objects(CONTAINER, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(objs) != objects {
+				b.Fatalf("Expecting %d objects got %d", objects, len(objs))
+			}
+		}
+	})
+	b.Run("ObjectNames", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			names, err := c.ObjectNames(CONTAINER, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(names) != objects {
+				b.Fatalf("Expecting %d names got %d", objects, len(names))
+			}
+		}
+	})
+}
+
+// benchHeaders makes a Headers with n metadata items as well as some
+// standard headers
+func benchHeaders(n int) swift.Headers {
+	h := swift.Headers{
+		"Content-Type":   "text/plain",
+		"Content-Length": "12345",
+		"Etag":           CONTENT_MD5,
+		"Last-Modified":  "Fri, 12 Jun 2010 13:40:18 GMT",
+	}
+	for i := 0; i < n; i++ {
+		h[fmt.Sprintf("X-Object-Meta-Key%d", i)] = fmt.Sprintf("value %d", i)
+	}
+	return h
+}
+
+func BenchmarkHeadersObjectMetadata(b *testing.B) {
+	h := benchHeaders(20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = h.ObjectMetadata()
+	}
+}
+
+func BenchmarkMetadataObjectHeaders(b *testing.B) {
+	m := benchHeaders(20).ObjectMetadata()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.ObjectHeaders()
+	}
+}
@@ -0,0 +1,380 @@
+// Caching downloaded objects on local disk and in memory
+
+package swift
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheMaxBytes is the size of a Cache if CacheOpts.MaxBytes
+// isn't set
+const DefaultCacheMaxBytes = 1 << 30
+
+// CacheOpts is options for NewCache
+type CacheOpts struct {
+	Dir      string // Directory to keep the objects in - created if it doesn't exist - no disk cache if not set
+	MaxBytes int64  // Total size of the objects kept on disk - DefaultCacheMaxBytes if not set
+
+	MemoryMaxBytes      int64         // Total size of the objects kept in memory - no memory cache if not set
+	MemoryMaxObjectSize int64         // Largest object kept in memory - DefaultCacheMemoryMaxObjectSize if not set
+	MemoryMaxAge        time.Duration // Serve objects in memory without checking the Etag for this long
+}
+
+// Cache wraps a Connection keeping copies of the objects downloaded
+// through it on local disk so repeated reads don't download them
+// again.  Small frequently read objects, eg configuration or
+// thumbnails, can be kept in memory too.
+//
+// ObjectGet, ObjectGetBytes and ObjectGetString check the Etag of the
+// object with a HEAD request and copy it from the cache if it is
+// there, otherwise they download it saving a copy.  Objects are only
+// saved if the download checked the hash, and requests with headers
+// or the WithHeaders, WithGzip or WithEncryption options aren't
+// cached.
+//
+// The cached objects are named after the container, object name and
+// Etag so they are never served when the object has changed, and are
+// kept when the program restarts.  Once the cache is bigger than
+// MaxBytes the least recently used objects are removed.
+//
+// Writes made through the Cache remove the copies of the objects they
+// change before and after the write, or when the file is closed for
+// those returning one, so a read racing with the write can't keep a
+// copy of the old version.  All the other methods are passed on to
+// the Connection.
+//
+// If MemoryMaxBytes is set objects up to MemoryMaxObjectSize are kept
+// in memory as well, the least recently used being removed when they
+// total more than MemoryMaxBytes.  These are checked against the Etag
+// in the same way unless they were checked less than MemoryMaxAge
+// ago, in which case they are served without a request.  As changes
+// to the object made other than through the Cache won't be seen
+// until then, only set MemoryMaxAge if that is acceptable.
+type Cache struct {
+	Interface // the Connection being cached
+
+	dir      string
+	maxBytes int64
+	memory   *memoryCache // set if keeping objects in memory
+
+	mu      sync.Mutex
+	lru     *list.List               // of *cacheEntry, most recently used first
+	entries map[string]*list.Element // by key
+	names   map[string]string        // key of the last copy of each object by container/object
+	size    int64                    // total size of the entries
+}
+
+// cacheEntry is an object kept in the cache
+type cacheEntry struct {
+	key  string // file name of the copy
+	name string // container/object or "" if unknown
+	size int64
+}
+
+// cacheTempPrefix starts the names of files being downloaded
+const cacheTempPrefix = "tmp-"
+
+// NewCache returns a Cache of the objects downloaded with c.  Any
+// objects already in opts.Dir from a previous Cache are kept.  Other
+// files in opts.Dir are left alone and don't count towards MaxBytes.
+func NewCache(c Interface, opts *CacheOpts) (*Cache, error) {
+	cache := &Cache{
+		Interface: c,
+		dir:       opts.Dir,
+		maxBytes:  opts.MaxBytes,
+		memory:    newMemoryCache(opts),
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+		names:     make(map[string]string),
+	}
+	if cache.maxBytes <= 0 {
+		cache.maxBytes = DefaultCacheMaxBytes
+	}
+	if cache.dir == "" {
+		return cache, nil
+	}
+	if err := os.MkdirAll(cache.dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(cache.dir)
+	if err != nil {
+		return nil, err
+	}
+	// Add the oldest first so they are evicted first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for _, file := range files {
+		if file.Mode().IsRegular() && isCacheKey(file.Name()) {
+			cache.add(file.Name(), "", file.Size())
+		}
+	}
+	cache.evict()
+	return cache, nil
+}
+
+// cacheName returns the name used to find the copies of an object
+func cacheName(container string, objectName string) string {
+	return container + "/" + objectName
+}
+
+// cacheKey returns the file name of the copy of an object with etag
+func cacheKey(container string, objectName string, etag string) string {
+	sum := sha256.Sum256([]byte(cacheName(container, objectName) + "\x00" + strings.Trim(etag, `"`)))
+	return hex.EncodeToString(sum[:])
+}
+
+// isCacheKey returns whether name could have been made by cacheKey
+func isCacheKey(name string) bool {
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	for _, r := range name {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isCacheable returns whether a GET with h and options can use the
+// cache
+func isCacheable(h Headers, options []RequestOption) bool {
+	var p RequestOpts
+	p.apply(options)
+	return len(h) == 0 && len(p.Headers) == 0 && !p.Gzip && p.Encryption == nil
+}
+
+// add records the copy key of the object name, which may be "", of
+// size bytes as the most recently used.  Call with mu held.
+func (cache *Cache) add(key string, name string, size int64) {
+	if element, ok := cache.entries[key]; ok {
+		cache.lru.MoveToFront(element)
+		return
+	}
+	if name != "" {
+		// Remove the copy of the previous version
+		if oldKey, ok := cache.names[name]; ok {
+			cache.remove(oldKey)
+		}
+		cache.names[name] = key
+	}
+	cache.entries[key] = cache.lru.PushFront(&cacheEntry{key: key, name: name, size: size})
+	cache.size += size
+}
+
+// remove deletes the copy key.  Call with mu held.
+func (cache *Cache) remove(key string) {
+	element, ok := cache.entries[key]
+	if !ok {
+		return
+	}
+	entry := cache.lru.Remove(element).(*cacheEntry)
+	delete(cache.entries, key)
+	if entry.name != "" && cache.names[entry.name] == key {
+		delete(cache.names, entry.name)
+	}
+	cache.size -= entry.size
+	_ = os.Remove(filepath.Join(cache.dir, key))
+}
+
+// evict removes the least recently used copies until the cache is
+// small enough.  Call with mu held.
+func (cache *Cache) evict() {
+	for cache.size > cache.maxBytes && cache.lru.Len() > 0 {
+		cache.remove(cache.lru.Back().Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate removes the copies of an object which has been changed
+func (cache *Cache) invalidate(container string, objectName string) {
+	if cache.memory != nil {
+		cache.memory.invalidate(cacheName(container, objectName))
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if key, ok := cache.names[cacheName(container, objectName)]; ok {
+		cache.remove(key)
+	}
+}
+
+// open returns the copy of an object with etag or nil if there isn't
+// one
+func (cache *Cache) open(container string, objectName string, etag string) *os.File {
+	key := cacheKey(container, objectName, etag)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil
+	}
+	in, err := os.Open(filepath.Join(cache.dir, key))
+	if err != nil {
+		cache.remove(key)
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if entry.name == "" {
+		// Loaded from disk so learn its name
+		entry.name = cacheName(container, objectName)
+		cache.names[entry.name] = key
+	}
+	cache.lru.MoveToFront(element)
+	return in
+}
+
+// cacheWriter writes the copy of an object remembering the first
+// error rather than returning it so the download carries on
+type cacheWriter struct {
+	file *os.File
+	err  error
+}
+
+// Write to the file if there hasn't been an error - see io.Writer
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		_, w.err = w.file.Write(p)
+	}
+	return len(p), nil
+}
+
+// download gets an object into contents saving a copy
+func (cache *Cache) download(container string, objectName string, contents io.Writer, options []RequestOption) (headers Headers, err error) {
+	if cache.dir == "" {
+		return cache.Interface.ObjectGetWithOpts(container, objectName, contents, &ObjectGetOpts{CheckHash: true}, options...)
+	}
+	tmp, err := ioutil.TempFile(cache.dir, cacheTempPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if tmp != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	w := &cacheWriter{file: tmp}
+	headers, err = cache.Interface.ObjectGetWithOpts(container, objectName, io.MultiWriter(contents, w), &ObjectGetOpts{CheckHash: true}, options...)
+	if err != nil {
+		return headers, err
+	}
+	etag := headers["Etag"]
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if w.err != nil || err != nil || etag == "" || size > cache.maxBytes {
+		// Not worth failing the download for
+		return headers, nil
+	}
+	if err = tmp.Close(); err != nil {
+		return headers, nil
+	}
+	key := cacheKey(container, objectName, etag)
+	if err = os.Rename(tmp.Name(), filepath.Join(cache.dir, key)); err != nil {
+		return headers, nil
+	}
+	tmp = nil
+	cache.mu.Lock()
+	cache.add(key, cacheName(container, objectName), size)
+	cache.evict()
+	cache.mu.Unlock()
+	return headers, nil
+}
+
+// ObjectGet gets the object into contents, from the cache if it has
+// the current version - see Connection.ObjectGet
+func (cache *Cache) ObjectGet(container string, objectName string, contents io.Writer, checkHash bool, h Headers) (headers Headers, err error) {
+	return cache.objectGet(container, objectName, contents, checkHash, h, nil)
+}
+
+// objectGet does the work for ObjectGet and the other calls which
+// read whole objects
+func (cache *Cache) objectGet(container string, objectName string, contents io.Writer, checkHash bool, h Headers, options []RequestOption) (headers Headers, err error) {
+	if !isCacheable(h, options) {
+		return cache.Interface.ObjectGetWithOpts(container, objectName, contents, &ObjectGetOpts{CheckHash: checkHash, Headers: h}, options...)
+	}
+	name := cacheName(container, objectName)
+	if cache.memory != nil {
+		if data, headers, ok := cache.memory.fresh(name); ok {
+			_, err = contents.Write(data)
+			return headers, err
+		}
+	}
+	info, headers, err := cache.Interface.ObjectWithOptions(container, objectName, options...)
+	if err != nil {
+		if err == ObjectNotFound {
+			cache.invalidate(container, objectName)
+		}
+		return headers, err
+	}
+	if cache.memory != nil {
+		if data, ok := cache.memory.get(name, info.Hash, headers); ok {
+			_, err = contents.Write(data)
+			return headers, err
+		}
+	}
+	// Keep a copy of small objects in memory
+	var buf *bytes.Buffer
+	if cache.memory != nil && info.Bytes <= cache.memory.maxObjectSize {
+		buf = new(bytes.Buffer)
+		contents = io.MultiWriter(contents, buf)
+	}
+	if in := cache.open(container, objectName, info.Hash); in != nil {
+		defer func() { _ = in.Close() }()
+		if _, err = io.Copy(contents, in); err != nil {
+			return headers, err
+		}
+	} else if !checkHash {
+		return cache.Interface.ObjectGetWithOpts(container, objectName, contents, &ObjectGetOpts{Headers: h}, options...)
+	} else if headers, err = cache.download(container, objectName, contents, options); err != nil {
+		return headers, err
+	}
+	if buf != nil {
+		cache.memory.put(name, strings.Trim(headers["Etag"], `"`), headers, buf.Bytes())
+	}
+	return headers, nil
+}
+
+// ObjectGetBytes returns an object as a []byte, from the cache if it
+// has the current version - see Connection.ObjectGetBytes
+func (cache *Cache) ObjectGetBytes(container string, objectName string) (contents []byte, err error) {
+	return cache.ObjectGetBytesWithOptions(container, objectName)
+}
+
+// ObjectGetBytesWithOptions is ObjectGetBytes with options applied to
+// its requests
+func (cache *Cache) ObjectGetBytesWithOptions(container string, objectName string, options ...RequestOption) (contents []byte, err error) {
+	var buf bytes.Buffer
+	_, err = cache.objectGet(container, objectName, &buf, true, nil, options)
+	contents = buf.Bytes()
+	return
+}
+
+// ObjectGetString returns an object as a string, from the cache if it
+// has the current version - see Connection.ObjectGetString
+func (cache *Cache) ObjectGetString(container string, objectName string) (contents string, err error) {
+	return cache.ObjectGetStringWithOptions(container, objectName)
+}
+
+// ObjectGetStringWithOptions is ObjectGetString with options applied
+// to its requests
+func (cache *Cache) ObjectGetStringWithOptions(container string, objectName string, options ...RequestOption) (contents string, err error) {
+	var buf bytes.Buffer
+	_, err = cache.objectGet(container, objectName, &buf, true, nil, options)
+	contents = buf.String()
+	return
+}
+
+// Check it satisfies the interface
+var _ Interface = &Cache{}
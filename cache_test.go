@@ -0,0 +1,323 @@
+// Tests for the disk cache
+package swift
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestCache(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cache, err := NewCache(c, &CacheOpts{Dir: dir, MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cache.ObjectPutString("container", "a", "aaaaaa", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(cache *Cache, name string, want string) {
+		t.Helper()
+		got, err := cache.ObjectGetString("container", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q want %q", name, got, want)
+		}
+	}
+	get(cache, "a", "aaaaaa")
+	info, _, err := c.Object("container", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(dir, cacheKey("container", "a", info.Hash))
+	data, err := ioutil.ReadFile(copyPath)
+	if err != nil || string(data) != "aaaaaa" {
+		t.Fatalf("bad copy %q, %v", data, err)
+	}
+
+	// Repeat reads come from the copy - change it to tell
+	err = ioutil.WriteFile(copyPath, []byte("cached"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(cache, "a", "cached")
+
+	// Copies survive restarts
+	cache, err = NewCache(c, &CacheOpts{Dir: dir, MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(cache, "a", "cached")
+
+	// Changing the object behind the cache's back changes the Etag
+	err = c.ObjectPutString("container", "a", "AAAAAA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(cache, "a", "AAAAAA")
+	if _, err = os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Errorf("copy of old version not removed: %v", err)
+	}
+
+	// Going over MaxBytes evicts the least recently used
+	err = c.ObjectPutString("container", "b", "bbbbbb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(cache, "b", "bbbbbb")
+	info, _, err = c.Object("container", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != cacheKey("container", "b", info.Hash) {
+		t.Errorf("expecting only the copy of b, got %d files", len(files))
+	}
+
+	// Writes through the cache remove the copy
+	err = cache.ObjectDelete("container", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err = ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expecting no copies, got %d", len(files))
+	}
+	_, err = cache.ObjectGetString("container", "b")
+	if err != ObjectNotFound {
+		t.Errorf("deleted object got %v", err)
+	}
+}
+
+func TestCacheDirOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	key := cacheKey("container", "a", "etag")
+	names := []string{"notes.txt", "tmp-1234", key[1:] + "g", key + ".bak", key}
+	for _, name := range names {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte("0123456789"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache, err := NewCache(&Connection{}, &CacheOpts{Dir: dir, MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 1 || cache.entries[key] == nil || cache.size != 10 {
+		t.Errorf("expecting only %s adopted got %v", key, cache.entries)
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestCacheMemory(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{"a": "aaaa", "b": "bbbb", "big": "0123456789"} {
+		err = c.ObjectPutString("container", name, contents, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache, err := NewCache(c, &CacheOpts{
+		MemoryMaxBytes:      8,
+		MemoryMaxObjectSize: 5,
+		MemoryMaxAge:        time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(name string, want string) {
+		t.Helper()
+		got, err := cache.ObjectGetString("container", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q want %q", name, got, want)
+		}
+	}
+	get("a", "aaaa")
+	get("big", "0123456789")
+	if cache.memory.size != 4 || cache.memory.lru.Len() != 1 {
+		t.Errorf("expecting only a in memory, got %d objects, %d bytes", cache.memory.lru.Len(), cache.memory.size)
+	}
+
+	// Within MemoryMaxAge changes behind the cache's back aren't seen
+	err = c.ObjectPutString("container", "a", "AAAA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "aaaa")
+
+	// But writes through it are
+	err = cache.ObjectPutString("container", "a", "aAaA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "aAaA")
+
+	// Going over MemoryMaxBytes evicts the least recently used
+	get("b", "bbbb")
+	get("a", "aAaA")
+	err = c.ObjectPutString("container", "c", "cccc", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("c", "cccc")
+	if _, ok := cache.memory.entries["container/b"]; ok || cache.memory.size != 8 {
+		t.Errorf("expecting b evicted, got %d bytes", cache.memory.size)
+	}
+
+	// Without MemoryMaxAge the Etag is always checked
+	cache, err = NewCache(c, &CacheOpts{MemoryMaxBytes: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "aAaA")
+	err = c.ObjectPutString("container", "a", "AAAA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "AAAA")
+}
+
+// racingInterface reads an object through the Cache while it is
+// being written
+type racingInterface struct {
+	Interface
+	cache *Cache
+}
+
+func (r *racingInterface) ObjectPutStringWithOptions(container string, objectName string, contents string, contentType string, options ...RequestOption) error {
+	_, _ = r.cache.ObjectGetString(container, objectName)
+	return r.Interface.ObjectPutStringWithOptions(container, objectName, contents, contentType, options...)
+}
+
+func TestCacheWrites(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	racing := &racingInterface{Interface: c}
+	cache, err := NewCache(racing, &CacheOpts{
+		MemoryMaxBytes: 1024,
+		MemoryMaxAge:   time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	racing.cache = cache
+	get := func(name string, want string) {
+		t.Helper()
+		got, err := cache.ObjectGetString("container", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q want %q", name, got, want)
+		}
+	}
+	put := func(name string, contents string) {
+		t.Helper()
+		cache.invalidate("container", name)
+		err := c.ObjectPutString("container", name, contents, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		get(name, contents)
+	}
+
+	// A read during the write doesn't leave the old version behind
+	put("a", "old")
+	err = cache.ObjectPutString("container", "a", "new", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "new")
+
+	put("a", "old")
+	_, err = cache.ObjectPutWithOpts("container", "a", bytes.NewBufferString("put"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "put")
+
+	// Files are invalidated again when they are closed
+	put("a", "old")
+	file, err := cache.ObjectCreateWithOpts("container", "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get("a", "old")
+	_, _ = file.Write([]byte("created"))
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	get("a", "created")
+
+	put("a", "old")
+	err = cache.ObjectRename("container", "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.ObjectGetString("container", "a")
+	if err != ObjectNotFound {
+		t.Errorf("expecting ObjectNotFound got %v", err)
+	}
+	get("b", "old")
+
+	put("dir/a", "old")
+	_, err = cache.ObjectsDelete("container", "dir/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.ObjectGetString("container", "dir/a")
+	if err != ObjectNotFound {
+		t.Errorf("expecting ObjectNotFound got %v", err)
+	}
+	if cache.memory.lru.Len() != 1 {
+		t.Errorf("expecting only b in memory, got %d objects", cache.memory.lru.Len())
+	}
+}
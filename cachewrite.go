@@ -0,0 +1,451 @@
+// Removing the copies a Cache keeps of the objects written through it
+
+package swift
+
+import (
+	"io"
+	"strings"
+)
+
+// invalidatePrefix removes the copies of the objects whose
+// container/object names start with prefix
+func (cache *Cache) invalidatePrefix(prefix string) {
+	if cache.memory != nil {
+		cache.memory.invalidatePrefix(prefix)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for name, key := range cache.names {
+		if strings.HasPrefix(name, prefix) {
+			cache.remove(key)
+		}
+	}
+}
+
+// invalidating removes the copies of the objects in container
+// returning a function to remove them again once they have been
+// written, so a read made while the write is in progress can't leave
+// a copy of the old version behind, eg
+//
+//	defer cache.invalidating(container, objectName)()
+func (cache *Cache) invalidating(container string, objectNames ...string) func() {
+	invalidate := func() {
+		for _, objectName := range objectNames {
+			cache.invalidate(container, objectName)
+		}
+	}
+	invalidate()
+	return invalidate
+}
+
+// invalidatingPrefix is invalidating for the objects whose
+// container/object names start with prefix
+func (cache *Cache) invalidatingPrefix(prefix string) func() {
+	cache.invalidatePrefix(prefix)
+	return func() {
+		cache.invalidatePrefix(prefix)
+	}
+}
+
+// ObjectPut removes the copy of the object and uploads it - see
+// Connection.ObjectPut
+func (cache *Cache) ObjectPut(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers) (headers Headers, err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectPut(container, objectName, contents, checkHash, Hash, contentType, h)
+}
+
+// ObjectPutWithOpts removes the copy of the object and uploads it -
+// see Connection.ObjectPutWithOpts
+func (cache *Cache) ObjectPutWithOpts(container string, objectName string, contents io.Reader, opts *ObjectPutOpts, options ...RequestOption) (headers Headers, err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectPutWithOpts(container, objectName, contents, opts, options...)
+}
+
+// ObjectPutIfNotExists removes the copy of the object and uploads it
+// - see Connection.ObjectPutIfNotExists
+func (cache *Cache) ObjectPutIfNotExists(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectPutIfNotExists(container, objectName, contents, checkHash, Hash, contentType, h, options...)
+}
+
+// ObjectPutBytes removes the copy of the object and uploads it - see
+// Connection.ObjectPutBytes
+func (cache *Cache) ObjectPutBytes(container string, objectName string, contents []byte, contentType string) (err error) {
+	return cache.ObjectPutBytesWithOptions(container, objectName, contents, contentType)
+}
+
+// ObjectPutBytesWithOptions is ObjectPutBytes with options applied to
+// its requests
+func (cache *Cache) ObjectPutBytesWithOptions(container string, objectName string, contents []byte, contentType string, options ...RequestOption) (err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectPutBytesWithOptions(container, objectName, contents, contentType, options...)
+}
+
+// ObjectPutString removes the copy of the object and uploads it - see
+// Connection.ObjectPutString
+func (cache *Cache) ObjectPutString(container string, objectName string, contents string, contentType string) (err error) {
+	return cache.ObjectPutStringWithOptions(container, objectName, contents, contentType)
+}
+
+// ObjectPutStringWithOptions is ObjectPutString with options applied
+// to its requests
+func (cache *Cache) ObjectPutStringWithOptions(container string, objectName string, contents string, contentType string, options ...RequestOption) (err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectPutStringWithOptions(container, objectName, contents, contentType, options...)
+}
+
+// ObjectPutRouted removes the copy of the object the router chooses
+// and uploads it - see Connection.ObjectPutRouted
+func (cache *Cache) ObjectPutRouted(router *Router, obj RouteObject, contents io.Reader, h Headers) (container string, objectName string, headers Headers, err error) {
+	container, objectName, err = router.Route(obj)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectPutRouted(router, obj, contents, h)
+}
+
+// createFile arranges for the copy of the object being uploaded by
+// file to be removed again when it is closed
+func (cache *Cache) createFile(container string, objectName string, file *ObjectCreateFile, err error) (*ObjectCreateFile, error) {
+	if err != nil {
+		cache.invalidate(container, objectName)
+		return file, err
+	}
+	closed := file.closed
+	file.closed = func() {
+		if closed != nil {
+			closed()
+		}
+		cache.invalidate(container, objectName)
+	}
+	return file, nil
+}
+
+// ObjectCreate removes the copy of the object and starts uploading it
+// - see Connection.ObjectCreate
+func (cache *Cache) ObjectCreate(container string, objectName string, checkHash bool, Hash string, contentType string, h Headers) (file *ObjectCreateFile, err error) {
+	cache.invalidate(container, objectName)
+	file, err = cache.Interface.ObjectCreate(container, objectName, checkHash, Hash, contentType, h)
+	return cache.createFile(container, objectName, file, err)
+}
+
+// ObjectCreateWithOpts removes the copy of the object and starts
+// uploading it - see Connection.ObjectCreateWithOpts
+func (cache *Cache) ObjectCreateWithOpts(container string, objectName string, opts *ObjectPutOpts, options ...RequestOption) (file *ObjectCreateFile, err error) {
+	cache.invalidate(container, objectName)
+	file, err = cache.Interface.ObjectCreateWithOpts(container, objectName, opts, options...)
+	return cache.createFile(container, objectName, file, err)
+}
+
+// ObjectSymlinkCreate removes the copy of the symlink and creates it
+// - see Connection.ObjectSymlinkCreate
+func (cache *Cache) ObjectSymlinkCreate(container string, symlink string, targetAccount string, targetContainer string, targetObject string, targetEtag string) (headers Headers, err error) {
+	defer cache.invalidating(container, symlink)()
+	return cache.Interface.ObjectSymlinkCreate(container, symlink, targetAccount, targetContainer, targetObject, targetEtag)
+}
+
+// MkDir removes the copy of the directory marker and creates it - see
+// Connection.MkDir
+func (cache *Cache) MkDir(container string, dir string) error {
+	defer cache.invalidating(container, strings.Trim(dir, "/"))()
+	return cache.Interface.MkDir(container, dir)
+}
+
+// ObjectDelete removes the copy of the object and deletes it - see
+// Connection.ObjectDelete
+func (cache *Cache) ObjectDelete(container string, objectName string) error {
+	return cache.ObjectDeleteWithOptions(container, objectName)
+}
+
+// ObjectDeleteWithOptions is ObjectDelete with options applied to its
+// requests
+func (cache *Cache) ObjectDeleteWithOptions(container string, objectName string, options ...RequestOption) error {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectDeleteWithOptions(container, objectName, options...)
+}
+
+// RmDir removes the copy of the directory marker and deletes it - see
+// Connection.RmDir
+func (cache *Cache) RmDir(container string, dir string) error {
+	defer cache.invalidating(container, strings.Trim(dir, "/"))()
+	return cache.Interface.RmDir(container, dir)
+}
+
+// BulkDelete removes the copies of the objects and deletes them - see
+// Connection.BulkDelete
+func (cache *Cache) BulkDelete(container string, objectNames []string) (result BulkDeleteResult, err error) {
+	defer cache.invalidating(container, objectNames...)()
+	return cache.Interface.BulkDelete(container, objectNames)
+}
+
+// BulkDeleteHeaders removes the copies of the objects and deletes
+// them - see Connection.BulkDeleteHeaders
+func (cache *Cache) BulkDeleteHeaders(container string, objectNames []string, h Headers) (result BulkDeleteResult, err error) {
+	defer cache.invalidating(container, objectNames...)()
+	return cache.Interface.BulkDeleteHeaders(container, objectNames, h)
+}
+
+// BulkUpload removes the copies of the objects under uploadPath and
+// uploads the archive - see Connection.BulkUpload
+func (cache *Cache) BulkUpload(uploadPath string, dataStream io.Reader, format string, h Headers) (result BulkUploadResult, err error) {
+	prefix := uploadPath
+	if prefix != "" && !strings.Contains(prefix, "/") {
+		prefix += "/"
+	}
+	defer cache.invalidatingPrefix(prefix)()
+	return cache.Interface.BulkUpload(uploadPath, dataStream, format, h)
+}
+
+// ObjectsDelete removes the copies of the objects starting with
+// prefix and deletes them - see Connection.ObjectsDelete
+func (cache *Cache) ObjectsDelete(container string, prefix string, opts *ObjectsDeleteOpts) (result BulkDeleteResult, err error) {
+	defer cache.invalidatingPrefix(cacheName(container, prefix))()
+	return cache.Interface.ObjectsDelete(container, prefix, opts)
+}
+
+// ExpiredObjectsDelete removes the copies of the objects it looks at
+// and deletes the expired ones - see Connection.ExpiredObjectsDelete
+func (cache *Cache) ExpiredObjectsDelete(container string, opts *ExpiredObjectsOpts) (result ExpiredObjectsResult, err error) {
+	prefix := ""
+	if opts != nil {
+		prefix = opts.Prefix
+	}
+	defer cache.invalidatingPrefix(cacheName(container, prefix))()
+	return cache.Interface.ExpiredObjectsDelete(container, opts)
+}
+
+// ObjectUpdate removes the copy of the object and updates its
+// metadata - see Connection.ObjectUpdate
+func (cache *Cache) ObjectUpdate(container string, objectName string, h Headers) error {
+	return cache.ObjectUpdateWithOptions(container, objectName, h)
+}
+
+// ObjectUpdateWithOptions is ObjectUpdate with options applied to its
+// requests
+func (cache *Cache) ObjectUpdateWithOptions(container string, objectName string, h Headers, options ...RequestOption) error {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectUpdateWithOptions(container, objectName, h, options...)
+}
+
+// ObjectUpdateContentType removes the copy of the object and updates
+// its content type - see Connection.ObjectUpdateContentType
+func (cache *Cache) ObjectUpdateContentType(container string, objectName string, contentType string) (err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectUpdateContentType(container, objectName, contentType)
+}
+
+// ObjectRemoveMetadata removes the copy of the object and removes the
+// metadata keys from it - see Connection.ObjectRemoveMetadata
+func (cache *Cache) ObjectRemoveMetadata(container string, objectName string, keys ...string) error {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectRemoveMetadata(container, objectName, keys...)
+}
+
+// ObjectRewriteHeaders removes the copy of the object and rewrites its
+// headers - see Connection.ObjectRewriteHeaders
+func (cache *Cache) ObjectRewriteHeaders(container string, objectName string, h Headers, freshMetadata bool) (err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectRewriteHeaders(container, objectName, h, freshMetadata)
+}
+
+// ObjectCopy removes the copy of the destination object and copies
+// to it - see Connection.ObjectCopy
+func (cache *Cache) ObjectCopy(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
+	return cache.ObjectCopyWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName, h)
+}
+
+// ObjectCopyWithOptions is ObjectCopy with options applied to its
+// requests
+func (cache *Cache) ObjectCopyWithOptions(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	defer cache.invalidating(dstContainer, dstObjectName)()
+	return cache.Interface.ObjectCopyWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName, h, options...)
+}
+
+// ObjectCopyAccount removes the copy of the destination object and
+// copies to it - see Connection.ObjectCopyAccount
+func (cache *Cache) ObjectCopyAccount(srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	defer cache.invalidating(dstContainer, dstObjectName)()
+	return cache.Interface.ObjectCopyAccount(srcContainer, srcObjectName, dstAccount, dstContainer, dstObjectName, h, options...)
+}
+
+// ObjectMove removes the copies of the objects and moves the source
+// to the destination - see Connection.ObjectMove
+func (cache *Cache) ObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) (err error) {
+	return cache.ObjectMoveWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName)
+}
+
+// ObjectMoveWithOptions is ObjectMove with options applied to its
+// requests
+func (cache *Cache) ObjectMoveWithOptions(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, options ...RequestOption) (err error) {
+	defer cache.invalidating(srcContainer, srcObjectName)()
+	defer cache.invalidating(dstContainer, dstObjectName)()
+	return cache.Interface.ObjectMoveWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName, options...)
+}
+
+// ObjectRename removes the copies of the objects and renames the
+// source to the destination - see Connection.ObjectRename
+func (cache *Cache) ObjectRename(container string, srcObjectName string, dstObjectName string) error {
+	defer cache.invalidating(container, srcObjectName, dstObjectName)()
+	return cache.Interface.ObjectRename(container, srcObjectName, dstObjectName)
+}
+
+// VersionObjectRestore removes the copy of the object and restores
+// the old version over it - see Connection.VersionObjectRestore
+func (cache *Cache) VersionObjectRestore(current, version, versionName string) error {
+	if object, err := VersionObjectName(versionName); err == nil {
+		defer cache.invalidating(current, object)()
+	}
+	return cache.Interface.VersionObjectRestore(current, version, versionName)
+}
+
+// ObjectRestoreVersion removes the copy of the object and restores
+// the old version over it - see Connection.ObjectRestoreVersion
+func (cache *Cache) ObjectRestoreVersion(container string, objectName string, versionId string) error {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.ObjectRestoreVersion(container, objectName, versionId)
+}
+
+// ObjectsRewrite removes the copies of the objects starting with
+// opts.Prefix and rewrites them - see Connection.ObjectsRewrite
+func (cache *Cache) ObjectsRewrite(container string, opts *RewriteOpts, fn RewriteFn) error {
+	defer cache.invalidatingPrefix(cacheName(container, rewritePrefix(opts)))()
+	return cache.Interface.ObjectsRewrite(container, opts, fn)
+}
+
+// ReEncrypt removes the copies of the objects starting with
+// opts.Prefix and encrypts them again - see Connection.ReEncrypt
+func (cache *Cache) ReEncrypt(container string, e *Encryption, opts *RewriteOpts) error {
+	defer cache.invalidatingPrefix(cacheName(container, rewritePrefix(opts)))()
+	return cache.Interface.ReEncrypt(container, e, opts)
+}
+
+// rewritePrefix returns the prefix of the objects rewritten with
+// opts which may be nil
+func rewritePrefix(opts *RewriteOpts) string {
+	if opts == nil {
+		return ""
+	}
+	return opts.Prefix
+}
+
+// StaticLargeObjectManifestPut removes the copy of the object and
+// uploads its manifest - see Connection.StaticLargeObjectManifestPut
+func (cache *Cache) StaticLargeObjectManifestPut(container string, objectName string, contentType string, segments []SLOSegment, h Headers) error {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.StaticLargeObjectManifestPut(container, objectName, contentType, segments, h)
+}
+
+// LargeObjectUpdate removes the copy of the object and updates its
+// metadata - see Connection.LargeObjectUpdate
+func (cache *Cache) LargeObjectUpdate(container string, objectName string, h Headers, segmentHeaders []string) (ignored []string, err error) {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.LargeObjectUpdate(container, objectName, h, segmentHeaders)
+}
+
+// cacheLargeObjectFile removes the copy of a large object when its
+// manifest is written
+type cacheLargeObjectFile struct {
+	LargeObjectFile
+	invalidate func()
+}
+
+// Flush writes the manifest - see LargeObjectFile
+func (file *cacheLargeObjectFile) Flush() error {
+	defer file.invalidate()
+	return file.LargeObjectFile.Flush()
+}
+
+// Close writes the manifest - see LargeObjectFile
+func (file *cacheLargeObjectFile) Close() error {
+	defer file.invalidate()
+	return file.LargeObjectFile.Close()
+}
+
+// largeObjectFile removes the copy of the large object described by
+// opts and wraps file to remove it again when it is written
+func (cache *Cache) largeObjectFile(opts *LargeObjectOpts, create func(*LargeObjectOpts) (LargeObjectFile, error)) (LargeObjectFile, error) {
+	invalidate := cache.invalidating(opts.Container, opts.ObjectName)
+	file, err := create(opts)
+	if err != nil {
+		invalidate()
+		return nil, err
+	}
+	return &cacheLargeObjectFile{LargeObjectFile: file, invalidate: invalidate}, nil
+}
+
+// LargeObjectCreateFile removes the copy of the object and starts
+// uploading it - see Connection.LargeObjectCreateFile
+func (cache *Cache) LargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	return cache.largeObjectFile(opts, cache.Interface.LargeObjectCreateFile)
+}
+
+// LargeObjectCreate removes the copy of the object and starts
+// uploading it - see Connection.LargeObjectCreate
+func (cache *Cache) LargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	return cache.largeObjectFile(opts, cache.Interface.LargeObjectCreate)
+}
+
+// StaticLargeObjectCreateFile removes the copy of the object and
+// starts uploading it - see Connection.StaticLargeObjectCreateFile
+func (cache *Cache) StaticLargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	return cache.largeObjectFile(opts, cache.Interface.StaticLargeObjectCreateFile)
+}
+
+// StaticLargeObjectCreate removes the copy of the object and starts
+// uploading it - see Connection.StaticLargeObjectCreate
+func (cache *Cache) StaticLargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	return cache.largeObjectFile(opts, cache.Interface.StaticLargeObjectCreate)
+}
+
+// DynamicLargeObjectCreateFile removes the copy of the object and
+// starts uploading it - see Connection.DynamicLargeObjectCreateFile
+func (cache *Cache) DynamicLargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	return cache.largeObjectFile(opts, cache.Interface.DynamicLargeObjectCreateFile)
+}
+
+// DynamicLargeObjectCreate removes the copy of the object and starts
+// uploading it - see Connection.DynamicLargeObjectCreate
+func (cache *Cache) DynamicLargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	return cache.largeObjectFile(opts, cache.Interface.DynamicLargeObjectCreate)
+}
+
+// LargeObjectDelete removes the copy of the object and deletes it and
+// its segments - see Connection.LargeObjectDelete
+func (cache *Cache) LargeObjectDelete(container string, objectName string) error {
+	defer cache.invalidating(container, objectName)()
+	return cache.Interface.LargeObjectDelete(container, objectName)
+}
+
+// StaticLargeObjectDelete removes the copy of the object and deletes
+// it and its segments - see Connection.StaticLargeObjectDelete
+func (cache *Cache) StaticLargeObjectDelete(container string, path string) error {
+	defer cache.invalidating(container, path)()
+	return cache.Interface.StaticLargeObjectDelete(container, path)
+}
+
+// DynamicLargeObjectDelete removes the copy of the object and deletes
+// it and its segments - see Connection.DynamicLargeObjectDelete
+func (cache *Cache) DynamicLargeObjectDelete(container string, path string) error {
+	defer cache.invalidating(container, path)()
+	return cache.Interface.DynamicLargeObjectDelete(container, path)
+}
+
+// StaticLargeObjectMove removes the copies of the objects and moves
+// the source to the destination - see Connection.StaticLargeObjectMove
+func (cache *Cache) StaticLargeObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) error {
+	defer cache.invalidating(srcContainer, srcObjectName)()
+	defer cache.invalidating(dstContainer, dstObjectName)()
+	return cache.Interface.StaticLargeObjectMove(srcContainer, srcObjectName, dstContainer, dstObjectName)
+}
+
+// DynamicLargeObjectMove removes the copies of the objects and moves
+// the source to the destination - see Connection.DynamicLargeObjectMove
+func (cache *Cache) DynamicLargeObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) error {
+	defer cache.invalidating(srcContainer, srcObjectName)()
+	defer cache.invalidating(dstContainer, dstObjectName)()
+	return cache.Interface.DynamicLargeObjectMove(srcContainer, srcObjectName, dstContainer, dstObjectName)
+}
+
+// Check it satisfies the interface
+var _ LargeObjectFile = &cacheLargeObjectFile{}
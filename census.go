@@ -0,0 +1,189 @@
+// Fast counting of all the objects in an account
+
+package swift
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultCensusWorkers is the number of listings AccountCensus runs
+// at once if CensusOpts.Workers isn't set
+const DefaultCensusWorkers = 16
+
+// DefaultCensusBoundaries are the object names AccountCensus splits
+// the listings of big containers at if CensusOpts.Boundaries isn't
+// set.  They divide up names made of digits and ASCII letters.
+var DefaultCensusBoundaries = []string{"0", "5", "A", "H", "O", "V", "a", "h", "o", "v"}
+
+// CensusOpts is options for AccountCensus
+type CensusOpts struct {
+	Workers      int      // Number of listings to run at once - 0 for DefaultCensusWorkers
+	ShardObjects int64    // Split the listings of containers with more objects than this - 0 for one page of listing
+	Boundaries   []string // Object names to split the listings of big containers at - nil for DefaultCensusBoundaries
+}
+
+// ContainerCensus holds the totals for one container
+type ContainerCensus struct {
+	Name    string `json:"name"`    // Name of the container
+	Objects int64  `json:"objects"` // Number of objects counted
+	Bytes   int64  `json:"bytes"`   // Number of bytes used by the objects counted
+}
+
+// Census holds the totals for an account as returned by
+// AccountCensus
+type Census struct {
+	Containers []ContainerCensus `json:"containers"` // Totals for each container sorted by name
+	Objects    int64             `json:"objects"`    // Number of objects in the account
+	Bytes      int64             `json:"bytes"`      // Number of bytes used by the account
+}
+
+// censusShard is a range of object names in a container to be
+// listed.  Names from the marker up to but not including the end
+// marker are counted.  The marker itself is included.
+type censusShard struct {
+	index     int    // index of the container
+	container string // name of the container
+	marker    string
+	endMarker string
+}
+
+// censusResult is the totals for a censusShard
+type censusResult struct {
+	index   int
+	objects int64
+	bytes   int64
+	err     error
+}
+
+// AccountCensus counts the objects and bytes in every container of
+// the account by listing them.
+//
+// Unlike the totals returned by Account and Containers, which are
+// updated asynchronously by the server, this counts what is actually
+// in the listings.  The listings are done Workers at a time and
+// containers with more than ShardObjects objects are split into
+// shards at the Boundaries which are listed in parallel, so this is
+// much faster than listing each container in turn for accounts with
+// very many objects.
+//
+// opts may be nil for the defaults.
+func (c *Connection) AccountCensus(opts *CensusOpts) (*Census, error) {
+	workers := DefaultCensusWorkers
+	shardObjects := int64(allObjectsLimit)
+	boundaries := DefaultCensusBoundaries
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		if opts.ShardObjects > 0 {
+			shardObjects = opts.ShardObjects
+		}
+		if opts.Boundaries != nil {
+			boundaries = append([]string(nil), opts.Boundaries...)
+			sort.Strings(boundaries)
+		}
+	}
+	containers, err := c.ContainersAll(nil)
+	if err != nil {
+		return nil, err
+	}
+	census := &Census{
+		Containers: make([]ContainerCensus, len(containers)),
+	}
+	var shards []censusShard
+	for i, container := range containers {
+		census.Containers[i].Name = container.Name
+		if container.Count <= shardObjects {
+			shards = append(shards, censusShard{index: i, container: container.Name})
+			continue
+		}
+		marker := ""
+		for _, boundary := range boundaries {
+			if boundary == marker {
+				continue
+			}
+			shards = append(shards, censusShard{index: i, container: container.Name, marker: marker, endMarker: boundary})
+			marker = boundary
+		}
+		shards = append(shards, censusShard{index: i, container: container.Name, marker: marker})
+	}
+
+	in := make(chan censusShard)
+	out := make(chan censusResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range in {
+				out <- c.censusShard(shard)
+			}
+		}()
+	}
+	go func() {
+		defer close(in)
+		for _, shard := range shards {
+			in <- shard
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	for result := range out {
+		if result.err != nil {
+			if err == nil {
+				err = result.err
+			}
+			continue
+		}
+		census.Containers[result.index].Objects += result.objects
+		census.Containers[result.index].Bytes += result.bytes
+		census.Objects += result.objects
+		census.Bytes += result.bytes
+	}
+	if err != nil {
+		return nil, err
+	}
+	return census, nil
+}
+
+// censusShard counts the objects in one shard
+func (c *Connection) censusShard(shard censusShard) (result censusResult) {
+	result.index = shard.index
+	count := func(objects []Object) {
+		for _, object := range objects {
+			result.objects++
+			result.bytes += object.Bytes
+		}
+	}
+	// The marker is exclusive so look for an object named exactly
+	// the same as it separately
+	if shard.marker != "" {
+		objects, err := c.Objects(shard.container, &ObjectsOpts{Prefix: shard.marker, Limit: 1})
+		if err != nil {
+			result.err = err
+			return
+		}
+		if len(objects) > 0 && objects[0].Name == shard.marker {
+			count(objects)
+		}
+	}
+	opts := &ObjectsOpts{
+		Limit:      allObjectsLimit,
+		Marker:     shard.marker,
+		EndMarker:  shard.endMarker,
+		KeepMarker: true,
+	}
+	result.err = c.ObjectsWalk(shard.container, opts, func(opts *ObjectsOpts) (interface{}, error) {
+		objects, err := c.Objects(shard.container, opts)
+		if err != nil {
+			return nil, err
+		}
+		count(objects)
+		return objects, nil
+	})
+	return result
+}
@@ -0,0 +1,144 @@
+// Selectable checksums for checking the integrity of object data
+
+package swift
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Checksum selects which hashes are used to check the integrity of
+// object data when checkHash is set on uploads and downloads.
+type Checksum int
+
+// Values that Checksum can take
+const (
+	// Check the MD5 against the Etag returned by the server (default)
+	ChecksumMD5 Checksum = iota
+	// Check the MD5 and additionally store a SHA-256 in the object
+	// metadata on upload and check it on download
+	ChecksumMD5SHA256
+	// Don't use MD5 at all, only store and check the SHA-256 in the
+	// object metadata - for FIPS environments where MD5 is disallowed
+	ChecksumSHA256
+)
+
+// SHA256Header is the header the SHA-256 of the object is stored in
+// when using ChecksumMD5SHA256 or ChecksumSHA256
+const SHA256Header = "X-Object-Meta-Sha256"
+
+// checksummer accumulates the hashes selected by a Checksum as data
+// is written to it
+type checksummer struct {
+	md5    hash.Hash // nil if not in use
+	sha256 hash.Hash // nil if not in use
+}
+
+// newChecksummer makes a new checksummer for the hashes selected by cs
+func newChecksummer(cs Checksum) *checksummer {
+	h := &checksummer{}
+	if cs != ChecksumSHA256 {
+		h.md5 = md5.New()
+	}
+	if cs != ChecksumMD5 {
+		h.sha256 = sha256.New()
+	}
+	return h
+}
+
+// Write adds p to the hashes in use - see io.Writer
+func (h *checksummer) Write(p []byte) (int, error) {
+	if h.md5 != nil {
+		_, _ = h.md5.Write(p)
+	}
+	if h.sha256 != nil {
+		_, _ = h.sha256.Write(p)
+	}
+	return len(p), nil
+}
+
+// sha256Sum returns the hex encoded SHA-256 or "" if not in use
+func (h *checksummer) sha256Sum() string {
+	if h.sha256 == nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", h.sha256.Sum(nil))
+}
+
+// etagMatches returns whether the Etag received from the server
+// matches the calculated hash.
+//
+// Any quotes around the Etag, as Swift sends for static large objects
+// and some servers send for everything, are ignored, and so is its
+// case as calculated is always lower case hex.
+func etagMatches(received string, calculated string) bool {
+	return strings.ToLower(strings.Trim(received, `"`)) == calculated
+}
+
+// check compares the hashes against those in headers returning
+// ObjectCorrupted if they don't match.
+//
+// The SHA-256 is only checked if it is present in the headers.
+func (h *checksummer) check(headers Headers) error {
+	if h.md5 != nil && !headers.IsLargeObject() {
+		calculatedMd5 := fmt.Sprintf("%x", h.md5.Sum(nil))
+		if !etagMatches(headers["Etag"], calculatedMd5) {
+			return ObjectCorrupted
+		}
+	}
+	if received := headers[SHA256Header]; h.sha256 != nil && received != "" {
+		if !strings.EqualFold(received, h.sha256Sum()) {
+			return ObjectCorrupted
+		}
+	}
+	return nil
+}
+
+// Check it satisfies the interface
+var _ io.Writer = &checksummer{}
+
+// seekableSHA256 calculates the SHA-256 of the remaining data in in
+// if it is an io.ReadSeeker, restoring the position afterwards.
+//
+// ok is false if in isn't seekable.
+func seekableSHA256(in io.Reader) (sum string, ok bool, err error) {
+	rs, ok := in.(io.ReadSeeker)
+	if !ok {
+		return "", false, nil
+	}
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", false, nil
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, rs); err != nil {
+		return "", true, err
+	}
+	if _, err = rs.Seek(pos, io.SeekStart); err != nil {
+		return "", true, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), true, nil
+}
+
+// objectSetMetadata adds the headers in extra, eg the SHA-256, to an
+// object which has just been uploaded with the headers h.
+//
+// This is used for metadata which couldn't be calculated before the
+// upload.  As updating the object replaces all its metadata the
+// metadata from h is sent again.
+func (c *Connection) objectSetMetadata(container string, objectName string, h Headers, extra Headers, options ...RequestOption) error {
+	headers := h.ObjectMetadata().ObjectHeaders()
+	for _, key := range []string{"Content-Encoding", "Content-Disposition", "X-Delete-At", "X-Delete-After"} {
+		if value, ok := h[key]; ok {
+			headers[key] = value
+		}
+	}
+	for key, value := range extra {
+		headers[key] = value
+	}
+	return c.ObjectUpdateWithOptions(container, objectName, headers, options...)
+}
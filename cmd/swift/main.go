@@ -0,0 +1,301 @@
+// Command swift is a simple command line client for OpenStack Swift
+// built on github.com/ncw/swift.
+//
+// It reads its credentials from the environment variables described
+// in Connection.ApplyEnvironment, eg
+//
+//	export ST_AUTH=https://auth.example.com/v1.0
+//	export ST_USER=user
+//	export ST_KEY=key
+//
+// Usage:
+//
+//	swift ls [-l] [container[/prefix]]
+//	swift stat [container[/object]]
+//	swift get container/object [file]
+//	swift put file container[/object]
+//	swift rm container[/object]
+//	swift mkdir container
+//	swift copy container/object container/object
+//	swift tempurl [-method GET] [-expires 1h] [-key key] container/object
+//
+// A file of "-" means stdin or stdout.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ncw/swift"
+)
+
+// usage is printed for bad command lines
+const usage = `Usage: swift <command> [arguments]
+
+Commands:
+  ls [-l] [container[/prefix]]    list containers or objects
+  stat [container[/object]]       show the account, a container or an object
+  get container/object [file]     download an object, to stdout by default
+  put file container[/object]     upload a file, from stdin if file is "-"
+  rm container[/object]           delete an object or an empty container
+  mkdir container                 create a container
+  copy src/object dst/object      copy an object server side
+  tempurl [-method GET] [-expires 1h] [-key key] container/object
+                                  make a temporary URL for an object
+
+Credentials are read from the environment, eg ST_AUTH, ST_USER and ST_KEY
+or the OS_* variables.
+`
+
+// errUsage is returned for bad command lines
+var errUsage = errors.New("bad arguments")
+
+func main() {
+	c := &swift.Connection{}
+	err := c.ApplyEnvironment()
+	if err == nil {
+		err = run(c, os.Args[1:], os.Stdin, os.Stdout)
+	}
+	if err == errUsage {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "swift: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command in args
+func run(c swift.Interface, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "ls":
+		return ls(c, args, stdout)
+	case "stat":
+		return stat(c, args, stdout)
+	case "get":
+		return get(c, args, stdout)
+	case "put":
+		return put(c, args, stdin)
+	case "rm":
+		return rm(c, args)
+	case "mkdir":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return c.ContainerCreate(args[0], nil)
+	case "copy":
+		return copyObject(c, args)
+	case "tempurl":
+		return tempURL(c, args, stdout)
+	}
+	return errUsage
+}
+
+// splitPath splits "container/object" into its parts, object being
+// "" if not present
+func splitPath(p string) (container string, object string) {
+	i := strings.Index(p, "/")
+	if i < 0 {
+		return p, ""
+	}
+	return p[:i], p[i+1:]
+}
+
+// objectPath splits p which must name an object
+func objectPath(p string) (container string, object string, err error) {
+	container, object = splitPath(p)
+	if container == "" || object == "" {
+		return "", "", fmt.Errorf("%q isn't container/object", p)
+	}
+	return container, object, nil
+}
+
+func ls(c swift.Interface, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	long := flags.Bool("l", false, "show sizes and modification times")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+	w := tabwriter.NewWriter(stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
+	if flags.NArg() == 0 {
+		containers, err := c.ContainersAll(nil)
+		if err != nil {
+			return err
+		}
+		for _, container := range containers {
+			if *long {
+				fmt.Fprintf(w, "%d\t%d\t %s\n", container.Count, container.Bytes, container.Name)
+			} else {
+				fmt.Fprintln(w, container.Name)
+			}
+		}
+		return w.Flush()
+	}
+	container, prefix := splitPath(flags.Arg(0))
+	objects, err := c.ObjectsAll(container, &swift.ObjectsOpts{Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if *long {
+			fmt.Fprintf(w, "%d\t %s\t %s\n", object.Bytes, object.LastModified.Format("2006-01-02 15:04:05"), object.Name)
+		} else {
+			fmt.Fprintln(w, object.Name)
+		}
+	}
+	return w.Flush()
+}
+
+func stat(c swift.Interface, args []string, stdout io.Writer) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	var headers swift.Headers
+	var err error
+	container, object := "", ""
+	if len(args) == 1 {
+		container, object = splitPath(args[0])
+	}
+	switch {
+	case container == "":
+		_, headers, err = c.Account()
+	case object == "":
+		_, headers, err = c.Container(container)
+	default:
+		_, headers, err = c.Object(container, object)
+	}
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(stdout, "%s: %s\n", key, headers[key])
+	}
+	return nil
+}
+
+func get(c swift.Interface, args []string, stdout io.Writer) (err error) {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	container, object, err := objectPath(args[0])
+	if err != nil {
+		return err
+	}
+	out := stdout
+	if len(args) == 2 && args[1] != "-" {
+		f, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		out = f
+	}
+	_, err = c.ObjectGet(container, object, out, true, nil)
+	return err
+}
+
+func put(c swift.Interface, args []string, stdin io.Reader) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	file := args[0]
+	container, object := splitPath(args[1])
+	if object == "" || strings.HasSuffix(object, "/") {
+		if file == "-" {
+			return fmt.Errorf("need an object name to upload stdin")
+		}
+		object += path.Base(file)
+	}
+	in := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	_, err := c.ObjectPut(container, object, in, true, "", "", nil)
+	return err
+}
+
+func rm(c swift.Interface, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	container, object := splitPath(args[0])
+	if object == "" {
+		return c.ContainerDelete(container)
+	}
+	return c.ObjectDelete(container, object)
+}
+
+func copyObject(c swift.Interface, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	srcContainer, srcObject, err := objectPath(args[0])
+	if err != nil {
+		return err
+	}
+	dstContainer, dstObject := splitPath(args[1])
+	if dstObject == "" {
+		dstObject = srcObject
+	}
+	_, err = c.ObjectCopy(srcContainer, srcObject, dstContainer, dstObject, nil)
+	return err
+}
+
+func tempURL(c swift.Interface, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("tempurl", flag.ContinueOnError)
+	method := flags.String("method", "GET", "HTTP method the URL is for")
+	expires := flags.Duration("expires", time.Hour, "how long the URL is valid for")
+	key := flags.String("key", "", "temp URL key - read from the account if not set")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	container, object, err := objectPath(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *key == "" {
+		keys, err := c.AccountTempUrlKeys()
+		if err != nil {
+			return err
+		}
+		if keys.Key == "" {
+			return fmt.Errorf("no temp URL key set on the account - use -key")
+		}
+		*key = keys.Key
+	}
+	// The URL is made from the storage URL so make sure it is known
+	if !c.Authenticated() {
+		if err := c.Authenticate(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(stdout, c.ObjectTempUrl(container, object, *key, *method, time.Now().Add(*expires)))
+	return nil
+}
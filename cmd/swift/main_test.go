@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift"
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestCommands(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &swift.Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	dir := t.TempDir()
+	local := filepath.Join(dir, "file.txt")
+	if err := ioutil.WriteFile(local, []byte("hello"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	swiftCmd := func(stdin string, args ...string) string {
+		var stdout bytes.Buffer
+		err := run(c, args, strings.NewReader(stdin), &stdout)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return stdout.String()
+	}
+
+	swiftCmd("", "mkdir", "container")
+	swiftCmd("", "put", local, "container")
+	swiftCmd("from stdin", "put", "-", "container/dir/stdin")
+	swiftCmd("", "copy", "container/file.txt", "container/copy.txt")
+	if got := swiftCmd("", "ls"); got != "container\n" {
+		t.Errorf("Bad container listing %q", got)
+	}
+	if got := swiftCmd("", "ls", "container"); got != "copy.txt\ndir/stdin\nfile.txt\n" {
+		t.Errorf("Bad object listing %q", got)
+	}
+	if got := swiftCmd("", "ls", "container/dir"); got != "dir/stdin\n" {
+		t.Errorf("Bad prefix listing %q", got)
+	}
+	if got := swiftCmd("", "get", "container/copy.txt"); got != "hello" {
+		t.Errorf("Bad contents %q", got)
+	}
+	out := filepath.Join(dir, "out.txt")
+	swiftCmd("", "get", "container/dir/stdin", out)
+	if data, err := ioutil.ReadFile(out); err != nil || string(data) != "from stdin" {
+		t.Errorf("Bad downloaded file %q %v", data, err)
+	}
+	if got := swiftCmd("", "stat", "container/file.txt"); !strings.Contains(got, "Content-Length: 5\n") {
+		t.Errorf("Bad stat %q", got)
+	}
+	if got := swiftCmd("", "tempurl", "-key", "secret", "container/file.txt"); !strings.Contains(got, "temp_url_sig=") {
+		t.Errorf("Bad temp URL %q", got)
+	}
+	for _, name := range []string{"container/file.txt", "container/copy.txt", "container/dir/stdin", "container"} {
+		swiftCmd("", "rm", name)
+	}
+	if got := swiftCmd("", "ls"); got != "" {
+		t.Errorf("Expecting no containers got %q", got)
+	}
+
+	for _, args := range [][]string{nil, {"potato"}, {"get"}, {"mkdir", "a", "b"}} {
+		if err := run(c, args, nil, ioutil.Discard); err != errUsage {
+			t.Errorf("%v: expecting errUsage got %v", args, err)
+		}
+	}
+}
+
+// Each command works on a Connection which hasn't been authenticated
+// yet, as main makes
+func TestCommandsUnauthenticated(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	for _, args := range [][]string{
+		{"ls"},
+		{"stat"},
+		{"tempurl", "-key", "secret", "container/object"},
+	} {
+		c := &swift.Connection{
+			UserName: swifttest.TEST_ACCOUNT,
+			ApiKey:   swifttest.TEST_ACCOUNT,
+			AuthUrl:  srv.AuthURL,
+		}
+		var stdout bytes.Buffer
+		if err := run(c, args, nil, &stdout); err != nil {
+			t.Errorf("%v: %v", args, err)
+		}
+		if args[0] == "tempurl" && !strings.HasPrefix(stdout.String(), "http://") {
+			t.Errorf("Bad temp URL %q", stdout.String())
+		}
+	}
+}
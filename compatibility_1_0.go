@@ -5,14 +5,13 @@
 package swift
 
 import (
-	"log"
 	"net/http"
 	"time"
 )
 
 // Cancel the request - doesn't work under < go 1.1
-func cancelRequest(transport http.RoundTripper, req *http.Request) {
-	log.Printf("Tried to cancel a request but couldn't - recompile with go 1.1")
+func (c *Connection) cancelRequest(req *http.Request) {
+	c.logf("Tried to cancel a request but couldn't - recompile with go 1.1")
 }
 
 // Reset a timer - Doesn't work properly < go 1.1
@@ -10,8 +10,8 @@ import (
 )
 
 // Cancel the request
-func cancelRequest(transport http.RoundTripper, req *http.Request) {
-	if tr, ok := transport.(interface {
+func (c *Connection) cancelRequest(req *http.Request) {
+	if tr, ok := c.Transport.(interface {
 		CancelRequest(*http.Request)
 	}); ok {
 		tr.CancelRequest(req)
@@ -0,0 +1,426 @@
+// Tests for using one Connection from many go routines at once
+//
+// Run these with the race detector
+//
+//	go test -race -run Concurrent
+
+package swift_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/swift"
+)
+
+const concurrency = 8
+
+// runConcurrently runs fn(i) for i in 0..concurrency-1 in parallel and
+// waits for them all to finish
+func runConcurrently(fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func concurrentObjectName(i int) string {
+	return fmt.Sprintf("%s_concurrent_%d", OBJECT, i)
+}
+
+func concurrentContents(i int) string {
+	return strings.Repeat(fmt.Sprintf("%d", i), 100)
+}
+
+// uploadDownloadDelete uploads, downloads and deletes the i-th object
+// reporting any errors
+func uploadDownloadDelete(t *testing.T, c *swift.Connection, i int) {
+	objectName := concurrentObjectName(i)
+	err := c.ObjectPutString(CONTAINER, objectName, concurrentContents(i), "")
+	if err != nil {
+		t.Errorf("%s: put failed: %v", objectName, err)
+		return
+	}
+	contents, err := c.ObjectGetString(CONTAINER, objectName)
+	if err != nil {
+		t.Errorf("%s: get failed: %v", objectName, err)
+	} else if contents != concurrentContents(i) {
+		t.Errorf("%s: contents wrong %q", objectName, contents)
+	}
+	err = c.ObjectDelete(CONTAINER, objectName)
+	if err != nil {
+		t.Errorf("%s: delete failed: %v", objectName, err)
+	}
+}
+
+func TestConcurrentAuthenticate(t *testing.T) {
+	c, rollback := makeConnection(t)
+	defer rollback()
+	runConcurrently(func(i int) {
+		if i%2 == 0 {
+			if err := c.Authenticate(); err != nil {
+				t.Error("Auth failed", err)
+			}
+		} else {
+			_ = c.Authenticated()
+		}
+	})
+	if !c.Authenticated() {
+		t.Error("Not authenticated")
+	}
+}
+
+func TestConcurrentUploadDownload(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	runConcurrently(func(i int) {
+		uploadDownloadDelete(t, c, i)
+	})
+}
+
+func TestConcurrentListings(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	runConcurrently(func(i int) {
+		if i%2 == 0 {
+			uploadDownloadDelete(t, c, i)
+			return
+		}
+		if _, err := c.ObjectNamesAll(CONTAINER, nil); err != nil {
+			t.Errorf("object listing failed: %v", err)
+		}
+		if _, err := c.ContainerNamesAll(nil); err != nil {
+			t.Errorf("container listing failed: %v", err)
+		}
+		if _, _, err := c.Container(CONTAINER); err != nil {
+			t.Errorf("container info failed: %v", err)
+		}
+	})
+}
+
+func TestConcurrentAuthExpiry(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	runConcurrently(func(i int) {
+		if i == 0 {
+			// Keep invalidating the token so the others have to
+			// re-authenticate part way through their operations
+			for j := 0; j < 20; j++ {
+				c.UnAuthenticate()
+				if _, _, err := c.Account(); err != nil {
+					t.Errorf("account info failed: %v", err)
+				}
+			}
+			return
+		}
+		for j := 0; j < 5; j++ {
+			uploadDownloadDelete(t, c, i)
+		}
+	})
+}
+
+func TestConcurrentAccountCensus(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	// Names either side of and on the shard boundaries
+	names := []string{"", "0", "00", "4", "5", "Z", "a", "b", "zzz", "é"}
+	for i := range names {
+		names[i] += "object"
+	}
+	names = append(names, "5", "a")
+	var bytes int64
+	for i, name := range names {
+		contents := concurrentContents(i)
+		bytes += int64(len(contents))
+		if err := c.ObjectPutString(CONTAINER, name, contents, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for _, opts := range []*swift.CensusOpts{
+		nil,
+		{Workers: concurrency, ShardObjects: 1},
+		{Workers: 1, ShardObjects: 1, Boundaries: []string{"a", "5", "5"}},
+	} {
+		census, err := c.AccountCensus(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, container := range census.Containers {
+			if container.Name != CONTAINER {
+				continue
+			}
+			found = true
+			if container.Objects != int64(len(names)) || container.Bytes != bytes {
+				t.Errorf("%+v: expecting %d objects and %d bytes got %+v", opts, len(names), bytes, container)
+			}
+		}
+		if !found {
+			t.Errorf("%+v: container %q not found", opts, CONTAINER)
+		}
+		if census.Objects < int64(len(names)) || census.Bytes < bytes {
+			t.Errorf("%+v: account totals too small %d objects %d bytes", opts, census.Objects, census.Bytes)
+		}
+	}
+}
+
+func TestConcurrentAccountObjectsWalk(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	containers := []string{CONTAINER, CONTAINER + "_walk"}
+	err := c.ContainerCreate(containers[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{}
+	for _, container := range containers {
+		for i := 0; i < concurrency; i++ {
+			name := concurrentObjectName(i)
+			if err := c.ObjectPutString(container, name, concurrentContents(i), ""); err != nil {
+				t.Fatal(err)
+			}
+			want[container] = append(want[container], name)
+		}
+	}
+	defer func() {
+		for _, container := range containers {
+			for _, name := range want[container] {
+				if err := c.ObjectDelete(container, name); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+		if err := c.ContainerDelete(containers[1]); err != nil {
+			t.Error(err)
+		}
+	}()
+	opts := &swift.AccountObjectsOpts{
+		Workers:    concurrency,
+		Containers: &swift.ContainersOpts{Prefix: CONTAINER},
+		Objects:    &swift.ObjectsOpts{Prefix: OBJECT, Limit: 3},
+	}
+	got := map[string][]string{}
+	err = c.AccountObjectsWalk(opts, func(container string, object *swift.Object) error {
+		got[container] = append(got[container], object.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, container := range containers {
+		if !reflect.DeepEqual(got[container], want[container]) {
+			t.Errorf("%s: expecting %q got %q", container, want[container], got[container])
+		}
+	}
+	calls := 0
+	err = c.AccountObjectsWalk(opts, func(container string, object *swift.Object) error {
+		calls++
+		return swift.StopWalk
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("Expecting one call and no error got %d calls and %v", calls, err)
+	}
+}
+
+func TestConcurrentDownload(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	for i := 0; i < concurrency; i++ {
+		if err := c.ObjectPutString(CONTAINER, concurrentObjectName(i), concurrentContents(i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for i := 0; i < concurrency; i++ {
+			if err := c.ObjectDelete(CONTAINER, concurrentObjectName(i)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	want := map[string]string{}
+	names := make(chan string)
+	go func() {
+		defer close(names)
+		for i := 0; i < concurrency; i++ {
+			want[concurrentObjectName(i)] = concurrentContents(i)
+			names <- concurrentObjectName(i)
+		}
+		names <- "not found"
+	}()
+	const inFlight = 2
+	results := c.Download(CONTAINER, names, &swift.DownloadOpts{InFlight: inFlight, CheckHash: true})
+
+	// Nothing more is opened until one of the objects is closed
+	var open []swift.DownloadResult
+	for i := 0; i < inFlight; i++ {
+		open = append(open, <-results)
+	}
+	select {
+	case result := <-results:
+		t.Fatalf("Unexpected result %q with %d objects open", result.Name, inFlight)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	got := map[string]string{}
+	check := func(result swift.DownloadResult) {
+		if result.Err != nil {
+			if result.Name != "not found" || result.Err != swift.ObjectNotFound {
+				t.Errorf("%s: unexpected error %v", result.Name, result.Err)
+			}
+			return
+		}
+		data, err := ioutil.ReadAll(result.Contents)
+		if err != nil {
+			t.Errorf("%s: read failed: %v", result.Name, err)
+		}
+		if err = result.Contents.Close(); err != nil {
+			t.Errorf("%s: close failed: %v", result.Name, err)
+		}
+		got[result.Name] = string(data)
+	}
+	for _, result := range open {
+		check(result)
+	}
+	for result := range results {
+		check(result)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expecting %v got %v", want, got)
+	}
+}
+
+func TestConcurrentObjectsAllPrefixes(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var names []string
+	for _, prefix := range []string{"a", "b", "c"} {
+		for i := 0; i < 3; i++ {
+			names = append(names, fmt.Sprintf("%s/%d", prefix, i))
+		}
+	}
+	for _, name := range names {
+		if err := c.ObjectPutString(CONTAINER, name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	prefixes := []string{"c", "a/1", "b", "a", "b/"}
+	opts := &swift.PrefixesOpts{Workers: concurrency, Objects: &swift.ObjectsOpts{Limit: 2}}
+	objects, err := c.ObjectsAllPrefixes(CONTAINER, prefixes, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, object := range objects {
+		got = append(got, object.Name)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("expecting %q got %q", names, got)
+	}
+	got, err = c.ObjectNamesAllPrefixes(CONTAINER, []string{"c", "a"}, &swift.PrefixesOpts{Objects: &swift.ObjectsOpts{Reverse: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"c/2", "c/1", "c/0", "a/2", "a/1", "a/0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expecting %q got %q", want, got)
+	}
+}
+
+func TestConcurrentScheduler(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	c.Scheduler = swift.NewScheduler(2, 1)
+	background := *c
+	background.Priority = swift.PriorityBackground
+	runConcurrently(func(i int) {
+		if i%2 == 0 {
+			uploadDownloadDelete(t, &background, i)
+		} else {
+			uploadDownloadDelete(t, c, i)
+		}
+	})
+}
+
+func TestConcurrentAccountUsage(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{"a/1", "a/2", "b/1", "top"}
+	var bytes int64
+	for i, name := range names {
+		contents := concurrentContents(i)
+		bytes += int64(len(contents))
+		if err := c.ObjectPutString(CONTAINER, name, contents, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	var calls, total int
+	usage, err := c.AccountUsage(&swift.AccountUsageOpts{
+		Workers:    concurrency,
+		Depth:      1,
+		Containers: &swift.ContainersOpts{Prefix: CONTAINER},
+		Progress: func(done int, n int, container *swift.ContainerUsage) {
+			calls++
+			total = n
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(usage.Containers) || total != len(usage.Containers) {
+		t.Errorf("Expecting %d progress calls got %d with total %d", len(usage.Containers), calls, total)
+	}
+	var found bool
+	for _, container := range usage.Containers {
+		if container.Name != CONTAINER {
+			continue
+		}
+		found = true
+		if container.Objects != int64(len(names)) || container.Bytes != bytes {
+			t.Errorf("expecting %d objects and %d bytes got %+v", len(names), bytes, container)
+		}
+		var prefixes []string
+		for _, stat := range container.Prefixes {
+			prefixes = append(prefixes, fmt.Sprintf("%s=%d", stat.Prefix, stat.Objects))
+		}
+		if want := []string{"a/=2", "b/=1"}; !reflect.DeepEqual(prefixes, want) {
+			t.Errorf("expecting prefixes %q got %q", want, prefixes)
+		}
+	}
+	if !found {
+		t.Errorf("container %q not found", CONTAINER)
+	}
+	if usage.Objects < int64(len(names)) || usage.Bytes < bytes {
+		t.Errorf("account totals too small %d objects %d bytes", usage.Objects, usage.Bytes)
+	}
+}
@@ -0,0 +1,73 @@
+// Conditional requests for objects
+
+package swift
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Conditions holds the preconditions for a conditional GET or HEAD
+// of an object.
+//
+// Fields which are empty or zero aren't sent.  If the object hasn't
+// changed according to IfNoneMatch or IfModifiedSince then
+// NotModified is returned.  If it doesn't match IfMatch or
+// IfUnmodifiedSince then PreconditionFailed is returned.
+type Conditions struct {
+	IfMatch           string    // Etag the object must have, or "*"
+	IfNoneMatch       string    // Etag the object must not have, or "*"
+	IfModifiedSince   time.Time // Time the object must have been modified since
+	IfUnmodifiedSince time.Time // Time the object must not have been modified since
+}
+
+// Headers returns the conditions as HTTP headers
+func (cond *Conditions) Headers() Headers {
+	h := Headers{}
+	if cond.IfMatch != "" {
+		h["If-Match"] = cond.IfMatch
+	}
+	if cond.IfNoneMatch != "" {
+		h["If-None-Match"] = cond.IfNoneMatch
+	}
+	if !cond.IfModifiedSince.IsZero() {
+		h["If-Modified-Since"] = cond.IfModifiedSince.UTC().Format(http.TimeFormat)
+	}
+	if !cond.IfUnmodifiedSince.IsZero() {
+		h["If-Unmodified-Since"] = cond.IfUnmodifiedSince.UTC().Format(http.TimeFormat)
+	}
+	return h
+}
+
+// ObjectGetConditional gets the object into the io.Writer contents
+// if it satisfies cond.
+//
+// This is like ObjectGet but returns NotModified or
+// PreconditionFailed if the conditions aren't met, in which case
+// nothing is written to contents.  This can be used to make a cache
+// which only fetches objects which have changed.
+func (c *Connection) ObjectGetConditional(container string, objectName string, contents io.Writer, checkHash bool, cond *Conditions, h Headers) (headers Headers, err error) {
+	return c.ObjectGetWithOpts(container, objectName, contents, &ObjectGetOpts{
+		CheckHash:  checkHash,
+		Conditions: cond,
+		Headers:    h,
+	})
+}
+
+// ObjectConditional returns info about a single object if it
+// satisfies cond.
+//
+// This is like Object but returns NotModified or PreconditionFailed
+// if the conditions aren't met.
+func (c *Connection) ObjectConditional(container string, objectName string, cond *Conditions) (info Object, headers Headers, err error) {
+	h := cond.Headers()
+	err = withLORetry(0, func() (Headers, int64, error) {
+		info, headers, err = c.objectBase(container, objectName, h)
+		if err != nil {
+			return headers, 0, err
+		}
+		return headers, info.Bytes, nil
+	})
+	return
+}
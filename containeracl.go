@@ -0,0 +1,210 @@
+// Parsing and building container ACLs
+
+package swift
+
+import (
+	"strings"
+)
+
+// ContainerACL is a parsed X-Container-Read or X-Container-Write
+// header.
+//
+// The header is a comma separated list of elements.  Referrer
+// elements, eg ".r:*" or ".r:.example.com", allow access by the HTTP
+// Referer of the request, ".rlistings" allows anyone with referrer
+// access to list the container and anything else names users, eg
+// "project:user", "project:*" or "*:user".
+//
+// Referrer elements only make sense in a read ACL.
+type ContainerACL struct {
+	Referrers []string // Referrers allowed access, eg "*", ".example.com" or "-bad.example.com" to deny
+	Listings  bool     // Whether referrers can list the container
+	Users     []string // Users allowed access, eg "project:user"
+}
+
+// ParseContainerACL parses an X-Container-Read or X-Container-Write
+// header.
+//
+// The aliases Swift accepts for ".r:" (".ref:", ".referer:" and
+// ".referrer:") are understood and duplicate elements are removed.
+func ParseContainerACL(s string) *ContainerACL {
+	acl := new(ContainerACL)
+	for _, element := range strings.Split(s, ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+		if element == ".rlistings" {
+			acl.Listings = true
+			continue
+		}
+		if strings.HasPrefix(element, ".") {
+			if i := strings.IndexRune(element, ':'); i >= 0 {
+				switch element[:i] {
+				case ".r", ".ref", ".referer", ".referrer":
+					acl.addReferrer(strings.TrimSpace(element[i+1:]))
+					continue
+				}
+			}
+		}
+		acl.Grant(element)
+	}
+	return acl
+}
+
+// stringsContain returns whether s is in list
+func stringsContain(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// stringsRemove returns list without s
+func stringsRemove(list []string, s string) []string {
+	result := list[:0]
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// addReferrer adds referrer if it isn't already present
+func (acl *ContainerACL) addReferrer(referrer string) {
+	if referrer != "" && !stringsContain(acl.Referrers, referrer) {
+		acl.Referrers = append(acl.Referrers, referrer)
+	}
+}
+
+// String returns the ACL in the form used in the X-Container-Read
+// and X-Container-Write headers.  An empty ACL returns "".
+func (acl *ContainerACL) String() string {
+	var elements []string
+	for _, referrer := range acl.Referrers {
+		elements = append(elements, ".r:"+referrer)
+	}
+	if acl.Listings {
+		elements = append(elements, ".rlistings")
+	}
+	elements = append(elements, acl.Users...)
+	return strings.Join(elements, ",")
+}
+
+// IsPublic returns whether anyone can read the container without
+// authenticating.
+func (acl *ContainerACL) IsPublic() bool {
+	return stringsContain(acl.Referrers, "*")
+}
+
+// MakePublic allows anyone to read the objects in the container
+// without authenticating and to list it if listings is set.
+func (acl *ContainerACL) MakePublic(listings bool) {
+	acl.addReferrer("*")
+	acl.Listings = listings
+}
+
+// MakePrivate removes all the referrer access so only the users in
+// the ACL and the account owner can access the container.
+func (acl *ContainerACL) MakePrivate() {
+	acl.Referrers = nil
+	acl.Listings = false
+}
+
+// Grant gives user access, eg "project:user".
+func (acl *ContainerACL) Grant(user string) {
+	if user != "" && !stringsContain(acl.Users, user) {
+		acl.Users = append(acl.Users, user)
+	}
+}
+
+// Revoke removes the access given to user by Grant.
+func (acl *ContainerACL) Revoke(user string) {
+	acl.Users = stringsRemove(acl.Users, user)
+}
+
+// ContainerACLs returns the parsed read and write ACLs of container.
+func (c *Connection) ContainerACLs(container string) (read *ContainerACL, write *ContainerACL, err error) {
+	_, headers, err := c.Container(container)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseContainerACL(headers["X-Container-Read"]), ParseContainerACL(headers["X-Container-Write"]), nil
+}
+
+// ContainerSetACLs sets the read and write ACLs of container.  A nil
+// ACL is left unchanged.
+func (c *Connection) ContainerSetACLs(container string, read *ContainerACL, write *ContainerACL) error {
+	h := Headers{}
+	if read != nil {
+		h["X-Container-Read"] = read.String()
+	}
+	if write != nil {
+		h["X-Container-Write"] = write.String()
+	}
+	return c.ContainerUpdate(container, h)
+}
+
+// ContainerMakePublic allows anyone to read the objects in container
+// without authenticating and to list it if listings is set.  The
+// users in the read ACL are kept.
+func (c *Connection) ContainerMakePublic(container string, listings bool) error {
+	read, _, err := c.ContainerACLs(container)
+	if err != nil {
+		return err
+	}
+	read.MakePublic(listings)
+	return c.ContainerSetACLs(container, read, nil)
+}
+
+// ContainerMakePrivate removes the referrer access to container set
+// by ContainerMakePublic.  The users in the read ACL are kept.
+func (c *Connection) ContainerMakePrivate(container string) error {
+	read, _, err := c.ContainerACLs(container)
+	if err != nil {
+		return err
+	}
+	read.MakePrivate()
+	return c.ContainerSetACLs(container, read, nil)
+}
+
+// ContainerGrantRead gives users read access to container.
+func (c *Connection) ContainerGrantRead(container string, users ...string) error {
+	read, _, err := c.ContainerACLs(container)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		read.Grant(user)
+	}
+	return c.ContainerSetACLs(container, read, nil)
+}
+
+// ContainerGrantWrite gives users write access to container.
+func (c *Connection) ContainerGrantWrite(container string, users ...string) error {
+	_, write, err := c.ContainerACLs(container)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		write.Grant(user)
+	}
+	return c.ContainerSetACLs(container, nil, write)
+}
+
+// ContainerRevoke removes the read and write access given to users
+// with ContainerGrantRead and ContainerGrantWrite.
+func (c *Connection) ContainerRevoke(container string, users ...string) error {
+	read, write, err := c.ContainerACLs(container)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		read.Revoke(user)
+		write.Revoke(user)
+	}
+	return c.ContainerSetACLs(container, read, write)
+}
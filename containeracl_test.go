@@ -0,0 +1,53 @@
+// Tests for container ACLs
+package swift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseContainerACL(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ContainerACL
+		out  string
+	}{
+		{"", ContainerACL{}, ""},
+		{".r:*", ContainerACL{Referrers: []string{"*"}}, ".r:*"},
+		{".r:*,.rlistings", ContainerACL{Referrers: []string{"*"}, Listings: true}, ".r:*,.rlistings"},
+		{
+			" .referrer:.example.com , .r:-bad.example.com,project:user,.r:.example.com, *:admin",
+			ContainerACL{Referrers: []string{".example.com", "-bad.example.com"}, Users: []string{"project:user", "*:admin"}},
+			".r:.example.com,.r:-bad.example.com,project:user,*:admin",
+		},
+		{"project:*,project:*", ContainerACL{Users: []string{"project:*"}}, "project:*"},
+	} {
+		got := ParseContainerACL(test.in)
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("%q: want %+v got %+v", test.in, test.want, *got)
+		}
+		if out := got.String(); out != test.out {
+			t.Errorf("%q: want %q got %q", test.in, test.out, out)
+		}
+	}
+}
+
+func TestContainerACLEdit(t *testing.T) {
+	acl := ParseContainerACL("project:user")
+	if acl.IsPublic() {
+		t.Error("Shouldn't be public")
+	}
+	acl.MakePublic(true)
+	acl.Grant("project:other")
+	if !acl.IsPublic() {
+		t.Error("Should be public")
+	}
+	if got := acl.String(); got != ".r:*,.rlistings,project:user,project:other" {
+		t.Errorf("Bad ACL %q", got)
+	}
+	acl.MakePrivate()
+	acl.Revoke("project:user")
+	if got := acl.String(); got != "project:other" {
+		t.Errorf("Bad ACL %q", got)
+	}
+}
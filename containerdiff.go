@@ -0,0 +1,103 @@
+// Finding the changes in a container between two listings
+
+package swift
+
+import (
+	"sort"
+)
+
+// ObjectsDiff holds the differences between two listings of a
+// container as returned by DiffObjects and ContainerDiff
+type ObjectsDiff struct {
+	Added    []Object // Objects in the new listing but not the old one
+	Removed  []Object // Objects in the old listing but not the new one
+	Modified []Object // Objects in both listings which have changed, as in the new listing
+}
+
+// Empty returns whether there are no differences
+func (d *ObjectsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// objectChanged returns whether the same object has changed between
+// the listings
+func objectChanged(from *Object, to *Object) bool {
+	return from.Hash != to.Hash ||
+		from.Bytes != to.Bytes ||
+		from.SLOHash != to.SLOHash ||
+		!from.LastModified.Equal(to.LastModified)
+}
+
+// sortedListing returns the objects in listing sorted by the key of
+// their names without any pseudo directories
+func sortedListing(listing []Object, key func(string) string) []Object {
+	sorted := make([]Object, 0, len(listing))
+	for _, object := range listing {
+		if !object.PseudoDirectory {
+			sorted = append(sorted, object)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return key(sorted[i].Name) < key(sorted[j].Name)
+	})
+	return sorted
+}
+
+// DiffObjects compares two listings of a container, from and to, and
+// returns the objects which have been added, removed and modified.
+//
+// Objects are matched by name and are modified if their hash, size or
+// last modified time has changed.  Pseudo directories are ignored.
+// The listings don't need to be sorted but the results are sorted by
+// name.
+//
+// A listing from Objects or ObjectsAll can be saved with
+// encoding/json and compared with a later one.
+func DiffObjects(from []Object, to []Object) *ObjectsDiff {
+	return diffObjects(from, to, NameNormalizer(nil).normalize)
+}
+
+// diffObjects does the work for DiffObjects matching objects by the
+// key of their names
+func diffObjects(from []Object, to []Object, key func(string) string) *ObjectsDiff {
+	from, to = sortedListing(from, key), sortedListing(to, key)
+	diff := &ObjectsDiff{}
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case j >= len(to) || (i < len(from) && key(from[i].Name) < key(to[j].Name)):
+			diff.Removed = append(diff.Removed, from[i])
+			i++
+		case i >= len(from) || key(to[j].Name) < key(from[i].Name):
+			diff.Added = append(diff.Added, to[j])
+			j++
+		default:
+			if objectChanged(&from[i], &to[j]) {
+				diff.Modified = append(diff.Modified, to[j])
+			}
+			i++
+			j++
+		}
+	}
+	return diff
+}
+
+// ContainerDiff lists container and compares it with from, a listing
+// made earlier, using DiffObjects.
+//
+// If the Connection's NormalizeName is set then objects are matched
+// using it as NameNormalizer.DiffObjects does.
+//
+// It returns the differences and the new listing which can be saved
+// to pass as from next time to find what has changed since.
+//
+// opts may be nil.  If opts.Prefix is set then from should have been
+// listed with the same prefix or objects outside it will be reported
+// as removed.
+func (c *Connection) ContainerDiff(container string, from []Object, opts *ObjectsOpts) (*ObjectsDiff, []Object, error) {
+	to, err := c.ObjectsAll(container, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.NormalizeName.DiffObjects(from, to), to, nil
+}
@@ -0,0 +1,108 @@
+// Typed access to the settings of a container
+
+package swift
+
+import (
+	"strconv"
+)
+
+// ContainerSettings holds the settings of a container which Swift
+// and its middleware keep in the container's headers.
+//
+// Read them with Container or Headers.ContainerSettings and change
+// them with ContainerConfigure.  Zero values mean the setting isn't
+// in use, eg a QuotaBytes of 0 means there is no quota.
+type ContainerSettings struct {
+	ReadACL          string // X-Container-Read - who can read the container
+	WriteACL         string // X-Container-Write - who can write the container
+	QuotaBytes       int64  // X-Container-Meta-Quota-Bytes - maximum bytes in the container
+	QuotaCount       int64  // X-Container-Meta-Quota-Count - maximum objects in the container
+	VersionsLocation string // X-Versions-Location - container old versions are stored in using stack mode
+	HistoryLocation  string // X-History-Location - container old versions are stored in using history mode
+	WebIndex         string // X-Container-Meta-Web-Index - object served for pseudo directories by staticweb
+	WebListings      bool   // X-Container-Meta-Web-Listings - whether staticweb serves listings
+	SyncTo           string // X-Container-Sync-To - container to sync this container to
+	SyncKey          string // X-Container-Sync-Key - secret key for container sync
+	StoragePolicy    string // X-Storage-Policy - can only be set when the container is created
+}
+
+// ContainerSettings reads the settings of a container from the
+// headers returned by Container.
+//
+// Values which can't be parsed are treated as unset.
+func (h Headers) ContainerSettings() *ContainerSettings {
+	quotaBytes, _ := strconv.ParseInt(h["X-Container-Meta-Quota-Bytes"], 10, 64)
+	quotaCount, _ := strconv.ParseInt(h["X-Container-Meta-Quota-Count"], 10, 64)
+	webListings, _ := strconv.ParseBool(h["X-Container-Meta-Web-Listings"])
+	return &ContainerSettings{
+		ReadACL:          h["X-Container-Read"],
+		WriteACL:         h["X-Container-Write"],
+		QuotaBytes:       quotaBytes,
+		QuotaCount:       quotaCount,
+		VersionsLocation: h["X-Versions-Location"],
+		HistoryLocation:  h["X-History-Location"],
+		WebIndex:         h["X-Container-Meta-Web-Index"],
+		WebListings:      webListings,
+		SyncTo:           h["X-Container-Sync-To"],
+		SyncKey:          h["X-Container-Sync-Key"],
+		StoragePolicy:    h["X-Storage-Policy"],
+	}
+}
+
+// formatQuota returns the header value for a quota of n
+func formatQuota(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// Headers returns the headers which set the container to s.
+//
+// Settings which aren't in use have empty values so the headers
+// remove them.  Only one of X-Versions-Location and X-History-Location
+// is included unless both or neither are set, as Swift rejects a
+// request setting both.  X-Storage-Policy is only included if set.
+//
+// These can be passed to ContainerCreate to make a container with
+// these settings.
+func (s *ContainerSettings) Headers() Headers {
+	h := Headers{
+		"X-Container-Read":              s.ReadACL,
+		"X-Container-Write":             s.WriteACL,
+		"X-Container-Meta-Quota-Bytes":  formatQuota(s.QuotaBytes),
+		"X-Container-Meta-Quota-Count":  formatQuota(s.QuotaCount),
+		"X-Container-Meta-Web-Index":    s.WebIndex,
+		"X-Container-Meta-Web-Listings": "",
+		"X-Container-Sync-To":           s.SyncTo,
+		"X-Container-Sync-Key":          s.SyncKey,
+	}
+	if s.WebListings {
+		h["X-Container-Meta-Web-Listings"] = "true"
+	}
+	if s.VersionsLocation != "" || s.HistoryLocation == "" {
+		h["X-Versions-Location"] = s.VersionsLocation
+	}
+	if s.HistoryLocation != "" || s.VersionsLocation == "" {
+		h["X-History-Location"] = s.HistoryLocation
+	}
+	if s.StoragePolicy != "" {
+		h["X-Storage-Policy"] = s.StoragePolicy
+	}
+	return h
+}
+
+// ContainerConfigure sets all the settings of an existing container
+// to s, removing any which aren't in use in s.
+//
+// To change some of the settings read them with Container first and
+// modify them.  The storage policy of a container can't be changed so
+// StoragePolicy is ignored.
+//
+// Any user metadata other than that used by the settings is left
+// alone.
+func (c *Connection) ContainerConfigure(container string, s *ContainerSettings) error {
+	h := s.Headers()
+	delete(h, "X-Storage-Policy")
+	return c.ContainerUpdate(container, h)
+}
@@ -0,0 +1,105 @@
+// Configuring CORS on containers
+
+package swift
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS headers of a container read by Swift's CORS support
+const (
+	corsAllowOrigin   = "X-Container-Meta-Access-Control-Allow-Origin"
+	corsMaxAge        = "X-Container-Meta-Access-Control-Max-Age"
+	corsExposeHeaders = "X-Container-Meta-Access-Control-Expose-Headers"
+)
+
+// CORSConfig is the CORS configuration of a container which lets web
+// pages from other origins use the objects in it directly.
+type CORSConfig struct {
+	AllowOrigins  []string      // Origins allowed to make requests, eg "https://example.com" - "*" for any
+	ExposeHeaders []string      // Response headers the browser lets scripts read, eg "Etag"
+	MaxAge        time.Duration // How long browsers can cache the result of a preflight request - 0 for the browser's default
+}
+
+// CORSConfig reads the CORS configuration of a container from
+// the headers returned by Container.
+//
+// A MaxAge which can't be parsed is treated as unset.
+func (h Headers) CORSConfig() *CORSConfig {
+	config := &CORSConfig{
+		AllowOrigins:  strings.Fields(h[corsAllowOrigin]),
+		ExposeHeaders: strings.Fields(h[corsExposeHeaders]),
+	}
+	if seconds, err := strconv.ParseInt(h[corsMaxAge], 10, 64); err == nil && seconds > 0 {
+		config.MaxAge = time.Duration(seconds) * time.Second
+	}
+	return config
+}
+
+// Validate checks the configuration would be understood by Swift
+// and browsers.
+//
+// Origins must be "*" or a scheme and host with an optional port,
+// eg "https://example.com:8443", with no path.  Header names mustn't
+// contain spaces or commas.  MaxAge mustn't be negative.
+func (config *CORSConfig) Validate() error {
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return newErrorf(0, "Invalid CORS origin %q: must be \"*\" or scheme://host[:port]", origin)
+		}
+	}
+	for _, header := range config.ExposeHeaders {
+		if header == "" || strings.ContainsAny(header, " \t\r\n,:") {
+			return newErrorf(0, "Invalid CORS expose header %q", header)
+		}
+	}
+	if config.MaxAge < 0 {
+		return newErrorf(0, "Invalid CORS max age %v: mustn't be negative", config.MaxAge)
+	}
+	return nil
+}
+
+// Headers returns the headers which set the CORS configuration of a
+// container to config.  Unset values are empty so the headers remove
+// them.
+//
+// MaxAge is rounded up to a whole number of seconds.
+func (config *CORSConfig) Headers() Headers {
+	h := Headers{
+		corsAllowOrigin:   strings.Join(config.AllowOrigins, " "),
+		corsExposeHeaders: strings.Join(config.ExposeHeaders, " "),
+		corsMaxAge:        "",
+	}
+	if config.MaxAge > 0 {
+		seconds := (config.MaxAge + time.Second - 1) / time.Second
+		h[corsMaxAge] = strconv.FormatInt(int64(seconds), 10)
+	}
+	return h
+}
+
+// ContainerCORS reads the CORS configuration of container.
+func (c *Connection) ContainerCORS(container string) (*CORSConfig, error) {
+	_, headers, err := c.Container(container)
+	if err != nil {
+		return nil, err
+	}
+	return headers.CORSConfig(), nil
+}
+
+// ContainerSetCORS validates config and sets it as the CORS
+// configuration of container, replacing the existing one.  Pass an
+// empty CORSConfig to turn CORS off.
+func (c *Connection) ContainerSetCORS(container string, config *CORSConfig) error {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+	return c.ContainerUpdate(container, config.Headers())
+}
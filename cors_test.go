@@ -0,0 +1,54 @@
+// Tests for CORS configuration
+package swift
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCORSConfigValidate(t *testing.T) {
+	for _, config := range []CORSConfig{
+		{},
+		{AllowOrigins: []string{"*"}},
+		{AllowOrigins: []string{"https://example.com", "http://localhost:8080/"}, ExposeHeaders: []string{"Etag", "X-Object-Meta-Color"}, MaxAge: time.Hour},
+	} {
+		if err := config.Validate(); err != nil {
+			t.Errorf("%+v: %v", config, err)
+		}
+	}
+	for _, config := range []CORSConfig{
+		{AllowOrigins: []string{"example.com"}},
+		{AllowOrigins: []string{"https://example.com/path"}},
+		{AllowOrigins: []string{"https://example.com?x=1"}},
+		{ExposeHeaders: []string{"Etag, Content-Type"}},
+		{ExposeHeaders: []string{""}},
+		{MaxAge: -time.Second},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%+v: expecting error", config)
+		}
+	}
+}
+
+func TestCORSConfigHeaders(t *testing.T) {
+	config := CORSConfig{
+		AllowOrigins:  []string{"https://a.example.com", "https://b.example.com"},
+		ExposeHeaders: []string{"Etag"},
+		MaxAge:        1500 * time.Millisecond,
+	}
+	h := config.Headers()
+	compareMaps(t, h, Headers{
+		"X-Container-Meta-Access-Control-Allow-Origin":   "https://a.example.com https://b.example.com",
+		"X-Container-Meta-Access-Control-Expose-Headers": "Etag",
+		"X-Container-Meta-Access-Control-Max-Age":        "2",
+	})
+	got := h.CORSConfig()
+	if len(got.AllowOrigins) != 2 || got.AllowOrigins[1] != "https://b.example.com" || len(got.ExposeHeaders) != 1 || got.MaxAge != 2*time.Second {
+		t.Errorf("Bad config %+v", got)
+	}
+	compareMaps(t, (&CORSConfig{}).Headers(), Headers{
+		"X-Container-Meta-Access-Control-Allow-Origin":   "",
+		"X-Container-Meta-Access-Control-Expose-Headers": "",
+		"X-Container-Meta-Access-Control-Max-Age":        "",
+	})
+}
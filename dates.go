@@ -0,0 +1,88 @@
+// Parsing the date formats Swift uses
+
+package swift
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Swift uses three formats for times:
+//
+//	Listings      2012-11-11T14:49:47.887250   ISO 8601 in UTC without a zone
+//	Last-Modified Fri, 12 Jun 2010 13:40:18 GMT RFC 1123 as used by HTTP
+//	X-Delete-At   1354040105.12345             seconds since the epoch
+//
+// The functions here parse all of them into UTC time.Time values
+// keeping any fractional seconds.
+
+// ParseListingTime parses a last_modified time from a container
+// listing, eg "2012-11-11T14:49:47.887250".
+//
+// Fractional seconds are optional and kept if present.  Times with a
+// zone as returned by some Swift compatible servers, eg
+// "2016-02-12T16:15:05.000Z", are accepted too.  The result is always
+// in UTC.
+func ParseListingTime(s string) (time.Time, error) {
+	t, err := time.Parse(TimeFormat, s)
+	if err != nil {
+		var zoneErr error
+		t, zoneErr = time.Parse(time.RFC3339Nano, s)
+		if zoneErr != nil {
+			return time.Time{}, err
+		}
+	}
+	return t.UTC(), nil
+}
+
+// ParseHTTPTime parses a time from an HTTP header such as
+// Last-Modified, eg "Fri, 12 Jun 2010 13:40:18 GMT".
+//
+// The obsolete formats allowed by HTTP/1.1 are accepted as well as
+// RFC 1123.  The result is always in UTC.
+func ParseHTTPTime(s string) (time.Time, error) {
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// ParseEpochTime parses a number of seconds since the epoch as used
+// by X-Delete-At and X-Timestamp, eg "1354040105" or
+// "1354040105.12345".
+//
+// Fractional seconds are kept without loss of precision.  The result
+// is always in UTC.
+func ParseEpochTime(s string) (time.Time, error) {
+	t, err := FloatStringToTime(strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// Timestamp returns the time read from the X-Timestamp header.
+//
+// This is the time the object, container or account was created, to
+// 10µs, as recorded by Swift.  ok is false if the header isn't set or
+// is invalid.
+func (h Headers) Timestamp() (t time.Time, ok bool) {
+	t, err := ParseEpochTime(h["X-Timestamp"])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// LastModified returns the time read from the Last-Modified header.
+//
+// ok is false if the header isn't set or is invalid.
+func (h Headers) LastModified() (t time.Time, ok bool) {
+	t, err := ParseHTTPTime(h["Last-Modified"])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,97 @@
+// Tests for parsing dates
+package swift
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseListingTime(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want time.Time
+	}{
+		// Swift
+		{"2012-11-11T14:49:47.887250", time.Date(2012, 11, 11, 14, 49, 47, 887250000, time.UTC)},
+		// Swift with a whole number of seconds
+		{"2011-06-30T08:20:47", time.Date(2011, 6, 30, 8, 20, 47, 0, time.UTC)},
+		// Ceph radosgw
+		{"2016-02-12T16:15:05.000Z", time.Date(2016, 2, 12, 16, 15, 5, 0, time.UTC)},
+		// With a zone
+		{"2016-02-12T18:15:05.5+02:00", time.Date(2016, 2, 12, 16, 15, 5, 500000000, time.UTC)},
+	} {
+		got, err := ParseListingTime(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location() != time.UTC {
+			t.Errorf("%q: want %v got %v", test.in, test.want, got)
+		}
+	}
+	for _, in := range []string{"", "potato", "2012-11-11 14:49:47"} {
+		if _, err := ParseListingTime(in); err == nil {
+			t.Errorf("%q: expecting error", in)
+		}
+	}
+}
+
+func TestParseHTTPTime(t *testing.T) {
+	want := time.Date(2010, 6, 12, 13, 40, 18, 0, time.UTC)
+	for _, in := range []string{
+		"Fri, 12 Jun 2010 13:40:18 GMT",  // RFC 1123
+		"Friday, 12-Jun-10 13:40:18 GMT", // RFC 850
+		"Fri Jun 12 13:40:18 2010",       // ANSI C
+	} {
+		got, err := ParseHTTPTime(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%q: want %v got %v", in, want, got)
+		}
+	}
+	if _, err := ParseHTTPTime("2010-06-12"); err == nil {
+		t.Error("Expecting error")
+	}
+	if _, ok := (Headers{}).LastModified(); ok {
+		t.Error("Expecting no Last-Modified")
+	}
+	got, ok := Headers{"Last-Modified": "Fri, 12 Jun 2010 13:40:18 GMT"}.LastModified()
+	if !ok || !got.Equal(want) {
+		t.Errorf("Bad Last-Modified %v %v", got, ok)
+	}
+}
+
+func TestParseEpochTime(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"1449849600", time.Date(2015, 12, 11, 16, 0, 0, 0, time.UTC)},
+		{"1449849600.12345", time.Date(2015, 12, 11, 16, 0, 0, 123450000, time.UTC)},
+		{"1449849600.00000", time.Date(2015, 12, 11, 16, 0, 0, 0, time.UTC)},
+	} {
+		got, err := ParseEpochTime(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location() != time.UTC {
+			t.Errorf("%q: want %v got %v", test.in, test.want, got)
+		}
+	}
+	for _, in := range []string{"", "potato", "1e9"} {
+		if _, err := ParseEpochTime(in); err == nil {
+			t.Errorf("%q: expecting error", in)
+		}
+	}
+	if _, ok := (Headers{}).Timestamp(); ok {
+		t.Error("Expecting no X-Timestamp")
+	}
+	got, ok := Headers{"X-Timestamp": "1449849600.12345"}.Timestamp()
+	if !ok || !got.Equal(time.Date(2015, 12, 11, 16, 0, 0, 123450000, time.UTC)) {
+		t.Errorf("Bad X-Timestamp %v %v", got, ok)
+	}
+}
@@ -0,0 +1,266 @@
+// Deleting all the objects with a prefix and cleaning up expired objects
+
+package swift
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDeleteWorkers is the number of delete requests ObjectsDelete
+// runs at once if ObjectsDeleteOpts.Workers isn't set
+const DefaultDeleteWorkers = 4
+
+// ObjectsDeleteOpts is options for ObjectsDelete
+type ObjectsDeleteOpts struct {
+	Workers   int // Number of delete requests to run at once - 0 for DefaultDeleteWorkers
+	BatchSize int // Number of objects to delete in each bulk delete - 0 for the maximum the server allows
+}
+
+// defaultBulkDeleteSize is the number of objects deleted in each bulk
+// delete if the server doesn't say what its limit is.  This is the
+// Swift default.
+const defaultBulkDeleteSize = 10000
+
+// maxBulkDeletes returns the maximum number of objects the server
+// allows in one bulk delete or 0 if it doesn't support bulk delete.
+func (i SwiftInfo) maxBulkDeletes() int {
+	bulk, ok := i["bulk_delete"]
+	if !ok {
+		return 0
+	}
+	if bulk, ok := bulk.(map[string]interface{}); ok {
+		if max, ok := bulk["max_deletes_per_request"].(float64); ok && max >= 1 {
+			return int(max)
+		}
+	}
+	return defaultBulkDeleteSize
+}
+
+// ObjectsDelete deletes all the objects in container whose names
+// start with prefix.  Use a prefix of "" to empty the container.
+//
+// The objects are listed a page at a time and deleted Workers
+// requests at a time.  Bulk delete is used if the server supports it,
+// otherwise the objects are deleted one at a time.
+//
+// The result has the total numbers of objects deleted and not found
+// and an error for each object which couldn't be deleted, keyed by
+// "/container/object".  If any objects couldn't be deleted then the
+// first error is returned as well as the result.
+//
+// opts may be nil for the defaults.
+func (c *Connection) ObjectsDelete(container string, prefix string, opts *ObjectsDeleteOpts) (result BulkDeleteResult, err error) {
+	workers := DefaultDeleteWorkers
+	batchSize := 0
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		batchSize = opts.BatchSize
+	}
+	if !c.Authenticated() {
+		if err = c.Authenticate(); err != nil {
+			return
+		}
+	}
+	maxDeletes := defaultBulkDeleteSize
+	if info, infoErr := c.cachedQueryInfo(); infoErr == nil {
+		maxDeletes = info.maxBulkDeletes()
+	}
+	if maxDeletes == 0 {
+		// No bulk delete so spread the objects over the workers
+		batchSize = 1
+	} else if batchSize <= 0 || batchSize > maxDeletes {
+		batchSize = maxDeletes
+	}
+
+	result.Errors = make(map[string]error)
+	var mu sync.Mutex
+	add := func(names []string, batch BulkDeleteResult, batchErr error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.NumberDeleted += batch.NumberDeleted
+		result.NumberNotFound += batch.NumberNotFound
+		for name, objectErr := range batch.Errors {
+			result.Errors[name] = objectErr
+		}
+		if batchErr != nil {
+			if len(batch.Errors) == 0 {
+				// The whole batch failed
+				for _, name := range names {
+					result.Errors[fmt.Sprintf("/%s/%s", container, name)] = batchErr
+				}
+			}
+			if err == nil {
+				err = batchErr
+			}
+		}
+	}
+
+	batches := make(chan []string, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for names := range batches {
+				batch, batchErr := c.BulkDelete(container, names)
+				add(names, batch, batchErr)
+			}
+		}()
+	}
+
+	listOpts := &ObjectsOpts{Prefix: prefix, Limit: allObjectsLimit}
+	listErr := c.ObjectsWalk(container, listOpts, func(opts *ObjectsOpts) (interface{}, error) {
+		names, err := c.ObjectNames(container, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(names); i += batchSize {
+			end := i + batchSize
+			if end > len(names) {
+				end = len(names)
+			}
+			batches <- names[i:end:end]
+		}
+		return names, nil
+	})
+	close(batches)
+	wg.Wait()
+	if listErr != nil {
+		err = listErr
+	}
+	return result, err
+}
+
+// ExpiredObjectsOpts is options for ExpiredObjectsDelete
+type ExpiredObjectsOpts struct {
+	Prefix  string // Only look at objects whose names start with this
+	Workers int    // Number of objects to check at once - 0 for DefaultDeleteWorkers
+	DryRun  bool   // If set find the expired objects but don't delete them
+}
+
+// ExpiredObjectsResult is the result of ExpiredObjectsDelete
+type ExpiredObjectsResult struct {
+	Expired  []string // Names of the objects past their X-Delete-At time
+	NotFound []string // Names of the listed objects which returned 404 without an X-Delete-At
+}
+
+// objectExpired returns whether the object is in the listing but has
+// expired and whether its X-Delete-At could be read.
+//
+// Swift returns 404 for expired objects the object expirer hasn't
+// removed yet, so these are read again with X-Open-Expired which
+// servers with allow_open_expired set honour.  An object which still
+// returns 404 may have been deleted rather than expired.
+func (c *Connection) objectExpired(container string, objectName string, now time.Time) (expired bool, found bool, err error) {
+	_, headers, err := c.Object(container, objectName)
+	if err == ObjectNotFound {
+		_, headers, err = c.ObjectWithOptions(container, objectName, WithHeaders(Headers{"X-Open-Expired": "true"}))
+		if err == ObjectNotFound {
+			return false, false, nil
+		}
+	}
+	if err != nil {
+		return false, true, err
+	}
+	deleteAt, ok := headers.DeleteAt()
+	return ok && !deleteAt.After(now), true, nil
+}
+
+// ExpiredObjectsDelete finds the objects in container which are past
+// their X-Delete-At time but still in the listings because the object
+// expirer hasn't got to them yet, and deletes them.
+//
+// Each listed object is checked with a HEAD request, Workers at a
+// time, so this can take a while on big containers - use the Prefix
+// to limit the objects looked at.  Deleting an expired object returns
+// ObjectNotFound from Swift but removes it from the listings, so that
+// isn't treated as an error.
+//
+// Swift only shows the X-Delete-At of an expired object if it allows
+// X-Open-Expired.  Objects which return 404 without it aren't deleted
+// as they can't be told apart from deleted objects still in the
+// listings - they are returned in NotFound instead.
+//
+// It returns the sorted names of the expired objects found and of the
+// objects not found.  If there were any errors then the first one is
+// returned after all the objects have been looked at.
+//
+// opts may be nil for the defaults.
+func (c *Connection) ExpiredObjectsDelete(container string, opts *ExpiredObjectsOpts) (result ExpiredObjectsResult, err error) {
+	var options ExpiredObjectsOpts
+	if opts != nil {
+		options = *opts
+	}
+	if options.Workers <= 0 {
+		options.Workers = DefaultDeleteWorkers
+	}
+
+	var mu sync.Mutex
+	setErr := func(newErr error) {
+		mu.Lock()
+		if err == nil {
+			err = newErr
+		}
+		mu.Unlock()
+	}
+	now := time.Now()
+	names := make(chan string, options.Workers)
+	var wg sync.WaitGroup
+	for i := 0; i < options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				isExpired, found, checkErr := c.objectExpired(container, name, now)
+				if checkErr != nil {
+					setErr(checkErr)
+					continue
+				}
+				if !found {
+					mu.Lock()
+					result.NotFound = append(result.NotFound, name)
+					mu.Unlock()
+					continue
+				}
+				if !isExpired {
+					continue
+				}
+				if !options.DryRun {
+					deleteErr := c.ObjectDelete(container, name)
+					if deleteErr != nil && deleteErr != ObjectNotFound {
+						setErr(deleteErr)
+						continue
+					}
+				}
+				mu.Lock()
+				result.Expired = append(result.Expired, name)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	listOpts := &ObjectsOpts{Prefix: options.Prefix, Limit: allObjectsLimit}
+	listErr := c.ObjectsWalk(container, listOpts, func(opts *ObjectsOpts) (interface{}, error) {
+		objects, err := c.ObjectNames(container, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range objects {
+			names <- name
+		}
+		return objects, nil
+	})
+	close(names)
+	wg.Wait()
+	if listErr != nil {
+		err = listErr
+	}
+	sort.Strings(result.Expired)
+	sort.Strings(result.NotFound)
+	return result, err
+}
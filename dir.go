@@ -0,0 +1,167 @@
+// Modelling directories with pseudo directories and directory markers
+
+package swift
+
+import (
+	"sort"
+	"strings"
+)
+
+// DirectoryContentType is the Content-Type of the marker objects made
+// by MkDir
+const DirectoryContentType = "application/directory"
+
+// DirNotEmpty is returned by RmDir if the directory has anything in it
+var DirNotEmpty = newError(409, "Directory Not Empty")
+
+// dirPrefix returns the listing prefix for the objects in dir
+func dirPrefix(dir string) string {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// MkDir makes a directory marker object for dir, an empty object
+// named dir with DirectoryContentType, so the directory exists even
+// when there is nothing in it.
+//
+// Directories don't need markers to be listed by ListDir as long as
+// they have objects in them.
+func (c *Connection) MkDir(container string, dir string) error {
+	return c.ObjectPutString(container, strings.Trim(dir, "/"), "", DirectoryContentType)
+}
+
+// IsDir returns whether dir is a directory, either because it has a
+// directory marker object or because there are objects in it.
+func (c *Connection) IsDir(container string, dir string) (bool, error) {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return true, nil
+	}
+	info, _, err := c.Object(container, dir)
+	if err == nil && info.ContentType == DirectoryContentType {
+		return true, nil
+	}
+	if err != nil && err != ObjectNotFound {
+		return false, err
+	}
+	return c.dirHasObjects(container, dir)
+}
+
+// dirHasObjects returns whether there are any objects in dir
+func (c *Connection) dirHasObjects(container string, dir string) (bool, error) {
+	names, err := c.ObjectNames(container, &ObjectsOpts{Prefix: dirPrefix(dir), Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	return len(names) > 0, nil
+}
+
+// ListDirResult is a listing made with a Delimiter split into the
+// real objects and the pseudo directories
+type ListDirResult struct {
+	Objects []Object // The objects, without any pseudo directories
+	SubDirs []string // The common prefixes of the other objects up to and including the Delimiter, eg "dir/subdir/"
+}
+
+// newListDirResult splits a listing into a ListDirResult
+func newListDirResult(listing []Object) *ListDirResult {
+	result := &ListDirResult{}
+	for _, object := range listing {
+		if object.PseudoDirectory {
+			result.SubDirs = append(result.SubDirs, object.Name)
+		} else {
+			result.Objects = append(result.Objects, object)
+		}
+	}
+	return result
+}
+
+// listDirOpts makes a copy of opts if set or makes a new one with
+// Delimiter set to '/' if it wasn't set
+func listDirOpts(opts *ObjectsOpts) *ObjectsOpts {
+	var newOpts ObjectsOpts
+	if opts != nil {
+		newOpts = *opts
+	}
+	if newOpts.Delimiter == 0 {
+		newOpts.Delimiter = '/'
+	}
+	return &newOpts
+}
+
+// ObjectsListDir is like Objects with a Delimiter but it returns the
+// objects and the pseudo directories separately so there is no need
+// to check Object.PseudoDirectory.
+//
+// The Delimiter defaults to '/' if not set in opts.  Like Objects this
+// returns at most one page of the listing.
+func (c *Connection) ObjectsListDir(container string, opts *ObjectsOpts) (*ListDirResult, error) {
+	listing, err := c.Objects(container, listDirOpts(opts))
+	if err != nil {
+		return nil, err
+	}
+	return newListDirResult(listing), nil
+}
+
+// ObjectsListDirAll is like ObjectsListDir but it returns the whole
+// listing.
+func (c *Connection) ObjectsListDirAll(container string, opts *ObjectsOpts) (*ListDirResult, error) {
+	listing, err := c.ObjectsAll(container, listDirOpts(opts))
+	if err != nil {
+		return nil, err
+	}
+	return newListDirResult(listing), nil
+}
+
+// ListDir lists the contents of dir using "/" as the directory
+// separator.  Use "" for the top level of the container.
+//
+// It returns the objects in dir and, separately, the full names of
+// the directories in it, sorted and without a trailing "/".  These
+// are the pseudo directories with objects in them and the directory
+// markers made by MkDir.
+func (c *Connection) ListDir(container string, dir string) (objects []Object, dirs []string, err error) {
+	result, err := c.ObjectsListDirAll(container, &ObjectsOpts{Prefix: dirPrefix(dir), Delimiter: '/'})
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := map[string]bool{}
+	addDir := func(name string) {
+		name = strings.TrimSuffix(name, "/")
+		if !seen[name] {
+			seen[name] = true
+			dirs = append(dirs, name)
+		}
+	}
+	for _, subDir := range result.SubDirs {
+		addDir(subDir)
+	}
+	for _, object := range result.Objects {
+		if object.ContentType == DirectoryContentType {
+			addDir(object.Name)
+		} else {
+			objects = append(objects, object)
+		}
+	}
+	sort.Strings(dirs)
+	return objects, dirs, nil
+}
+
+// RmDir removes the directory marker object for dir.
+//
+// It returns DirNotEmpty if there is anything in the directory and
+// ObjectNotFound if there is no directory marker.
+func (c *Connection) RmDir(container string, dir string) error {
+	dir = strings.Trim(dir, "/")
+	notEmpty, err := c.dirHasObjects(container, dir)
+	if err != nil {
+		return err
+	}
+	if notEmpty {
+		return DirNotEmpty
+	}
+	return c.ObjectDelete(container, dir)
+}
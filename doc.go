@@ -6,6 +6,9 @@ Standard Usage
 Most of the work is done through the Container*() and Object*() methods.
 
 All methods are safe to use concurrently in multiple go routines.
+This includes re-authenticating when the token expires - a single
+Connection can be shared by all the go routines of a program.  Only
+change the parameters of the Connection before it is shared.
 
 Object Versioning
 
@@ -0,0 +1,114 @@
+// Downloading a stream of objects with back-pressure
+
+package swift
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultDownloadInFlight is the number of objects Download has open
+// at once if DownloadOpts.InFlight isn't set
+const DefaultDownloadInFlight = 4
+
+// DownloadOpts is options for Download
+type DownloadOpts struct {
+	InFlight  int     // Maximum number of objects open at once - 0 for DefaultDownloadInFlight
+	CheckHash bool    // If set check the hash of each object as ObjectOpen does
+	Headers   Headers // Additional headers for each request - can be nil
+}
+
+// DownloadResult is an object opened by Download
+type DownloadResult struct {
+	Name     string        // Name of the object
+	Contents io.ReadCloser // Contents of the object - nil if Err is set
+	Headers  Headers       // Headers of the response
+	Err      error         // Error opening the object if any
+}
+
+// downloadFile releases its in flight slot when it is closed
+type downloadFile struct {
+	*ObjectOpenFile
+	once    sync.Once
+	release func()
+}
+
+// Close the object and release the slot
+func (file *downloadFile) Close() error {
+	err := file.ObjectOpenFile.Close()
+	file.once.Do(file.release)
+	return err
+}
+
+// Download opens the objects in container named on the names channel
+// and sends them on the returned channel as they are opened, so a
+// stream of objects can be processed without downloading them all
+// first.
+//
+// At most InFlight objects are open at once.  An object stays open
+// until its Contents is closed, so you must Close the Contents of
+// every result or Download will stop opening objects.  This gives
+// natural back-pressure: if the consumer is slow then Download waits
+// for it rather than opening more objects.
+//
+// Results are sent in the order the objects were opened which isn't
+// necessarily the order of the names.  If an object can't be opened
+// then a result with Err set is sent.
+//
+// Close the names channel when there are no more objects.  The
+// returned channel is closed once all the objects have been sent.
+//
+// opts may be nil for the defaults.
+func (c *Connection) Download(container string, names <-chan string, opts *DownloadOpts) <-chan DownloadResult {
+	inFlight := DefaultDownloadInFlight
+	checkHash := false
+	var h Headers
+	if opts != nil {
+		if opts.InFlight > 0 {
+			inFlight = opts.InFlight
+		}
+		checkHash = opts.CheckHash
+		h = opts.Headers
+	}
+	out := make(chan DownloadResult)
+	slots := make(chan struct{}, inFlight)
+	release := func() {
+		<-slots
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < inFlight; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// Wait for a free slot before taking a name
+				slots <- struct{}{}
+				name, ok := <-names
+				if !ok {
+					release()
+					return
+				}
+				file, headers, err := c.ObjectOpen(container, name, checkHash, h)
+				result := DownloadResult{
+					Name:    name,
+					Headers: headers,
+					Err:     err,
+				}
+				if err != nil {
+					release()
+				} else {
+					result.Contents = &downloadFile{
+						ObjectOpenFile: file,
+						release:        release,
+					}
+				}
+				out <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
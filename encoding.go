@@ -0,0 +1,138 @@
+// Objects stored with a Content-Encoding
+
+package swift
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Headers of the metadata WithGzip stores about the data before it
+// was compressed
+const (
+	UncompressedSizeHeader = "X-Object-Meta-Uncompressed-Size"
+	UncompressedMD5Header  = "X-Object-Meta-Uncompressed-Md5"
+)
+
+// IsGzipped returns true if the object was stored with
+// Content-Encoding: gzip.
+func (h Headers) IsGzipped() bool {
+	return strings.EqualFold(strings.TrimSpace(h["Content-Encoding"]), "gzip")
+}
+
+// WithGzip compresses objects with gzip as they are uploaded and
+// decompresses them as they are downloaded.
+//
+// Uploads are stored with Content-Encoding: gzip and the size and
+// MD5 of the uncompressed data are stored in the object's metadata
+// afterwards.  Any Hash passed in is checked against the uncompressed
+// data.
+//
+// Downloads of objects stored with Content-Encoding: gzip are
+// decompressed as they are read, and the length and MD5 of the result
+// are checked against the metadata if it is present.  Files opened
+// like this can't be seeked.
+func WithGzip() RequestOption {
+	return func(p *RequestOpts) {
+		p.Gzip = true
+	}
+}
+
+// isGzipRequest returns true if options contain WithGzip
+func isGzipRequest(options []RequestOption) bool {
+	var p RequestOpts
+	p.apply(options)
+	return p.Gzip
+}
+
+// gzipRequestHeaders returns a copy of h asking for the stored
+// bytes.  Asking for gzip stops the transport decompressing them
+// itself so they can be checked against the Etag.
+func gzipRequestHeaders(h Headers) Headers {
+	headers := Headers{"Accept-Encoding": "gzip"}
+	for key, value := range h {
+		headers[key] = value
+	}
+	return headers
+}
+
+// gzipUpload compresses the data for an upload, measuring it as it
+// goes.
+type gzipUpload struct {
+	in   io.Reader
+	md5  hash.Hash
+	size int64
+	hash string // expected MD5 of the uncompressed data if set
+}
+
+// newGzipUpload sets up extraHeaders for a compressed upload of data
+// with MD5 hash, which may be empty.  The length and Etag of the
+// data don't apply to the compressed data so are removed.
+func newGzipUpload(extraHeaders Headers, hash string) *gzipUpload {
+	extraHeaders["Content-Encoding"] = "gzip"
+	delete(extraHeaders, "Content-Length")
+	delete(extraHeaders, "Etag")
+	return &gzipUpload{md5: md5.New(), hash: hash}
+}
+
+// Read the uncompressed data - see io.Reader
+func (g *gzipUpload) Read(p []byte) (n int, err error) {
+	n, err = g.in.Read(p)
+	_, _ = g.md5.Write(p[:n])
+	g.size += int64(n)
+	return
+}
+
+// compress returns in compressed with gzip.  It must be closed to
+// stop compressing if it isn't read to the end.
+func (g *gzipUpload) compress(in io.Reader) io.ReadCloser {
+	g.in = in
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pipeWriter)
+		_, err := io.Copy(gz, g)
+		if err == nil {
+			err = gz.Close()
+		}
+		_ = pipeWriter.CloseWithError(err)
+	}()
+	return pipeReader
+}
+
+// metadata checks the MD5 of the uncompressed data read and adds the
+// headers recording it and its size to meta
+func (g *gzipUpload) metadata(meta Headers) error {
+	sum := hex.EncodeToString(g.md5.Sum(nil))
+	if g.hash != "" && !strings.EqualFold(g.hash, sum) {
+		return ObjectCorrupted
+	}
+	meta[UncompressedSizeHeader] = strconv.FormatInt(g.size, 10)
+	meta[UncompressedMD5Header] = sum
+	return nil
+}
+
+// decompress makes file return the contents of a gzipped object
+// decompressed.  The hash is still checked on the compressed bytes
+// and the length and MD5 of the decompressed data are checked
+// against the metadata in headers if set.
+func (file *ObjectOpenFile) decompress(headers Headers) error {
+	gz, err := gzip.NewReader(file.body)
+	if err != nil {
+		return err
+	}
+	file.body = gz
+	file.gzipped = true
+	file.length, err = strconv.ParseInt(headers[UncompressedSizeHeader], 10, 64)
+	file.lengthOk = err == nil
+	if sum := headers[UncompressedMD5Header]; sum != "" && file.checkHash {
+		file.gzipMD5 = md5.New()
+		file.gzipSum = sum
+		file.body = io.TeeReader(gz, file.gzipMD5)
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+// Client side encryption of objects
+
+package swift
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EncryptionKeySize is the size in bytes of the AES-256 keys used by
+// WithEncryption
+const EncryptionKeySize = 32
+
+// Headers of the metadata WithEncryption stores about how an object
+// was encrypted
+const (
+	EncryptionKeyIDHeader   = "X-Object-Meta-Crypto-Key-Id"   // ID of the master key
+	EncryptionDataKeyHeader = "X-Object-Meta-Crypto-Data-Key" // data key wrapped with the master key
+	EncryptionNonceHeader   = "X-Object-Meta-Crypto-Nonce"    // nonce of the data
+)
+
+// The object data is encrypted in chunks of encryptionChunkSize bytes
+// each of which gets encryptionOverhead bytes of authentication tag
+const (
+	encryptionChunkSize = 64 * 1024
+	encryptionOverhead  = 16
+)
+
+// Errors returned when reading encrypted objects
+var (
+	ObjectNotEncrypted   = newError(0, "Object isn't encrypted")
+	UnknownEncryptionKey = newError(0, "Object encrypted with an unknown key")
+)
+
+// Encryption has the master keys to encrypt and decrypt objects with
+// - pass it to WithEncryption.  Make one with NewEncryption or
+// NewKeyringEncryption.
+type Encryption struct {
+	keyID   string      // ID of the master key for new objects
+	master  cipher.AEAD // master key for new objects
+	keyring Keyring     // all the master keys
+}
+
+// NewEncryption makes an Encryption with a single EncryptionKeySize
+// byte master key.  The keyID is stored with each object encrypted so
+// it can be told which key is needed to decrypt it.  It is not
+// secret.
+func NewEncryption(keyID string, key []byte) (*Encryption, error) {
+	return NewKeyringEncryption(StaticKeyring{keyID: key}, keyID)
+}
+
+// NewKeyringEncryption makes an Encryption which encrypts new objects
+// with the master key keyID from keyring, and decrypts objects with
+// whichever key from keyring they were encrypted with.
+func NewKeyringEncryption(keyring Keyring, keyID string) (*Encryption, error) {
+	key, err := keyring.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	master, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Encryption{keyID: keyID, master: master, keyring: keyring}, nil
+}
+
+// newAEAD returns AES-256-GCM with key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, newError(0, "Encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// masterKey returns the master key with keyID
+func (e *Encryption) masterKey(keyID string) (cipher.AEAD, error) {
+	if keyID == e.keyID {
+		return e.master, nil
+	}
+	key, err := e.keyring.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// wrapKey returns dataKey encrypted with the master key for new
+// objects
+func (e *Encryption) wrapKey(dataKey []byte) (string, error) {
+	nonce := make([]byte, e.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	wrapped := e.master.Seal(nonce, nonce, dataKey, []byte(e.keyID))
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrapKey returns the data key wrapped by wrapKey with the master
+// key keyID
+func unwrapKey(master cipher.AEAD, keyID string, wrapped string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(sealed) < master.NonceSize() {
+		return nil, ObjectCorrupted
+	}
+	nonce, sealed := sealed[:master.NonceSize()], sealed[master.NonceSize():]
+	dataKey, err := master.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, ObjectCorrupted
+	}
+	return dataKey, nil
+}
+
+// WithEncryption encrypts objects with e as they are uploaded and
+// decrypts them as they are downloaded, so the contents of the
+// objects and their metadata can't be read by anyone with access to
+// the storage who doesn't have the key.
+//
+// Each object is encrypted with its own random data key which is
+// stored in its metadata wrapped with the master key, along with the
+// ID of the master key, so the master key can be changed without
+// encrypting the data again - see ReEncrypt.  The data is encrypted
+// with AES-256-GCM in chunks of 64k, each authenticated separately,
+// so it can be streamed.  The values of the object's metadata
+// (X-Object-Meta-*) are encrypted too, but not their names, nor the
+// object name, Content-Type, headers added with WithHeaders or the
+// size of the object.  The Etag is the MD5 of the encrypted data and
+// is checked as usual.
+//
+// Objects read with WithEncryption must have been encrypted with it,
+// otherwise ObjectNotEncrypted is returned, and with a master key
+// which the Encryption has, otherwise UnknownEncryptionKey is
+// returned.  Data or metadata which has been tampered with or
+// decrypted with the wrong key gives ObjectCorrupted.  Files opened
+// like this can't be seeked and Range requests aren't supported.
+//
+// ObjectUpdateWithOptions with WithEncryption encrypts the new
+// metadata and keeps the object's encryption headers, reading them
+// with a HEAD request if they aren't in the new headers.
+//
+// This can't be combined with WithGzip and doesn't encrypt the
+// segments of large objects.
+func WithEncryption(e *Encryption) RequestOption {
+	return func(p *RequestOpts) {
+		p.Encryption = e
+	}
+}
+
+// encryptionRequest returns the Encryption set in options or nil
+func encryptionRequest(options []RequestOption) *Encryption {
+	var p RequestOpts
+	p.apply(options)
+	return p.Encryption
+}
+
+// errEncryptionGzip is returned if WithGzip and WithEncryption are
+// both used
+var errEncryptionGzip = newError(0, "Can't use WithGzip and WithEncryption together")
+
+// chunkNonce returns the nonce for chunk n of an object with nonce
+func chunkNonce(nonce []byte, n uint64) []byte {
+	chunk := append([]byte(nil), nonce...)
+	for i := 0; i < 8; i++ {
+		chunk[len(chunk)-1-i] ^= byte(n >> (8 * i))
+	}
+	return chunk
+}
+
+// chunkAdditionalData returns the additional data authenticated with
+// a chunk.  This marks the last chunk so truncation can be detected.
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// chunkReader splits a stream into chunks of size bytes, the last of
+// which may be shorter or empty, reading one byte ahead so it knows
+// which chunk is the last.
+type chunkReader struct {
+	in   io.Reader
+	size int
+	buf  []byte // a chunk and the first byte of the next
+	have int    // bytes in buf
+	done bool   // set once the last chunk has been returned
+}
+
+// newChunkReader returns a chunkReader for in
+func newChunkReader(in io.Reader, size int) *chunkReader {
+	return &chunkReader{in: in, size: size, buf: make([]byte, size+1)}
+}
+
+// next returns the next chunk, valid until the next call, and whether
+// it is the last.  It returns io.EOF after the last chunk.
+func (r *chunkReader) next() (chunk []byte, last bool, err error) {
+	if r.done {
+		return nil, false, io.EOF
+	}
+	if r.have == len(r.buf) {
+		r.buf[0] = r.buf[r.size]
+		r.have = 1
+	}
+	n, err := io.ReadFull(r.in, r.buf[r.have:])
+	r.have += n
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.done = true
+		return r.buf[:r.have], true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return r.buf[:r.size], false, nil
+}
+
+// encryptReader encrypts a stream in chunks
+type encryptReader struct {
+	aead   cipher.AEAD
+	nonce  []byte
+	chunks *chunkReader
+	n      uint64 // number of the next chunk
+	sealed []byte // buffer for the encrypted chunk
+	out    []byte // unread part of sealed
+}
+
+// Read the encrypted stream - see io.Reader
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		chunk, last, err := e.chunks.next()
+		if err != nil {
+			return 0, err
+		}
+		e.sealed = e.aead.Seal(e.sealed[:0], chunkNonce(e.nonce, e.n), chunk, chunkAdditionalData(last))
+		e.out = e.sealed
+		e.n++
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// decryptReader decrypts a stream encrypted by encryptReader
+type decryptReader struct {
+	aead   cipher.AEAD
+	nonce  []byte
+	chunks *chunkReader
+	n      uint64 // number of the next chunk
+	opened []byte // buffer for the decrypted chunk
+	out    []byte // unread part of opened
+}
+
+// Read the decrypted stream - see io.Reader
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		chunk, last, err := d.chunks.next()
+		if err != nil {
+			return 0, err
+		}
+		d.opened, err = d.aead.Open(d.opened[:0], chunkNonce(d.nonce, d.n), chunk, chunkAdditionalData(last))
+		if err != nil {
+			return 0, ObjectCorrupted
+		}
+		d.out = d.opened
+		d.n++
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// decryptedLength returns the length of the data encrypted into
+// length bytes and whether length is possible
+func decryptedLength(length int64) (int64, bool) {
+	const sealedChunkSize = encryptionChunkSize + encryptionOverhead
+	chunks := (length + sealedChunkSize - 1) / sealedChunkSize
+	if chunks == 0 || length-(chunks-1)*sealedChunkSize < encryptionOverhead {
+		return 0, false
+	}
+	return length - chunks*encryptionOverhead, true
+}
+
+// isEncryptedMetadata returns whether the value of the header key is
+// encrypted.  The headers saying how the object was encrypted and the
+// SHA-256 of the encrypted data aren't.
+func isEncryptedMetadata(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	if !strings.HasPrefix(key, "X-Object-Meta-") {
+		return false
+	}
+	switch key {
+	case EncryptionKeyIDHeader, EncryptionDataKeyHeader, EncryptionNonceHeader, SHA256Header:
+		return false
+	}
+	return true
+}
+
+// encryptMetadata returns a copy of h with the metadata values
+// encrypted with aead.  Each is sealed with a random nonce and the
+// header name so values can't be swapped between headers.
+func encryptMetadata(aead cipher.AEAD, h Headers) (Headers, error) {
+	encrypted := make(Headers, len(h))
+	for key, value := range h {
+		if value != "" && isEncryptedMetadata(key) {
+			nonce := make([]byte, aead.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+			sealed := aead.Seal(nonce, nonce, []byte(value), []byte(strings.ToLower(key)))
+			value = base64.StdEncoding.EncodeToString(sealed)
+		}
+		encrypted[key] = value
+	}
+	return encrypted, nil
+}
+
+// decryptMetadata decrypts the metadata values in h with aead in
+// place
+func decryptMetadata(aead cipher.AEAD, h Headers) error {
+	for key, value := range h {
+		if value == "" || !isEncryptedMetadata(key) {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return ObjectCorrupted
+		}
+		nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		opened, err := aead.Open(nil, nonce, sealed, []byte(strings.ToLower(key)))
+		if err != nil {
+			return ObjectCorrupted
+		}
+		h[key] = string(opened)
+	}
+	return nil
+}
+
+// objectKey returns the key the data and metadata of the object with
+// headers were encrypted with and the nonce of its data
+func (e *Encryption) objectKey(headers Headers) (aead cipher.AEAD, nonce []byte, err error) {
+	keyID, ok := headers[EncryptionKeyIDHeader]
+	if !ok {
+		return nil, nil, ObjectNotEncrypted
+	}
+	master, err := e.masterKey(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err = base64.StdEncoding.DecodeString(headers[EncryptionNonceHeader])
+	if err != nil || len(nonce) != master.NonceSize() {
+		return nil, nil, ObjectCorrupted
+	}
+	wrapped, ok := headers[EncryptionDataKeyHeader]
+	if !ok {
+		return nil, nil, ObjectCorrupted
+	}
+	dataKey, err := unwrapKey(master, keyID, wrapped)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err = newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, ObjectCorrupted
+	}
+	return aead, nonce, nil
+}
+
+// encryptHeaders returns the headers of a request to set the metadata
+// of the object to h.  The encryption headers are read from the
+// object if they aren't in h.
+func (c *Connection) encryptHeaders(container string, objectName string, h Headers, e *Encryption, options ...RequestOption) (Headers, error) {
+	if _, ok := h[EncryptionKeyIDHeader]; !ok {
+		_, headers, err := c.objectBase(container, objectName, nil, options...)
+		if err != nil {
+			return nil, err
+		}
+		merged := Headers{}
+		for _, key := range []string{EncryptionKeyIDHeader, EncryptionDataKeyHeader, EncryptionNonceHeader} {
+			if value, ok := headers[key]; ok {
+				merged[key] = value
+			}
+		}
+		for key, value := range h {
+			merged[key] = value
+		}
+		h = merged
+	}
+	aead, _, err := e.objectKey(h)
+	if err != nil {
+		return nil, err
+	}
+	return encryptMetadata(aead, h)
+}
+
+// encryptUpload encrypts the data for an upload
+type encryptUpload struct {
+	aead  cipher.AEAD // the data key
+	nonce []byte
+	md5   hash.Hash // MD5 of the data if checking it
+	hash  string    // expected MD5 of the data
+}
+
+// newUpload sets up extraHeaders for an encrypted upload of data with
+// MD5 hash, which may be empty, with a new data key.  The length and
+// Etag of the data don't apply to the encrypted data so are removed.
+func (e *Encryption) newUpload(extraHeaders Headers, hash string) (*encryptUpload, error) {
+	dataKey := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := e.wrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	extraHeaders[EncryptionKeyIDHeader] = e.keyID
+	extraHeaders[EncryptionDataKeyHeader] = wrapped
+	extraHeaders[EncryptionNonceHeader] = base64.StdEncoding.EncodeToString(nonce)
+	delete(extraHeaders, "Content-Length")
+	delete(extraHeaders, "Etag")
+	u := &encryptUpload{aead: aead, nonce: nonce, hash: hash}
+	if hash != "" {
+		u.md5 = md5.New()
+	}
+	return u, nil
+}
+
+// encryptMetadata returns a copy of h with the metadata values
+// encrypted with the data key
+func (u *encryptUpload) encryptMetadata(h Headers) (Headers, error) {
+	return encryptMetadata(u.aead, h)
+}
+
+// encrypt returns in encrypted
+func (u *encryptUpload) encrypt(in io.Reader) io.Reader {
+	if u.md5 != nil {
+		in = io.TeeReader(in, u.md5)
+	}
+	return &encryptReader{
+		aead:   u.aead,
+		nonce:  u.nonce,
+		chunks: newChunkReader(in, encryptionChunkSize),
+	}
+}
+
+// check checks the MD5 of the data read against the hash passed in
+func (u *encryptUpload) check() error {
+	if u.md5 != nil && !strings.EqualFold(hex.EncodeToString(u.md5.Sum(nil)), u.hash) {
+		return ObjectCorrupted
+	}
+	return nil
+}
+
+// decrypt makes file return the contents of an encrypted object
+// decrypted and decrypts the metadata in headers.  The hash is still
+// checked on the encrypted bytes.
+func (file *ObjectOpenFile) decrypt(e *Encryption, headers Headers) error {
+	aead, nonce, err := e.objectKey(headers)
+	if err != nil {
+		return err
+	}
+	if err = decryptMetadata(aead, headers); err != nil {
+		return err
+	}
+	file.body = &decryptReader{
+		aead:   aead,
+		nonce:  nonce,
+		chunks: newChunkReader(file.body, encryptionChunkSize+encryptionOverhead),
+	}
+	file.decrypted = true
+	if file.lengthOk {
+		file.length, file.lengthOk = decryptedLength(file.length)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Tests for client side encryption
+package swift
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func newTestEncryption(t *testing.T, keyID string, fill byte) *Encryption {
+	t.Helper()
+	enc, err := NewEncryption(keyID, bytes.Repeat([]byte{fill}, EncryptionKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func TestNewEncryptionKeySize(t *testing.T) {
+	_, err := NewEncryption("key", make([]byte, 16))
+	if err == nil {
+		t.Fatal("expecting error for a short key")
+	}
+}
+
+func TestEncryptionStream(t *testing.T) {
+	enc := newTestEncryption(t, "key", 1)
+	for _, size := range []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1, 3 * encryptionChunkSize} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		upload, err := enc.newUpload(Headers{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := ioutil.ReadAll(upload.encrypt(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length, ok := decryptedLength(int64(len(encrypted))); !ok || length != int64(size) {
+			t.Errorf("size %d: decryptedLength got %d, %v", size, length, ok)
+		}
+		decrypt := func(encrypted []byte) ([]byte, error) {
+			return ioutil.ReadAll(&decryptReader{
+				aead:   upload.aead,
+				nonce:  upload.nonce,
+				chunks: newChunkReader(bytes.NewReader(encrypted), encryptionChunkSize+encryptionOverhead),
+			})
+		}
+		decrypted, err := decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("size %d: data differs", size)
+		}
+		// Truncating at a chunk boundary must be detected
+		if size > encryptionChunkSize {
+			_, err = decrypt(encrypted[:encryptionChunkSize+encryptionOverhead])
+			if err != ObjectCorrupted {
+				t.Errorf("size %d: truncated got %v", size, err)
+			}
+		}
+	}
+}
+
+func TestObjectPutWithEncryption(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := newTestEncryption(t, "key1", 1)
+	withEnc := WithEncryption(enc)
+	contents := bytes.Repeat([]byte("secret "), 20000)
+	sum := md5.Sum(contents)
+	h := Metadata{"colour": "red"}.ObjectHeaders()
+	_, err = c.ObjectPutWithOpts("container", "object", bytes.NewReader(contents), &ObjectPutOpts{CheckHash: true, Hash: hex.EncodeToString(sum[:]), ContentType: "text/plain", Headers: h}, withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// What the server has is encrypted
+	stored, err := c.ObjectGetBytes("container", "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("secret")) {
+		t.Error("data stored unencrypted")
+	}
+	_, headers, err := c.Object("container", "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Object-Meta-Colour"] == "red" || headers[EncryptionKeyIDHeader] != "key1" {
+		t.Errorf("bad stored headers %v", headers)
+	}
+
+	// Reading it with the key decrypts it
+	var buf bytes.Buffer
+	headers, err = c.ObjectGetWithOpts("container", "object", &buf, &ObjectGetOpts{CheckHash: true}, withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), contents) {
+		t.Error("decrypted contents differ")
+	}
+	if headers["X-Object-Meta-Colour"] != "red" {
+		t.Errorf("metadata got %q", headers["X-Object-Meta-Colour"])
+	}
+	info, headers, err := c.ObjectWithOptions("container", "object", withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Bytes != int64(len(contents)) || headers["X-Object-Meta-Colour"] != "red" {
+		t.Errorf("Object got %d bytes, metadata %q", info.Bytes, headers["X-Object-Meta-Colour"])
+	}
+
+	// Updating the metadata keeps the encryption headers
+	err = c.ObjectUpdateWithOptions("container", "object", Metadata{"colour": "blue"}.ObjectHeaders(), withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.ObjectWithOptions("container", "object", withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Object-Meta-Colour"] != "blue" {
+		t.Errorf("updated metadata got %q", headers["X-Object-Meta-Colour"])
+	}
+
+	// Streaming uploads are encrypted too
+	file, err := c.ObjectCreateWithOpts("container", "created", &ObjectPutOpts{CheckHash: true}, withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.Write(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := c.ObjectOpenWithOpts("container", "created", &ObjectGetOpts{CheckHash: true}, withEnc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = got.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, contents) {
+		t.Error("created contents differ")
+	}
+	if _, err = got.Seek(0, 0); err == nil {
+		t.Error("expecting error seeking")
+	}
+
+	// The wrong keys and unencrypted objects are errors
+	_, err = c.ObjectGetBytesWithOptions("container", "object", WithEncryption(newTestEncryption(t, "key2", 1)))
+	if err != UnknownEncryptionKey {
+		t.Errorf("other key ID got %v", err)
+	}
+	_, err = c.ObjectGetBytesWithOptions("container", "object", WithEncryption(newTestEncryption(t, "key1", 2)))
+	if err != ObjectCorrupted {
+		t.Errorf("wrong key got %v", err)
+	}
+	err = c.ObjectPutString("container", "plain", "plain", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ObjectGetBytesWithOptions("container", "plain", withEnc)
+	if err != ObjectNotEncrypted {
+		t.Errorf("plain object got %v", err)
+	}
+	_, err = c.ObjectPutWithOpts("container", "object", bytes.NewReader(contents), &ObjectPutOpts{CheckHash: true}, withEnc, WithGzip())
+	if err != errEncryptionGzip {
+		t.Errorf("gzip got %v", err)
+	}
+}
+
+func TestReEncrypt(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key1 := bytes.Repeat([]byte{1}, EncryptionKeySize)
+	key2 := bytes.Repeat([]byte{2}, EncryptionKeySize)
+	old, err := NewEncryption("key1", key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := bytes.Repeat([]byte("secret "), 20000)
+	for name, colour := range map[string]string{"red": "red", "green": "green"} {
+		h := Metadata{"colour": colour}.ObjectHeaders()
+		_, err = c.ObjectPutWithOpts("container", name, bytes.NewReader(contents), &ObjectPutOpts{CheckHash: true, Headers: h}, WithEncryption(old))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = c.ObjectPutString("container", "plain", "plain", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotate, err := NewKeyringEncryption(StaticKeyring{"key1": key1, "key2": key2}, "key2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ReEncrypt("container", rotate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The objects can now be read with only the new key
+	current, err := NewEncryption("key2", key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, colour := range map[string]string{"red": "red", "green": "green"} {
+		var buf bytes.Buffer
+		headers, err := c.ObjectGetWithOpts("container", name, &buf, &ObjectGetOpts{CheckHash: true}, WithEncryption(current))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(buf.Bytes(), contents) {
+			t.Errorf("%s: contents differ", name)
+		}
+		if headers["X-Object-Meta-Colour"] != colour {
+			t.Errorf("%s: metadata got %q", name, headers["X-Object-Meta-Colour"])
+		}
+		if headers[EncryptionKeyIDHeader] != "key2" || headers[EncryptionDataKeyHeader] == "" {
+			t.Errorf("%s: bad headers %v", name, headers)
+		}
+	}
+	_, err = c.ObjectGetBytesWithOptions("container", "red", WithEncryption(old))
+	if err != UnknownEncryptionKey {
+		t.Errorf("old key got %v", err)
+	}
+	plain, err := c.ObjectGetString("container", "plain")
+	if err != nil || plain != "plain" {
+		t.Errorf("plain object got %q, %v", plain, err)
+	}
+}
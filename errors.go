@@ -0,0 +1,72 @@
+// Classifying errors
+
+package swift
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrorStatusCode returns the HTTP status code of err if it is or
+// wraps an *Error, or 0 if it doesn't.
+func ErrorStatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound returns whether err means the container or object
+// wasn't found, eg ObjectNotFound or ContainerNotFound, even if it is
+// wrapped.
+func IsNotFound(err error) bool {
+	return ErrorStatusCode(err) == 404
+}
+
+// IsAuthorizationFailed returns whether err means the credentials or
+// token weren't accepted, eg AuthorizationFailed, even if it is
+// wrapped.
+func IsAuthorizationFailed(err error) bool {
+	return ErrorStatusCode(err) == 401
+}
+
+// IsForbidden returns whether err means the user isn't allowed to do
+// the operation, eg Forbidden, even if it is wrapped.
+func IsForbidden(err error) bool {
+	return ErrorStatusCode(err) == 403
+}
+
+// IsConflict returns whether err means the operation conflicts with
+// the state of the resource, eg ContainerNotEmpty, even if it is
+// wrapped.
+func IsConflict(err error) bool {
+	return ErrorStatusCode(err) == 409
+}
+
+// IsPreconditionFailed returns whether err means a condition of the
+// request wasn't met, eg PreconditionFailed or ObjectExists, even if
+// it is wrapped.
+func IsPreconditionFailed(err error) bool {
+	return ErrorStatusCode(err) == 412
+}
+
+// IsRateLimited returns whether err means the server is rate
+// limiting requests, eg RateLimit or TooManyRequests, even if it is
+// wrapped.
+func IsRateLimited(err error) bool {
+	status := ErrorStatusCode(err)
+	return status == 429 || status == 498
+}
+
+// IsTimeout returns whether err is a timeout, eg TimeoutError, a
+// network timeout or an expired context deadline, even if it is
+// wrapped.
+func IsTimeout(err error) bool {
+	if ErrorStatusCode(err) == 408 || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,68 @@
+// Tests for classifying errors
+package swift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestErrorWrapping(t *testing.T) {
+	wrapped := fmt.Errorf("deleting backup: %w", ObjectNotFound)
+	if !errors.Is(wrapped, ObjectNotFound) {
+		t.Error("Expecting wrapped ObjectNotFound to match")
+	}
+	if errors.Is(wrapped, ContainerNotFound) {
+		t.Error("Expecting wrapped ObjectNotFound not to match ContainerNotFound")
+	}
+
+	_, cause := strconv.Atoi("potato")
+	err := wrapErrorf(cause, 0, "bad number: %v", cause)
+	if err.Error() != `bad number: strconv.Atoi: parsing "potato": invalid syntax` {
+		t.Errorf("Bad error %q", err)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Error("Expecting cause to be preserved")
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || numErr.Func != "Atoi" {
+		t.Error("Expecting errors.As to find cause")
+	}
+}
+
+func TestErrorPredicates(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		is   func(error) bool
+		want bool
+	}{
+		{ObjectNotFound, IsNotFound, true},
+		{fmt.Errorf("context: %w", ContainerNotFound), IsNotFound, true},
+		{newErrorf(404, "HTTP Error: 404"), IsNotFound, true},
+		{Forbidden, IsNotFound, false},
+		{errors.New("404"), IsNotFound, false},
+		{nil, IsNotFound, false},
+		{AuthorizationFailed, IsAuthorizationFailed, true},
+		{Forbidden, IsForbidden, true},
+		{ContainerNotEmpty, IsConflict, true},
+		{ObjectExists, IsPreconditionFailed, true},
+		{PreconditionFailed, IsPreconditionFailed, true},
+		{RateLimit, IsRateLimited, true},
+		{TooManyRequests, IsRateLimited, true},
+		{BadRequest, IsRateLimited, false},
+		{TimeoutError, IsTimeout, true},
+		{fmt.Errorf("reading: %w", context.DeadlineExceeded), IsTimeout, true},
+		{&net.DNSError{IsTimeout: true}, IsTimeout, true},
+		{&net.DNSError{}, IsTimeout, false},
+	} {
+		if got := test.is(test.err); got != test.want {
+			t.Errorf("%v: want %v got %v", test.err, test.want, got)
+		}
+	}
+	if got := ErrorStatusCode(fmt.Errorf("x: %w", TooLargeObject)); got != 413 {
+		t.Errorf("Bad status code %d", got)
+	}
+}
@@ -4,7 +4,10 @@
 package swift_test
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"fmt"
+	"io"
 
 	"github.com/ncw/swift"
 )
@@ -107,3 +110,44 @@ func ExampleConnection_VersionDisable() {
 	// Disable versioning on a container.  Note that this does not delete the versioning container.
 	c.VersionDisable("movies")
 }
+
+func ExampleConnection_BulkUpload() {
+	c, rollback := makeConnection(nil)
+	defer rollback()
+
+	files := map[string]string{
+		"photos/1.txt": "first",
+		"photos/2.txt": "second",
+	}
+
+	// Stream a tar.gz archive to the server as it is made
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		tw := tar.NewWriter(gz)
+		for name, contents := range files {
+			err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents))})
+			if err == nil {
+				_, err = io.WriteString(tw, contents)
+			}
+			if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		err := tw.Close()
+		if err == nil {
+			err = gz.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	result, err := c.BulkUpload("backup", pr, swift.UploadTarGzip, nil)
+	if err != nil {
+		fmt.Println("Upload failed", err)
+	}
+	fmt.Println("Created", result.NumberCreated, "objects")
+	for name, err := range result.Errors {
+		fmt.Println("Failed to create", name, err)
+	}
+}
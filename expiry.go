@@ -0,0 +1,57 @@
+// Expiring objects
+
+package swift
+
+import (
+	"strconv"
+	"time"
+)
+
+// deleteAtHeaders returns the headers to make an object expire at
+// deleteAt or deleteAfter from now, or nil if both are zero.
+//
+// deleteAfter is converted to an absolute time so that objects made
+// of several uploads, such as large objects and their segments, all
+// expire together.  If both are set deleteAt takes precedence.
+func deleteAtHeaders(deleteAt time.Time, deleteAfter time.Duration) Headers {
+	if deleteAt.IsZero() {
+		if deleteAfter <= 0 {
+			return nil
+		}
+		deleteAt = time.Now().Add(deleteAfter)
+	}
+	return Headers{"X-Delete-At": strconv.FormatInt(deleteAt.Unix(), 10)}
+}
+
+// SetDeleteAt sets the header to make the object expire at t.
+//
+// Use this in the headers passed to ObjectPut, ObjectCreate or
+// ObjectUpdate.
+func (h Headers) SetDeleteAt(t time.Time) {
+	delete(h, "X-Delete-After")
+	h["X-Delete-At"] = strconv.FormatInt(t.Unix(), 10)
+}
+
+// SetDeleteAfter sets the header to make the object expire d after it
+// is uploaded or updated.  d is rounded up to a whole number of
+// seconds.
+//
+// Use this in the headers passed to ObjectPut, ObjectCreate or
+// ObjectUpdate.
+func (h Headers) SetDeleteAfter(d time.Duration) {
+	delete(h, "X-Delete-At")
+	seconds := (d + time.Second - 1) / time.Second
+	h["X-Delete-After"] = strconv.FormatInt(int64(seconds), 10)
+}
+
+// DeleteAt returns the time the object expires as read from the
+// X-Delete-At header.
+//
+// ok is false if the object doesn't expire or the header is invalid.
+func (h Headers) DeleteAt() (t time.Time, ok bool) {
+	t, err := ParseEpochTime(h["X-Delete-At"])
+	if err != nil {
+		return t, false
+	}
+	return t, true
+}
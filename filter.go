@@ -0,0 +1,111 @@
+// Filtering object listings by metadata
+
+package swift
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultFilterWorkers is the number of HEAD requests ObjectsFilter
+// runs at once if MetadataFilterOpts.Workers isn't set
+const DefaultFilterWorkers = 8
+
+// MetadataMatchFn returns whether an object with headers, as returned
+// by Object, should be passed on by ObjectsFilter
+type MetadataMatchFn func(object *Object, headers Headers) bool
+
+// MetadataEquals returns a MetadataMatchFn which matches objects
+// whose metadata key, eg "state" for X-Object-Meta-State, has value.
+func MetadataEquals(key string, value string) MetadataMatchFn {
+	key = strings.ToLower(key)
+	return func(object *Object, headers Headers) bool {
+		actual, ok := headers.ObjectMetadata()[key]
+		return ok && actual == value
+	}
+}
+
+// MetadataFilterOpts is options for ObjectsFilter
+type MetadataFilterOpts struct {
+	Workers int             // Number of HEAD requests to run at once - 0 for DefaultFilterWorkers
+	Match   MetadataMatchFn // Objects are passed on if this returns true - nil to pass on all of them
+}
+
+// filterResult is the result of the HEAD of one object
+type filterResult struct {
+	headers Headers
+	err     error
+}
+
+// ObjectsFilter calls fn with each object in container listed with
+// opts, and its headers, whose metadata is matched by filter.Match.
+//
+// Swift can't filter listings by metadata so each object listed is
+// read with a HEAD request, Workers at a time.  Objects are passed to
+// fn in listing order from one go routine.  Objects deleted since
+// they were listed are skipped.
+//
+// If fn returns an error the walk stops and the error is returned,
+// unless it is StopWalk in which case nil is returned.
+//
+// opts and filter may be nil for the defaults.
+func (c *Connection) ObjectsFilter(container string, opts *ObjectsOpts, filter *MetadataFilterOpts, fn func(object *Object, headers Headers) error) error {
+	workers := DefaultFilterWorkers
+	var match MetadataMatchFn
+	if filter != nil {
+		if filter.Workers > 0 {
+			workers = filter.Workers
+		}
+		match = filter.Match
+	}
+	return c.ObjectsWalk(container, opts, func(opts *ObjectsOpts) (interface{}, error) {
+		objects, err := c.Objects(container, opts)
+		if err != nil {
+			return nil, err
+		}
+		results := c.objectsHead(container, objects, workers)
+		for i := range objects {
+			object, result := &objects[i], results[i]
+			if result.err == ObjectNotFound || object.PseudoDirectory {
+				continue
+			}
+			if result.err != nil {
+				return nil, result.err
+			}
+			if match != nil && !match(object, result.headers) {
+				continue
+			}
+			err = fn(object, result.headers)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return objects, nil
+	})
+}
+
+// objectsHead reads the headers of objects with workers HEAD requests
+// at once, returning the results in the same order
+func (c *Connection) objectsHead(container string, objects []Object, workers int) []filterResult {
+	results := make([]filterResult, len(objects))
+	in := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range in {
+				if objects[i].PseudoDirectory {
+					continue
+				}
+				_, results[i].headers, results[i].err = c.Object(container, objects[i].Name)
+			}
+		}()
+	}
+	for i := range objects {
+		in <- i
+	}
+	close(in)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,248 @@
+// Uploads using the formpost middleware
+
+package swift
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormPostOpts describes the form used to upload objects with the
+// formpost middleware.
+//
+// The same values must be used when making the signature with
+// FormPostSignature and when uploading with FormPost.
+type FormPostOpts struct {
+	Redirect     string    // URL to redirect to after the upload, may be empty
+	MaxFileSize  int64     // Maximum size in bytes of each file uploaded
+	MaxFileCount int       // Maximum number of files which may be uploaded
+	Expires      time.Time // Time after which the form is no longer valid
+}
+
+// FormPostFile is a file to be uploaded with FormPost.
+type FormPostFile struct {
+	Name     string    // Name of the file - this is appended to the prefix to make the object name
+	Contents io.Reader // Contents of the file
+}
+
+// formPostPath returns the URL and the path used in the signature
+// for a formpost to container and prefix.
+//
+// If the StorageUrl isn't set then the Connection is authenticated to
+// find it.
+func (c *Connection) formPostPath(container string, prefix string) (postUrl string, postPath string, err error) {
+	c.initAuthLock()
+	storageUrl, err := c.accountStorageUrl("")
+	if err != nil {
+		return "", "", err
+	}
+	if storageUrl == "" {
+		if err = c.Authenticate(); err != nil {
+			return "", "", err
+		}
+		if storageUrl, err = c.accountStorageUrl(""); err != nil {
+			return "", "", err
+		}
+	}
+	u, err := url.Parse(storageUrl)
+	if err != nil {
+		return "", "", err
+	}
+	postPath = u.Path + "/" + container + "/" + prefix
+	return storageUrl + "/" + urlPathEscape(container+"/"+prefix), postPath, nil
+}
+
+// formPostSignature computes the signature of the form for path
+func formPostSignature(postPath string, secretKey string, opts *FormPostOpts) string {
+	mac := hmac.New(sha1.New, []byte(secretKey))
+	body := fmt.Sprintf("%s\n%s\n%d\n%d\n%d", postPath, opts.Redirect, opts.MaxFileSize, opts.MaxFileCount, opts.Expires.Unix())
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// FormPostSignature returns the signature to use in an HTML form
+// which uploads objects to container with names starting with prefix.
+//
+// secretKey should be the X-Account-Meta-Temp-Url-Key or
+// X-Container-Meta-Temp-Url-Key set on the account or container.
+func (c *Connection) FormPostSignature(container string, prefix string, secretKey string, opts *FormPostOpts) (string, error) {
+	_, postPath, err := c.formPostPath(container, prefix)
+	if err != nil {
+		return "", err
+	}
+	return formPostSignature(postPath, secretKey, opts), nil
+}
+
+// An io.Reader which returns TooLargeObject if more than limit bytes are read
+type formPostLimitReader struct {
+	reader io.Reader
+	limit  int64
+	n      int64
+}
+
+// Read reads up to len(p) bytes into p
+func (r *formPostLimitReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	if r.limit > 0 && r.n > r.limit {
+		return n, TooLargeObject
+	}
+	return n, err
+}
+
+// writeFormPost writes the multipart form for the upload into w
+func writeFormPost(w *multipart.Writer, signature string, opts *FormPostOpts, files []FormPostFile) error {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"redirect", opts.Redirect},
+		{"max_file_size", strconv.FormatInt(opts.MaxFileSize, 10)},
+		{"max_file_count", strconv.Itoa(opts.MaxFileCount)},
+		{"expires", strconv.FormatInt(opts.Expires.Unix(), 10)},
+		{"signature", signature},
+	}
+	for _, field := range fields {
+		if err := w.WriteField(field.name, field.value); err != nil {
+			return err
+		}
+	}
+	for i, file := range files {
+		part, err := w.CreateFormFile(fmt.Sprintf("file%d", i+1), file.Name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, &formPostLimitReader{reader: file.Contents, limit: opts.MaxFileSize})
+		if err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// parseFormPostResponse turns the response to a form post into an
+// error.
+//
+// If a redirect was requested then the status and message are read
+// from the query parameters of the redirect, otherwise from the
+// response itself.
+func parseFormPostResponse(resp *http.Response, redirect string) error {
+	status := resp.StatusCode
+	message := ""
+	var body []byte
+	if redirect != "" && status >= 300 && status <= 399 {
+		location, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			return err
+		}
+		query := location.Query()
+		status, err = strconv.Atoi(query.Get("status"))
+		if err != nil {
+			return newErrorf(0, "Bad status in formpost redirect %q", resp.Header.Get("Location"))
+		}
+		message = query.Get("message")
+	} else if status < 200 || status > 299 {
+		body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		message = string(body)
+	}
+	if status < 200 || status > 299 {
+		if message == "" {
+			message = http.StatusText(status)
+		}
+		err := newResponseError(resp, "Form post failed: %d: %s", status, strings.TrimSpace(message))
+		err.StatusCode = status
+		if len(body) > 0 {
+			err.Body = body
+		}
+		return err
+	}
+	return nil
+}
+
+// FormPost uploads files to container using the formpost middleware.
+//
+// Each file will be stored in an object named prefix followed by the
+// file's Name.  This doesn't use the auth token - the upload is
+// authorized by a signature made with secretKey, which should be the
+// X-Account-Meta-Temp-Url-Key or X-Container-Meta-Temp-Url-Key set
+// on the account or container.  The Connection is authenticated if
+// its StorageUrl isn't set.
+//
+// The ConnectTimeout and Timeout of the Connection apply as they do to
+// other requests.
+//
+// If opts.Redirect is set then the redirect returned by the server is
+// not followed, instead the status it carries is returned.
+//
+// The limits in opts are checked as the files are sent and
+// TooLargeObject will be returned if a file is bigger than
+// MaxFileSize.
+//
+// Some servers may not support the formpost middleware - these will
+// normally return an error with StatusCode 401.
+func (c *Connection) FormPost(container string, prefix string, secretKey string, opts *FormPostOpts, files []FormPostFile) (err error) {
+	if opts.MaxFileCount > 0 && len(files) > opts.MaxFileCount {
+		return newErrorf(400, "Form post failed: too many files: %d > %d", len(files), opts.MaxFileCount)
+	}
+	c.initAuthLock()
+	c.authLock.Lock()
+	c.setDefaults()
+	c.authLock.Unlock()
+	postUrl, postPath, err := c.formPostPath(container, prefix)
+	if err != nil {
+		return err
+	}
+	signature := formPostSignature(postPath, secretKey, opts)
+
+	pipeReader, pipeWriter := io.Pipe()
+	form := multipart.NewWriter(pipeWriter)
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeFormPost(form, signature, opts, files)
+		_ = pipeWriter.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	timer := time.NewTimer(c.ConnectTimeout)
+	defer timer.Stop()
+	req, err := http.NewRequest("POST", postUrl, newWatchdogReader(pipeReader, c.Timeout, timer))
+	if err != nil {
+		_ = pipeReader.CloseWithError(err)
+		<-writeErr
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", c.UserAgent)
+	client := &http.Client{
+		Transport: c.Transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := c.doTimeoutRequestWithClient(client, timer, req)
+	_ = pipeReader.Close()
+	if werr := <-writeErr; werr != nil && werr != io.ErrClosedPipe {
+		if resp != nil {
+			drainAndClose(resp.Body, nil)
+		}
+		return werr
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body = newTimeoutReader(resp.Body, c.Timeout, func() {
+		c.cancelRequest(req)
+	})
+	defer drainAndClose(resp.Body, &err)
+	return parseFormPostResponse(resp, opts.Redirect)
+}
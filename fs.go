@@ -0,0 +1,276 @@
+// A read-only io/fs.FS of the objects in a container
+
+package swift
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is a read-only fs.FS of the objects in a container which can be
+// used with fs.WalkDir, http.FS, template.ParseFS etc.  Make one with
+// Connection.FS.
+//
+// Object names are split into directories at each "/".  Directories
+// exist if they have objects in them or a marker made by MkDir.
+//
+// Files opened from it implement io.Seeker as well as fs.File.
+type FS struct {
+	c         *Connection
+	container string
+}
+
+// FS returns a read-only fs.FS of the objects in container
+func (c *Connection) FS(container string) *FS {
+	return &FS{c: c, container: container}
+}
+
+// fsError converts err into an *fs.PathError for op on name
+func fsError(op string, name string, err error) error {
+	if err == ObjectNotFound || err == ContainerNotFound {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// fileInfo describes an object or a directory.  It is both an
+// fs.FileInfo and an fs.DirEntry.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+// newObjectInfo describes object
+func newObjectInfo(object *Object) *fileInfo {
+	return &fileInfo{
+		name:    path.Base(object.Name),
+		size:    object.Bytes,
+		modTime: object.LastModified,
+	}
+}
+
+// newDirInfo describes the directory dir.  Directories have no
+// modification time.
+func newDirInfo(dir string) *fileInfo {
+	return &fileInfo{name: path.Base(dir), dir: true}
+}
+
+func (fi *fileInfo) Name() string               { return fi.name }
+func (fi *fileInfo) Size() int64                { return fi.size }
+func (fi *fileInfo) ModTime() time.Time         { return fi.modTime }
+func (fi *fileInfo) IsDir() bool                { return fi.dir }
+func (fi *fileInfo) Sys() interface{}           { return nil }
+func (fi *fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+// Mode returns read-only permissions for the object or directory
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// isDirMarker returns whether the object is a marker made by MkDir
+func isDirMarker(object *Object) bool {
+	return object.ContentType == DirectoryContentType
+}
+
+// stat describes name which must be a valid path other than "."
+func (f *FS) stat(op string, name string) (*fileInfo, error) {
+	object, _, err := f.c.Object(f.container, name)
+	if err == nil {
+		if isDirMarker(&object) {
+			return newDirInfo(name), nil
+		}
+		return newObjectInfo(&object), nil
+	}
+	if err != ObjectNotFound {
+		return nil, fsError(op, name, err)
+	}
+	isDir, err := f.c.dirHasObjects(f.container, name)
+	if err != nil {
+		return nil, fsError(op, name, err)
+	}
+	if !isDir {
+		return nil, fsError(op, name, fs.ErrNotExist)
+	}
+	return newDirInfo(name), nil
+}
+
+// Stat describes the object or directory name - see fs.StatFS
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, fsError("stat", name, fs.ErrInvalid)
+	}
+	if name == "." {
+		return newDirInfo("."), nil
+	}
+	fi, err := f.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// ReadDir returns the contents of the directory name sorted by name -
+// see fs.ReadDirFS
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, fsError("readdir", name, fs.ErrInvalid)
+	}
+	dir := name
+	if dir == "." {
+		dir = ""
+	}
+	objects, dirs, err := f.c.ListDir(f.container, dir)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	if dir != "" && len(objects) == 0 && len(dirs) == 0 {
+		// Check it is a directory and not a file or nothing
+		fi, err := f.stat("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.dir {
+			return nil, fsError("readdir", name, errors.New("not a directory"))
+		}
+	}
+	entries := make([]fs.DirEntry, 0, len(objects)+len(dirs))
+	for _, subDir := range dirs {
+		entries = append(entries, newDirInfo(subDir))
+	}
+	for i := range objects {
+		if base := path.Base(objects[i].Name); fs.ValidPath(base) && base != "." && !strings.HasSuffix(objects[i].Name, "/") {
+			entries = append(entries, newObjectInfo(&objects[i]))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Open opens the object or directory name for reading - see fs.FS
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, fsError("open", name, fs.ErrInvalid)
+	}
+	if name == "." {
+		return &fsDir{fs: f, name: name, info: newDirInfo(name)}, nil
+	}
+	file, headers, err := f.c.ObjectOpen(f.container, name, true, nil)
+	if err == nil {
+		if headers["Content-Type"] != DirectoryContentType {
+			info, err := file.headersInfo(headers)
+			if err != nil {
+				_ = file.Close()
+				return nil, fsError("open", name, err)
+			}
+			return &fsFile{file: file, info: info}, nil
+		}
+		_ = file.Close()
+		return &fsDir{fs: f, name: name, info: newDirInfo(name)}, nil
+	}
+	if err != ObjectNotFound {
+		return nil, fsError("open", name, err)
+	}
+	fi, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.dir {
+		// The object appeared after the GET
+		return nil, fsError("open", name, fs.ErrNotExist)
+	}
+	return &fsDir{fs: f, name: name, info: fi}, nil
+}
+
+// headersInfo describes the object file was opened on from the
+// headers of the response
+func (file *ObjectOpenFile) headersInfo(headers Headers) (*fileInfo, error) {
+	object := Object{Name: file.objectName}
+	size, err := file.Length()
+	if err != nil {
+		return nil, err
+	}
+	object.Bytes = size
+	if t, ok := headers.Timestamp(); ok {
+		object.LastModified = t
+	} else if t, ok := headers.LastModified(); ok {
+		object.LastModified = t
+	}
+	return newObjectInfo(&object), nil
+}
+
+// fsFile is an object opened by FS.Open
+type fsFile struct {
+	file *ObjectOpenFile
+	info *fileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error)                   { return f.info, nil }
+func (f *fsFile) Read(p []byte) (int, error)                   { return f.file.Read(p) }
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) { return f.file.Seek(offset, whence) }
+func (f *fsFile) Close() error                                 { return f.file.Close() }
+
+// fsDir is a directory opened by FS.Open
+type fsDir struct {
+	fs      *FS
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *fsDir) Close() error               { return nil }
+
+// Read returns an error as d is a directory
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, fsError("read", d.name, errors.New("is a directory"))
+}
+
+// ReadDir returns the next n entries of the directory, or all the
+// rest if n <= 0 - see fs.ReadDirFile
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// Check it satisfies the interfaces
+var (
+	_ fs.FS          = &FS{}
+	_ fs.ReadDirFS   = &FS{}
+	_ fs.StatFS      = &FS{}
+	_ fs.ReadDirFile = &fsDir{}
+	_ io.Seeker      = &fsFile{}
+)
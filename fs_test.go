@@ -0,0 +1,76 @@
+// Tests for the io/fs.FS of a container
+package swift
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestFS(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		err = c.ObjectPutString("container", name, "contents of "+name, "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = c.MkDir("container", "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := c.FS("container")
+
+	err = fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.txt", "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "dir/sub/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "contents of dir/sub/c.txt" {
+		t.Errorf("Bad contents %q", data)
+	}
+	fi, err := fs.Stat(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() || fi.Name() != "dir" {
+		t.Errorf("Bad dir info %v %q", fi.IsDir(), fi.Name())
+	}
+	for _, name := range []string{"missing", "dir/missing", "empty/missing"} {
+		_, err = fsys.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expecting fs.ErrNotExist got %v", name, err)
+		}
+	}
+	if _, err = fsys.Open("/a.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expecting fs.ErrInvalid got %v", err)
+	}
+	if _, err = fs.ReadDir(fsys, "a.txt"); err == nil {
+		t.Error("Expecting error reading a file as a directory")
+	}
+	f, err := fsys.Open("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ioutil.ReadAll(f); err == nil {
+		t.Error("Expecting error reading a directory")
+	}
+	_ = f.Close()
+}
@@ -1 +1,3 @@
 module github.com/ncw/swift
+
+go 1.16
@@ -0,0 +1,43 @@
+// An http.FileSystem for serving a container with http.FileServer
+
+package swift
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+// FileSystem returns an http.FileSystem of the objects in container
+// under prefix, so a static site in Swift can be served with
+//
+//	http.Handle("/", http.FileServer(c.FileSystem("site", "www")))
+//
+// A prefix of "" serves the whole container.  Object names are split
+// into directories at each "/" as in FS.  Directories with an
+// "index.html" object are served with it, and the others get an index
+// made from a delimiter listing of the directory.  Files can be
+// seeked so Range requests work.
+func (c *Connection) FileSystem(container string, prefix string) http.FileSystem {
+	var fsys fs.FS = c.FS(container)
+	// Clean the prefix into a valid path for fs.Sub
+	prefix = path.Clean("/" + prefix)[1:]
+	if prefix != "" {
+		sub, err := fs.Sub(fsys, prefix)
+		if err != nil {
+			return http.FS(errorFS{err: err})
+		}
+		fsys = sub
+	}
+	return http.FS(fsys)
+}
+
+// errorFS is an fs.FS which fails to open anything with err
+type errorFS struct {
+	err error
+}
+
+// Open returns the error - see fs.FS
+func (e errorFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: e.err}
+}
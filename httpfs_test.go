@@ -0,0 +1,87 @@
+// Tests for the http.FileSystem of a container
+package swift
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestFileSystem(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := map[string]string{
+		"outside.txt":         "not served",
+		"www/index.html":      "<p>home</p>",
+		"www/style.css":       "body {}",
+		"www/docs/guide.txt":  "0123456789",
+		"www/docs/notes.txt":  "notes",
+		"www/docs/sub/a.html": "a",
+	}
+	for name, contents := range objects {
+		err = c.ObjectPutString("container", name, contents, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	web := httptest.NewServer(http.FileServer(c.FileSystem("container", "/www/")))
+	defer web.Close()
+
+	get := func(p string, h map[string]string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", web.URL+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range h {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := get("/", nil); status != http.StatusOK || body != "<p>home</p>" {
+		t.Errorf("index: got %d %q", status, body)
+	}
+	if status, body := get("/style.css", nil); status != http.StatusOK || body != "body {}" {
+		t.Errorf("file: got %d %q", status, body)
+	}
+	status, body := get("/docs/", nil)
+	if status != http.StatusOK {
+		t.Fatalf("listing: got %d %q", status, body)
+	}
+	for _, want := range []string{`href="guide.txt"`, `href="notes.txt"`, `href="sub/"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("listing: %q not in %q", want, body)
+		}
+	}
+	if status, body := get("/docs/guide.txt", map[string]string{"Range": "bytes=3-5"}); status != http.StatusPartialContent || body != "345" {
+		t.Errorf("range: got %d %q", status, body)
+	}
+	if status, _ := get("/outside.txt", nil); status != http.StatusNotFound {
+		t.Errorf("outside prefix: got %d", status)
+	}
+	if status, _ := get("/missing.txt", nil); status != http.StatusNotFound {
+		t.Errorf("missing: got %d", status)
+	}
+}
@@ -0,0 +1,269 @@
+// An interface for mocking and wrapping Connection
+
+package swift
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Interface has all the public methods of Connection.
+//
+// Use it in place of *Connection so a mock can be substituted in
+// tests, or a wrapper, eg one which caches or instruments the calls.
+// Methods will be added to it as they are added to Connection, so
+// embed Interface in your own implementations to keep them
+// compiling.
+type Interface interface {
+	// Authentication, accounts, containers and objects
+	ApplyEnvironment() (err error)
+	Authenticate() (err error)
+	AuthenticateContext(ctx context.Context) (err error)
+	UnAuthenticate()
+	CurrentAuthToken() string
+	CurrentStorageUrl() string
+	AuthTokenExpiry() time.Time
+	ServiceCatalog() []CatalogService
+	Authenticated() bool
+	QueryInfo() (infos SwiftInfo, err error)
+	Call(targetUrl string, p RequestOpts) (resp *http.Response, headers Headers, err error)
+	ContainerNames(opts *ContainersOpts) ([]string, error)
+	ContainerNamesWithOptions(opts *ContainersOpts, options ...RequestOption) ([]string, error)
+	Containers(opts *ContainersOpts) ([]Container, error)
+	ContainersWithOptions(opts *ContainersOpts, options ...RequestOption) ([]Container, error)
+	ContainersInto(opts *ContainersOpts, result interface{}, options ...RequestOption) error
+	ContainersAll(opts *ContainersOpts) ([]Container, error)
+	ContainersAllWithOptions(opts *ContainersOpts, options ...RequestOption) ([]Container, error)
+	ContainerNamesAll(opts *ContainersOpts) ([]string, error)
+	ContainerNamesAllWithOptions(opts *ContainersOpts, options ...RequestOption) ([]string, error)
+	ObjectNames(container string, opts *ObjectsOpts) ([]string, error)
+	ObjectNamesWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]string, error)
+	Objects(container string, opts *ObjectsOpts) ([]Object, error)
+	ObjectsWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]Object, error)
+	ObjectsInto(container string, opts *ObjectsOpts, result interface{}, options ...RequestOption) error
+	ObjectsWalk(container string, opts *ObjectsOpts, walkFn ObjectsWalkFn) error
+	ObjectsAll(container string, opts *ObjectsOpts) ([]Object, error)
+	ObjectsAllWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]Object, error)
+	ObjectNamesAll(container string, opts *ObjectsOpts) ([]string, error)
+	ObjectNamesAllWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]string, error)
+	ObjectsEach(container string, opts *ObjectsOpts, fn func(*Object) error, options ...RequestOption) error
+	ObjectNamesEach(container string, opts *ObjectsOpts, fn func(string) error, options ...RequestOption) error
+	Account() (info Account, headers Headers, err error)
+	AccountWithOptions(options ...RequestOption) (info Account, headers Headers, err error)
+	AccountUpdate(h Headers) error
+	AccountUpdateWithOptions(h Headers, options ...RequestOption) error
+	ContainerCreate(container string, h Headers) error
+	ContainerCreateWithOptions(container string, h Headers, options ...RequestOption) error
+	ContainerDelete(container string) error
+	ContainerDeleteWithOptions(container string, options ...RequestOption) error
+	Container(container string) (info Container, headers Headers, err error)
+	ContainerWithOptions(container string, options ...RequestOption) (info Container, headers Headers, err error)
+	ContainerUpdate(container string, h Headers) error
+	ContainerUpdateWithOptions(container string, h Headers, options ...RequestOption) error
+	ObjectCreate(container string, objectName string, checkHash bool, Hash string, contentType string, h Headers) (file *ObjectCreateFile, err error)
+	ObjectSymlinkCreate(container string, symlink string, targetAccount string, targetContainer string, targetObject string, targetEtag string) (headers Headers, err error)
+	ObjectPut(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers) (headers Headers, err error)
+	ObjectPutIfNotExists(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers, options ...RequestOption) (headers Headers, err error)
+	ObjectPutBytes(container string, objectName string, contents []byte, contentType string) (err error)
+	ObjectPutBytesWithOptions(container string, objectName string, contents []byte, contentType string, options ...RequestOption) (err error)
+	ObjectPutString(container string, objectName string, contents string, contentType string) (err error)
+	ObjectPutStringWithOptions(container string, objectName string, contents string, contentType string, options ...RequestOption) (err error)
+	ObjectOpen(container string, objectName string, checkHash bool, h Headers) (file *ObjectOpenFile, headers Headers, err error)
+	ObjectGet(container string, objectName string, contents io.Writer, checkHash bool, h Headers) (headers Headers, err error)
+	ObjectGetBytes(container string, objectName string) (contents []byte, err error)
+	ObjectGetBytesWithOptions(container string, objectName string, options ...RequestOption) (contents []byte, err error)
+	ObjectGetString(container string, objectName string) (contents string, err error)
+	ObjectGetStringWithOptions(container string, objectName string, options ...RequestOption) (contents string, err error)
+	ObjectDelete(container string, objectName string) error
+	ObjectDeleteWithOptions(container string, objectName string, options ...RequestOption) error
+	ObjectTempUrl(container string, objectName string, secretKey string, method string, expires time.Time) string
+	BulkDelete(container string, objectNames []string) (result BulkDeleteResult, err error)
+	BulkDeleteHeaders(container string, objectNames []string, h Headers) (result BulkDeleteResult, err error)
+	BulkUpload(uploadPath string, dataStream io.Reader, format string, h Headers) (result BulkUploadResult, err error)
+	Object(container string, objectName string) (info Object, headers Headers, err error)
+	ObjectWithOptions(container string, objectName string, options ...RequestOption) (info Object, headers Headers, err error)
+	ObjectUpdate(container string, objectName string, h Headers) error
+	ObjectUpdateWithOptions(container string, objectName string, h Headers, options ...RequestOption) error
+	AccountRemoveMetadata(keys ...string) error
+	ContainerRemoveMetadata(container string, keys ...string) error
+	ObjectRemoveMetadata(container string, objectName string, keys ...string) error
+	ObjectCopy(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error)
+	ObjectCopyWithOptions(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error)
+	ObjectCopyAccount(srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error)
+	ObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) (err error)
+	ObjectMoveWithOptions(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, options ...RequestOption) (err error)
+	ObjectRename(container string, srcObjectName string, dstObjectName string) error
+	ObjectUpdateContentType(container string, objectName string, contentType string) (err error)
+	ObjectRewriteHeaders(container string, objectName string, h Headers, freshMetadata bool) (err error)
+	VersionContainerCreate(current, version string) error
+	VersionEnable(current, version string) error
+	VersionDisable(current string) error
+	VersionObjectList(version, object string) ([]string, error)
+	HistoryEnable(current, history string) error
+	HistoryDisable(current string) error
+	VersionObjectRestore(current, version, versionName string) error
+	ContainerVersioningEnable(container string) error
+	ContainerVersioningDisable(container string) error
+	ObjectVersions(container string, objectName string) ([]ObjectVersion, error)
+	ObjectGetVersion(container string, objectName string, versionId string, contents io.Writer, checkHash bool) (headers Headers, err error)
+	ObjectRestoreVersion(container string, objectName string, versionId string) error
+
+	// Parsing and building account ACLs
+	AccountACL() (*AccountACL, error)
+	AccountSetACL(acl *AccountACL) error
+
+	// Typed access to the settings of an account
+	AccountConfigure(s *AccountSettings) error
+
+	// Walking all the objects in an account
+	AccountObjectsWalk(opts *AccountObjectsOpts, fn AccountObjectsWalkFn) error
+
+	// Fast counting of all the objects in an account
+	AccountCensus(opts *CensusOpts) (*Census, error)
+
+	// Conditional requests for objects
+	ObjectGetConditional(container string, objectName string, contents io.Writer, checkHash bool, cond *Conditions, h Headers) (headers Headers, err error)
+	ObjectConditional(container string, objectName string, cond *Conditions) (info Object, headers Headers, err error)
+
+	// Parsing and building container ACLs
+	ContainerACLs(container string) (read *ContainerACL, write *ContainerACL, err error)
+	ContainerSetACLs(container string, read *ContainerACL, write *ContainerACL) error
+	ContainerMakePublic(container string, listings bool) error
+	ContainerMakePrivate(container string) error
+	ContainerGrantRead(container string, users ...string) error
+	ContainerGrantWrite(container string, users ...string) error
+	ContainerRevoke(container string, users ...string) error
+
+	// Finding the changes in a container between two listings
+	ContainerDiff(container string, from []Object, opts *ObjectsOpts) (*ObjectsDiff, []Object, error)
+
+	// Typed access to the settings of a container
+	ContainerConfigure(container string, s *ContainerSettings) error
+
+	// Configuring CORS on containers
+	ContainerCORS(container string) (*CORSConfig, error)
+	ContainerSetCORS(container string, config *CORSConfig) error
+
+	// Deleting all the objects with a prefix and cleaning up expired objects
+	ObjectsDelete(container string, prefix string, opts *ObjectsDeleteOpts) (result BulkDeleteResult, err error)
+	ExpiredObjectsDelete(container string, opts *ExpiredObjectsOpts) (result ExpiredObjectsResult, err error)
+
+	// Modelling directories with pseudo directories and directory markers
+	MkDir(container string, dir string) error
+	IsDir(container string, dir string) (bool, error)
+	ObjectsListDir(container string, opts *ObjectsOpts) (*ListDirResult, error)
+	ObjectsListDirAll(container string, opts *ObjectsOpts) (*ListDirResult, error)
+	ListDir(container string, dir string) (objects []Object, dirs []string, err error)
+	RmDir(container string, dir string) error
+
+	// Dynamic large objects
+	DynamicLargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error)
+	DynamicLargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error)
+	DynamicLargeObjectDelete(container string, path string) error
+	DynamicLargeObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) error
+
+	// Downloading a stream of objects with back-pressure
+	Download(container string, names <-chan string, opts *DownloadOpts) <-chan DownloadResult
+
+	// Filtering object listings by metadata
+	ObjectsFilter(container string, opts *ObjectsOpts, filter *MetadataFilterOpts, fn func(object *Object, headers Headers) error) error
+
+	// Uploads using the formpost middleware
+	FormPostSignature(container string, prefix string, secretKey string, opts *FormPostOpts) (string, error)
+	FormPost(container string, prefix string, secretKey string, opts *FormPostOpts, files []FormPostFile) (err error)
+
+	// A read-only io/fs.FS of the objects in a container
+	FS(container string) *FS
+
+	// An http.FileSystem for serving a container with http.FileServer
+	FileSystem(container string, prefix string) http.FileSystem
+
+	// Iterators over container and object listings
+	NewObjectIterator(container string, opts *ObjectsOpts) *ObjectIterator
+	NewContainerIterator(opts *ContainersOpts) *ContainerIterator
+
+	// Managing the master keys used by WithEncryption
+	ReEncrypt(container string, e *Encryption, opts *RewriteOpts) error
+
+	// Large objects
+	LargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error)
+	LargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error)
+	LargeObjectDelete(container string, objectName string) error
+	LargeObjectGetSegments(container string, path string) (string, []Object, error)
+	LargeObjectUpdate(container string, objectName string, h Headers, segmentHeaders []string) (ignored []string, err error)
+
+	// Confining objects to a prefix of a container
+	Namespace(container string, prefix string) *Namespace
+
+	// Options structs for uploading and downloading objects
+	ObjectPutWithOpts(container string, objectName string, contents io.Reader, opts *ObjectPutOpts, options ...RequestOption) (headers Headers, err error)
+	ObjectCreateWithOpts(container string, objectName string, opts *ObjectPutOpts, options ...RequestOption) (file *ObjectCreateFile, err error)
+	ObjectOpenWithOpts(container string, objectName string, opts *ObjectGetOpts, options ...RequestOption) (file *ObjectOpenFile, headers Headers, err error)
+	ObjectGetWithOpts(container string, objectName string, contents io.Writer, opts *ObjectGetOpts, options ...RequestOption) (headers Headers, err error)
+
+	// Downloading one object in parallel ranges
+	ObjectGetParallel(container string, objectName string, w io.WriterAt, opts *ParallelOpts) (headers Headers, err error)
+
+	// Falling back to plain text listings for proxies which don't do JSON
+	PlainListings() bool
+
+	// Listing several prefixes of a container at once
+	ObjectsAllPrefixes(container string, prefixes []string, opts *PrefixesOpts) ([]Object, error)
+	ObjectNamesAllPrefixes(container string, prefixes []string, opts *PrefixesOpts) ([]string, error)
+
+	// Setting container and account quotas
+	ContainerSetQuota(container string, quotaBytes int64, quotaCount int64) error
+	AccountSetQuota(quotaBytes int64) error
+
+	// Rewriting the objects under a prefix, eg to re-encrypt them
+	ObjectsRewrite(container string, opts *RewriteOpts, fn RewriteFn) error
+
+	// Choosing the container to upload objects to from rules
+	ObjectPutRouted(router *Router, obj RouteObject, contents io.Reader, h Headers) (container string, objectName string, headers Headers, err error)
+
+	// Serving objects to HTTP clients
+	ServeObject(w http.ResponseWriter, r *http.Request, container string, objectName string)
+
+	// Static large objects
+	StaticLargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error)
+	StaticLargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error)
+	StaticLargeObjectDelete(container string, path string) error
+	StaticLargeObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) error
+	StaticLargeObjectManifestPut(container string, objectName string, contentType string, segments []SLOSegment, h Headers) error
+
+	// Export and import of account and container metadata
+	AccountExport() (*AccountSnapshot, error)
+	AccountImport(snapshot *AccountSnapshot) error
+
+	// Per prefix statistics for containers
+	PrefixStats(container string, depth int) (*PrefixStats, error)
+	PrefixStatsSave(container string, objectName string, stats *PrefixStats) error
+	PrefixStatsLoad(container string, objectName string) (*PrefixStats, error)
+
+	// Temporary URLs for all the objects under a prefix and checking
+	TempUrlPrefix(container string, prefix string, secretKey string, method string, expires time.Time) *TempUrlPrefix
+
+	// Management of the keys used to sign temporary URLs
+	AccountTempUrlKeys() (TempUrlKeys, error)
+	AccountSetTempUrlKeys(keys TempUrlKeys) error
+	AccountRotateTempUrlKey(newKey string) (oldKey string, err error)
+	ContainerTempUrlKeys(container string) (TempUrlKeys, error)
+	ContainerSetTempUrlKeys(container string, keys TempUrlKeys) error
+	ContainerRotateTempUrlKey(container string, newKey string) (oldKey string, err error)
+
+	// Totting up the objects and bytes used in each container of an account
+	AccountUsage(opts *AccountUsageOpts) (*Usage, error)
+
+	// Watching a container for changes by polling it
+	Watch(container string, interval time.Duration, opts *WatchOpts) *Watcher
+
+	// Configuring containers to be served as websites by staticweb
+	ContainerWebsite(container string) (*WebsiteConfig, error)
+	ContainerSetWebsite(container string, config *WebsiteConfig) error
+	ContainerHostWebsite(container string, config *WebsiteConfig) error
+}
+
+// Check Connection satisfies the interface
+var _ Interface = (*Connection)(nil)
@@ -0,0 +1,116 @@
+// Iterators over container and object listings
+
+package swift
+
+import (
+	"io"
+)
+
+// ObjectIterator returns the objects in a container one at a time,
+// fetching the listing a page at a time as it is needed.
+//
+// Make one with NewObjectIterator.  It isn't safe to use from more
+// than one go routine at once.
+type ObjectIterator struct {
+	c         *Connection
+	container string
+	opts      *ObjectsOpts
+	objects   []Object // current page
+	i         int      // index of the next object in objects
+	last      bool     // set if the current page is the last one
+	err       error    // sticky error
+}
+
+// NewObjectIterator makes an ObjectIterator for the objects in
+// container listed with opts which may be nil.
+//
+// It has a default Limit parameter but you may pass in your own.
+// Marker is reset unless KeepMarker is set.
+func (c *Connection) NewObjectIterator(container string, opts *ObjectsOpts) *ObjectIterator {
+	return &ObjectIterator{
+		c:         c,
+		container: container,
+		opts:      objectsAllOpts(opts, allObjectsChanLimit),
+	}
+}
+
+// Next returns the next object in the listing.
+//
+// It returns io.EOF when there are no more objects.  Once an error
+// has been returned it is returned by every subsequent call.
+func (it *ObjectIterator) Next() (Object, error) {
+	for it.err == nil && it.i >= len(it.objects) {
+		if it.last {
+			it.err = io.EOF
+			break
+		}
+		it.objects, it.err = it.c.Objects(it.container, it.opts)
+		it.i = 0
+		n := len(it.objects)
+		if n == 0 || (n < it.opts.Limit && !it.c.listingMaybeTruncated(n)) {
+			it.last = true
+		}
+		if n > 0 {
+			it.opts.Marker = it.objects[n-1].Name
+		}
+	}
+	if it.err != nil {
+		return Object{}, it.err
+	}
+	object := it.objects[it.i]
+	it.i++
+	return object, nil
+}
+
+// ContainerIterator returns the containers in the account one at a
+// time, fetching the listing a page at a time as it is needed.
+//
+// Make one with NewContainerIterator.  It isn't safe to use from
+// more than one go routine at once.
+type ContainerIterator struct {
+	c          *Connection
+	opts       *ContainersOpts
+	containers []Container // current page
+	i          int         // index of the next container in containers
+	last       bool        // set if the current page is the last one
+	err        error       // sticky error
+}
+
+// NewContainerIterator makes a ContainerIterator for the containers
+// listed with opts which may be nil.
+//
+// It has a default Limit parameter but you may pass in your own
+func (c *Connection) NewContainerIterator(opts *ContainersOpts) *ContainerIterator {
+	return &ContainerIterator{
+		c:    c,
+		opts: containersAllOpts(opts),
+	}
+}
+
+// Next returns the next container in the listing.
+//
+// It returns io.EOF when there are no more containers.  Once an error
+// has been returned it is returned by every subsequent call.
+func (it *ContainerIterator) Next() (Container, error) {
+	for it.err == nil && it.i >= len(it.containers) {
+		if it.last {
+			it.err = io.EOF
+			break
+		}
+		it.containers, it.err = it.c.Containers(it.opts)
+		it.i = 0
+		n := len(it.containers)
+		if n == 0 || (n < it.opts.Limit && !it.c.listingMaybeTruncated(n)) {
+			it.last = true
+		}
+		if n > 0 {
+			it.opts.Marker = it.containers[n-1].Name
+		}
+	}
+	if it.err != nil {
+		return Container{}, it.err
+	}
+	container := it.containers[it.i]
+	it.i++
+	return container, nil
+}
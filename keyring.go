@@ -0,0 +1,80 @@
+// Managing the master keys used by WithEncryption
+
+package swift
+
+import (
+	"io"
+)
+
+// Keyring finds the master keys used by WithEncryption from their IDs.
+//
+// Implement it to fetch keys from a key management service or a
+// secrets store.  It must be safe to call from several go routines
+// at once.
+type Keyring interface {
+	// Key returns the EncryptionKeySize byte key with keyID or
+	// UnknownEncryptionKey if there isn't one
+	Key(keyID string) ([]byte, error)
+}
+
+// StaticKeyring is a Keyring of keys held in memory indexed by their
+// IDs
+type StaticKeyring map[string][]byte
+
+// Key returns the key with keyID - see Keyring
+func (k StaticKeyring) Key(keyID string) ([]byte, error) {
+	key, ok := k[keyID]
+	if !ok {
+		return nil, UnknownEncryptionKey
+	}
+	return key, nil
+}
+
+// ReEncrypt rotates the objects encrypted with WithEncryption in
+// container whose names start with opts.Prefix to the master key e
+// encrypts new objects with, so the old master keys can be retired.
+// e must be able to get the old keys from its Keyring.
+//
+// The data keys of the objects are wrapped with the new master key
+// and their metadata updated with ObjectUpdate, which is done on the
+// server without transferring the data.  Objects already using the
+// new master key and objects which aren't encrypted are left alone.
+//
+// This uses ObjectsRewrite so opts can be used to checkpoint and
+// resume the rotation.
+func (c *Connection) ReEncrypt(container string, e *Encryption, opts *RewriteOpts) error {
+	return c.ObjectsRewrite(container, opts, e.reEncrypt)
+}
+
+// reEncrypt is the RewriteFn for ReEncrypt
+func (e *Encryption) reEncrypt(object *Object, headers Headers, contents io.Reader) (io.Reader, Headers, error) {
+	keyID, ok := headers[EncryptionKeyIDHeader]
+	if !ok {
+		return nil, nil, nil
+	}
+	wrapped, ok := headers[EncryptionDataKeyHeader]
+	if !ok {
+		return nil, nil, ObjectCorrupted
+	}
+	if keyID == e.keyID {
+		return nil, nil, nil
+	}
+	master, err := e.masterKey(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := unwrapKey(master, keyID, wrapped)
+	if err != nil {
+		return nil, nil, err
+	}
+	newHeaders := keepObjectHeaders(headers.ObjectMetadata().ObjectHeaders(), headers)
+	newHeaders[EncryptionKeyIDHeader] = e.keyID
+	newHeaders[EncryptionDataKeyHeader], err = e.wrapKey(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, newHeaders, nil
+}
+
+// Check it satisfies the interface
+var _ Keyring = StaticKeyring{}
@@ -3,12 +3,15 @@ package swift
 import (
 	"bufio"
 	"bytes"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"net/url"
 	"os"
 	gopath "path"
 	"strconv"
@@ -39,7 +42,10 @@ type largeObjectCreateFile struct {
 	checkHash        bool
 	segments         []Object
 	headers          Headers
+	segmentHeaders   Headers
 	minChunkSize     int64
+	progress         ProgressFunc
+	written          int64
 }
 
 func swiftSegmentPath(path string) (string, error) {
@@ -79,6 +85,15 @@ func (headers Headers) IsLargeObject() bool {
 	return headers.IsLargeObjectSLO() || headers.IsLargeObjectDLO()
 }
 
+// isManifestPut returns whether a PUT with h, parameters and options
+// uploads a large object manifest.  The ETag returned for these isn't
+// the MD5 of the body so it can't be checked.
+func isManifestPut(h Headers, parameters url.Values, options []RequestOption) bool {
+	p := RequestOpts{Headers: h, Parameters: parameters}
+	p.apply(options)
+	return p.Headers.IsLargeObjectDLO() || p.Parameters.Get("multipart-manifest") == "put"
+}
+
 func (c *Connection) getAllSegments(container string, path string, headers Headers) (string, []Object, error) {
 	if manifest, isDLO := headers["X-Object-Manifest"]; isDLO {
 		segmentContainer, segmentPath := parseFullPath(manifest)
@@ -93,20 +108,29 @@ func (c *Connection) getAllSegments(container string, path string, headers Heade
 
 // LargeObjectOpts describes how a large object should be created
 type LargeObjectOpts struct {
-	Container        string  // Name of container to place object
-	ObjectName       string  // Name of object
-	Flags            int     // Creation flags
-	CheckHash        bool    // If set Check the hash
-	Hash             string  // If set use this hash to check
-	ContentType      string  // Content-Type of the object
-	Headers          Headers // Additional headers to upload the object with
-	ChunkSize        int64   // Size of chunks of the object, defaults to 10MB if not set
-	MinChunkSize     int64   // Minimum chunk size, automatically set for SLO's based on info
-	SegmentContainer string  // Name of the container to place segments
-	SegmentPrefix    string  // Prefix to use for the segments
-	NoBuffer         bool    // Prevents using a bufio.Writer to write segments
+	Container        string        // Name of container to place object
+	ObjectName       string        // Name of object
+	Flags            int           // Creation flags
+	CheckHash        bool          // If set Check the hash
+	Hash             string        // If set use this hash to check
+	ContentType      string        // Content-Type of the object
+	Headers          Headers       // Additional headers to upload the object with
+	ChunkSize        int64         // Size of chunks of the object, defaults to 10MB if not set
+	MinChunkSize     int64         // Minimum chunk size, automatically set for SLO's based on info
+	SegmentContainer string        // Name of the container to place segments
+	SegmentPrefix    string        // Prefix to use for the segments
+	NoBuffer         bool          // Prevents using a bufio.Writer to write segments
+	Progress         ProgressFunc  // If set called with the number of bytes written as each segment is uploaded
+	DeleteAt         time.Time     // If set the object and its segments expire at this time
+	DeleteAfter      time.Duration // If set the object and its segments expire this long after creation
+	ObjectType       ObjectType    // Type of large object LargeObjectCreateFile makes - RegularObjectType to choose automatically
+	Size             int64         // Expected size of the object if known - used by LargeObjectCreateFile to choose the type
 }
 
+// defaultChunkSize is the size of the segments of a large object if
+// LargeObjectOpts.ChunkSize isn't set
+const defaultChunkSize = 10 * 1024 * 1024
+
 type LargeObjectFile interface {
 	io.Writer
 	io.Seeker
@@ -168,6 +192,18 @@ func (c *Connection) largeObjectCreate(opts *LargeObjectOpts) (*largeObjectCreat
 		}
 	}
 
+	expiry := deleteAtHeaders(opts.DeleteAt, opts.DeleteAfter)
+	headers := opts.Headers
+	if expiry != nil {
+		headers = Headers{}
+		for key, value := range opts.Headers {
+			headers[key] = value
+		}
+		for key, value := range expiry {
+			headers[key] = value
+		}
+	}
+
 	file := &largeObjectCreateFile{
 		conn:             c,
 		checkHash:        opts.CheckHash,
@@ -175,7 +211,9 @@ func (c *Connection) largeObjectCreate(opts *LargeObjectOpts) (*largeObjectCreat
 		objectName:       opts.ObjectName,
 		chunkSize:        opts.ChunkSize,
 		minChunkSize:     opts.MinChunkSize,
-		headers:          opts.Headers,
+		headers:          headers,
+		segmentHeaders:   expiry,
+		progress:         opts.Progress,
 		segmentContainer: segmentContainer,
 		prefix:           segmentPath,
 		segments:         segments,
@@ -183,7 +221,7 @@ func (c *Connection) largeObjectCreate(opts *LargeObjectOpts) (*largeObjectCreat
 	}
 
 	if file.chunkSize == 0 {
-		file.chunkSize = 10 * 1024 * 1024
+		file.chunkSize = defaultChunkSize
 	}
 
 	if file.minChunkSize > file.chunkSize {
@@ -197,6 +235,79 @@ func (c *Connection) largeObjectCreate(opts *LargeObjectOpts) (*largeObjectCreat
 	return file, nil
 }
 
+// largeObjectType returns the type of large object
+// LargeObjectCreateFile should make for opts.
+func (c *Connection) largeObjectType(opts *LargeObjectOpts) ObjectType {
+	if opts.ObjectType != RegularObjectType {
+		return opts.ObjectType
+	}
+	// Carry on with the same type if appending to a large object
+	if opts.Flags&os.O_TRUNC == 0 {
+		if _, headers, err := c.Object(opts.Container, opts.ObjectName); err == nil {
+			if headers.IsLargeObjectDLO() {
+				return DynamicLargeObjectType
+			}
+			if headers.IsLargeObjectSLO() {
+				return StaticLargeObjectType
+			}
+		}
+	}
+	if !c.Authenticated() {
+		if err := c.Authenticate(); err != nil {
+			return DynamicLargeObjectType
+		}
+	}
+	info, err := c.cachedQueryInfo()
+	if err != nil || !info.SupportsSLO() {
+		return DynamicLargeObjectType
+	}
+	if opts.Size > 0 {
+		chunkSize := opts.ChunkSize
+		if chunkSize == 0 {
+			chunkSize = defaultChunkSize
+		}
+		if min := info.SLOMinSegmentSize(); min > chunkSize {
+			chunkSize = min
+		}
+		if (opts.Size+chunkSize-1)/chunkSize > info.SLOMaxSegments() {
+			return DynamicLargeObjectType
+		}
+	}
+	return StaticLargeObjectType
+}
+
+// LargeObjectCreateFile creates a large object returning an object
+// which satisfies io.Writer, io.Seeker, io.Closer and io.ReaderFrom.
+// The flags are as passed to the largeObjectCreate method.
+//
+// It makes a static large object if the server supports them and a
+// dynamic large object if not, so the same code works on clusters
+// with and without the SLO middleware.  If opts.Size is set and the
+// object would need more segments than an SLO manifest can hold then
+// a dynamic large object is made instead.  Appending to an existing
+// large object keeps its type.
+//
+// Set opts.ObjectType to StaticLargeObjectType or
+// DynamicLargeObjectType to choose the type.
+func (c *Connection) LargeObjectCreateFile(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	switch objectType := c.largeObjectType(opts); objectType {
+	case StaticLargeObjectType:
+		return c.StaticLargeObjectCreateFile(opts)
+	case DynamicLargeObjectType:
+		return c.DynamicLargeObjectCreateFile(opts)
+	default:
+		return nil, newErrorf(0, "Unknown large object type %d", objectType)
+	}
+}
+
+// LargeObjectCreate creates or truncates an existing large object
+// returning a writeable object. This sets opts.Flags to an
+// appropriate value before calling LargeObjectCreateFile
+func (c *Connection) LargeObjectCreate(opts *LargeObjectOpts) (LargeObjectFile, error) {
+	opts.Flags = os.O_TRUNC | os.O_CREATE
+	return c.LargeObjectCreateFile(opts)
+}
+
 // LargeObjectDelete deletes the large object named by container, path
 func (c *Connection) LargeObjectDelete(container string, objectName string) error {
 	_, headers, err := c.Object(container, objectName)
@@ -253,6 +364,79 @@ func (c *Connection) LargeObjectGetSegments(container string, path string) (stri
 	return c.getAllSegments(container, path, headers)
 }
 
+// segmentContentHeaders are the headers which describe how the
+// content of an object is encoded.  Setting them on a large object
+// manifest doesn't change the content served as that comes from the
+// segments.
+var segmentContentHeaders = []string{
+	"Content-Encoding",
+}
+
+// LargeObjectUpdate does an ObjectUpdate of the object with the
+// headers h, taking care if it is a large object.
+//
+// If the object is a large object then h is applied to the manifest
+// and the headers named in segmentHeaders (eg "Content-Encoding") are
+// also applied to each of its segments.  As ObjectUpdate replaces all
+// the metadata of an object, the segments are read first and keep
+// their own metadata with the headers named set on top.
+//
+// Headers like X-Object-Manifest and X-Delete-At which ObjectUpdate
+// would also remove are kept unless h or segmentHeaders sets them, so
+// a dynamic large object stays one.
+//
+// It returns the names of the headers in h which won't affect the
+// content served because they are only set on the manifest of a large
+// object, so the caller can warn about them or re-try with them in
+// segmentHeaders.
+func (c *Connection) LargeObjectUpdate(container string, objectName string, h Headers, segmentHeaders []string) (ignored []string, err error) {
+	_, headers, err := c.Object(container, objectName)
+	if err != nil {
+		return nil, err
+	}
+	err = c.ObjectUpdate(container, objectName, keepObjectHeaders(h, headers))
+	if err != nil || !headers.IsLargeObject() {
+		return nil, err
+	}
+
+	toSegments := Headers{}
+	for _, key := range segmentHeaders {
+		if value, ok := h[key]; ok {
+			toSegments[key] = value
+		}
+	}
+	for _, key := range segmentContentHeaders {
+		if _, ok := h[key]; ok {
+			if _, ok := toSegments[key]; !ok {
+				ignored = append(ignored, key)
+			}
+		}
+	}
+	if len(toSegments) == 0 {
+		return ignored, nil
+	}
+
+	segmentContainer, segments, err := c.getAllSegments(container, objectName, headers)
+	if err != nil {
+		return ignored, err
+	}
+	for _, segment := range segments {
+		_, current, err := c.Object(segmentContainer, segment.Name)
+		if err != nil {
+			return ignored, err
+		}
+		newHeaders := current.ObjectMetadata().ObjectHeaders()
+		for key, value := range toSegments {
+			newHeaders[key] = value
+		}
+		err = c.ObjectUpdate(segmentContainer, segment.Name, keepObjectHeaders(newHeaders, current))
+		if err != nil {
+			return ignored, err
+		}
+	}
+	return ignored, nil
+}
+
 // Seek sets the offset for the next write operation
 func (file *largeObjectCreateFile) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
@@ -305,7 +489,7 @@ func (c *Connection) waitForSegmentsToShowUp(container, objectName string, expec
 	err = withLORetry(expectedSize, func() (Headers, int64, error) {
 		var info Object
 		var headers Headers
-		info, headers, err = c.objectBase(container, objectName)
+		info, headers, err = c.objectBase(container, objectName, nil)
 		if err != nil {
 			return headers, 0, err
 		}
@@ -341,6 +525,10 @@ func (file *largeObjectCreateFile) Write(buf []byte) (int, error) {
 		offset += n
 		writeSegmentIdx++
 		relativeFilePos = 0
+		if file.progress != nil {
+			file.written += int64(n)
+			file.progress(file.container, file.objectName, file.written, -1)
+		}
 	}
 	file.filePos += int64(sizeToWrite)
 	file.currentLength = 0
@@ -393,7 +581,7 @@ func (file *largeObjectCreateFile) writeSegment(buf []byte, writeSegmentIdx int,
 		readers = append(readers, tailSegmentReader)
 	}
 	segmentReader := io.MultiReader(readers...)
-	headers, err := file.conn.ObjectPut(file.segmentContainer, segmentName, segmentReader, true, "", file.contentType, nil)
+	headers, err := file.conn.ObjectPut(file.segmentContainer, segmentName, segmentReader, true, "", file.contentType, file.segmentHeaders)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -446,3 +634,131 @@ func (blo *bufferedLargeObjectFile) Flush() error {
 	}
 	return blo.LargeObjectFile.Flush()
 }
+
+// LargeObjectEtag selects how downloads of large objects are checked
+// when checkHash is set.
+//
+// The Etag of a large object is the md5sum of the md5sums of its
+// segments so it can't be checked against the data received.
+type LargeObjectEtag int
+
+// Values that LargeObjectEtag can take
+const (
+	// Don't check the md5sum of large objects (default)
+	LargeObjectEtagSkip LargeObjectEtag = iota
+	// Read the segments from the manifest before downloading and
+	// check the md5sum of each segment as it is received.  This
+	// costs a listing of the segments for a DLO or a read of the
+	// manifest for an SLO on each download.
+	LargeObjectEtagSegments
+)
+
+// segmentVerifier checks the md5sum of each segment of a large object
+// as it is read
+type segmentVerifier struct {
+	in       io.Reader
+	segments []parallelRange
+	i        int       // index of the current segment
+	left     int64     // bytes left to read of the current segment
+	hash     hash.Hash // md5sum of the current segment so far
+	err      error     // set on the first bad segment
+}
+
+// newSegmentVerifier makes a segmentVerifier for the large object
+// with the headers given.
+//
+// The segments of an SLO are read from its manifest with sloRanges so
+// segments which are SLOs themselves or of which only a range is used
+// aren't checked.  If the segments don't add up to length then it
+// returns nil as the object can't be checked.
+func (c *Connection) newSegmentVerifier(container string, objectName string, headers Headers, length int64) (*segmentVerifier, error) {
+	var segments []parallelRange
+	var total int64
+	if headers.IsLargeObjectSLO() {
+		manifest, err := c.getSLOManifest(container, objectName)
+		if err != nil {
+			return nil, err
+		}
+		segments, total = sloRanges(manifest)
+	} else {
+		_, objects, err := c.getAllSegments(container, objectName, headers)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			segments = append(segments, parallelRange{
+				offset: total,
+				length: object.Bytes,
+				etag:   object.Hash,
+			})
+			total += object.Bytes
+		}
+	}
+	if length >= 0 && total != length {
+		return nil, nil
+	}
+	v := &segmentVerifier{
+		segments: segments,
+		i:        -1,
+	}
+	v.next()
+	return v, nil
+}
+
+// next checks the current segment if there is one and moves on to
+// the next segment with some data in
+func (v *segmentVerifier) next() {
+	for {
+		if v.i >= 0 && v.i < len(v.segments) && v.err == nil && v.segments[v.i].etag != "" {
+			calculated := fmt.Sprintf("%x", v.hash.Sum(nil))
+			if !etagMatches(v.segments[v.i].etag, calculated) {
+				v.err = ObjectCorrupted
+			}
+		}
+		v.i++
+		if v.i >= len(v.segments) {
+			return
+		}
+		v.hash = md5.New()
+		v.left = v.segments[v.i].length
+		if v.left > 0 {
+			return
+		}
+	}
+}
+
+// Read reads from the underlying reader checking the segments - see
+// io.Reader
+func (v *segmentVerifier) Read(p []byte) (n int, err error) {
+	n, err = v.in.Read(p)
+	data := p[:n]
+	for len(data) > 0 && v.i < len(v.segments) {
+		chunk := data
+		if int64(len(chunk)) > v.left {
+			chunk = chunk[:v.left]
+		}
+		_, _ = v.hash.Write(chunk)
+		v.left -= int64(len(chunk))
+		data = data[len(chunk):]
+		if v.left == 0 {
+			v.next()
+		}
+	}
+	if len(data) > 0 {
+		// More data than the segments hold
+		v.err = ObjectCorrupted
+	}
+	return n, err
+}
+
+// check returns ObjectCorrupted if any of the segments were bad or
+// not all of them were read.
+func (v *segmentVerifier) check() error {
+	if v.err != nil {
+		return v.err
+	}
+	if v.i < len(v.segments) {
+		return ObjectCorrupted
+	}
+	return nil
+}
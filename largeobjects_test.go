@@ -0,0 +1,119 @@
+// Tests for updating large objects
+package swift
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+// postTransport records the headers of the POSTs to objects as the
+// test server keeps the ones a real server would remove
+type postTransport struct {
+	mu    sync.Mutex
+	posts map[string]http.Header
+}
+
+func (t *postTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" {
+		t.mu.Lock()
+		t.posts[req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]] = req.Header.Clone()
+		t.mu.Unlock()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLargeObjectUpdateDLO(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	transport := &postTransport{posts: map[string]http.Header{}}
+	c := &Connection{
+		UserName:  swifttest.TEST_ACCOUNT,
+		ApiKey:    swifttest.TEST_ACCOUNT,
+		AuthUrl:   srv.AuthURL,
+		Transport: transport,
+	}
+	for _, container := range []string{"container", "segments"} {
+		if err := c.ContainerCreate(container, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleteAt := time.Now().Add(time.Hour)
+	out, err := c.DynamicLargeObjectCreate(&LargeObjectOpts{
+		Container:        "container",
+		ObjectName:       "object",
+		SegmentContainer: "segments",
+		ChunkSize:        5,
+		DeleteAt:         deleteAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = out.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Object("container", "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	segmentContainer, segments, err := c.LargeObjectGetSegments("container", "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 2 {
+		t.Fatalf("Expecting several segments got %v", segments)
+	}
+	h := Headers{"X-Object-Meta-Part": "first"}
+	h.SetDeleteAt(deleteAt)
+	err = c.ObjectUpdate(segmentContainer, segments[0].Name, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ignored, err := c.LargeObjectUpdate("container", "object", Headers{
+		"Content-Encoding":    "br",
+		"X-Object-Meta-Hello": "world",
+	}, []string{"Content-Encoding"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ignored) != 0 {
+		t.Errorf("Expecting nothing ignored got %v", ignored)
+	}
+	wantDeleteAt := strconv.FormatInt(deleteAt.Unix(), 10)
+	manifest := transport.posts["object"]
+	if got := manifest.Get("X-Object-Manifest"); got == "" || got != headers["X-Object-Manifest"] {
+		t.Errorf("Manifest not kept got %q", got)
+	}
+	if got := manifest.Get("X-Delete-At"); got != wantDeleteAt {
+		t.Errorf("X-Delete-At not kept on manifest got %q", got)
+	}
+	if got := manifest.Get("X-Object-Meta-Hello"); got != "world" {
+		t.Errorf("Metadata not set on manifest got %q", got)
+	}
+	for i, segment := range segments {
+		post := transport.posts[segment.Name[strings.LastIndex(segment.Name, "/")+1:]]
+		if post == nil {
+			t.Fatalf("Segment %q not updated", segment.Name)
+		}
+		if got := post.Get("Content-Encoding"); got != "br" {
+			t.Errorf("Content-Encoding not set on segment %q got %q", segment.Name, got)
+		}
+		if got := post.Get("X-Delete-At"); got != wantDeleteAt {
+			t.Errorf("X-Delete-At not kept on segment %q got %q", segment.Name, got)
+		}
+		if got := post.Get("X-Object-Meta-Hello"); got != "" {
+			t.Errorf("Metadata set on segment %q", segment.Name)
+		}
+		if i == 0 && post.Get("X-Object-Meta-Part") != "first" {
+			t.Errorf("Metadata of segment %q not kept", segment.Name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Logging and debugging the requests a Connection makes
+
+package swift
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Logger is where a Connection writes its log messages - set it as
+// the Logger of the Connection.  A *log.Logger is a Logger.  If it
+// isn't set the standard logger of the log package is used.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// DebugMode says what a Connection logs about the requests it makes
+type DebugMode int
+
+// Debug modes for Connection.Debug
+const (
+	DebugOff     DebugMode = iota // Don't log the requests (the default)
+	DebugHeaders                  // Log each request and response with their headers
+	DebugBodies                   // Log the start of the bodies as well as the headers
+)
+
+// DefaultDebugMaxBody is the number of bytes of each body logged with
+// DebugBodies if Connection.DebugMaxBody isn't set
+const DefaultDebugMaxBody = 1024
+
+// logf logs a message to the Logger of the Connection
+func (c *Connection) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// debugDump logs a request and its response for Connection.Debug
+type debugDump struct {
+	c      *Connection
+	req    *http.Request
+	url    string
+	bodies bool
+	body   *debugBody // start of the request body if bodies is set
+}
+
+// startDebug returns a debugDump of req, capturing the start of its
+// body if bodies are logged, or nil if Debug is off.
+//
+// The bodies of auth requests and their responses are never logged
+// as they contain credentials.  Credentials in the headers are
+// replaced with Redacted.
+func (c *Connection) startDebug(req *http.Request, auth bool) *debugDump {
+	if c.Debug == DebugOff {
+		return nil
+	}
+	d := &debugDump{
+		c:      c,
+		req:    req,
+		url:    redactURL(req.URL),
+		bodies: c.Debug >= DebugBodies && !auth,
+	}
+	if d.bodies && req.Body != nil && req.Body != http.NoBody {
+		d.body = newDebugBody(c.DebugMaxBody)
+		req.Body = &debugReadCloser{ReadCloser: req.Body, body: d.body}
+	}
+	return d
+}
+
+// end logs the request and resp, or err if there wasn't a response,
+// returning resp with its body wrapped to log its start when it is
+// closed if bodies are logged.  It may be called on a nil debugDump.
+func (d *debugDump) end(resp *http.Response, err error) *http.Response {
+	if d == nil {
+		return resp
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "swift: request %s %s\n", d.req.Method, d.url)
+	writeDebugHeaders(&out, d.req.Header)
+	if d.body != nil {
+		d.body.write(&out, "request body")
+	}
+	if err != nil {
+		fmt.Fprintf(&out, "swift: request %s %s failed: %v", d.req.Method, d.url, err)
+		d.c.logf("%s", out.String())
+		return resp
+	}
+	fmt.Fprintf(&out, "swift: response %s to %s %s\n", resp.Status, d.req.Method, d.url)
+	writeDebugHeaders(&out, resp.Header)
+	d.c.logf("%s", strings.TrimSuffix(out.String(), "\n"))
+	if d.bodies && resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = &debugReadCloser{
+			ReadCloser: resp.Body,
+			body:       newDebugBody(d.c.DebugMaxBody),
+			done: func(body *debugBody) {
+				var out strings.Builder
+				fmt.Fprintf(&out, "swift: response to %s %s\n", d.req.Method, d.url)
+				body.write(&out, "response body")
+				d.c.logf("%s", strings.TrimSuffix(out.String(), "\n"))
+			},
+		}
+	}
+	return resp
+}
+
+// writeDebugHeaders writes the headers h sorted by name with the
+// credentials redacted
+func writeDebugHeaders(out *strings.Builder, h http.Header) {
+	h = redactHeaders(h)
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range h[key] {
+			fmt.Fprintf(out, "  %s: %s\n", key, value)
+		}
+	}
+}
+
+// debugBody keeps the start of a body
+type debugBody struct {
+	mu    sync.Mutex
+	max   int
+	start bytes.Buffer
+	size  int64 // total bytes read
+}
+
+// newDebugBody returns a debugBody keeping max bytes, or
+// DefaultDebugMaxBody if max isn't set
+func newDebugBody(max int) *debugBody {
+	if max <= 0 {
+		max = DefaultDebugMaxBody
+	}
+	return &debugBody{max: max}
+}
+
+// add records p read from the body
+func (b *debugBody) add(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.size += int64(len(p))
+	if n := b.max - b.start.Len(); n > 0 {
+		if len(p) > n {
+			p = p[:n]
+		}
+		b.start.Write(p)
+	}
+}
+
+// write writes the start of the body called name to out
+func (b *debugBody) write(out *strings.Builder, name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fmt.Fprintf(out, "  %s (%d bytes read):\n", name, b.size)
+	if b.start.Len() > 0 {
+		out.Write(b.start.Bytes())
+		if b.size > int64(b.start.Len()) {
+			fmt.Fprintf(out, "\n  ... %d bytes not shown", b.size-int64(b.start.Len()))
+		}
+		out.WriteString("\n")
+	}
+}
+
+// debugReadCloser records the start of a body as it is read, calling
+// done, if set, when it is closed
+type debugReadCloser struct {
+	io.ReadCloser
+	body *debugBody
+	done func(body *debugBody)
+	once sync.Once
+}
+
+// Read and record the bytes - see io.Reader
+func (r *debugReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if n > 0 {
+		r.body.add(p[:n])
+	}
+	return n, err
+}
+
+// Close the body - see io.Closer
+func (r *debugReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	if r.done != nil {
+		r.once.Do(func() { r.done(r.body) })
+	}
+	return err
+}
+
+// Check it satisfies the interfaces
+var (
+	_ Logger        = &log.Logger{}
+	_ io.ReadCloser = &debugReadCloser{}
+)
@@ -0,0 +1,126 @@
+// Tests for the logging and debug output
+package swift
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func newDebugConnection(t *testing.T, debug DebugMode, out *bytes.Buffer) *Connection {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+		Logger:   log.New(out, "", 0),
+		Debug:    debug,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDebugHeaders(t *testing.T) {
+	var out bytes.Buffer
+	c := newDebugConnection(t, DebugHeaders, &out)
+	err := c.ObjectPutString("container", "object", "12345", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"swift: request GET " + c.AuthUrl + "\n",
+		"  X-Auth-Key: " + Redacted + "\n",
+		"swift: request PUT " + c.StorageUrl + "/container/object\n",
+		"  Content-Type: text/plain\n",
+		"  X-Auth-Token: " + Redacted + "\n",
+		"swift: response 200 OK to PUT " + c.StorageUrl + "/container/object\n",
+		"  Etag: 827ccb0eea8a706c4c34a16891f84e7b\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not in log:\n%s", want, got)
+		}
+	}
+	for _, notWant := range []string{c.AuthToken, ": " + swifttest.TEST_ACCOUNT + "\n", "12345\n", "body"} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("%q in log:\n%s", notWant, got)
+		}
+	}
+}
+
+func TestDebugHeadersTempUrlKey(t *testing.T) {
+	var out bytes.Buffer
+	c := newDebugConnection(t, DebugHeaders, &out)
+	err := c.AccountUpdate(Headers{"X-Account-Meta-Temp-Url-Key": "accountsecret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ContainerUpdate("container", Headers{"X-Container-Meta-Temp-Url-Key-2": "containersecret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"  X-Account-Meta-Temp-Url-Key: " + Redacted + "\n",
+		"  X-Container-Meta-Temp-Url-Key-2: " + Redacted + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not in log:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("temp URL key in log:\n%s", got)
+	}
+}
+
+func TestDebugBodies(t *testing.T) {
+	var out bytes.Buffer
+	c := newDebugConnection(t, DebugBodies, &out)
+	c.DebugMaxBody = 4
+	err := c.ObjectPutString("container", "object", "12345", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "  request body (5 bytes read):\n1234\n  ... 1 bytes not shown\n") {
+		t.Errorf("request body not in log:\n%s", out.String())
+	}
+
+	out.Reset()
+	file, _, err := c.ObjectOpen("container", "object", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "response body") {
+		t.Errorf("response body logged before it was read:\n%s", out.String())
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil || string(data) != "12345" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "swift: response to GET " + c.StorageUrl + "/container/object\n  response body (5 bytes read):\n1234\n  ... 1 bytes not shown\n"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("%q not in log:\n%s", want, out.String())
+	}
+}
+
+func TestDebugOff(t *testing.T) {
+	var out bytes.Buffer
+	c := newDebugConnection(t, DebugOff, &out)
+	_, _, err := c.Object("container", "missing")
+	if err != ObjectNotFound {
+		t.Fatalf("expecting ObjectNotFound got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected log:\n%s", out.String())
+	}
+}
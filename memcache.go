@@ -0,0 +1,150 @@
+// Caching small objects in memory
+
+package swift
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheMemoryMaxObjectSize is the size of the largest object
+// kept in memory by a Cache if CacheOpts.MemoryMaxObjectSize isn't set
+const DefaultCacheMemoryMaxObjectSize = 64 * 1024
+
+// memoryCache keeps the contents of small objects in memory
+type memoryCache struct {
+	maxBytes      int64
+	maxObjectSize int64
+	maxAge        time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List               // of *memoryEntry, most recently used first
+	entries map[string]*list.Element // by container/object
+	size    int64                    // total size of the entries
+}
+
+// memoryEntry is an object kept in memory
+type memoryEntry struct {
+	name      string    // container/object
+	etag      string    // Etag of the version kept
+	headers   Headers   // headers of the object when it was read
+	data      []byte    // contents of the object
+	validated time.Time // when the Etag was last checked
+}
+
+// newMemoryCache returns a memoryCache for opts or nil if it isn't
+// wanted
+func newMemoryCache(opts *CacheOpts) *memoryCache {
+	if opts.MemoryMaxBytes <= 0 {
+		return nil
+	}
+	m := &memoryCache{
+		maxBytes:      opts.MemoryMaxBytes,
+		maxObjectSize: opts.MemoryMaxObjectSize,
+		maxAge:        opts.MemoryMaxAge,
+		lru:           list.New(),
+		entries:       make(map[string]*list.Element),
+	}
+	if m.maxObjectSize <= 0 {
+		m.maxObjectSize = DefaultCacheMemoryMaxObjectSize
+	}
+	return m
+}
+
+// copyHeaders returns a copy of h
+func copyHeaders(h Headers) Headers {
+	headers := make(Headers, len(h))
+	for key, value := range h {
+		headers[key] = value
+	}
+	return headers
+}
+
+// fresh returns the contents and headers of the object name if they
+// were validated less than maxAge ago
+func (m *memoryCache) fresh(name string) (data []byte, headers Headers, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[name]
+	if !ok {
+		return nil, nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if time.Since(entry.validated) >= m.maxAge {
+		return nil, nil, false
+	}
+	m.lru.MoveToFront(element)
+	return entry.data, copyHeaders(entry.headers), true
+}
+
+// get returns the contents of the object name if the version with
+// etag is kept, marking it as validated with headers
+func (m *memoryCache) get(name string, etag string, headers Headers) (data []byte, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[name]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.etag != etag {
+		m.remove(name)
+		return nil, false
+	}
+	entry.headers = copyHeaders(headers)
+	entry.validated = time.Now()
+	m.lru.MoveToFront(element)
+	return entry.data, true
+}
+
+// put keeps the contents of the version with etag of the object name
+func (m *memoryCache) put(name string, etag string, headers Headers, data []byte) {
+	if int64(len(data)) > m.maxObjectSize {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(name)
+	m.entries[name] = m.lru.PushFront(&memoryEntry{
+		name:      name,
+		etag:      etag,
+		headers:   copyHeaders(headers),
+		data:      data,
+		validated: time.Now(),
+	})
+	m.size += int64(len(data))
+	for m.size > m.maxBytes && m.lru.Len() > 0 {
+		m.remove(m.lru.Back().Value.(*memoryEntry).name)
+	}
+}
+
+// remove forgets the object name.  Call with mu held.
+func (m *memoryCache) remove(name string) {
+	element, ok := m.entries[name]
+	if !ok {
+		return
+	}
+	entry := m.lru.Remove(element).(*memoryEntry)
+	delete(m.entries, name)
+	m.size -= int64(len(entry.data))
+}
+
+// invalidate forgets the object name which has been changed
+func (m *memoryCache) invalidate(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(name)
+}
+
+// invalidatePrefix forgets the objects whose names start with prefix
+func (m *memoryCache) invalidatePrefix(prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.entries {
+		if strings.HasPrefix(name, prefix) {
+			m.remove(name)
+		}
+	}
+}
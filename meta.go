@@ -78,6 +78,41 @@ func (m Metadata) ObjectHeaders() Headers {
 	return m.Headers("X-Object-Meta-")
 }
 
+// RemoveHeaders makes Headers which remove the metadata keys using
+// the X-Remove- form of the metaPrefix, eg "X-Remove-Container-Meta-"
+// for "X-Container-Meta-".
+//
+// The keys will be converted from lower case to http Canonical (see
+// http.CanonicalHeaderKey).
+//
+// Setting a key to an empty string also removes it, but some
+// middleware treats an empty value as a value, so the X-Remove- form
+// is more reliable.  The value of an X-Remove- header is ignored by
+// Swift so it is set to "x" to make sure it gets sent.
+func RemoveHeaders(metaPrefix string, keys ...string) Headers {
+	h := Headers{}
+	metaPrefix = strings.TrimPrefix(http.CanonicalHeaderKey(metaPrefix), "X-")
+	for _, key := range keys {
+		h[http.CanonicalHeaderKey("X-Remove-"+metaPrefix+key)] = "x"
+	}
+	return h
+}
+
+// AccountRemoveHeaders makes Headers which remove the account
+// metadata keys with X-Remove-Account-Meta-.
+func AccountRemoveHeaders(keys ...string) Headers {
+	return RemoveHeaders("X-Account-Meta-", keys...)
+}
+
+// ContainerRemoveHeaders makes Headers which remove the container
+// metadata keys with X-Remove-Container-Meta-.
+//
+// Objects don't have an X-Remove- form - object metadata is removed
+// by leaving it out of an ObjectUpdate, see ObjectRemoveMetadata.
+func ContainerRemoveHeaders(keys ...string) Headers {
+	return RemoveHeaders("X-Container-Meta-", keys...)
+}
+
 // Turns a number of ns into a floating point string in seconds
 //
 // Trims trailing zeros and guaranteed to be perfectly accurate
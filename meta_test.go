@@ -30,6 +30,26 @@ func TestMetadataToContainerHeaders(t *testing.T) {
 func TestMetadataToObjectHeaders(t *testing.T) {
 }
 
+func TestRemoveHeaders(t *testing.T) {
+	h := AccountRemoveHeaders("hello", "potato-salad")
+	want := Headers{
+		"X-Remove-Account-Meta-Hello":        "x",
+		"X-Remove-Account-Meta-Potato-Salad": "x",
+	}
+	if len(h) != len(want) {
+		t.Errorf("Expecting %v got %v", want, h)
+	}
+	for key, value := range want {
+		if h[key] != value {
+			t.Errorf("Expecting %q=%q got %v", key, value, h)
+		}
+	}
+	h = ContainerRemoveHeaders("mtime")
+	if _, ok := h["X-Remove-Container-Meta-Mtime"]; !ok || len(h) != 1 {
+		t.Errorf("Bad container headers %v", h)
+	}
+}
+
 func TestNsToFloatString(t *testing.T) {
 	for _, d := range []struct {
 		ns int64
@@ -0,0 +1,216 @@
+// Keeping metadata updates within the server's limits
+
+package swift
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MetadataLimits are the limits the server puts on the metadata in
+// one request
+type MetadataLimits struct {
+	Count       int // Maximum number of metadata items
+	NameLength  int // Maximum length of the name of an item, without the X-Container-Meta- etc prefix
+	ValueLength int // Maximum length of the value of an item
+	OverallSize int // Maximum total length of the names and values
+}
+
+// DefaultMetadataLimits are the limits Swift uses if they aren't
+// configured
+var DefaultMetadataLimits = MetadataLimits{
+	Count:       90,
+	NameLength:  128,
+	ValueLength: 256,
+	OverallSize: 4096,
+}
+
+// MetadataLimits returns the limits on metadata the server reports
+// in its /info, or the defaults for any it doesn't.
+func (i SwiftInfo) MetadataLimits() MetadataLimits {
+	limits := DefaultMetadataLimits
+	swift, ok := i["swift"].(map[string]interface{})
+	if !ok {
+		return limits
+	}
+	for key, limit := range map[string]*int{
+		"max_meta_count":        &limits.Count,
+		"max_meta_name_length":  &limits.NameLength,
+		"max_meta_value_length": &limits.ValueLength,
+		"max_meta_overall_size": &limits.OverallSize,
+	} {
+		if val, ok := swift[key].(float64); ok && val >= 1 {
+			*limit = int(val)
+		}
+	}
+	return limits
+}
+
+// MetadataLimitError is returned when metadata is over the server's
+// limits and the update can't be split into smaller ones
+type MetadataLimitError struct {
+	Key    string // The header which is too big, or "" if the metadata as a whole is
+	Reason string // What limit it is over
+}
+
+// Error satisfies the error interface
+func (e *MetadataLimitError) Error() string {
+	if e.Key == "" {
+		return "metadata over limits: " + e.Reason
+	}
+	return fmt.Sprintf("metadata %q over limits: %s", e.Key, e.Reason)
+}
+
+// MetadataPartialError is returned when a metadata update split into
+// several requests fails after some of them have been made
+type MetadataPartialError struct {
+	Applied []Headers // The batches of headers which were applied, in order
+	Failed  Headers   // The batch which failed
+	Err     error     // The error of the failed request
+}
+
+// Error satisfies the error interface
+func (e *MetadataPartialError) Error() string {
+	return fmt.Sprintf("metadata update failed after %d of the requests it was split into: %v", len(e.Applied), e.Err)
+}
+
+// Unwrap returns the error of the failed request so errors.Is and
+// errors.As see it
+func (e *MetadataPartialError) Unwrap() error {
+	return e.Err
+}
+
+// metadataName returns the name of the metadata item set or removed
+// by header key using metaPrefix, eg "X-Container-Meta-", and whether
+// key is metadata at all.
+func metadataName(key string, metaPrefix string) (string, bool) {
+	key = http.CanonicalHeaderKey(key)
+	metaPrefix = http.CanonicalHeaderKey(metaPrefix)
+	if strings.HasPrefix(key, metaPrefix) {
+		return key[len(metaPrefix):], true
+	}
+	removePrefix := "X-Remove-" + strings.TrimPrefix(metaPrefix, "X-")
+	if strings.HasPrefix(key, removePrefix) {
+		return key[len(removePrefix):], true
+	}
+	return "", false
+}
+
+// isRemoval returns whether the header key with value removes a
+// metadata item rather than setting it
+func isRemoval(key string, value string) bool {
+	return value == "" || strings.HasPrefix(http.CanonicalHeaderKey(key), "X-Remove-")
+}
+
+// split divides the headers h into as few batches as possible which
+// are each within the limits.  The headers which aren't metadata are
+// all put in the first batch.
+//
+// The items removed come before the items set so the removals are
+// made first, freeing room on the server for the items set.
+//
+// It returns a *MetadataLimitError if any item is too big on its own.
+func (l MetadataLimits) split(h Headers, metaPrefix string) ([]Headers, error) {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		removeI, removeJ := isRemoval(keys[i], h[keys[i]]), isRemoval(keys[j], h[keys[j]])
+		if removeI != removeJ {
+			return removeI
+		}
+		return keys[i] < keys[j]
+	})
+	batch := Headers{}
+	batches := []Headers{batch}
+	count, size := 0, 0
+	for _, key := range keys {
+		value := h[key]
+		name, isMeta := metadataName(key, metaPrefix)
+		if !isMeta {
+			batches[0][key] = value
+			continue
+		}
+		if strings.HasPrefix(http.CanonicalHeaderKey(key), "X-Remove-") {
+			// The server removes items by setting them empty
+			value = ""
+		}
+		switch {
+		case len(name) > l.NameLength:
+			return nil, &MetadataLimitError{Key: key, Reason: fmt.Sprintf("name longer than %d", l.NameLength)}
+		case len(value) > l.ValueLength:
+			return nil, &MetadataLimitError{Key: key, Reason: fmt.Sprintf("value longer than %d", l.ValueLength)}
+		case len(name)+len(value) > l.OverallSize:
+			return nil, &MetadataLimitError{Key: key, Reason: fmt.Sprintf("name and value longer than %d", l.OverallSize)}
+		}
+		if count+1 > l.Count || size+len(name)+len(value) > l.OverallSize {
+			batch = Headers{}
+			batches = append(batches, batch)
+			count, size = 0, 0
+		}
+		batch[key] = h[key]
+		count++
+		size += len(name) + len(value)
+	}
+	return batches, nil
+}
+
+// splitMetadata divides h into batches within the server's limits on
+// metadata.
+//
+// If /info hasn't been read already it is only read if h is over the
+// default limits, so most updates don't need it.
+func (c *Connection) splitMetadata(h Headers, metaPrefix string) ([]Headers, error) {
+	c.initAuthLock()
+	c.authLock.Lock()
+	info := c.swiftInfo
+	c.authLock.Unlock()
+	if info != nil {
+		return info.MetadataLimits().split(h, metaPrefix)
+	}
+	batches, err := DefaultMetadataLimits.split(h, metaPrefix)
+	if err == nil && len(batches) == 1 {
+		return batches, nil
+	}
+	if !c.Authenticated() {
+		if authErr := c.Authenticate(); authErr != nil {
+			return nil, authErr
+		}
+	}
+	if info, infoErr := c.cachedQueryInfo(); infoErr == nil {
+		batches, err = info.MetadataLimits().split(h, metaPrefix)
+	}
+	return batches, err
+}
+
+// postMetadata POSTs h to container, or the account if container is
+// "", splitting it into as many requests as needed to keep within
+// the server's limits on metadata.
+//
+// If a request fails after others have been made a
+// *MetadataPartialError saying which were applied is returned.
+func (c *Connection) postMetadata(container string, metaPrefix string, h Headers, options ...RequestOption) error {
+	batches, err := c.splitMetadata(h, metaPrefix)
+	if err != nil {
+		return err
+	}
+	for i, batch := range batches {
+		_, _, err = c.storage(RequestOpts{
+			Container:  container,
+			Operation:  "POST",
+			ErrorMap:   ContainerErrorMap,
+			NoResponse: true,
+			Headers:    batch,
+		}, options...)
+		if err != nil {
+			if i > 0 {
+				return &MetadataPartialError{Applied: batches[:i], Failed: batch, Err: err}
+			}
+			return err
+		}
+	}
+	return nil
+}
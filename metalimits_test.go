@@ -0,0 +1,108 @@
+// Tests for keeping metadata updates within the server's limits
+package swift
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestMetadataLimitsSplitRemovalsFirst(t *testing.T) {
+	limits := MetadataLimits{Count: 2, NameLength: 128, ValueLength: 256, OverallSize: 4096}
+	batches, err := limits.split(Headers{
+		"X-Container-Meta-A":        "1",
+		"X-Container-Meta-B":        "2",
+		"X-Container-Meta-C":        "",
+		"X-Remove-Container-Meta-D": "x",
+		"X-Container-Read":          ".r:*",
+	}, "X-Container-Meta-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Headers{
+		{"X-Container-Read": ".r:*", "X-Container-Meta-C": "", "X-Remove-Container-Meta-D": "x"},
+		{"X-Container-Meta-A": "1", "X-Container-Meta-B": "2"},
+	}
+	if len(batches) != len(want) {
+		t.Fatalf("want %d batches got %v", len(want), batches)
+	}
+	for i := range want {
+		if len(batches[i]) != len(want[i]) {
+			t.Errorf("batch %d: want %v got %v", i, want[i], batches[i])
+			continue
+		}
+		for key, value := range want[i] {
+			if got, ok := batches[i][key]; !ok || got != value {
+				t.Errorf("batch %d: want %v got %v", i, want[i], batches[i])
+				break
+			}
+		}
+	}
+}
+
+// failingPostTransport fails the POSTs after the first ok ones with a
+// 403
+type failingPostTransport struct {
+	ok int
+}
+
+func (t *failingPostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" {
+		if t.ok == 0 {
+			return &http.Response{
+				Status:     "403 Forbidden",
+				StatusCode: http.StatusForbidden,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}
+		t.ok--
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestMetadataPartialError(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	transport := &failingPostTransport{ok: 1}
+	c := &Connection{
+		UserName:  swifttest.TEST_ACCOUNT,
+		ApiKey:    swifttest.TEST_ACCOUNT,
+		AuthUrl:   srv.AuthURL,
+		Transport: transport,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.swiftInfo = SwiftInfo{"swift": map[string]interface{}{"max_meta_count": float64(1)}}
+
+	err = c.ContainerUpdate("container", Headers{
+		"X-Container-Meta-A":        "1",
+		"X-Remove-Container-Meta-B": "x",
+	})
+	var partialErr *MetadataPartialError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("expecting MetadataPartialError got %v", err)
+	}
+	if len(partialErr.Applied) != 1 || partialErr.Applied[0]["X-Remove-Container-Meta-B"] != "x" {
+		t.Errorf("bad applied batches %v", partialErr.Applied)
+	}
+	if partialErr.Failed["X-Container-Meta-A"] != "1" {
+		t.Errorf("bad failed batch %v", partialErr.Failed)
+	}
+	if !errors.Is(err, Forbidden) {
+		t.Errorf("expecting Forbidden got %v", err)
+	}
+
+	// Nothing applied returns the error of the request
+	transport.ok = 0
+	err = c.ContainerUpdate("container", Headers{"X-Container-Meta-A": "1"})
+	if !errors.Is(err, Forbidden) || errors.As(err, &partialErr) {
+		t.Errorf("expecting plain Forbidden got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Hooks for monitoring the requests a Connection makes
+
+package swift
+
+import (
+	"io"
+	"time"
+)
+
+// Metrics is told about the requests a Connection makes so they can
+// be monitored - set it as the Metrics of the Connection.
+// PrometheusMetrics is a ready made one.
+//
+// The operation is the HTTP method of the request, eg "GET" or "PUT".
+// The methods are called from many go routines at once so must be
+// safe for that, and shouldn't block as they are called while the
+// request is being made.
+type Metrics interface {
+	// Request is called when each request to storage is answered
+	// with the status code of the response, or 0 if there wasn't one,
+	// and how long it took to get the response headers.
+	Request(operation string, statusCode int, duration time.Duration)
+
+	// Retry is called when a request is about to be retried after
+	// an error or an expired token.
+	Retry(operation string)
+
+	// Authenticate is called after each authentication, including
+	// the refreshes of expired tokens, with its error if it failed.
+	Authenticate(err error)
+
+	// BytesUploaded is called as the body of a request is sent with
+	// the number of bytes just sent.
+	BytesUploaded(operation string, n int64)
+
+	// BytesDownloaded is called as the body of a response is read
+	// with the number of bytes just read.
+	BytesDownloaded(operation string, n int64)
+}
+
+// metricsReader counts the bytes read from the body of a request or
+// response
+type metricsReader struct {
+	io.Reader
+	fn        func(operation string, n int64)
+	operation string
+}
+
+// Read and count the bytes - see io.Reader
+func (r *metricsReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if n > 0 {
+		r.fn(r.operation, int64(n))
+	}
+	return n, err
+}
+
+// metricsReadCloser is a metricsReader of a response body
+type metricsReadCloser struct {
+	metricsReader
+	closer io.Closer
+}
+
+// Close the body - see io.Closer
+func (r *metricsReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// Check it satisfies the interfaces
+var (
+	_ io.Reader     = &metricsReader{}
+	_ io.ReadCloser = &metricsReadCloser{}
+)
@@ -0,0 +1,59 @@
+// Tests for the metrics hooks
+package swift
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	metrics := NewPrometheusMetrics()
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+		Metrics:  metrics,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectPutString("container", "object", "12345", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ObjectGetString("container", "object")
+	if err != nil || got != "12345" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	_, _, err = c.Object("container", "missing")
+	if err != ObjectNotFound {
+		t.Fatalf("expecting ObjectNotFound got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`swift_requests_total{operation="PUT",code="200"} 2`,
+		`swift_requests_total{operation="GET",code="200"} 1`,
+		`swift_requests_total{operation="HEAD",code="404"} 1`,
+		`swift_request_duration_seconds_bucket{operation="PUT",le="+Inf"} 2`,
+		`swift_request_duration_seconds_count{operation="GET"} 1`,
+		`swift_auth_total{result="ok"} 1`,
+		`swift_upload_bytes_total 5`,
+		`swift_download_bytes_total 5`,
+		`# TYPE swift_request_duration_seconds histogram`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("%q not in metrics:\n%s", want, body)
+		}
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type got %q", contentType)
+	}
+}
@@ -0,0 +1,135 @@
+// Checking container and object names before they are sent
+
+package swift
+
+import (
+	"strings"
+)
+
+// Default limits on the length of names in bytes as used by Swift
+// unless its /info says otherwise.
+const (
+	DefaultMaxContainerNameLength = 256
+	DefaultMaxObjectNameLength    = 1024
+)
+
+// InvalidName is wrapped by the errors returned for container and
+// object names which the server would reject.  Use errors.Is to check
+// for it.
+var InvalidName = newError(0, "Invalid container or object name")
+
+// MaxContainerNameLength returns the longest container name in bytes
+// the server accepts.
+func (i SwiftInfo) MaxContainerNameLength() int {
+	return i.swiftLimit("max_container_name_length", DefaultMaxContainerNameLength)
+}
+
+// MaxObjectNameLength returns the longest object name in bytes the
+// server accepts.
+func (i SwiftInfo) MaxObjectNameLength() int {
+	return i.swiftLimit("max_object_name_length", DefaultMaxObjectNameLength)
+}
+
+// swiftLimit reads the limit key from the "swift" section of /info
+// returning def if it isn't set
+func (i SwiftInfo) swiftLimit(key string, def int) int {
+	if swift, ok := i["swift"].(map[string]interface{}); ok {
+		if val, ok := swift[key].(float64); ok && val >= 1 {
+			return int(val)
+		}
+	}
+	return def
+}
+
+// ValidateContainerName returns an error wrapping InvalidName if
+// container is empty, contains a "/" or is longer than maxLength
+// bytes.
+func ValidateContainerName(container string, maxLength int) error {
+	switch {
+	case container == "":
+		return wrapErrorf(InvalidName, 0, "Invalid container name: empty")
+	case strings.Contains(container, "/"):
+		return wrapErrorf(InvalidName, 0, "Invalid container name %q: contains '/'", container)
+	case len(container) > maxLength:
+		return wrapErrorf(InvalidName, 0, "Invalid container name %q: %d bytes long, maximum is %d", container, len(container), maxLength)
+	}
+	return nil
+}
+
+// ValidateObjectName returns an error wrapping InvalidName if
+// objectName is empty or longer than maxLength bytes.
+func ValidateObjectName(objectName string, maxLength int) error {
+	switch {
+	case objectName == "":
+		return wrapErrorf(InvalidName, 0, "Invalid object name: empty")
+	case len(objectName) > maxLength:
+		return wrapErrorf(InvalidName, 0, "Invalid object name %q: %d bytes long, maximum is %d", objectName, len(objectName), maxLength)
+	}
+	return nil
+}
+
+// nameLimits returns the limits on container and object names using
+// /info if it has been read already.  No request is made to read it.
+//
+// The caller must have called initAuthLock.
+func (c *Connection) nameLimits() (maxContainer int, maxObject int) {
+	c.authLock.Lock()
+	info := c.swiftInfo
+	c.authLock.Unlock()
+	return info.MaxContainerNameLength(), info.MaxObjectNameLength()
+}
+
+// checkContainerName validates container before it is used by a
+// request which doesn't go through storage
+func (c *Connection) checkContainerName(container string) error {
+	maxContainer, _ := c.nameLimits()
+	return ValidateContainerName(container, maxContainer)
+}
+
+// checkObjectName validates container and objectName before they are
+// used by a request which doesn't go through storage
+func (c *Connection) checkObjectName(container string, objectName string) error {
+	maxContainer, maxObject := c.nameLimits()
+	if err := ValidateContainerName(container, maxContainer); err != nil {
+		return err
+	}
+	return ValidateObjectName(objectName, maxObject)
+}
+
+// requireContainerName checks container isn't empty for the calls
+// which would operate on the account instead.  The rest of the checks
+// are done by storage with checkRequestNames.
+func requireContainerName(container string) error {
+	if container == "" {
+		return ValidateContainerName(container, 0)
+	}
+	return nil
+}
+
+// requireObjectName checks container and objectName aren't empty for
+// the calls which would operate on the container or the account
+// instead.  The rest of the checks are done by storage with
+// checkRequestNames.
+func requireObjectName(container string, objectName string) error {
+	if err := requireContainerName(container); err != nil {
+		return err
+	}
+	if objectName == "" {
+		return ValidateObjectName(objectName, 0)
+	}
+	return nil
+}
+
+// checkRequestNames normalizes the object name in p with
+// NormalizeName and validates the names in p which are set.  An
+// object name without a container is invalid.
+func (c *Connection) checkRequestNames(p *RequestOpts) error {
+	p.ObjectName = c.NormalizeName.normalize(p.ObjectName)
+	if p.ObjectName != "" {
+		return c.checkObjectName(p.Container, p.ObjectName)
+	}
+	if p.Container != "" {
+		return c.checkContainerName(p.Container)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Tests for checking names
+package swift
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateContainerName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"container", ""},
+		{strings.Repeat("a", 256), ""},
+		{"", "Invalid container name: empty"},
+		{"a/b", `Invalid container name "a/b": contains '/'`},
+		{strings.Repeat("a", 257), "Invalid container name \"" + strings.Repeat("a", 257) + "\": 257 bytes long, maximum is 256"},
+	} {
+		err := ValidateContainerName(test.in, DefaultMaxContainerNameLength)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.in, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q got %v", test.in, test.want, err)
+		}
+		if !errors.Is(err, InvalidName) {
+			t.Errorf("%q: expecting InvalidName", test.in)
+		}
+	}
+}
+
+func TestValidateObjectName(t *testing.T) {
+	for _, test := range []struct {
+		in string
+		ok bool
+	}{
+		{"object", true},
+		{"dir/object", true},
+		{strings.Repeat("a", 1024), true},
+		{"", false},
+		{strings.Repeat("a", 1025), false},
+	} {
+		err := ValidateObjectName(test.in, DefaultMaxObjectNameLength)
+		if test.ok != (err == nil) {
+			t.Errorf("%q: want ok %v got %v", test.in, test.ok, err)
+		}
+		if err != nil && !errors.Is(err, InvalidName) {
+			t.Errorf("%q: expecting InvalidName", test.in)
+		}
+	}
+}
+
+func TestNameLimits(t *testing.T) {
+	if got := (SwiftInfo{}).MaxContainerNameLength(); got != DefaultMaxContainerNameLength {
+		t.Errorf("Bad default container limit %d", got)
+	}
+	info := SwiftInfo{"swift": map[string]interface{}{
+		"max_container_name_length": 100.0,
+		"max_object_name_length":    200.0,
+	}}
+	if info.MaxContainerNameLength() != 100 || info.MaxObjectNameLength() != 200 {
+		t.Errorf("Bad limits %d %d", info.MaxContainerNameLength(), info.MaxObjectNameLength())
+	}
+}
+
+func TestRequireNames(t *testing.T) {
+	if err := requireContainerName(""); !errors.Is(err, InvalidName) {
+		t.Errorf("empty container: expecting InvalidName got %v", err)
+	}
+	if err := requireObjectName("container", ""); !errors.Is(err, InvalidName) {
+		t.Errorf("empty object: expecting InvalidName got %v", err)
+	}
+	// The rest of the checks are left to storage
+	if err := requireObjectName("a/b", strings.Repeat("a", 2000)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestObjectCopyBadNameNotAuthenticated(t *testing.T) {
+	// The names are checked before anything else has made the
+	// authLock
+	c := &Connection{}
+	_, err := c.ObjectCopy("a/b", "object", "container", "object", nil)
+	if !errors.Is(err, InvalidName) {
+		t.Errorf("expecting InvalidName got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Confining objects to a prefix of a container
+
+package swift
+
+import (
+	"io"
+	"strings"
+)
+
+// NamespaceEscape is returned by the Namespace methods if an object
+// name would refer to an object outside the namespace
+var NamespaceEscape = newError(0, "Object name escapes namespace")
+
+// Namespace is a view of the objects under a fixed prefix of a
+// container, so several tenants or features can share one container
+// without seeing each other's objects.
+//
+// Object names passed to its methods are relative to the prefix and
+// the names returned in listings have the prefix removed.  Names
+// which are empty or have "." or ".." path elements are rejected with
+// NamespaceEscape so code using a Namespace can't refer to objects
+// outside it.
+//
+// Make one with Connection.Namespace.  A Namespace is safe for use
+// from multiple go routines if its Connection is.
+type Namespace struct {
+	c         *Connection
+	container string
+	prefix    string
+}
+
+// Namespace returns a Namespace for the objects in container whose
+// names start with prefix.
+//
+// A "/" is added to prefix if it doesn't end in one so one namespace
+// can't be a prefix of another, eg "tenant1" and "tenant10".
+func (c *Connection) Namespace(container string, prefix string) *Namespace {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Namespace{
+		c:         c,
+		container: container,
+		prefix:    prefix,
+	}
+}
+
+// Container returns the name of the container the namespace is in
+func (ns *Namespace) Container() string {
+	return ns.container
+}
+
+// Prefix returns the prefix of the namespace, which ends in "/"
+func (ns *Namespace) Prefix() string {
+	return ns.prefix
+}
+
+// FullName returns the name in the container of the object called
+// objectName in the namespace or NamespaceEscape if it isn't a valid
+// name in the namespace.
+func (ns *Namespace) FullName(objectName string) (string, error) {
+	if objectName == "" {
+		return "", NamespaceEscape
+	}
+	for _, element := range strings.Split(objectName, "/") {
+		if element == "." || element == ".." {
+			return "", NamespaceEscape
+		}
+	}
+	return ns.prefix + objectName, nil
+}
+
+// relativeName returns the name of an object in the namespace from
+// its name in the container
+func (ns *Namespace) relativeName(name string) string {
+	return strings.TrimPrefix(name, ns.prefix)
+}
+
+// relativeObject removes the prefix from the names in object
+func (ns *Namespace) relativeObject(object *Object) {
+	object.Name = ns.relativeName(object.Name)
+	if object.SubDir != "" {
+		object.SubDir = ns.relativeName(object.SubDir)
+	}
+}
+
+// listOpts returns a copy of opts with the prefix added to the
+// names in it
+func (ns *Namespace) listOpts(opts *ObjectsOpts) *ObjectsOpts {
+	newOpts := ObjectsOpts{}
+	if opts != nil {
+		newOpts = *opts
+	}
+	newOpts.Prefix = ns.prefix + newOpts.Prefix
+	if newOpts.Marker != "" {
+		newOpts.Marker = ns.prefix + newOpts.Marker
+	}
+	if newOpts.EndMarker != "" {
+		newOpts.EndMarker = ns.prefix + newOpts.EndMarker
+	}
+	if newOpts.Path != "" {
+		newOpts.Path = ns.prefix + newOpts.Path
+	}
+	return &newOpts
+}
+
+// relativeObjects removes the prefix from the names in objects
+func (ns *Namespace) relativeObjects(objects []Object, err error) ([]Object, error) {
+	for i := range objects {
+		ns.relativeObject(&objects[i])
+	}
+	return objects, err
+}
+
+// relativeNames removes the prefix from names
+func (ns *Namespace) relativeNames(names []string, err error) ([]string, error) {
+	for i := range names {
+		names[i] = ns.relativeName(names[i])
+	}
+	return names, err
+}
+
+// Objects returns a list of the objects in the namespace like
+// Connection.Objects.  The names in opts are relative to the
+// namespace.
+func (ns *Namespace) Objects(opts *ObjectsOpts) ([]Object, error) {
+	return ns.relativeObjects(ns.c.Objects(ns.container, ns.listOpts(opts)))
+}
+
+// ObjectsAll is like Objects but returns all the objects in the
+// namespace.
+func (ns *Namespace) ObjectsAll(opts *ObjectsOpts) ([]Object, error) {
+	return ns.relativeObjects(ns.c.ObjectsAll(ns.container, ns.listOpts(opts)))
+}
+
+// ObjectNames returns a list of the names of the objects in the
+// namespace like Connection.ObjectNames.  The names in opts are
+// relative to the namespace.
+func (ns *Namespace) ObjectNames(opts *ObjectsOpts) ([]string, error) {
+	return ns.relativeNames(ns.c.ObjectNames(ns.container, ns.listOpts(opts)))
+}
+
+// ObjectNamesAll is like ObjectNames but returns all the object names
+// in the namespace.
+func (ns *Namespace) ObjectNamesAll(opts *ObjectsOpts) ([]string, error) {
+	return ns.relativeNames(ns.c.ObjectNamesAll(ns.container, ns.listOpts(opts)))
+}
+
+// Object returns info about a single object in the namespace like
+// Connection.Object.
+func (ns *Namespace) Object(objectName string) (info Object, headers Headers, err error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return
+	}
+	info, headers, err = ns.c.Object(ns.container, name)
+	ns.relativeObject(&info)
+	return
+}
+
+// ObjectCreate creates or updates an object in the namespace like
+// Connection.ObjectCreate.
+func (ns *Namespace) ObjectCreate(objectName string, checkHash bool, Hash string, contentType string, h Headers) (*ObjectCreateFile, error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return nil, err
+	}
+	return ns.c.ObjectCreate(ns.container, name, checkHash, Hash, contentType, h)
+}
+
+// ObjectPut creates or updates an object in the namespace like
+// Connection.ObjectPut.
+func (ns *Namespace) ObjectPut(objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers) (Headers, error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return nil, err
+	}
+	return ns.c.ObjectPut(ns.container, name, contents, checkHash, Hash, contentType, h)
+}
+
+// ObjectPutBytes creates an object in the namespace from a []byte
+// like Connection.ObjectPutBytes.
+func (ns *Namespace) ObjectPutBytes(objectName string, contents []byte, contentType string) error {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return err
+	}
+	return ns.c.ObjectPutBytes(ns.container, name, contents, contentType)
+}
+
+// ObjectPutString creates an object in the namespace from a string
+// like Connection.ObjectPutString.
+func (ns *Namespace) ObjectPutString(objectName string, contents string, contentType string) error {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return err
+	}
+	return ns.c.ObjectPutString(ns.container, name, contents, contentType)
+}
+
+// ObjectOpen opens an object in the namespace for reading like
+// Connection.ObjectOpen.
+func (ns *Namespace) ObjectOpen(objectName string, checkHash bool, h Headers) (*ObjectOpenFile, Headers, error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ns.c.ObjectOpen(ns.container, name, checkHash, h)
+}
+
+// ObjectGet gets an object in the namespace like Connection.ObjectGet.
+func (ns *Namespace) ObjectGet(objectName string, contents io.Writer, checkHash bool, h Headers) (Headers, error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return nil, err
+	}
+	return ns.c.ObjectGet(ns.container, name, contents, checkHash, h)
+}
+
+// ObjectGetBytes returns an object in the namespace as a []byte like
+// Connection.ObjectGetBytes.
+func (ns *Namespace) ObjectGetBytes(objectName string) ([]byte, error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return nil, err
+	}
+	return ns.c.ObjectGetBytes(ns.container, name)
+}
+
+// ObjectGetString returns an object in the namespace as a string like
+// Connection.ObjectGetString.
+func (ns *Namespace) ObjectGetString(objectName string) (string, error) {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return "", err
+	}
+	return ns.c.ObjectGetString(ns.container, name)
+}
+
+// ObjectDelete deletes an object in the namespace like
+// Connection.ObjectDelete.
+func (ns *Namespace) ObjectDelete(objectName string) error {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return err
+	}
+	return ns.c.ObjectDelete(ns.container, name)
+}
+
+// ObjectUpdate adds, replaces or removes object metadata of an object
+// in the namespace like Connection.ObjectUpdate.
+func (ns *Namespace) ObjectUpdate(objectName string, h Headers) error {
+	name, err := ns.FullName(objectName)
+	if err != nil {
+		return err
+	}
+	return ns.c.ObjectUpdate(ns.container, name, h)
+}
+
+// ObjectCopy does a server side copy of an object to a new position
+// in the namespace like Connection.ObjectCopy.
+func (ns *Namespace) ObjectCopy(srcObjectName string, dstObjectName string, h Headers) (Headers, error) {
+	srcName, err := ns.FullName(srcObjectName)
+	if err != nil {
+		return nil, err
+	}
+	dstName, err := ns.FullName(dstObjectName)
+	if err != nil {
+		return nil, err
+	}
+	return ns.c.ObjectCopy(ns.container, srcName, ns.container, dstName, h)
+}
+
+// ObjectMove does a server side move of an object to a new position
+// in the namespace like Connection.ObjectMove.
+func (ns *Namespace) ObjectMove(srcObjectName string, dstObjectName string) error {
+	srcName, err := ns.FullName(srcObjectName)
+	if err != nil {
+		return err
+	}
+	dstName, err := ns.FullName(dstObjectName)
+	if err != nil {
+		return err
+	}
+	return ns.c.ObjectMove(ns.container, srcName, ns.container, dstName)
+}
@@ -0,0 +1,31 @@
+// Unicode normalization of object names
+
+package swift
+
+// NameNormalizer converts an object name to a normal form.
+//
+// Swift compares names byte by byte so the same name written in
+// different Unicode normal forms, eg "é" as one code point or as "e"
+// followed by a combining accent as macOS produces, names different
+// objects.  Set Connection.NormalizeName to a NameNormalizer such as
+// norm.NFC.String from golang.org/x/text/unicode/norm to keep them
+// the same.  It is applied to every object name the Connection sends
+// so objects can be read, written and deleted with their names in any
+// form.
+type NameNormalizer func(name string) string
+
+// normalize returns name converted by n, or name if n is nil
+func (n NameNormalizer) normalize(name string) string {
+	if n == nil {
+		return name
+	}
+	return n(name)
+}
+
+// DiffObjects is like the package function DiffObjects but matches
+// objects whose names are the same after normalizing them with n.
+//
+// The objects returned have their names as they are in the listings.
+func (n NameNormalizer) DiffObjects(from []Object, to []Object) *ObjectsDiff {
+	return diffObjects(from, to, n.normalize)
+}
@@ -0,0 +1,37 @@
+// Tests for normalizing names
+package swift
+
+import (
+	"strings"
+	"testing"
+)
+
+// composeE is a NameNormalizer which only composes "e" with an acute
+// accent
+func composeE(name string) string {
+	return strings.Replace(name, "e\u0301", "\u00e9", -1)
+}
+
+func TestNameNormalizerDiffObjects(t *testing.T) {
+	nfd := "cafe\u0301"
+	nfc := "caf\u00e9"
+	from := []Object{{Name: nfd, Hash: "1"}, {Name: "z"}}
+	to := []Object{{Name: nfc, Hash: "2"}, {Name: "z"}}
+
+	diff := DiffObjects(from, to)
+	if len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Modified) != 0 {
+		t.Errorf("Bad diff without normalizer %+v", diff)
+	}
+
+	diff = NameNormalizer(composeE).DiffObjects(from, to)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Modified) != 1 {
+		t.Fatalf("Bad diff with normalizer %+v", diff)
+	}
+	if diff.Modified[0].Name != nfc {
+		t.Errorf("Name not as listed %q", diff.Modified[0].Name)
+	}
+
+	if got := NameNormalizer(nil).normalize(nfd); got != nfd {
+		t.Errorf("nil normalizer changed name %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+// Options structs for uploading and downloading objects
+
+package swift
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ObjectPutOpts is options for ObjectPutWithOpts and
+// ObjectCreateWithOpts
+type ObjectPutOpts struct {
+	CheckHash   bool          // Calculate the MD5 while uploading and check it against the server's
+	Hash        string        // MD5 of the contents if known in advance - sent as the Etag for the server to check
+	ContentType string        // Content type - guessed from the object name and contents if empty
+	Size        int64         // Size of the contents if known - sent as the Content-Length, 0 for unknown
+	Metadata    Metadata      // User metadata for the object - can be nil
+	DeleteAt    time.Time     // Time the object expires - zero for never
+	DeleteAfter time.Duration // How long after the upload the object expires - used if DeleteAt isn't set
+	IfNotExists bool          // Only create the object if it doesn't exist, returning ObjectExists if it does
+	Headers     Headers       // Any additional HTTP headers - can be nil
+}
+
+// headers returns the HTTP headers for opts which may be nil.
+// Headers overrides any of the others.
+func (opts *ObjectPutOpts) headers() Headers {
+	h := Headers{}
+	if opts == nil {
+		return h
+	}
+	for key, value := range opts.Metadata.ObjectHeaders() {
+		h[key] = value
+	}
+	for key, value := range deleteAtHeaders(opts.DeleteAt, opts.DeleteAfter) {
+		h[key] = value
+	}
+	if opts.Size > 0 {
+		h["Content-Length"] = strconv.FormatInt(opts.Size, 10)
+	}
+	if opts.IfNotExists {
+		h["If-None-Match"] = "*"
+	}
+	for key, value := range opts.Headers {
+		h[key] = value
+	}
+	return h
+}
+
+// ObjectPutWithOpts creates or updates the object in the container
+// from contents as described by opts, which may be nil.
+//
+// This is ObjectPut with the arguments collected into a struct, see
+// it for details of CheckHash, Hash and ContentType.
+func (c *Connection) ObjectPutWithOpts(container string, objectName string, contents io.Reader, opts *ObjectPutOpts, options ...RequestOption) (headers Headers, err error) {
+	if opts == nil {
+		opts = &ObjectPutOpts{}
+	}
+	return c.objectPut(container, objectName, contents, opts.CheckHash, opts.Hash, opts.ContentType, opts.headers(), nil, options...)
+}
+
+// ObjectCreateWithOpts creates or updates the object in the container
+// returning an io.WriteCloser to write the contents to as described
+// by opts, which may be nil.
+//
+// This is ObjectCreate with the arguments collected into a struct,
+// see it for details.  You MUST call Close() on the result and check
+// its error.
+func (c *Connection) ObjectCreateWithOpts(container string, objectName string, opts *ObjectPutOpts, options ...RequestOption) (file *ObjectCreateFile, err error) {
+	if opts == nil {
+		opts = &ObjectPutOpts{}
+	}
+	return c.objectCreate(container, objectName, opts.CheckHash, opts.Hash, opts.ContentType, opts.headers(), options...)
+}
+
+// ObjectGetOpts is options for ObjectGetWithOpts and
+// ObjectOpenWithOpts
+type ObjectGetOpts struct {
+	CheckHash  bool        // Check the MD5 of the contents as they are received - ignored when reading a range
+	Offset     int64       // Offset in the object to start reading from
+	Length     int64       // Number of bytes to read from Offset - 0 for the rest of the object
+	Conditions *Conditions // Conditions the object must satisfy - can be nil
+	Decompress bool        // Decompress objects stored with Content-Encoding: gzip
+	Headers    Headers     // Any additional HTTP headers - can be nil
+}
+
+// headers returns the HTTP headers for opts which may be nil.
+// Headers overrides any of the others.
+func (opts *ObjectGetOpts) headers() Headers {
+	h := Headers{}
+	if opts == nil {
+		return h
+	}
+	if opts.Conditions != nil {
+		h = opts.Conditions.Headers()
+	}
+	if opts.Length > 0 {
+		h["Range"] = fmt.Sprintf("bytes=%d-%d", opts.Offset, opts.Offset+opts.Length-1)
+	} else if opts.Offset > 0 {
+		h["Range"] = fmt.Sprintf("bytes=%d-", opts.Offset)
+	}
+	for key, value := range opts.Headers {
+		h[key] = value
+	}
+	return h
+}
+
+// ObjectOpenWithOpts returns an ObjectOpenFile for reading the
+// contents of the object as described by opts, which may be nil.
+//
+// This is ObjectOpen with the arguments collected into a struct, see
+// it for details.  You must call Close() on the result.
+//
+// Setting opts.Decompress is the same as passing WithGzip().
+func (c *Connection) ObjectOpenWithOpts(container string, objectName string, opts *ObjectGetOpts, options ...RequestOption) (file *ObjectOpenFile, headers Headers, err error) {
+	h := opts.headers()
+	checkHash := opts != nil && opts.CheckHash && h["Range"] == ""
+	if opts != nil && opts.Decompress {
+		options = append(options[:len(options):len(options)], WithGzip())
+	}
+	return c.objectOpen(container, objectName, checkHash, h, nil, options...)
+}
+
+// ObjectGetWithOpts gets the object into the io.Writer contents as
+// described by opts, which may be nil.
+//
+// This is ObjectGet with the arguments collected into a struct, see
+// it for details.
+func (c *Connection) ObjectGetWithOpts(container string, objectName string, contents io.Writer, opts *ObjectGetOpts, options ...RequestOption) (headers Headers, err error) {
+	file, headers, err := c.ObjectOpenWithOpts(container, objectName, opts, options...)
+	if err != nil {
+		return
+	}
+	defer checkClose(file, &err)
+	_, err = io.Copy(contents, file)
+	return
+}
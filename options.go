@@ -0,0 +1,94 @@
+// Functional options for requests
+
+package swift
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// RequestOption changes the request an operation makes.
+//
+// The operations of a Connection accept any number of them after
+// their other arguments.  The operations which didn't take options
+// originally keep their signatures and have an XWithOptions variant
+// which does, eg
+//
+//	c.ObjectDeleteWithOptions(container, objectName, swift.WithContext(ctx), swift.WithRetries(0))
+//
+// apart from ObjectPut, ObjectCreate, ObjectGet and ObjectOpen whose
+// variants taking options are ObjectPutWithOpts, ObjectCreateWithOpts,
+// ObjectGetWithOpts and ObjectOpenWithOpts.
+//
+// The options are applied after the operation has set up its own
+// request so they can override the headers and parameters it uses.
+// Operations which make several requests apply them to all of them.
+type RequestOption func(*RequestOpts)
+
+// apply calls each of options on p
+func (p *RequestOpts) apply(options []RequestOption) {
+	for _, option := range options {
+		if option != nil {
+			option(p)
+		}
+	}
+}
+
+// WithHeaders adds h to the headers of the request, replacing any the
+// operation set with the same key.
+func WithHeaders(h Headers) RequestOption {
+	return func(p *RequestOpts) {
+		headers := make(Headers, len(p.Headers)+len(h))
+		for k, v := range p.Headers {
+			headers[k] = v
+		}
+		for k, v := range h {
+			headers[k] = v
+		}
+		p.Headers = headers
+	}
+}
+
+// WithParams adds v to the query parameters of the request, replacing
+// any the operation set with the same key.
+func WithParams(v url.Values) RequestOption {
+	return func(p *RequestOpts) {
+		parameters := make(url.Values, len(p.Parameters)+len(v))
+		for k, values := range p.Parameters {
+			parameters[k] = values
+		}
+		for k, values := range v {
+			parameters[k] = values
+		}
+		p.Parameters = parameters
+	}
+}
+
+// WithContext makes the request, and any re-authentication it needs,
+// use ctx so it can be cancelled.
+func WithContext(ctx context.Context) RequestOption {
+	return func(p *RequestOpts) {
+		p.Context = ctx
+	}
+}
+
+// WithRetries sets the number of times the request is retried,
+// overriding Connection.Retries.  0 means the request isn't retried.
+func WithRetries(retries int) RequestOption {
+	if retries <= 0 {
+		retries = -1
+	}
+	return func(p *RequestOpts) {
+		p.Retries = retries
+	}
+}
+
+// WithTimeout sets both the connect and the data timeouts of the
+// request, overriding Connection.ConnectTimeout and
+// Connection.Timeout.
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(p *RequestOpts) {
+		p.Timeout = timeout
+	}
+}
@@ -0,0 +1,219 @@
+// Downloading one object in parallel ranges
+
+package swift
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultParallelWorkers is the number of ranges ObjectGetParallel
+// downloads at once if ParallelOpts.Workers isn't set
+const DefaultParallelWorkers = 4
+
+// DefaultParallelChunkSize is the size of the ranges ObjectGetParallel
+// downloads if ParallelOpts.ChunkSize isn't set
+const DefaultParallelChunkSize = 64 * 1024 * 1024
+
+// ParallelOpts is options for ObjectGetParallel
+type ParallelOpts struct {
+	Workers        int     // Number of ranges to download at once - 0 for DefaultParallelWorkers
+	ChunkSize      int64   // Size of each range - 0 for DefaultParallelChunkSize
+	VerifySegments bool    // If set download an SLO a segment at a time and check each one against the manifest
+	Headers        Headers // Additional headers for each request - can be nil
+}
+
+// parallelRange is a range of an object to be downloaded
+type parallelRange struct {
+	offset int64
+	length int64
+	etag   string // MD5 hash the range should have - "" not to check it
+}
+
+// offsetWriter writes to an io.WriterAt sequentially from an offset
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+// Write writes p at the current offset - see io.Writer
+func (ow *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = ow.w.WriteAt(p, ow.off)
+	ow.off += int64(n)
+	return n, err
+}
+
+// sloRanges returns a range for each of the segments of an SLO with
+// their MD5 hashes from the manifest and the total size of the
+// segments.
+//
+// The hash of a segment which is itself an SLO is the hash of its
+// manifest, and the hash of a segment of which only a range is used
+// is the hash of all of it, so neither are checked.
+func sloRanges(segments []swiftSegment) (ranges []parallelRange, size int64) {
+	for _, segment := range segments {
+		if segment.Bytes == 0 {
+			continue
+		}
+		etag := segment.Hash
+		if segment.SubSLO || segment.Range != "" {
+			etag = ""
+		}
+		ranges = append(ranges, parallelRange{
+			offset: size,
+			length: segment.Bytes,
+			etag:   etag,
+		})
+		size += segment.Bytes
+	}
+	return ranges, size
+}
+
+// parallelRanges splits an object of size bytes into ranges.
+//
+// If verify is set and the object is an SLO then the ranges are its
+// segments with their MD5 hashes from the manifest as made by
+// sloRanges.  If the segments don't add up to the size of the object,
+// for example if it is an SLO using ranges of its segments, then it
+// is split into chunks as normal.
+func (c *Connection) parallelRanges(container string, objectName string, size int64, headers Headers, verify bool, chunkSize int64) ([]parallelRange, error) {
+	var ranges []parallelRange
+	if verify && headers.IsLargeObjectSLO() {
+		segments, err := c.getSLOManifest(container, objectName)
+		if err != nil {
+			return nil, err
+		}
+		ranges, total := sloRanges(segments)
+		if total == size {
+			return ranges, nil
+		}
+	}
+	for offset := int64(0); offset < size; offset += chunkSize {
+		length := chunkSize
+		if offset+length > size {
+			length = size - offset
+		}
+		ranges = append(ranges, parallelRange{offset: offset, length: length})
+	}
+	return ranges, nil
+}
+
+// getRange downloads one range of the object with etag into w.  If
+// the object no longer has etag PreconditionFailed is returned.
+func (c *Connection) getRange(container string, objectName string, etag string, w io.WriterAt, r parallelRange, h Headers) (err error) {
+	rangeHeaders := Headers{}
+	if etag != "" {
+		rangeHeaders["If-Match"] = etag
+	}
+	for k, v := range h {
+		rangeHeaders[k] = v
+	}
+	rangeHeaders["Range"] = fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+r.length-1)
+	file, _, err := c.ObjectOpen(container, objectName, false, rangeHeaders)
+	if err != nil {
+		return err
+	}
+	defer checkClose(file, &err)
+	var out io.Writer = &offsetWriter{w: w, off: r.offset}
+	hash := md5.New()
+	if r.etag != "" {
+		out = io.MultiWriter(out, hash)
+	}
+	n, err := io.Copy(out, io.LimitReader(file, r.length))
+	if err != nil {
+		return err
+	}
+	if n != r.length {
+		return io.ErrUnexpectedEOF
+	}
+	if r.etag != "" && !etagMatches(r.etag, fmt.Sprintf("%x", hash.Sum(nil))) {
+		return ObjectCorrupted
+	}
+	return nil
+}
+
+// ObjectGetParallel downloads the object into w, Workers ranges at a
+// time, which is faster than ObjectGet for big objects on fast
+// networks.  The ranges may be written in any order.
+//
+// The composite Etag of a large object can't be checked against the
+// data received so if VerifySegments is set an SLO is downloaded one
+// segment per range and each segment is checked against its MD5 hash
+// in the manifest.  ObjectCorrupted is returned if any of them don't
+// match.  If the segments can't be checked, for example because the
+// manifest uses ranges of its segments, the object is downloaded
+// without checking them.
+//
+// The ranges are requested with If-Match set to the Etag the object
+// had when it was first looked at, so if it is changed during the
+// download PreconditionFailed is returned rather than a mixture of
+// the old and new contents.
+//
+// It returns the headers of the object.  If a range fails the first
+// error is returned once the ranges in progress have finished and
+// the contents of w should not be used.
+//
+// opts may be nil for the defaults.
+func (c *Connection) ObjectGetParallel(container string, objectName string, w io.WriterAt, opts *ParallelOpts) (headers Headers, err error) {
+	workers := DefaultParallelWorkers
+	chunkSize := int64(DefaultParallelChunkSize)
+	verify := false
+	var h Headers
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		if opts.ChunkSize > 0 {
+			chunkSize = opts.ChunkSize
+		}
+		verify = opts.VerifySegments
+		h = opts.Headers
+	}
+	info, headers, err := c.Object(container, objectName)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := c.parallelRanges(container, objectName, info.Bytes, headers, verify, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		in   = make(chan parallelRange)
+		stop = make(chan struct{})
+	)
+	setErr := func(newErr error) {
+		mu.Lock()
+		if err == nil {
+			err = newErr
+			close(stop)
+		}
+		mu.Unlock()
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range in {
+				if rangeErr := c.getRange(container, objectName, headers["Etag"], w, r, h); rangeErr != nil {
+					setErr(rangeErr)
+				}
+			}
+		}()
+	}
+feed:
+	for _, r := range ranges {
+		select {
+		case in <- r:
+		case <-stop:
+			break feed
+		}
+	}
+	close(in)
+	wg.Wait()
+	return headers, err
+}
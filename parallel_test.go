@@ -0,0 +1,91 @@
+// Tests for downloading objects in parallel ranges
+package swift
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestSLORanges(t *testing.T) {
+	ranges, size := sloRanges([]swiftSegment{
+		{Name: "/segments/0", Hash: "hash0", Bytes: 10},
+		{Name: "/segments/sub", Hash: "manifesthash", Bytes: 20, SubSLO: true},
+		{Name: "/segments/empty", Hash: "hash2", Bytes: 0},
+		{Name: "/segments/part", Hash: "wholehash", Bytes: 5, Range: "0-4"},
+		{Name: "/segments/3", Hash: "hash3", Bytes: 7},
+	})
+	want := []parallelRange{
+		{offset: 0, length: 10, etag: "hash0"},
+		{offset: 10, length: 20},
+		{offset: 30, length: 5},
+		{offset: 35, length: 7, etag: "hash3"},
+	}
+	if size != 42 || len(ranges) != len(want) {
+		t.Fatalf("got size %d ranges %+v", size, ranges)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("range %d: want %+v got %+v", i, want[i], ranges[i])
+		}
+	}
+}
+
+// sliceAt is an io.WriterAt writing into a fixed size buffer
+type sliceAt []byte
+
+func (b sliceAt) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(b)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(b[off:], p), nil
+}
+
+// changingTransport calls change once before the first range request
+type changingTransport struct {
+	once   sync.Once
+	change func()
+}
+
+func (t *changingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Range") != "" {
+		t.once.Do(t.change)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestObjectGetParallelChanged(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	other := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := other.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.ObjectPutString("container", "object", "0123456789", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+		Transport: &changingTransport{change: func() {
+			if err := other.ObjectPutString("container", "object", "abcdefghij", ""); err != nil {
+				t.Error(err)
+			}
+		}},
+	}
+	buf := make([]byte, 10)
+	_, err = c.ObjectGetParallel("container", "object", sliceAt(buf), &ParallelOpts{ChunkSize: 4})
+	if !errors.Is(err, PreconditionFailed) {
+		t.Errorf("expecting PreconditionFailed got %v", err)
+	}
+}
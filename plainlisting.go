@@ -0,0 +1,141 @@
+// Falling back to plain text listings for proxies which don't do JSON
+
+package swift
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// jsonRejected returns whether err from a listing with format=json
+// is the server saying it can't do JSON listings.
+//
+// Only 406 Not Acceptable and 415 Unsupported Media Type count - a
+// 400 Bad Request could be any problem with the listing, so falling
+// back on it would switch a working server to plain listings for
+// good.
+func jsonRejected(err error) bool {
+	var swiftErr *Error
+	if errors.As(err, &swiftErr) {
+		switch swiftErr.StatusCode {
+		case 406, 415:
+			return true
+		}
+	}
+	return false
+}
+
+// peekedBody is a response body which has had its start read with a
+// bufio.Reader
+type peekedBody struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// isPlainListing returns whether resp to a listing with format=json
+// is a plain text listing because the server ignored the format.
+//
+// Servers don't always set the Content-Type of JSON listings properly
+// so the start of the body is checked too.
+func isPlainListing(resp *http.Response) bool {
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		return false
+	}
+	body := bufio.NewReader(resp.Body)
+	resp.Body = peekedBody{Reader: body, Closer: resp.Body}
+	start, _ := body.Peek(512)
+	start = bytes.TrimLeft(start, " \t\r\n")
+	return len(start) > 0 && start[0] != '[' && start[0] != '{'
+}
+
+// plainToJson replaces the body of resp, a plain text listing, with a
+// JSON listing of the names.  If delimiter is set then names ending
+// with it are returned as pseudo directories.
+func plainToJson(resp *http.Response, delimiter string) (*http.Response, error) {
+	lines, err := readLines(resp)
+	if err != nil {
+		return nil, err
+	}
+	listing := make([]map[string]string, len(lines))
+	for i, line := range lines {
+		if delimiter != "" && strings.HasSuffix(line, delimiter) {
+			listing[i] = map[string]string{"subdir": line}
+		} else {
+			listing[i] = map[string]string{"name": line}
+		}
+	}
+	data, err := json.Marshal(listing)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// jsonListing does a listing of container, or of the account if
+// container is "", with the parameters v and headers h returning a
+// response with a JSON body.
+//
+// Some old or restricted proxies reject format=json with a 406 or a
+// 415, or ignore it and return a text/plain listing.  If that happens
+// the listing is done again as plain text, if needed, and converted
+// to JSON with just the names, and the Connection remembers to ask
+// for plain text listings from then on.  Any other error is returned.
+func (c *Connection) jsonListing(container string, v url.Values, h Headers, options ...RequestOption) (*http.Response, error) {
+	delimiter := v.Get("delimiter")
+	list := func() (*http.Response, error) {
+		resp, _, err := c.storage(RequestOpts{
+			Container:  container,
+			Operation:  "GET",
+			Parameters: v,
+			ErrorMap:   ContainerErrorMap,
+			Headers:    h,
+		}, options...)
+		return resp, err
+	}
+	if !c.PlainListings() {
+		v.Set("format", "json")
+		resp, err := list()
+		if err == nil {
+			if !isPlainListing(resp) {
+				return resp, nil
+			}
+			atomic.StoreInt32(&c.plainListings, 1)
+			return plainToJson(resp, delimiter)
+		}
+		if !jsonRejected(err) {
+			return nil, err
+		}
+		v.Del("format")
+		resp, plainErr := list()
+		if plainErr != nil {
+			return nil, err
+		}
+		atomic.StoreInt32(&c.plainListings, 1)
+		return plainToJson(resp, delimiter)
+	}
+	v.Del("format")
+	resp, err := list()
+	if err != nil {
+		return nil, err
+	}
+	return plainToJson(resp, delimiter)
+}
+
+// PlainListings returns whether the listings have fallen back to plain
+// text because the server didn't do JSON listings.
+//
+// When this is set Objects and Containers only return the names, with
+// the other fields left empty.  Pseudo directories are still found if
+// a Delimiter is used.
+func (c *Connection) PlainListings() bool {
+	return atomic.LoadInt32(&c.plainListings) != 0
+}
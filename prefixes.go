@@ -0,0 +1,137 @@
+// Listing several prefixes of a container at once
+
+package swift
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultPrefixesWorkers is the number of listings ObjectsAllPrefixes
+// runs at once if PrefixesOpts.Workers isn't set
+const DefaultPrefixesWorkers = 8
+
+// PrefixesOpts is options for ObjectsAllPrefixes and
+// ObjectNamesAllPrefixes
+type PrefixesOpts struct {
+	Workers int          // Number of listings to run at once - 0 for DefaultPrefixesWorkers
+	Objects *ObjectsOpts // Options for each listing - Prefix is overridden - can be nil
+}
+
+// uniquePrefixes returns prefixes sorted with duplicates and any
+// prefixes which start with another of the prefixes removed, so
+// listing each of them gives every object once.
+func uniquePrefixes(prefixes []string) []string {
+	sorted := append([]string(nil), prefixes...)
+	sort.Strings(sorted)
+	var result []string
+	for _, prefix := range sorted {
+		if len(result) > 0 && strings.HasPrefix(prefix, result[len(result)-1]) {
+			continue
+		}
+		result = append(result, prefix)
+	}
+	return result
+}
+
+// reverse returns whether the listings are in reverse order so the
+// prefixes should be merged in reverse order too
+func (opts *PrefixesOpts) reverse() bool {
+	return opts != nil && opts.Objects != nil && opts.Objects.Reverse
+}
+
+// listPrefixes calls list for each of prefixes, workers at a time,
+// with the index of the prefix and options to list it with.  It
+// returns the first error.
+func (opts *PrefixesOpts) listPrefixes(prefixes []string, list func(i int, opts *ObjectsOpts) error) error {
+	workers := DefaultPrefixesWorkers
+	var baseOpts *ObjectsOpts
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		baseOpts = opts.Objects
+	}
+	var (
+		mu  sync.Mutex
+		err error
+		wg  sync.WaitGroup
+	)
+	in := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range in {
+				listOpts := objectsAllOpts(baseOpts, allObjectsLimit)
+				listOpts.Prefix = prefixes[i]
+				if listErr := list(i, listOpts); listErr != nil {
+					mu.Lock()
+					if err == nil {
+						err = listErr
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range prefixes {
+		in <- i
+	}
+	close(in)
+	wg.Wait()
+	return err
+}
+
+// ObjectsAllPrefixes lists all the objects in container whose names
+// start with any of prefixes.
+//
+// The prefixes are listed in parallel, Workers at a time, and the
+// results merged in name order.  Splitting the listing of a container
+// with very many objects into prefixes, eg "0" to "9" and "a" to "f"
+// for names starting with hex digits, makes it much quicker to read.
+// Prefixes which overlap are only listed once.
+//
+// opts may be nil for the defaults.
+func (c *Connection) ObjectsAllPrefixes(container string, prefixes []string, opts *PrefixesOpts) ([]Object, error) {
+	prefixes = uniquePrefixes(prefixes)
+	results := make([][]Object, len(prefixes))
+	err := opts.listPrefixes(prefixes, func(i int, listOpts *ObjectsOpts) (err error) {
+		results[i], err = c.ObjectsAll(container, listOpts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for i := range results {
+		if opts.reverse() {
+			i = len(results) - 1 - i
+		}
+		objects = append(objects, results[i]...)
+	}
+	return objects, nil
+}
+
+// ObjectNamesAllPrefixes is like ObjectsAllPrefixes but it returns
+// only the names of the objects.
+func (c *Connection) ObjectNamesAllPrefixes(container string, prefixes []string, opts *PrefixesOpts) ([]string, error) {
+	prefixes = uniquePrefixes(prefixes)
+	results := make([][]string, len(prefixes))
+	err := opts.listPrefixes(prefixes, func(i int, listOpts *ObjectsOpts) (err error) {
+		results[i], err = c.ObjectNamesAll(container, listOpts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := range results {
+		if opts.reverse() {
+			i = len(results) - 1 - i
+		}
+		names = append(names, results[i]...)
+	}
+	return names, nil
+}
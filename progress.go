@@ -0,0 +1,49 @@
+package swift
+
+import (
+	"io"
+)
+
+// ProgressFunc is called as data is transferred to or from an object.
+//
+// transferred is the number of bytes transferred so far and total is
+// the total size of the transfer or -1 if it isn't known.
+type ProgressFunc func(container, objectName string, transferred, total int64)
+
+// An io.Reader which reports the number of bytes read to a ProgressFunc
+type progressReader struct {
+	reader      io.Reader
+	fn          ProgressFunc
+	container   string
+	objectName  string
+	transferred int64
+	total       int64
+}
+
+// Returns a wrapper around the reader which calls fn whenever data
+// is read.  If fn is nil then reader is returned unchanged.
+func newProgressReader(reader io.Reader, fn ProgressFunc, container, objectName string, total int64) io.Reader {
+	if fn == nil {
+		return reader
+	}
+	return &progressReader{
+		reader:     reader,
+		fn:         fn,
+		container:  container,
+		objectName: objectName,
+		total:      total,
+	}
+}
+
+// Read reads up to len(p) bytes into p
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	if n > 0 {
+		p.transferred += int64(n)
+		p.fn(p.container, p.objectName, p.transferred, p.total)
+	}
+	return n, err
+}
+
+// Check it satisfies the interface
+var _ io.Reader = &progressReader{}
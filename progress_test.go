@@ -0,0 +1,46 @@
+// This tests progressReader
+
+package swift
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestProgressReader(t *testing.T) {
+	var calls []int64
+	fn := func(container, objectName string, transferred, total int64) {
+		if container != "container" || objectName != "object" {
+			t.Errorf("Bad names %q %q", container, objectName)
+		}
+		if total != 10 {
+			t.Errorf("Bad total %d", total)
+		}
+		calls = append(calls, transferred)
+	}
+	in := bytes.NewBufferString("0123456789")
+	pr := newProgressReader(ioutil.NopCloser(in), fn, "container", "object", 10)
+	buf := make([]byte, 4)
+	var got []byte
+	for {
+		n, err := pr.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if string(got) != "0123456789" {
+		t.Fatalf("Bad read %q", got)
+	}
+	if len(calls) != 3 || calls[0] != 4 || calls[1] != 8 || calls[2] != 10 {
+		t.Fatalf("Bad progress calls %v", calls)
+	}
+}
+
+func TestProgressReaderNil(t *testing.T) {
+	in := bytes.NewBufferString("hello")
+	if newProgressReader(in, nil, "", "", -1) != in {
+		t.Fatal("Expecting reader to be returned unchanged")
+	}
+}
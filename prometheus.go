@@ -0,0 +1,204 @@
+// Metrics in the Prometheus text format
+
+package swift
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PrometheusBuckets are the upper bounds in seconds of the buckets of
+// the request latency histogram of PrometheusMetrics
+var PrometheusBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics is Metrics which can be scraped by Prometheus.
+//
+// It is an http.Handler serving the metrics in the Prometheus text
+// format, so can be added to an existing /metrics page with a
+// reverse proxy, or served on its own with
+//
+//	metrics := swift.NewPrometheusMetrics()
+//	c.Metrics = metrics
+//	http.Handle("/metrics", metrics)
+//
+// The metrics are
+//
+//	swift_requests_total{operation,code}             counter of requests
+//	swift_request_duration_seconds{operation}        histogram of request latency
+//	swift_retries_total{operation}                   counter of retries
+//	swift_auth_total{result}                         counter of authentications, result "ok" or "error"
+//	swift_upload_bytes_total                         counter of bytes uploaded
+//	swift_download_bytes_total                       counter of bytes downloaded
+//
+// The code is "0" for requests which didn't get a response.  It can be
+// shared by several Connections to total their requests.
+//
+// It isn't a prometheus.Collector as that would make this package
+// depend on the Prometheus client library for everyone using it.
+// With the client library serve it from a separate registry, or
+// scrape it as its own target.
+type PrometheusMetrics struct {
+	// The byte counts are updated with sync/atomic as every Read
+	// of a body updates them, so they come first to be 64 bit
+	// aligned on 32 bit platforms
+	uploaded   int64
+	downloaded int64
+
+	mu        sync.Mutex
+	requests  map[[2]string]int64 // by operation and code
+	durations map[string]*histogram
+	retries   map[string]int64
+	auths     map[string]int64
+}
+
+// histogram counts the observations in each of PrometheusBuckets
+type histogram struct {
+	counts []int64 // cumulative count for each bucket
+	count  int64
+	sum    float64
+}
+
+// NewPrometheusMetrics returns a new PrometheusMetrics with all the
+// metrics zero
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		requests:  make(map[[2]string]int64),
+		durations: make(map[string]*histogram),
+		retries:   make(map[string]int64),
+		auths:     make(map[string]int64),
+	}
+}
+
+// Request counts the request and its latency - see Metrics
+func (m *PrometheusMetrics) Request(operation string, statusCode int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{operation, strconv.Itoa(statusCode)}]++
+	h, ok := m.durations[operation]
+	if !ok {
+		h = &histogram{counts: make([]int64, len(PrometheusBuckets))}
+		m.durations[operation] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range PrometheusBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Retry counts the retry - see Metrics
+func (m *PrometheusMetrics) Retry(operation string) {
+	m.mu.Lock()
+	m.retries[operation]++
+	m.mu.Unlock()
+}
+
+// Authenticate counts the authentication - see Metrics
+func (m *PrometheusMetrics) Authenticate(err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.mu.Lock()
+	m.auths[result]++
+	m.mu.Unlock()
+}
+
+// BytesUploaded counts the bytes - see Metrics
+func (m *PrometheusMetrics) BytesUploaded(operation string, n int64) {
+	atomic.AddInt64(&m.uploaded, n)
+}
+
+// BytesDownloaded counts the bytes - see Metrics
+func (m *PrometheusMetrics) BytesDownloaded(operation string, n int64) {
+	atomic.AddInt64(&m.downloaded, n)
+}
+
+// sortedKeys returns the keys of m sorted
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats f as Prometheus expects
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format - see
+// http.Handler
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	defer func() { _ = out.Flush() }()
+	header := func(name, kind, help string) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header("swift_requests_total", "counter", "Requests made to Swift by operation and status code.")
+	requests := make([][2]string, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i][0] != requests[j][0] {
+			return requests[i][0] < requests[j][0]
+		}
+		return requests[i][1] < requests[j][1]
+	})
+	for _, key := range requests {
+		fmt.Fprintf(out, "swift_requests_total{operation=%q,code=%q} %d\n", key[0], key[1], m.requests[key])
+	}
+
+	header("swift_request_duration_seconds", "histogram", "Time taken to get the response headers from Swift.")
+	operations := make([]string, 0, len(m.durations))
+	for operation := range m.durations {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		h := m.durations[operation]
+		for i, bound := range PrometheusBuckets {
+			fmt.Fprintf(out, "swift_request_duration_seconds_bucket{operation=%q,le=%q} %d\n", operation, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(out, "swift_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", operation, h.count)
+		fmt.Fprintf(out, "swift_request_duration_seconds_sum{operation=%q} %s\n", operation, formatFloat(h.sum))
+		fmt.Fprintf(out, "swift_request_duration_seconds_count{operation=%q} %d\n", operation, h.count)
+	}
+
+	header("swift_retries_total", "counter", "Requests to Swift retried by operation.")
+	for _, operation := range sortedKeys(m.retries) {
+		fmt.Fprintf(out, "swift_retries_total{operation=%q} %d\n", operation, m.retries[operation])
+	}
+
+	header("swift_auth_total", "counter", "Authentications with Swift by result.")
+	for _, result := range sortedKeys(m.auths) {
+		fmt.Fprintf(out, "swift_auth_total{result=%q} %d\n", result, m.auths[result])
+	}
+
+	header("swift_upload_bytes_total", "counter", "Bytes uploaded to Swift.")
+	fmt.Fprintf(out, "swift_upload_bytes_total %d\n", atomic.LoadInt64(&m.uploaded))
+	header("swift_download_bytes_total", "counter", "Bytes downloaded from Swift.")
+	fmt.Fprintf(out, "swift_download_bytes_total %d\n", atomic.LoadInt64(&m.downloaded))
+}
+
+// Check it satisfies the interfaces
+var (
+	_ Metrics      = &PrometheusMetrics{}
+	_ http.Handler = &PrometheusMetrics{}
+)
@@ -0,0 +1,143 @@
+// Workarounds for quirks of particular Swift providers
+
+package swift
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// ProviderProfile collects flags which work around the quirks of
+// the proxies used by particular Swift providers.
+//
+// The zero value uses the standard behaviour which works with most
+// providers.
+type ProviderProfile struct {
+	NoChunkedPut     bool // Don't use chunked transfer encoding - bodies of unknown length are buffered in memory to find the length
+	NoExpectContinue bool // Don't send "Expect: 100-continue" with requests which have a body
+	StripHostPort    bool // Remove the port from the Host header
+}
+
+// lengther is satisfied by readers which know how much data they
+// have left such as bytes.Buffer, bytes.Reader and strings.Reader
+type lengther interface {
+	Len() int
+}
+
+// fixedLengthBody returns the body and its length so that it can be
+// sent without chunked transfer encoding.
+//
+// If the length of the body can't be found without reading it, then
+// it is read into memory.
+func fixedLengthBody(body io.Reader) (io.Reader, int64, error) {
+	if l, ok := body.(lengther); ok {
+		return body, int64(l.Len()), nil
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// applyRequest adjusts the headers of req according to the profile.
+func (p *ProviderProfile) applyRequest(req *http.Request) {
+	if p.NoExpectContinue {
+		req.Header.Del("Expect")
+	}
+	if p.StripHostPort {
+		req.Host = req.URL.Hostname()
+	}
+}
+
+// Provider holds the preset settings for a public Swift service
+type Provider struct {
+	AuthUrl      string          // Auth URL
+	AuthVersion  int             // Auth version 1, 2 or 3
+	EndpointType EndpointType    // Endpoint type (v2,v3 auth only)
+	Domain       string          // Default user's domain name (v3 auth only)
+	Region       string          // Default region - empty for the first region
+	Profile      ProviderProfile // Workarounds for quirks of the provider's proxies
+}
+
+// Providers contains presets for common public Swift services
+// indexed by the name used with NewConnection.
+//
+// You can add your own presets to this before calling NewConnection.
+var Providers = map[string]Provider{
+	"rackspace-us": {
+		AuthUrl:      "https://identity.api.rackspacecloud.com/v2.0",
+		AuthVersion:  2,
+		EndpointType: EndpointTypePublic,
+	},
+	"rackspace-uk": {
+		AuthUrl:      "https://lon.identity.api.rackspacecloud.com/v2.0",
+		AuthVersion:  2,
+		EndpointType: EndpointTypePublic,
+	},
+	"ovh": {
+		AuthUrl:      "https://auth.cloud.ovh.net/v3",
+		AuthVersion:  3,
+		EndpointType: EndpointTypePublic,
+		Domain:       "Default",
+	},
+	"memset": {
+		AuthUrl:      "https://auth.storage.memset.com/v2.0",
+		AuthVersion:  2,
+		EndpointType: EndpointTypePublic,
+		Profile: ProviderProfile{
+			NoExpectContinue: true,
+		},
+	},
+	"citycloud": {
+		AuthUrl:      "https://identity1.citycloud.com:5000/v3/",
+		AuthVersion:  3,
+		EndpointType: EndpointTypePublic,
+		Domain:       "Default",
+	},
+}
+
+// Credentials holds the user specific parameters for NewConnection
+type Credentials struct {
+	UserName string // UserName for api
+	ApiKey   string // Key for api access
+	Tenant   string // Name of the tenant (v2,v3 auth only)
+	TenantId string // Id of the tenant (v2,v3 auth only)
+	Domain   string // User's domain name (v3 auth only) - overrides the provider's default
+	Region   string // Region to use - overrides the provider's default
+}
+
+// NewConnection makes a Connection for the named provider from
+// Providers using the credentials passed in.
+//
+// The Connection isn't authenticated - call Authenticate on it or it
+// will be authenticated on first use.
+func NewConnection(provider string, creds Credentials) (*Connection, error) {
+	p, ok := Providers[provider]
+	if !ok {
+		return nil, newErrorf(0, "unknown provider %q", provider)
+	}
+	c := &Connection{
+		UserName:     creds.UserName,
+		ApiKey:       creds.ApiKey,
+		AuthUrl:      p.AuthUrl,
+		AuthVersion:  p.AuthVersion,
+		EndpointType: p.EndpointType,
+		Tenant:       creds.Tenant,
+		TenantId:     creds.TenantId,
+		Domain:       p.Domain,
+		Region:       p.Region,
+		Profile:      p.Profile,
+		authLock:     &sync.Mutex{},
+	}
+	if creds.Domain != "" {
+		c.Domain = creds.Domain
+	}
+	if creds.Region != "" {
+		c.Region = creds.Region
+	}
+	return c, nil
+}
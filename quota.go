@@ -0,0 +1,63 @@
+// Setting container and account quotas
+
+package swift
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// quotaExceededBody is in the body of the 413 responses the quota
+// middlewares send, eg "Upload exceeds quota."
+var quotaExceededBody = []byte("quota")
+
+// isQuotaExceeded returns whether a 413 response was sent because a
+// quota was exceeded rather than the object being too big.
+//
+// It reads the start of the body and puts it back so resp.Body can be
+// read from the beginning again.
+func isQuotaExceeded(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body = &replayedBody{
+		Reader: io.MultiReader(bytes.NewReader(body), resp.Body),
+		Closer: resp.Body,
+	}
+	return bytes.Contains(bytes.ToLower(body), quotaExceededBody)
+}
+
+// replayedBody is a response body with its start put back
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// ContainerSetQuota sets the quotas of container to a maximum of
+// quotaBytes bytes and quotaCount objects.  0 removes the quota.
+//
+// Uploads which would exceed the quota fail with QuotaExceeded.  The
+// container_quotas middleware must be enabled for this to have any
+// effect.
+func (c *Connection) ContainerSetQuota(container string, quotaBytes int64, quotaCount int64) error {
+	return c.ContainerUpdate(container, Headers{
+		"X-Container-Meta-Quota-Bytes": formatQuota(quotaBytes),
+		"X-Container-Meta-Quota-Count": formatQuota(quotaCount),
+	})
+}
+
+// AccountSetQuota sets the quota of the account to a maximum of
+// quotaBytes bytes.  0 removes the quota.
+//
+// Only a reseller admin can set the quota of an account.  Uploads
+// which would exceed the quota fail with QuotaExceeded.  The
+// account_quotas middleware must be enabled for this to have any
+// effect.
+func (c *Connection) AccountSetQuota(quotaBytes int64) error {
+	return c.AccountUpdate(Headers{
+		"X-Account-Meta-Quota-Bytes": formatQuota(quotaBytes),
+	})
+}
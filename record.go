@@ -0,0 +1,255 @@
+// Recording HTTP requests so they can be replayed in tests
+
+package swift
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// redactedHeaders are the headers carrying credentials which aren't
+// recorded
+var redactedHeaders = []string{
+	"Authorization",
+	"X-Auth-Key",
+	"X-Auth-Token",
+	"X-Auth-User",
+	"X-Storage-Pass",
+	"X-Storage-Token",
+	"X-Subject-Token",
+	DefaultNewTokenHeader,
+}
+
+// redactedHeaderSuffixes are the endings of the names of headers
+// carrying credentials which aren't recorded, eg the temp URL keys
+// in X-Account-Meta-Temp-Url-Key and X-Container-Meta-Temp-Url-Key-2
+var redactedHeaderSuffixes = []string{
+	"-Temp-Url-Key",
+	"-Temp-Url-Key-2",
+}
+
+// Redacted replaces any credentials in a recording
+const Redacted = "REDACTED"
+
+// RecordedInteraction is a request and its response as recorded by a
+// Recorder.  Request bodies aren't recorded.
+type RecordedInteraction struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"` // path and query only
+	RequestHeaders  http.Header `json:"request_headers"`
+	StatusCode      int         `json:"status_code"`
+	Status          string      `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	ResponseBody    []byte      `json:"response_body"`
+}
+
+// recordedURL returns the path and query of u as recorded, with any
+// temp_url_sig removed
+func recordedURL(u *url.URL) string {
+	redacted := url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	return redactURL(&redacted)
+}
+
+// redactHeaders returns a copy of h with the credentials removed
+func redactHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, key := range redactedHeaders {
+		if h.Get(key) != "" {
+			h.Set(key, Redacted)
+		}
+	}
+	for key := range h {
+		canonical := http.CanonicalHeaderKey(key)
+		for _, suffix := range redactedHeaderSuffixes {
+			if strings.HasSuffix(canonical, suffix) {
+				h[key] = []string{Redacted}
+			}
+		}
+	}
+	return h
+}
+
+// redactAuthBody removes credentials from the body of an auth
+// request's response.  v2 auth returns the token in the body.
+func redactAuthBody(body []byte) []byte {
+	var v2 map[string]interface{}
+	if json.Unmarshal(body, &v2) != nil {
+		return body
+	}
+	access, _ := v2["access"].(map[string]interface{})
+	token, _ := access["token"].(map[string]interface{})
+	if _, ok := token["id"]; !ok {
+		return body
+	}
+	token["id"] = Redacted
+	redacted, err := json.Marshal(v2)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// Recorder is an http.RoundTripper which records the requests made
+// through it and their responses so they can be saved with Save and
+// replayed with a Replayer.
+//
+// Set it as the Transport of a Connection used in an integration
+// test.  Credentials in the headers, and the token in the body of v2
+// auth responses, are replaced with Redacted.  Responses are read
+// into memory so keep the objects used small.
+type Recorder struct {
+	Transport http.RoundTripper // Transport used for the requests - http.DefaultTransport if not set
+
+	mu           sync.Mutex
+	interactions []RecordedInteraction
+}
+
+// NewRecorder returns a Recorder making requests with transport which
+// may be nil.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+// RoundTrip makes the request with the Transport, recording it and
+// the response - see http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if strings.HasSuffix(req.URL.Path, "/tokens") {
+		body = redactAuthBody(body)
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, RecordedInteraction{
+		Method:          req.Method,
+		URL:             recordedURL(req.URL),
+		RequestHeaders:  redactHeaders(req.Header),
+		StatusCode:      resp.StatusCode,
+		Status:          resp.Status,
+		ResponseHeaders: redactHeaders(resp.Header),
+		ResponseBody:    body,
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Interactions returns the requests and responses recorded so far
+func (r *Recorder) Interactions() []RecordedInteraction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedInteraction(nil), r.interactions...)
+}
+
+// Save writes the interactions recorded so far to the file at path as
+// JSON
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Interactions(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+// Replayer is an http.RoundTripper which answers requests with the
+// responses recorded by a Recorder without making any network
+// connections.
+//
+// Each request is answered with the first unused interaction with
+// the same method, path and query.  The host isn't compared so the
+// Connection may use any AuthUrl with the same path.  Requests which
+// can't be answered return an error.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []RecordedInteraction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer of interactions
+func NewReplayer(interactions []RecordedInteraction) *Replayer {
+	return &Replayer{
+		interactions: interactions,
+		used:         make([]bool, len(interactions)),
+	}
+}
+
+// LoadReplayer returns a Replayer of the interactions saved by
+// Recorder.Save in the file at path
+func LoadReplayer(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []RecordedInteraction
+	if err = json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("failed to read recording %q: %w", path, err)
+	}
+	return NewReplayer(interactions), nil
+}
+
+// RoundTrip answers the request from the recording - see
+// http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(ioutil.Discard, req.Body)
+		_ = req.Body.Close()
+	}
+	u := recordedURL(req.URL)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Method != req.Method || interaction.URL != u {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        interaction.Status,
+			StatusCode:    interaction.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.ResponseHeaders.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(interaction.ResponseBody)),
+			ContentLength: int64(len(interaction.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, u)
+}
+
+// Unused returns the recorded interactions which haven't been
+// replayed yet
+func (r *Replayer) Unused() []RecordedInteraction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []RecordedInteraction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Check they satisfy the interface
+var (
+	_ http.RoundTripper = &Recorder{}
+	_ http.RoundTripper = &Replayer{}
+)
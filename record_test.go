@@ -0,0 +1,117 @@
+// Tests for recording and replaying requests
+package swift
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	recorder := NewRecorder(nil)
+	c := &Connection{
+		UserName:  swifttest.TEST_ACCOUNT,
+		ApiKey:    swifttest.TEST_ACCOUNT,
+		AuthUrl:   srv.AuthURL,
+		Transport: recorder,
+	}
+	run := func(c *Connection) {
+		err := c.ContainerCreate("container", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.ObjectPutString("container", "object", "hello", "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := c.ObjectGetString("container", "object")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != "hello" {
+			t.Errorf("Bad contents %q", contents)
+		}
+		_, _, err = c.Object("container", "missing")
+		if err != ObjectNotFound {
+			t.Errorf("Expecting ObjectNotFound got %v", err)
+		}
+	}
+	run(c)
+	path := filepath.Join(t.TempDir(), "recording.json")
+	err := recorder.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := c.CurrentAuthToken(); strings.Contains(string(data), token) {
+		t.Errorf("Auth token %q not redacted", token)
+	}
+
+	replayer, err := LoadReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(&Connection{
+		UserName:  swifttest.TEST_ACCOUNT,
+		ApiKey:    swifttest.TEST_ACCOUNT,
+		AuthUrl:   "http://replay.invalid/v1.0",
+		Transport: replayer,
+	})
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("Unused interactions %v", unused)
+	}
+	req, err := http.NewRequest("GET", "http://replay.invalid/v1/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replayer.RoundTrip(req)
+	if err == nil {
+		t.Error("Expecting error for request not recorded")
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{
+		"X-Auth-Token":                    {"token"},
+		"X-Account-Meta-Temp-Url-Key":     {"key1"},
+		"X-Account-Meta-Temp-Url-Key-2":   {"key2"},
+		"X-Container-Meta-Temp-Url-Key":   {"key3"},
+		"X-Container-Meta-Temp-Url-Key-2": {"key4"},
+		"x-container-meta-temp-url-key":   {"key5"},
+		"X-Object-Meta-Colour":            {"blue"},
+	}
+	got := redactHeaders(h)
+	for key, values := range got {
+		if key == "X-Object-Meta-Colour" {
+			if values[0] != "blue" {
+				t.Errorf("%s changed to %q", key, values)
+			}
+		} else if len(values) != 1 || values[0] != Redacted {
+			t.Errorf("%s not redacted: %q", key, values)
+		}
+	}
+	if h.Get("X-Account-Meta-Temp-Url-Key") != "key1" {
+		t.Error("original headers changed")
+	}
+}
+
+func TestRedactAuthBody(t *testing.T) {
+	body := redactAuthBody([]byte(`{"access":{"token":{"id":"secret","expires":"x"}}}`))
+	if strings.Contains(string(body), "secret") || !strings.Contains(string(body), Redacted) {
+		t.Errorf("Bad redaction %s", body)
+	}
+	other := []byte(`{"token":{"methods":["password"]}}`)
+	if got := redactAuthBody(other); string(got) != string(other) {
+		t.Errorf("Body changed %s", got)
+	}
+}
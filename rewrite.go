@@ -0,0 +1,143 @@
+// Rewriting the objects under a prefix
+
+package swift
+
+import (
+	"context"
+	"io"
+)
+
+// RewriteOpts is options for ObjectsRewrite and ReEncrypt
+type RewriteOpts struct {
+	Prefix     string             // Only rewrite objects whose names start with this
+	Marker     string             // Resume after this object - the last name passed to Checkpoint
+	Checkpoint func(string) error // If set called with the name of each object after it has been done
+}
+
+// RewriteFn is called by ObjectsRewrite for each object with its
+// listing, headers and contents.
+//
+// The contents are only downloaded if they are read.  Return the new
+// contents and headers to upload the object again, nil contents and
+// the new headers to change only the metadata with ObjectUpdate,
+// which is done on the server without transferring the data, or nil
+// for both to leave the object alone.
+//
+// The new headers should include all the metadata the object should
+// have as both uploading and updating replace it.
+type RewriteFn func(object *Object, headers Headers, contents io.Reader) (newContents io.Reader, newHeaders Headers, err error)
+
+// lazyObjectReader opens the object on the first Read
+type lazyObjectReader struct {
+	c          *Connection
+	container  string
+	objectName string
+	options    []RequestOption
+	file       *ObjectOpenFile
+}
+
+// Read opens the object if necessary and reads from it
+func (r *lazyObjectReader) Read(p []byte) (int, error) {
+	if r.file == nil {
+		file, _, err := r.c.ObjectOpenWithOpts(r.container, r.objectName, &ObjectGetOpts{CheckHash: true}, r.options...)
+		if err != nil {
+			return 0, err
+		}
+		r.file = file
+	}
+	return r.file.Read(p)
+}
+
+// Close the object if it was opened
+func (r *lazyObjectReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// ObjectsRewrite calls fn for each object in container whose name
+// starts with opts.Prefix and uploads or updates the object with
+// what it returns.  This is the basis for tools which transform the
+// objects in place.
+//
+// It doesn't know anything about encryption itself - to rotate the
+// objects encrypted with WithEncryption to a new key use ReEncrypt,
+// which is built on it.
+//
+// The objects are done one at a time in listing order, streaming the
+// contents from the old object to the new one.  With a Scheduler all
+// the requests share one slot as the listing and the download are read
+// while the other requests are made.  After each object is
+// done Checkpoint is called with its name.  If the rewrite is
+// interrupted it can be resumed by passing the last name saved from
+// Checkpoint as the Marker.
+//
+// Large objects are passed to fn like any other object, with the
+// contents of all their segments.  Uploading new contents replaces
+// the manifest with an ordinary object so fn should leave large
+// objects alone or only change their metadata.
+//
+// The first error from fn, Checkpoint or the server stops the rewrite
+// and is returned.
+func (c *Connection) ObjectsRewrite(container string, opts *RewriteOpts, fn RewriteFn) error {
+	var options RewriteOpts
+	if opts != nil {
+		options = *opts
+	}
+	listOpts := &ObjectsOpts{
+		Prefix:     options.Prefix,
+		Marker:     options.Marker,
+		KeepMarker: true,
+	}
+	ctx, release, err := c.holdSlot(context.Background())
+	if err != nil {
+		return err
+	}
+	defer release()
+	requestOptions := []RequestOption{WithContext(ctx)}
+	return c.ObjectsEach(container, listOpts, func(object *Object) error {
+		if object.PseudoDirectory {
+			return nil
+		}
+		err := c.objectRewrite(container, object, fn, requestOptions)
+		if err != nil {
+			return err
+		}
+		if options.Checkpoint != nil {
+			return options.Checkpoint(object.Name)
+		}
+		return nil
+	}, requestOptions...)
+}
+
+// objectRewrite rewrites one object for ObjectsRewrite
+func (c *Connection) objectRewrite(container string, object *Object, fn RewriteFn, options []RequestOption) (err error) {
+	_, headers, err := c.ObjectWithOptions(container, object.Name, options...)
+	if err != nil {
+		return err
+	}
+	contents := &lazyObjectReader{
+		c:          c,
+		container:  container,
+		objectName: object.Name,
+		options:    options,
+	}
+	defer checkClose(contents, &err)
+	newContents, newHeaders, err := fn(object, headers, contents)
+	if err != nil {
+		return err
+	}
+	if newContents != nil {
+		_, err = c.ObjectPutWithOpts(container, object.Name, newContents, &ObjectPutOpts{
+			CheckHash:   true,
+			ContentType: headers["Content-Type"],
+			Headers:     newHeaders,
+		}, options...)
+		return err
+	}
+	if newHeaders != nil {
+		return c.ObjectUpdateWithOptions(container, object.Name, newHeaders, options...)
+	}
+	return nil
+}
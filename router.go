@@ -0,0 +1,119 @@
+// Choosing the container to upload objects to from rules
+
+package swift
+
+import (
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// NoRoute is returned by Router.Route if none of the rules match the
+// object
+var NoRoute = newError(0, "No route matches the object")
+
+// RouteRule is one rule of a Router.  An object matches the rule if
+// it matches all of the conditions which are set.
+//
+// Container and Prefix may contain these placeholders which are
+// replaced with the UTC time of the object: {YYYY} the year, {MM} the
+// month, {DD} the day and {hh} the hour.  So a Container of
+// "logs-{YYYY}" and a Prefix of "{MM}/{DD}/" partitions objects by
+// date.
+type RouteRule struct {
+	ContentType string // Match Content-Types matching this path.Match pattern, eg "image/*" - "" for any
+	MinSize     int64  // Match objects of at least this many bytes
+	MaxSize     int64  // Match objects of less than this many bytes - 0 for no limit
+	Container   string // Container to upload matching objects to
+	Prefix      string // Put this in front of the object name
+}
+
+// RouteObject describes an object to be routed by a Router
+type RouteObject struct {
+	Name        string    // Name of the object
+	ContentType string    // Content-Type of the object - guessed from the Name if not set
+	Size        int64     // Size of the object or -1 if unknown
+	Time        time.Time // Time used for the placeholders - the current time if not set
+}
+
+// Router chooses the container to upload objects to from a list of
+// rules so ingestion code doesn't have to work it out itself.
+//
+// The rules are tried in order and the first one which matches is
+// used.  A rule with no conditions matches everything so can be put
+// last as a default.
+//
+// A Router is safe for use from multiple go routines as long as the
+// Rules aren't changed.
+type Router struct {
+	Rules []RouteRule
+}
+
+// matches returns whether obj matches the rule
+func (rule *RouteRule) matches(obj *RouteObject) bool {
+	if rule.ContentType != "" {
+		contentType := strings.TrimSpace(strings.SplitN(obj.ContentType, ";", 2)[0])
+		if ok, err := path.Match(rule.ContentType, contentType); err != nil || !ok {
+			return false
+		}
+	}
+	if rule.MinSize > 0 && obj.Size < rule.MinSize {
+		return false
+	}
+	if rule.MaxSize > 0 && (obj.Size < 0 || obj.Size >= rule.MaxSize) {
+		return false
+	}
+	return true
+}
+
+// expandRoute replaces the placeholders in s with parts of t
+func expandRoute(s string, t time.Time) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	t = t.UTC()
+	return strings.NewReplacer(
+		"{YYYY}", t.Format("2006"),
+		"{MM}", t.Format("01"),
+		"{DD}", t.Format("02"),
+		"{hh}", t.Format("15"),
+	).Replace(s)
+}
+
+// Route returns the container and object name to upload obj to using
+// the first of the Rules which matches it.
+//
+// It returns NoRoute if none of them match.
+func (r *Router) Route(obj RouteObject) (container string, objectName string, err error) {
+	if obj.ContentType == "" {
+		obj.ContentType = mime.TypeByExtension(path.Ext(obj.Name))
+	}
+	if obj.Time.IsZero() {
+		obj.Time = time.Now()
+	}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.matches(&obj) {
+			return expandRoute(rule.Container, obj.Time), expandRoute(rule.Prefix, obj.Time) + obj.Name, nil
+		}
+	}
+	return "", "", NoRoute
+}
+
+// ObjectPutRouted uploads contents to the container and object name
+// router chooses for obj.  It returns where the object was put and
+// the headers of the response.
+//
+// The object is uploaded with ObjectPut with checkHash set and the
+// Content-Type of obj.  Set obj.Size if it is known so rules using the
+// size of the object can match.
+func (c *Connection) ObjectPutRouted(router *Router, obj RouteObject, contents io.Reader, h Headers) (container string, objectName string, headers Headers, err error) {
+	container, objectName, err = router.Route(obj)
+	if err != nil {
+		return "", "", nil, err
+	}
+	headers, err = c.ObjectPut(container, objectName, contents, true, "", obj.ContentType, h)
+	return container, objectName, headers, err
+}
@@ -0,0 +1,43 @@
+// Tests for routing uploads to containers
+package swift
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouterRoute(t *testing.T) {
+	router := &Router{Rules: []RouteRule{
+		{ContentType: "image/*", MaxSize: 1024, Container: "thumbnails"},
+		{ContentType: "image/*", Container: "images"},
+		{ContentType: "text/plain", MinSize: 1, Container: "logs-{YYYY}", Prefix: "{MM}/{DD}/{hh}/"},
+		{Container: "other"},
+	}}
+	when := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, test := range []struct {
+		obj       RouteObject
+		container string
+		name      string
+	}{
+		{RouteObject{Name: "a.png", Size: 100}, "thumbnails", "a.png"},
+		{RouteObject{Name: "a.png", Size: 1024}, "images", "a.png"},
+		{RouteObject{Name: "a.png", Size: -1}, "images", "a.png"},
+		{RouteObject{Name: "a", ContentType: "image/jpeg", Size: 10}, "thumbnails", "a"},
+		{RouteObject{Name: "app.log", ContentType: "text/plain; charset=utf-8", Size: 10, Time: when}, "logs-2019", "03/04/05/app.log"},
+		{RouteObject{Name: "app.log", ContentType: "text/plain", Size: 0, Time: when}, "other", "app.log"},
+		{RouteObject{Name: "data.bin", Size: 10}, "other", "data.bin"},
+	} {
+		container, name, err := router.Route(test.obj)
+		if err != nil {
+			t.Errorf("%+v: unexpected error %v", test.obj, err)
+		}
+		if container != test.container || name != test.name {
+			t.Errorf("%+v: expecting %q %q got %q %q", test.obj, test.container, test.name, container, name)
+		}
+	}
+	router.Rules = router.Rules[:1]
+	_, _, err := router.Route(RouteObject{Name: "data.bin"})
+	if err != NoRoute {
+		t.Errorf("expecting NoRoute got %v", err)
+	}
+}
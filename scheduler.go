@@ -0,0 +1,196 @@
+// Sharing request slots between interactive and background work
+
+package swift
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Priority is the priority of the requests a Connection makes when
+// it has a Scheduler
+type Priority int
+
+// Priorities for Connection.Priority
+const (
+	PriorityInteractive Priority = iota // Requests a user is waiting for - the default
+	PriorityBackground                  // Bulk work which can wait for the interactive requests
+)
+
+// Scheduler limits the number of requests in flight from the
+// Connections which share it, giving interactive requests priority
+// over background ones.
+//
+// Make two Connections with the same credentials and Scheduler, one
+// with Priority set to PriorityBackground for bulk jobs, and the
+// interactive requests will be sent before any waiting background
+// requests.  Reserved slots can only be used by interactive requests
+// so they don't have to wait for a slot to come free when the
+// background jobs are busy.
+//
+// A request holds its slot until its response body is closed, so
+// close bodies promptly and don't make more requests while holding
+// one open if all the slots may be in use, eg from the callback of
+// ObjectsEach which streams the listing.  Helpers which do, like
+// ObjectsRewrite, take one slot for all their requests.
+type Scheduler struct {
+	mu       sync.Mutex
+	slots    int                // number of requests allowed in flight
+	reserved int                // number of slots background requests can't use
+	inUse    int                // number of slots in use
+	waiting  [2][]chan struct{} // requests waiting for a slot by Priority
+}
+
+// NewScheduler makes a Scheduler allowing slots requests in flight at
+// once, reserved of which are only used by interactive requests.
+func NewScheduler(slots int, reserved int) *Scheduler {
+	if slots < 1 {
+		slots = 1
+	}
+	if reserved >= slots {
+		reserved = slots - 1
+	}
+	if reserved < 0 {
+		reserved = 0
+	}
+	return &Scheduler{
+		slots:    slots,
+		reserved: reserved,
+	}
+}
+
+// canRun returns whether a request with priority p can have a slot
+// now
+//
+// Call with mu held
+func (s *Scheduler) canRun(p Priority) bool {
+	if p == PriorityBackground {
+		return s.inUse < s.slots-s.reserved && len(s.waiting[PriorityInteractive]) == 0
+	}
+	return s.inUse < s.slots
+}
+
+// dispatch gives any free slots to the waiting requests, interactive
+// ones first
+//
+// Call with mu held
+func (s *Scheduler) dispatch() {
+	for _, p := range []Priority{PriorityInteractive, PriorityBackground} {
+		for len(s.waiting[p]) > 0 && s.canRun(p) {
+			close(s.waiting[p][0])
+			s.waiting[p] = s.waiting[p][1:]
+			s.inUse++
+		}
+	}
+}
+
+// acquire waits for a slot for a request with priority p.  It returns
+// ctx.Err() if ctx is done first.
+func (s *Scheduler) acquire(ctx context.Context, p Priority) error {
+	if p != PriorityBackground {
+		p = PriorityInteractive
+	}
+	s.mu.Lock()
+	if len(s.waiting[p]) == 0 && s.canRun(p) {
+		s.inUse++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiting[p] = append(s.waiting[p], ready)
+	s.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, waiter := range s.waiting[p] {
+		if waiter == ready {
+			s.waiting[p] = append(s.waiting[p][:i], s.waiting[p][i+1:]...)
+			// Removing an interactive request may let a
+			// background one run
+			s.dispatch()
+			return ctx.Err()
+		}
+	}
+	// The slot was given to us while giving up so hand it on
+	s.inUse--
+	s.dispatch()
+	return ctx.Err()
+}
+
+// release frees a slot
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	s.inUse--
+	s.dispatch()
+	s.mu.Unlock()
+}
+
+// slotHeldKey is the context key marking requests made while holding
+// a slot from holdSlot
+type slotHeldKey struct{}
+
+// holdSlot waits for a request slot if the Connection has a Scheduler
+// and returns a context for the requests which share it, and a
+// function to free it when they are done.
+//
+// This is for making several requests at once which depend on each
+// other, eg a PUT reading from the body of a GET, as taking a slot
+// for each could wait forever for the other to free one.
+func (c *Connection) holdSlot(ctx context.Context) (context.Context, func(), error) {
+	acquired, err := c.acquireSlot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return context.WithValue(ctx, slotHeldKey{}, true), func() { c.releaseSlot(acquired) }, nil
+}
+
+// acquireSlot waits for a request slot if the Connection has a
+// Scheduler, returning whether one was taken.
+//
+// No slot is taken for a request with a context from holdSlot.
+func (c *Connection) acquireSlot(ctx context.Context) (bool, error) {
+	if c.Scheduler == nil || ctx.Value(slotHeldKey{}) != nil {
+		return false, nil
+	}
+	if err := c.Scheduler.acquire(ctx, c.Priority); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseSlot frees the slot of a request which didn't get a response
+// if one was acquired
+func (c *Connection) releaseSlot(acquired bool) {
+	if acquired {
+		c.Scheduler.release()
+	}
+}
+
+// scheduleBody returns body made to free the slot of its request when
+// it is closed if one was acquired
+func (c *Connection) scheduleBody(body io.ReadCloser, acquired bool) io.ReadCloser {
+	if !acquired {
+		return body
+	}
+	return &scheduledBody{ReadCloser: body, scheduler: c.Scheduler}
+}
+
+// scheduledBody releases the slot of a request when its response
+// body is closed
+type scheduledBody struct {
+	io.ReadCloser
+	once      sync.Once
+	scheduler *Scheduler
+}
+
+// Close the body and release the slot
+func (b *scheduledBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.scheduler.release)
+	return err
+}
@@ -0,0 +1,225 @@
+// Tests for sharing request slots between priorities
+package swift
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+// waitFor returns whether ch is closed within a short time
+func waitFor(ch chan error) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := NewScheduler(2, 1)
+	ctx := context.Background()
+	if err := s.acquire(ctx, PriorityBackground); err != nil {
+		t.Fatal(err)
+	}
+	acquire := func(p Priority) chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- s.acquire(ctx, p)
+		}()
+		return done
+	}
+
+	// The reserved slot isn't used by background requests
+	background := acquire(PriorityBackground)
+	if waitFor(background) {
+		t.Fatal("Background request used the reserved slot")
+	}
+	if err := s.acquire(ctx, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interactive requests go before waiting background ones
+	interactive := acquire(PriorityInteractive)
+	if waitFor(interactive) {
+		t.Fatal("Interactive request didn't wait for a slot")
+	}
+	s.release()
+	if !waitFor(interactive) {
+		t.Fatal("Interactive request didn't get the free slot")
+	}
+	if waitFor(background) {
+		t.Fatal("Background request ran before the interactive one")
+	}
+	s.release()
+	s.release()
+	if !waitFor(background) {
+		t.Fatal("Background request didn't run")
+	}
+	s.release()
+	if s.inUse != 0 {
+		t.Errorf("Expecting no slots in use got %d", s.inUse)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler(1, 0)
+	if err := s.acquire(context.Background(), PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.acquire(ctx, PriorityBackground)
+	}()
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expecting context.Canceled got %v", err)
+	}
+	s.release()
+	if s.inUse != 0 || len(s.waiting[PriorityBackground]) != 0 {
+		t.Errorf("Bad state after cancel %d in use, %d waiting", s.inUse, len(s.waiting[PriorityBackground]))
+	}
+}
+
+// orderTransport records the paths of the requests made through it
+type orderTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (t *orderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.paths = append(t.paths, req.URL.Path)
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSchedulerCall(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	s := NewScheduler(1, 0)
+	transport := &orderTransport{}
+	newConnection := func(p Priority) *Connection {
+		c := &Connection{
+			UserName:  swifttest.TEST_ACCOUNT,
+			ApiKey:    swifttest.TEST_ACCOUNT,
+			AuthUrl:   srv.AuthURL,
+			Transport: transport,
+			Scheduler: s,
+			Priority:  p,
+		}
+		if err := c.Authenticate(); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	interactive := newConnection(PriorityInteractive)
+	background := newConnection(PriorityBackground)
+	if err := interactive.ContainerCreate("container", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"held", "background", "interactive"} {
+		if err := interactive.ObjectPutString("container", name, name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A streamed response holds its slot until the body is closed
+	file, _, err := background.ObjectOpen("container", "held", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued := func(p Priority, n int) {
+		for i := 0; i < 100; i++ {
+			s.mu.Lock()
+			waiting := len(s.waiting[p])
+			s.mu.Unlock()
+			if waiting == n {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("expecting %d requests waiting", n)
+	}
+	get := func(c *Connection, name string) chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := c.ObjectGetString("container", name)
+			done <- err
+		}()
+		return done
+	}
+	transport.mu.Lock()
+	transport.paths = nil
+	transport.mu.Unlock()
+	backgroundDone := get(background, "background")
+	queued(PriorityBackground, 1)
+	interactiveDone := get(interactive, "interactive")
+	queued(PriorityInteractive, 1)
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, done := range []chan error{interactiveDone, backgroundDone} {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.paths) != 2 || !strings.HasSuffix(transport.paths[0], "/interactive") || !strings.HasSuffix(transport.paths[1], "/background") {
+		t.Errorf("interactive request didn't go first: %q", transport.paths)
+	}
+	if s.inUse != 0 {
+		t.Errorf("Expecting no slots in use got %d", s.inUse)
+	}
+}
+
+func TestSchedulerObjectsRewrite(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName:  swifttest.TEST_ACCOUNT,
+		ApiKey:    swifttest.TEST_ACCOUNT,
+		AuthUrl:   srv.AuthURL,
+		Scheduler: NewScheduler(1, 0),
+	}
+	if err := c.ContainerCreate("container", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ObjectPutString("container", "object", "hello", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The upload streams from the download of the object
+	done := make(chan error, 1)
+	go func() {
+		done <- c.ObjectsRewrite("container", nil, func(object *Object, headers Headers, contents io.Reader) (io.Reader, Headers, error) {
+			return contents, headers.ObjectMetadata().ObjectHeaders(), nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ObjectsRewrite deadlocked with one slot")
+	}
+	contents, err := c.ObjectGetString("container", "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != "hello" {
+		t.Errorf("expecting %q got %q", "hello", contents)
+	}
+	if s := c.Scheduler; s.inUse != 0 {
+		t.Errorf("Expecting no slots in use got %d", s.inUse)
+	}
+}
@@ -0,0 +1,129 @@
+// Serving objects to HTTP clients
+
+package swift
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// serveRequestHeaders are the headers of the client's request passed
+// on to Swift by ServeObject
+var serveRequestHeaders = []string{
+	"Accept-Encoding",
+	"If-Match",
+	"If-Modified-Since",
+	"If-None-Match",
+	"If-Range",
+	"If-Unmodified-Since",
+	"Range",
+}
+
+// serveResponseHeaders are the headers of Swift's response passed
+// back to the client by ServeObject.  The Etag is quoted separately.
+var serveResponseHeaders = []string{
+	"Accept-Ranges",
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"Expires",
+	"Last-Modified",
+}
+
+// copyServeHeaders sets the headers of w from Swift's response
+// headers
+func copyServeHeaders(w http.ResponseWriter, headers Headers) {
+	out := w.Header()
+	for _, key := range serveResponseHeaders {
+		if value, ok := headers[key]; ok {
+			out.Set(key, value)
+		}
+	}
+	if etag := strings.Trim(headers["Etag"], `"`); etag != "" {
+		out.Set("ETag", `"`+etag+`"`)
+	}
+}
+
+// serveError writes the response for err from Swift with its response
+// headers which may be nil
+func serveError(w http.ResponseWriter, headers Headers, err error) {
+	switch err {
+	case NotModified:
+		copyServeHeaders(w, headers)
+		for _, key := range []string{"Content-Type", "Content-Length", "Content-Range", "Content-Encoding"} {
+			w.Header().Del(key)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	case PreconditionFailed:
+		http.Error(w, "412 precondition failed", http.StatusPreconditionFailed)
+	case RangeNotSatisfiable:
+		if contentRange := headers["Content-Range"]; contentRange != "" {
+			w.Header().Set("Content-Range", contentRange)
+		}
+		http.Error(w, "416 range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+	case ObjectNotFound, ContainerNotFound:
+		http.NotFound(w, nil)
+	case Forbidden:
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "502 bad gateway", http.StatusBadGateway)
+	}
+}
+
+// ServeObject answers the GET or HEAD request r with the object from
+// Swift, so it can be used to write an http.Handler serving objects.
+//
+// The Range and the conditional headers (If-None-Match,
+// If-Modified-Since etc) of r are passed on to Swift and its status
+// code is returned, so browsers can cache objects and resume
+// downloads.  The Content-Type, ETag, Last-Modified and other
+// content headers are set from the object and its contents are
+// streamed to w.
+//
+// Other methods get 405 Method Not Allowed.  Missing objects get 404
+// Not Found and Swift errors other than those from the conditions get
+// 502 Bad Gateway - use Object first if you need to do anything
+// different.
+func (c *Connection) ServeObject(w http.ResponseWriter, r *http.Request, container string, objectName string) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h := Headers{}
+	for _, key := range serveRequestHeaders {
+		if value := r.Header.Get(key); value != "" {
+			h[key] = value
+		}
+	}
+	if r.Method == "HEAD" {
+		delete(h, "Range")
+		delete(h, "If-Range")
+		_, headers, err := c.objectBase(container, objectName, h, WithContext(r.Context()))
+		if err != nil {
+			serveError(w, headers, err)
+			return
+		}
+		copyServeHeaders(w, headers)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	file, headers, err := c.ObjectOpenWithOpts(container, objectName, &ObjectGetOpts{Headers: h}, WithContext(r.Context()))
+	if err != nil {
+		serveError(w, headers, err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+	copyServeHeaders(w, headers)
+	if file.resp.Uncompressed {
+		// The transport decompressed the body
+		w.Header().Del("Content-Length")
+	}
+	w.WriteHeader(file.resp.StatusCode)
+	_, _ = io.Copy(w, file)
+}
@@ -0,0 +1,93 @@
+// Tests for serving objects over HTTP
+package swift
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestServeObject(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectPutString("container", "object.txt", "0123456789", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.ServeObject(w, r, "container", r.URL.Path[1:])
+	}))
+	defer web.Close()
+
+	do := func(method string, name string, h map[string]string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, web.URL+"/"+name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range h {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	resp, body := do("GET", "object.txt", nil)
+	if resp.StatusCode != http.StatusOK || body != "0123456789" {
+		t.Fatalf("GET: got %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag != `"781e5e245d69b566979b86e28d23f2c7"` {
+		t.Errorf("ETag: got %q", etag)
+	}
+	if resp.Header.Get("Last-Modified") == "" {
+		t.Error("no Last-Modified")
+	}
+
+	resp, body = do("GET", "object.txt", map[string]string{"Range": "bytes=2-4"})
+	if body != "234" {
+		t.Errorf("Range: got %d %q", resp.StatusCode, body)
+	}
+
+	resp, body = do("GET", "object.txt", map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified || body != "" {
+		t.Errorf("If-None-Match: got %d %q", resp.StatusCode, body)
+	}
+
+	resp, body = do("HEAD", "object.txt", nil)
+	if resp.StatusCode != http.StatusOK || body != "" || resp.ContentLength != 10 {
+		t.Errorf("HEAD: got %d %q length %d", resp.StatusCode, body, resp.ContentLength)
+	}
+
+	resp, _ = do("GET", "missing.txt", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing: got %d", resp.StatusCode)
+	}
+
+	resp, _ = do("POST", "object.txt", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: got %d allow %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
@@ -18,19 +18,17 @@ type StaticLargeObjectCreateFile struct {
 var SLONotSupported = errors.New("SLO not supported")
 
 type swiftSegment struct {
-	Path string `json:"path,omitempty"`
-	Etag string `json:"etag,omitempty"`
-	Size int64  `json:"size_bytes,omitempty"`
-	// When uploading a manifest, the attributes must be named `path`, `etag` and `size_bytes`
-	// but when querying the JSON content of a manifest with the `multipart-manifest=get`
-	// parameter, Swift names those attributes `name`, `hash` and `bytes`.
-	// We use all the different attributes names in this structure to be able to use
-	// the same structure for both uploading and retrieving.
+	// When querying the JSON content of a manifest with the
+	// `multipart-manifest=get` parameter, Swift names the attributes
+	// `name`, `hash` and `bytes`.  Manifests are uploaded with
+	// sloManifestSegment which uses `path`, `etag` and `size_bytes`.
 	Name         string `json:"name,omitempty"`
 	Hash         string `json:"hash,omitempty"`
 	Bytes        int64  `json:"bytes,omitempty"`
 	ContentType  string `json:"content_type,omitempty"`
 	LastModified string `json:"last_modified,omitempty"`
+	Range        string `json:"range,omitempty"`   // Range of the segment used if not all of it
+	SubSLO       bool   `json:"sub_slo,omitempty"` // Set if the segment is itself an SLO
 }
 
 // StaticLargeObjectCreateFile creates a static large object returning
@@ -104,14 +102,15 @@ func (c *Connection) StaticLargeObjectMove(srcContainer string, srcObjectName st
 
 // createSLOManifest creates a static large object manifest
 func (c *Connection) createSLOManifest(container string, path string, contentType string, segmentContainer string, segments []Object, h Headers) error {
-	sloSegments := make([]swiftSegment, len(segments))
+	sloSegments := make([]SLOSegment, len(segments))
 	for i, segment := range segments {
-		sloSegments[i].Path = fmt.Sprintf("%s/%s", segmentContainer, segment.Name)
+		sloSegments[i].Container = segmentContainer
+		sloSegments[i].Name = segment.Name
 		sloSegments[i].Etag = segment.Hash
-		sloSegments[i].Size = segment.Bytes
+		sloSegments[i].Bytes = segment.Bytes
 	}
 
-	content, err := json.Marshal(sloSegments)
+	content, err := SLOManifest(sloSegments)
 	if err != nil {
 		return err
 	}
@@ -138,26 +137,15 @@ func (file *StaticLargeObjectCreateFile) Flush() error {
 
 func (c *Connection) getAllSLOSegments(container, path string) (string, []Object, error) {
 	var (
-		segmentList      []swiftSegment
 		segments         []Object
 		segPath          string
 		segmentContainer string
 	)
 
-	values := url.Values{}
-	values.Set("multipart-manifest", "get")
-
-	file, _, err := c.objectOpen(container, path, true, nil, values)
-	if err != nil {
-		return "", nil, err
-	}
-
-	content, err := ioutil.ReadAll(file)
+	segmentList, err := c.getSLOManifest(container, path)
 	if err != nil {
 		return "", nil, err
 	}
-
-	json.Unmarshal(content, &segmentList)
 	for _, segment := range segmentList {
 		segmentContainer, segPath = parseFullPath(segment.Name[1:])
 		segments = append(segments, Object{
@@ -169,3 +157,149 @@ func (c *Connection) getAllSLOSegments(container, path string) (string, []Object
 
 	return segmentContainer, segments, nil
 }
+
+// getSLOManifest reads the segments in the manifest of the SLO
+func (c *Connection) getSLOManifest(container, path string) (segmentList []swiftSegment, err error) {
+	values := url.Values{}
+	values.Set("multipart-manifest", "get")
+
+	// The hash isn't checked as the manifest may be reformatted
+	file, _, err := c.objectOpen(container, path, false, nil, values)
+	if err != nil {
+		return nil, err
+	}
+	defer checkClose(file, &err)
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(content, &segmentList)
+	return segmentList, nil
+}
+
+// SLOSegment describes one segment of a static large object to be
+// written with StaticLargeObjectManifestPut
+type SLOSegment struct {
+	Container string // Container the segment is in
+	Name      string // Name of the segment object
+	Etag      string // MD5 hash of the segment - "" not to have it checked by the server
+	Offset    int64  // Offset in the large object of the first byte of the segment
+	Bytes     int64  // Size of the segment in bytes
+}
+
+// sloManifestSegment is a segment of a manifest being uploaded.  The
+// fields are in the order of their JSON keys so the manifest is in
+// canonical form.
+type sloManifestSegment struct {
+	Etag string `json:"etag,omitempty"`
+	Path string `json:"path"`
+	Size int64  `json:"size_bytes,omitempty"`
+}
+
+// SLOManifest returns the manifest for a static large object made of
+// segments as it is sent to the server.
+//
+// The JSON is canonical - the keys are sorted, there is no
+// insignificant white space and nothing is escaped which doesn't need
+// to be - so the same segments always make the same bytes.  Manifests
+// can then be compared, diffed or deduplicated by their MD5 hash.
+func SLOManifest(segments []SLOSegment) ([]byte, error) {
+	manifest := make([]sloManifestSegment, len(segments))
+	for i, segment := range segments {
+		manifest[i] = sloManifestSegment{
+			Etag: segment.Etag,
+			Path: segment.Container + "/" + segment.Name,
+			Size: segment.Bytes,
+		}
+	}
+	return canonicalJson(manifest)
+}
+
+var (
+	// SLONoSegments is returned by CheckSLOSegments for a manifest
+	// with no segments
+	SLONoSegments = newError(0, "SLO manifest has no segments")
+
+	// InvalidSLOSegment is wrapped by the errors CheckSLOSegments
+	// returns for a segment the server would reject.  Use errors.Is
+	// to check for it.
+	InvalidSLOSegment = newError(0, "Invalid SLO segment")
+)
+
+// CheckSLOSegments checks that segments make a valid static large
+// object manifest using the limits the server reports in its /info.
+//
+// Segments must be in order with each one starting at the Offset the
+// previous one ended, so the first starts at 0.  Every segment apart
+// from the last must be at least SLOMinSegmentSize bytes and there
+// mustn't be more than SLOMaxSegments of them.
+//
+// The first problem found is returned as an error wrapping
+// InvalidSLOSegment saying which segment is wrong and why.
+func (i SwiftInfo) CheckSLOSegments(segments []SLOSegment) error {
+	if len(segments) == 0 {
+		return SLONoSegments
+	}
+	minSize := i.SLOMinSegmentSize()
+	if minSize < 1 {
+		minSize = 1
+	}
+	maxSegments := i.SLOMaxSegments()
+	var offset int64
+	for index, segment := range segments {
+		invalid := func(format string, a ...interface{}) error {
+			return wrapErrorf(InvalidSLOSegment, 0, "Invalid SLO segment %d (%s/%s): %s", index, segment.Container, segment.Name, fmt.Sprintf(format, a...))
+		}
+		switch {
+		case int64(index) >= maxSegments:
+			return invalid("manifest has more than the %d segments allowed", maxSegments)
+		case segment.Container == "" || segment.Name == "":
+			return invalid("container and name must be set")
+		case segment.Offset != offset:
+			return invalid("starts at offset %d but the previous segments end at %d", segment.Offset, offset)
+		case segment.Bytes < 0:
+			return invalid("size %d is negative", segment.Bytes)
+		case segment.Bytes < minSize && index != len(segments)-1:
+			return invalid("size %d is less than the minimum segment size %d", segment.Bytes, minSize)
+		}
+		offset += segment.Bytes
+	}
+	return nil
+}
+
+// StaticLargeObjectManifestPut writes the manifest for a static large
+// object made of segments which have already been uploaded.
+//
+// The segments are checked with CheckSLOSegments first so an invalid
+// manifest returns an error wrapping InvalidSLOSegment saying which
+// segment is wrong rather than the server rejecting the whole
+// manifest.
+//
+// h may be nil.
+func (c *Connection) StaticLargeObjectManifestPut(container string, objectName string, contentType string, segments []SLOSegment, h Headers) error {
+	if !c.Authenticated() {
+		if err := c.Authenticate(); err != nil {
+			return err
+		}
+	}
+	info, err := c.cachedQueryInfo()
+	if err != nil {
+		return err
+	}
+	if !info.SupportsSLO() {
+		return SLONotSupported
+	}
+	if err = info.CheckSLOSegments(segments); err != nil {
+		return err
+	}
+	content, err := SLOManifest(segments)
+	if err != nil {
+		return err
+	}
+	values := url.Values{}
+	values.Set("multipart-manifest", "put")
+	_, err = c.objectPut(container, objectName, bytes.NewBuffer(content), false, "", contentType, h, values)
+	return err
+}
@@ -0,0 +1,141 @@
+// Export and import of account and container metadata
+
+package swift
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AccountSnapshot holds the metadata of an account and all of its
+// containers.
+//
+// This includes the user metadata, ACLs, quotas, container sync,
+// versioning and CORS settings, but not the objects.  It can be
+// marshalled to and from JSON with encoding/json.
+type AccountSnapshot struct {
+	Headers    Headers            `json:"headers"`    // Account headers
+	Containers map[string]Headers `json:"containers"` // Container headers indexed by container name
+}
+
+// snapshotHeaders are the headers other than metadata which are
+// saved in an AccountSnapshot
+var snapshotHeaders = map[string]bool{
+	"X-Account-Access-Control": true,
+	"X-Container-Read":         true,
+	"X-Container-Write":        true,
+	"X-Container-Sync-To":      true,
+	"X-Container-Sync-Key":     true,
+	"X-Versions-Location":      true,
+	"X-History-Location":       true,
+	"X-Storage-Policy":         true,
+}
+
+// snapshotFilter returns only the headers in h which can be
+// re-applied to an account or container.
+func snapshotFilter(h Headers) Headers {
+	result := Headers{}
+	for key, value := range h {
+		if snapshotHeaders[key] || strings.HasPrefix(key, "X-Account-Meta-") || strings.HasPrefix(key, "X-Container-Meta-") {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// AccountExport reads the metadata of the account and all of its
+// containers into an AccountSnapshot.
+//
+// This does one HEAD request per container.
+func (c *Connection) AccountExport() (*AccountSnapshot, error) {
+	_, headers, err := c.Account()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &AccountSnapshot{
+		Headers:    snapshotFilter(headers),
+		Containers: map[string]Headers{},
+	}
+	containers, err := c.ContainerNamesAll(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, container := range containers {
+		_, headers, err := c.Container(container)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Containers[container] = snapshotFilter(headers)
+	}
+	return snapshot, nil
+}
+
+// AccountImport applies the metadata in snapshot to the account,
+// creating any containers which don't exist.
+//
+// This can be used with AccountExport to copy the settings of an
+// account to another account or cluster.  Metadata which exists but
+// isn't mentioned in the snapshot isn't removed.
+func (c *Connection) AccountImport(snapshot *AccountSnapshot) error {
+	if len(snapshot.Headers) > 0 {
+		if err := c.AccountUpdate(snapshot.Headers); err != nil {
+			return err
+		}
+	}
+	for container, headers := range snapshot.Containers {
+		if err := c.ContainerCreate(container, headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffHeaders returns the Headers to POST to an account or container
+// with the headers in from to make them the headers in to.
+//
+// Headers which are new or have changed are set to their value in to.
+// Headers in from but not in to are removed with the X-Remove- form
+// of the header, eg X-Remove-Container-Meta-Potato for
+// X-Container-Meta-Potato, or set to "" if they don't start with
+// "X-".  Keys are compared after converting them to http Canonical
+// form (see http.CanonicalHeaderKey).
+//
+// Read only headers like X-Container-Object-Count aren't filtered out
+// so use headers from AccountExport or the same source for both.
+//
+// Object headers can't be updated this way as Swift replaces all the
+// metadata of an object on a POST.
+func DiffHeaders(from Headers, to Headers) Headers {
+	canonical := func(h Headers) Headers {
+		result := make(Headers, len(h))
+		for key, value := range h {
+			result[http.CanonicalHeaderKey(key)] = value
+		}
+		return result
+	}
+	from, to = canonical(from), canonical(to)
+	diff := Headers{}
+	for key, value := range to {
+		if oldValue, ok := from[key]; !ok || oldValue != value {
+			diff[key] = value
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; ok {
+			continue
+		}
+		if strings.HasPrefix(key, "X-") {
+			diff["X-Remove-"+key[2:]] = "x"
+		} else {
+			diff[key] = ""
+		}
+	}
+	return diff
+}
+
+// DiffMetadata returns the Headers to POST to an account or container
+// to change its metadata from from to to, using metaPrefix, eg
+// "X-Container-Meta-".  See DiffHeaders.
+func DiffMetadata(metaPrefix string, from Metadata, to Metadata) Headers {
+	return DiffHeaders(from.Headers(metaPrefix), to.Headers(metaPrefix))
+}
@@ -0,0 +1,116 @@
+// Per prefix statistics for containers
+
+package swift
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PrefixStat holds the number of objects and bytes used under a
+// prefix
+type PrefixStat struct {
+	Prefix  string `json:"prefix"`  // Prefix of the objects, eg "dir/subdir/" or "" for the whole container
+	Objects int64  `json:"objects"` // Number of objects under the prefix
+	Bytes   int64  `json:"bytes"`   // Number of bytes used by objects under the prefix
+}
+
+// PrefixStats holds the statistics for every directory level of a
+// container down to Depth as returned by Connection.PrefixStats
+type PrefixStats struct {
+	Container string       `json:"container"` // Name of the container
+	Depth     int          `json:"depth"`     // Number of directory levels examined
+	Created   time.Time    `json:"created"`   // Time the statistics were gathered
+	Prefixes  []PrefixStat `json:"prefixes"`  // Statistics sorted by Prefix
+}
+
+// Get returns the statistics for prefix, which should end in "/"
+// unless it is "" for the whole container.
+func (s *PrefixStats) Get(prefix string) (stat PrefixStat, ok bool) {
+	i := sort.Search(len(s.Prefixes), func(i int) bool {
+		return s.Prefixes[i].Prefix >= prefix
+	})
+	if i < len(s.Prefixes) && s.Prefixes[i].Prefix == prefix {
+		return s.Prefixes[i], true
+	}
+	return PrefixStat{Prefix: prefix}, false
+}
+
+// PrefixStats computes the number of objects and bytes used under
+// each directory of the container down to depth levels using "/" as
+// the directory separator, like the unix "du" command.
+//
+// The totals for the whole container are stored under the prefix "".
+//
+// This does a single listing of the container.
+func (c *Connection) PrefixStats(container string, depth int) (*PrefixStats, error) {
+	totals := map[string]*PrefixStat{}
+	add := func(prefix string, bytes int64) {
+		stat := totals[prefix]
+		if stat == nil {
+			stat = &PrefixStat{Prefix: prefix}
+			totals[prefix] = stat
+		}
+		stat.Objects++
+		stat.Bytes += bytes
+	}
+	created := time.Now()
+	err := c.ObjectsWalk(container, &ObjectsOpts{Limit: allObjectsLimit}, func(opts *ObjectsOpts) (interface{}, error) {
+		objects, err := c.Objects(container, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			add("", object.Bytes)
+			parts := strings.Split(object.Name, "/")
+			for level := 1; level <= depth && level < len(parts); level++ {
+				add(strings.Join(parts[:level], "/")+"/", object.Bytes)
+			}
+		}
+		return objects, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := &PrefixStats{
+		Container: container,
+		Depth:     depth,
+		Created:   created,
+		Prefixes:  make([]PrefixStat, 0, len(totals)),
+	}
+	for _, stat := range totals {
+		stats.Prefixes = append(stats.Prefixes, *stat)
+	}
+	sort.Slice(stats.Prefixes, func(i, j int) bool {
+		return stats.Prefixes[i].Prefix < stats.Prefixes[j].Prefix
+	})
+	return stats, nil
+}
+
+// PrefixStatsSave stores stats as JSON in the object objectName in
+// container so it can be re-used with PrefixStatsLoad without listing
+// the container again.
+func (c *Connection) PrefixStatsSave(container string, objectName string, stats *PrefixStats) error {
+	data, err := canonicalJson(stats)
+	if err != nil {
+		return err
+	}
+	return c.ObjectPutBytes(container, objectName, data, "application/json")
+}
+
+// PrefixStatsLoad reads statistics previously stored with
+// PrefixStatsSave.
+func (c *Connection) PrefixStatsLoad(container string, objectName string) (*PrefixStats, error) {
+	data, err := c.ObjectGetBytes(container, objectName)
+	if err != nil {
+		return nil, err
+	}
+	stats := new(PrefixStats)
+	err = json.Unmarshal(data, stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
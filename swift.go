@@ -3,8 +3,9 @@ package swift
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/cipher"
 	"crypto/hmac"
-	"crypto/md5"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
@@ -20,6 +21,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,7 +38,7 @@ const (
 )
 
 // ObjectType is the type of the swift object, regular, static large,
-// or dynamic large.
+// dynamic large or symlink.
 type ObjectType int
 
 // Values that ObjectType can take
@@ -44,6 +46,7 @@ const (
 	RegularObjectType ObjectType = iota
 	StaticLargeObjectType
 	DynamicLargeObjectType
+	SymlinkObjectType
 )
 
 // Connection holds the details of the connection to the swift server.
@@ -93,6 +96,13 @@ const (
 // http.ProxyFromEnvironment (http://golang.org/pkg/net/http/#ProxyFromEnvironment).
 // This means that the connection will respect the HTTP proxy specified by the
 // environment variables $HTTP_PROXY and $NO_PROXY.
+//
+// Requests with a body, such as uploads, are sent with "Expect:
+// 100-continue" so errors like failed authentication, an exceeded
+// quota or a missing container are returned before the body is sent.
+// The Transport made if you don't supply one waits
+// ExpectContinueTimeout for the server to agree to the body - set
+// ExpectContinueTimeout on your own http.Transport if you supply one.
 type Connection struct {
 	// Parameters - fill these in before calling Authenticate
 	// They are all optional except UserName, ApiKey and AuthUrl
@@ -109,6 +119,7 @@ type Connection struct {
 	UserAgent                   string            // Http User agent (default goswift/1.0)
 	ConnectTimeout              time.Duration     // Connect channel timeout (default 10s)
 	Timeout                     time.Duration     // Data channel timeout (default 60s)
+	ExpectContinueTimeout       time.Duration     // Wait for a 100 Continue before sending upload data (default 5s, < 0 to not wait)
 	Region                      string            // Region to use eg "LON", "ORD" - default is use first region (v2,v3 auth only)
 	AuthVersion                 int               // Set to 1, 2 or 3 or leave at 0 for autodetect
 	Internal                    bool              // Set this to true to use the the internal / service network
@@ -119,6 +130,20 @@ type Connection struct {
 	TenantDomainId              string            // Id of the tenant's domain (v3 auth only), only needed if it differs the from user domain
 	TrustId                     string            // Id of the trust (v3 auth only)
 	Transport                   http.RoundTripper `json:"-" xml:"-"` // Optional specialised http.Transport (eg. for Google Appengine)
+	Progress                    ProgressFunc      `json:"-" xml:"-"` // Optional function called as object data is uploaded or downloaded
+	Profile                     ProviderProfile   // Workarounds for quirks of the provider's proxies
+	Checksum                    Checksum          // Hashes used to check object integrity (default MD5)
+	CopyMethod                  CopyMethod        // How server side copies are done (default is to choose using /info)
+	LargeObjectEtag             LargeObjectEtag   // How downloads of large objects are checked when checkHash is set (default is not to)
+	NewTokenHeader              string            // Response header with a refreshed auth token to use from then on (default X-Auth-New-Token)
+	Scheduler                   *Scheduler        `json:"-" xml:"-"` // Optional Scheduler to share request slots with other Connections
+	Priority                    Priority          // Priority of the requests if Scheduler is set (default interactive)
+	NormalizeName               NameNormalizer    `json:"-" xml:"-"` // Optional function, eg norm.NFC.String, applied to the names of objects in requests and compared
+	Metrics                     Metrics           `json:"-" xml:"-"` // Optional Metrics told about the requests made
+	Tracer                      Tracer            `json:"-" xml:"-"` // Optional Tracer to start a span for each request made
+	Logger                      Logger            `json:"-" xml:"-"` // Optional Logger for messages and Debug output (default is the log package)
+	Debug                       DebugMode         // What to log about the requests made (default is DebugOff)
+	DebugMaxBody                int               // Bytes of each body logged with DebugBodies (default DefaultDebugMaxBody)
 	// These are filled in after Authenticate is called as are the defaults for above
 	StorageUrl string
 	AuthToken  string
@@ -128,6 +153,14 @@ type Connection struct {
 	authLock   *sync.Mutex   // lock when R/W StorageUrl, AuthToken, Auth
 	// swiftInfo is filled after QueryInfo is called
 	swiftInfo SwiftInfo
+	// plainListings is set to 1 if the server doesn't do JSON listings
+	plainListings int32
+	// autoCopyMethod is the CopyMethod chosen for CopyMethodAuto
+	// once it has been
+	autoCopyMethod int32
+	// listingLimit is the server's listing limit once it has been
+	// read from /info or -1 if that failed
+	listingLimit int32
 }
 
 // setFromEnv reads the value that param points to (it must be a
@@ -135,7 +168,13 @@ type Connection struct {
 // variable name passed in, parses it according to the type and writes
 // it to the pointer.
 func setFromEnv(param interface{}, name string) (err error) {
-	val := os.Getenv(name)
+	return setFromString(param, os.Getenv(name))
+}
+
+// setFromString parses val according to the type of param (it must
+// be a pointer) and writes it to the pointer if the value that param
+// points to is the zero value and val isn't empty.
+func setFromString(param interface{}, val string) (err error) {
 	if val == "" {
 		return
 	}
@@ -261,7 +300,7 @@ func (c *Connection) ApplyEnvironment() (err error) {
 	} {
 		err = setFromEnv(item.result, item.name)
 		if err != nil {
-			return newErrorf(0, "failed to read env var %q: %v", item.name, err)
+			return wrapErrorf(err, 0, "failed to read env var %q: %v", item.name, err)
 		}
 	}
 	return nil
@@ -269,16 +308,39 @@ func (c *Connection) ApplyEnvironment() (err error) {
 
 // Error - all errors generated by this package are of this type.  Other error
 // may be passed on from library functions though.
+//
+// The specific errors below, eg ObjectNotFound, are shared so they can
+// be compared with ==.  Other errors made from HTTP responses have the
+// TransId of the response which can be quoted to the operators of the
+// Swift cluster to find the request in their logs.
 type Error struct {
-	StatusCode int // HTTP status code if relevant or 0 if not
-	Text       string
+	StatusCode int     // HTTP status code if relevant or 0 if not
+	Text       string  // Description of the error
+	TransId    string  // X-Trans-Id or X-Openstack-Request-Id of the response if known
+	Method     string  // Method of the request if known, eg "PUT"
+	URL        string  // URL of the request if known with any temp_url_sig removed
+	Headers    Headers // Headers of the response if known
+	Body       []byte  // Start of the body of the response if known - at most maxErrorBody bytes
+	Err        error   // Underlying error which caused this one if any
 }
 
+// maxErrorBody is the most of the body of a response kept in an Error
+const maxErrorBody = 4096
+
 // Error satisfy the error interface.
 func (e *Error) Error() string {
+	if e.TransId != "" {
+		return e.Text + " (transaction id " + e.TransId + ")"
+	}
 	return e.Text
 }
 
+// Unwrap returns the underlying error if any so errors.Is and
+// errors.As can see it.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 // newError make a new error from a string.
 func newError(StatusCode int, Text string) *Error {
 	return &Error{
@@ -292,6 +354,51 @@ func newErrorf(StatusCode int, Text string, Parameters ...interface{}) *Error {
 	return newError(StatusCode, fmt.Sprintf(Text, Parameters...))
 }
 
+// wrapErrorf makes a new error from sprintf parameters which wraps
+// err.
+func wrapErrorf(err error, StatusCode int, Text string, Parameters ...interface{}) *Error {
+	e := newErrorf(StatusCode, Text, Parameters...)
+	e.Err = err
+	return e
+}
+
+// newResponseError makes a new error for resp from sprintf
+// parameters, recording the transaction id, request, headers and the
+// start of the body of the response.
+//
+// It reads from the body but doesn't close it.
+func newResponseError(resp *http.Response, Text string, Parameters ...interface{}) *Error {
+	err := newErrorf(resp.StatusCode, Text, Parameters...)
+	err.TransId = responseTransId(resp.Header)
+	err.Headers = readHeaders(resp)
+	if req := resp.Request; req != nil {
+		err.Method = req.Method
+		if req.URL != nil {
+			err.URL = redactURL(req.URL)
+		}
+	}
+	if resp.Body != nil {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		if len(body) > 0 {
+			err.Body = body
+		}
+	}
+	return err
+}
+
+// redactURL returns u as a string with the value of any temp_url_sig
+// parameter removed so it can be logged.
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	if query.Get("temp_url_sig") == "" {
+		return u.String()
+	}
+	query.Set("temp_url_sig", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
 // errorMap defines http error codes to error mappings.
 type errorMap map[int]error
 
@@ -307,8 +414,12 @@ var (
 	TimeoutError        = newError(408, "Timeout when reading or writing data")
 	Forbidden           = newError(403, "Operation forbidden")
 	TooLargeObject      = newError(413, "Too Large Object")
+	QuotaExceeded       = newError(413, "Quota Exceeded")
 	RateLimit           = newError(498, "Rate Limit")
 	TooManyRequests     = newError(429, "TooManyRequests")
+	ObjectExists        = newError(412, "Object Exists")
+	PreconditionFailed  = newError(412, "Precondition Failed")
+	RangeNotSatisfiable = newError(416, "Range Not Satisfiable")
 
 	// Mappings for authentication errors
 	authErrorMap = errorMap{
@@ -332,13 +443,31 @@ var (
 		400: BadRequest,
 		403: Forbidden,
 		404: ObjectNotFound,
+		412: PreconditionFailed,
 		413: TooLargeObject,
+		416: RangeNotSatisfiable,
 		422: ObjectCorrupted,
 		429: TooManyRequests,
 		498: RateLimit,
 	}
 )
 
+// objectPutErrorMap returns the error map to use for a PUT with the
+// headers h.
+//
+// If the PUT is create-only then 412 is mapped to ObjectExists.
+func objectPutErrorMap(h Headers) errorMap {
+	if h["If-None-Match"] != "*" {
+		return objectErrorMap
+	}
+	m := make(errorMap, len(objectErrorMap)+1)
+	for code, err := range objectErrorMap {
+		m[code] = err
+	}
+	m[412] = ObjectExists
+	return m
+}
+
 // checkClose is used to check the return from Close in a defer
 // statement.
 func checkClose(c io.Closer, err *error) {
@@ -348,14 +477,20 @@ func checkClose(c io.Closer, err *error) {
 	}
 }
 
-// drainAndClose discards all data from rd and closes it.
-// If an error occurs during Read, it is discarded.
+// maxDrainBody is the most data drainAndClose will read from a
+// response body.  Reading the rest of a small body lets the
+// connection be reused, but for a large one it is cheaper to drop the
+// connection and make another.
+const maxDrainBody = 64 * 1024
+
+// drainAndClose discards up to maxDrainBody bytes of data from rd and
+// closes it.  If an error occurs during Read, it is discarded.
 func drainAndClose(rd io.ReadCloser, err *error) {
 	if rd == nil {
 		return
 	}
 
-	_, _ = io.Copy(ioutil.Discard, rd)
+	_, _ = io.CopyN(ioutil.Discard, rd, maxDrainBody)
 	cerr := rd.Close()
 	if err != nil && *err == nil {
 		*err = cerr
@@ -366,6 +501,10 @@ func drainAndClose(rd io.ReadCloser, err *error) {
 // standard errors if necessary. If an error is returned, resp.Body
 // has been drained and closed.
 func (c *Connection) parseHeaders(resp *http.Response, errorMap errorMap) error {
+	if resp.StatusCode == 413 && isQuotaExceeded(resp) {
+		drainAndClose(resp.Body, nil)
+		return QuotaExceeded
+	}
 	if errorMap != nil {
 		if err, ok := errorMap[resp.StatusCode]; ok {
 			drainAndClose(resp.Body, nil)
@@ -373,29 +512,70 @@ func (c *Connection) parseHeaders(resp *http.Response, errorMap errorMap) error
 		}
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := newResponseError(resp, "HTTP Error: %d: %s", resp.StatusCode, resp.Status)
 		drainAndClose(resp.Body, nil)
-		return newErrorf(resp.StatusCode, "HTTP Error: %d: %s", resp.StatusCode, resp.Status)
+		return err
 	}
 	return nil
 }
 
 // readHeaders returns a Headers object from the http.Response.
 //
-// If it receives multiple values for a key (which should never
-// happen) it will use the first one
+// If it receives multiple values for a key (eg Vary or Set-Cookie
+// from middleware) they are joined with headerValueSeparator so none
+// are lost - use Headers.Values to read them.
 func readHeaders(resp *http.Response) Headers {
 	headers := Headers{}
 	for key, values := range resp.Header {
-		headers[key] = values[0]
+		headers[key] = strings.Join(values, headerValueSeparator)
 	}
 	return headers
 }
 
-// Headers stores HTTP headers (can only have one of each header like Swift).
+// headerValueSeparator separates multiple values for the same key in
+// Headers.  HTTP header values can't contain newlines so this can't
+// be confused with part of a value.
+const headerValueSeparator = "\n"
+
+// Headers stores HTTP headers.
+//
+// Swift only uses one of each header, but if a response has a header
+// more than once the values are stored in one entry separated by
+// newlines.  Use Values and Add to work with these.  An entry with
+// multiple values is sent as multiple headers in a request.
 type Headers map[string]string
 
+// Values returns all the values for key or nil if it isn't set.
+//
+// key is canonicalized with http.CanonicalHeaderKey as the keys of
+// response headers are.
+func (h Headers) Values(key string) []string {
+	value, ok := h[http.CanonicalHeaderKey(key)]
+	if !ok {
+		return nil
+	}
+	return strings.Split(value, headerValueSeparator)
+}
+
+// Add adds value to the values for key, keeping any already set.
+//
+// key is canonicalized with http.CanonicalHeaderKey.
+func (h Headers) Add(key, value string) {
+	key = http.CanonicalHeaderKey(key)
+	if old, ok := h[key]; ok {
+		value = old + headerValueSeparator + value
+	}
+	h[key] = value
+}
+
 // Does an http request using the running timer passed in
 func (c *Connection) doTimeoutRequest(timer *time.Timer, req *http.Request) (*http.Response, error) {
+	return c.doTimeoutRequestWithClient(c.client, timer, req)
+}
+
+// doTimeoutRequestWithClient is doTimeoutRequest using client to do
+// the request
+func (c *Connection) doTimeoutRequestWithClient(client *http.Client, timer *time.Timer, req *http.Request) (*http.Response, error) {
 	// Do the request in the background so we can check the timeout
 	type result struct {
 		resp *http.Response
@@ -403,7 +583,7 @@ func (c *Connection) doTimeoutRequest(timer *time.Timer, req *http.Request) (*ht
 	}
 	done := make(chan result, 1)
 	go func() {
-		resp, err := c.client.Do(req)
+		resp, err := client.Do(req)
 		done <- result{resp, err}
 	}()
 	// Wait for the read or the timeout
@@ -412,7 +592,7 @@ func (c *Connection) doTimeoutRequest(timer *time.Timer, req *http.Request) (*ht
 		return r.resp, r.err
 	case <-timer.C:
 		// Kill the connection on timeout so we don't leak sockets or goroutines
-		cancelRequest(c.Transport, req)
+		c.cancelRequest(req)
 		return nil, TimeoutError
 	}
 	panic("unreachable") // For Go 1.0
@@ -434,6 +614,9 @@ func (c *Connection) setDefaults() {
 	if c.Timeout == 0 {
 		c.Timeout = 60 * time.Second
 	}
+	if c.ExpectContinueTimeout == 0 {
+		c.ExpectContinueTimeout = 5 * time.Second
+	}
 	if c.Transport == nil {
 		t := &http.Transport{
 			//		TLSClientConfig:    &tls.Config{RootCAs: pool},
@@ -442,7 +625,9 @@ func (c *Connection) setDefaults() {
 			// Half of linux's default open files limit (1024).
 			MaxIdleConnsPerHost: 512,
 		}
-		SetExpectContinueTimeout(t, 5*time.Second)
+		if c.ExpectContinueTimeout > 0 {
+			SetExpectContinueTimeout(t, c.ExpectContinueTimeout)
+		}
 		c.Transport = t
 	}
 	if c.client == nil {
@@ -453,24 +638,49 @@ func (c *Connection) setDefaults() {
 	}
 }
 
+// authLockInit protects the creation of the authLock of every
+// Connection
+var authLockInit sync.Mutex
+
+// initAuthLock creates the authLock if it doesn't exist yet.
+//
+// This is safe to call from multiple go routines at once.
+func (c *Connection) initAuthLock() {
+	authLockInit.Lock()
+	if c.authLock == nil {
+		c.authLock = &sync.Mutex{}
+	}
+	authLockInit.Unlock()
+}
+
 // Authenticate connects to the Swift server.
 //
 // If you don't call it before calling one of the connection methods
 // then it will be called for you on the first access.
 func (c *Connection) Authenticate() (err error) {
-	if c.authLock == nil {
-		c.authLock = &sync.Mutex{}
-	}
+	return c.AuthenticateContext(context.Background())
+}
+
+// AuthenticateContext connects to the Swift server like Authenticate
+// but gives up when ctx is cancelled or its deadline expires,
+// returning ctx.Err().
+func (c *Connection) AuthenticateContext(ctx context.Context) (err error) {
+	c.initAuthLock()
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
-	return c.authenticate()
+	return c.authenticate(ctx)
 }
 
 // Internal implementation of Authenticate
 //
 // Call with authLock held
-func (c *Connection) authenticate() (err error) {
+func (c *Connection) authenticate(ctx context.Context) (err error) {
 	c.setDefaults()
+	if c.Metrics != nil {
+		defer func() {
+			c.Metrics.Authenticate(err)
+		}()
+	}
 
 	// Flush the keepalives connection - if we are
 	// re-authenticating then stuff has gone wrong
@@ -491,11 +701,26 @@ again:
 		return
 	}
 	if req != nil {
+		req = req.WithContext(ctx)
 		timer := time.NewTimer(c.ConnectTimeout)
 		defer timer.Stop()
 		var resp *http.Response
+		var acquired bool
+		if acquired, err = c.acquireSlot(ctx); err != nil {
+			return
+		}
+		dump := c.startDebug(req, true)
 		resp, err = c.doTimeoutRequest(timer, req)
 		if err != nil {
+			c.releaseSlot(acquired)
+		} else {
+			resp.Body = c.scheduleBody(resp.Body, acquired)
+		}
+		resp = dump.end(resp, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
 			return
 		}
 		defer func() {
@@ -541,12 +766,12 @@ again:
 // Get an authToken and url
 //
 // The Url may be updated if it needed to authenticate using the OnReAuth function
-func (c *Connection) getUrlAndAuthToken(targetUrlIn string, OnReAuth func() (string, error)) (targetUrlOut, authToken string, err error) {
+func (c *Connection) getUrlAndAuthToken(ctx context.Context, targetUrlIn string, OnReAuth func() (string, error)) (targetUrlOut, authToken string, err error) {
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 	targetUrlOut = targetUrlIn
 	if !c.authenticated() {
-		err = c.authenticate()
+		err = c.authenticate(ctx)
 		if err != nil {
 			return
 		}
@@ -561,6 +786,40 @@ func (c *Connection) getUrlAndAuthToken(targetUrlIn string, OnReAuth func() (str
 	return
 }
 
+// DefaultNewTokenHeader is the response header some auth middleware
+// uses to send a refreshed auth token
+const DefaultNewTokenHeader = "X-Auth-New-Token"
+
+// adoptNewToken replaces the auth token the request was made with by
+// the refreshed token in the response if the auth middleware sent
+// one.  This saves authenticating again when the old token expires.
+//
+// If the response has an X-Auth-Token-Expires header, the number of
+// seconds the new token is valid for, then Expires is updated too.
+func (c *Connection) adoptNewToken(resp *http.Response, oldToken string) {
+	header := c.NewTokenHeader
+	if header == "" {
+		header = DefaultNewTokenHeader
+	}
+	newToken := resp.Header.Get(header)
+	if newToken == "" || newToken == oldToken {
+		return
+	}
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	// Don't overwrite a token which has been changed since the
+	// request was made
+	if c.AuthToken != oldToken {
+		return
+	}
+	c.AuthToken = newToken
+	if expires := resp.Header.Get("X-Auth-Token-Expires"); expires != "" {
+		if seconds, err := strconv.ParseInt(expires, 10, 64); err == nil {
+			c.Expires = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+	}
+}
+
 // flushKeepaliveConnections is called to flush pending requests after an error.
 func flushKeepaliveConnections(transport http.RoundTripper) {
 	if tr, ok := transport.(interface {
@@ -578,14 +837,60 @@ func (c *Connection) UnAuthenticate() {
 	c.authLock.Unlock()
 }
 
+// CurrentAuthToken returns the auth token of the connection, or "" if
+// it isn't authenticated.
+//
+// Unlike reading the AuthToken field, this is safe to call while the
+// Connection is in use.  The token can be passed to other OpenStack
+// clients to save them authenticating again.
+func (c *Connection) CurrentAuthToken() string {
+	c.initAuthLock()
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	return c.AuthToken
+}
+
+// CurrentStorageUrl returns the storage URL of the connection, or ""
+// if it isn't authenticated.
+//
+// Unlike reading the StorageUrl field, this is safe to call while the
+// Connection is in use.
+func (c *Connection) CurrentStorageUrl() string {
+	c.initAuthLock()
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	return c.StorageUrl
+}
+
+// AuthTokenExpiry returns the time the auth token expires, which is
+// Zero if it isn't known.
+func (c *Connection) AuthTokenExpiry() time.Time {
+	c.initAuthLock()
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	return c.Expires
+}
+
+// ServiceCatalog returns the service catalog read when authenticating.
+//
+// This returns nil if the Connection isn't authenticated or the auth
+// doesn't return a catalog as is the case with v1 auth.
+func (c *Connection) ServiceCatalog() []CatalogService {
+	c.initAuthLock()
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	if cataloger, ok := c.Auth.(Cataloger); ok && c.authenticated() {
+		return cataloger.ServiceCatalog()
+	}
+	return nil
+}
+
 // Authenticated returns a boolean to show if the current connection
 // is authenticated.
 //
 // Doesn't actually check the credentials against the server.
 func (c *Connection) Authenticated() bool {
-	if c.authLock == nil {
-		c.authLock = &sync.Mutex{}
-	}
+	c.initAuthLock()
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 	return c.authenticated()
@@ -595,7 +900,13 @@ func (c *Connection) Authenticated() bool {
 //
 // Call with authLock held
 func (c *Connection) authenticated() bool {
-	if c.StorageUrl == "" || c.AuthToken == "" {
+	if c.StorageUrl == "" {
+		return false
+	}
+	if _, anonymous := c.Auth.(*anonymousAuth); anonymous {
+		return true
+	}
+	if c.AuthToken == "" {
 		return false
 	}
 	if c.Expires.IsZero() {
@@ -620,6 +931,39 @@ func (i SwiftInfo) SupportsSLO() bool {
 	return val
 }
 
+// SupportsCopyVerb returns whether the server is known to accept the
+// COPY verb.
+//
+// Swift's own proxy always does, so this is true if /info has a
+// "swift" section.  Other servers implementing the Swift API may only
+// accept PUT with X-Copy-From.
+func (i SwiftInfo) SupportsCopyVerb() bool {
+	_, val := i["swift"]
+	return val
+}
+
+// ListingLimit returns the maximum number of items the server returns
+// in one container or object listing.
+func (i SwiftInfo) ListingLimit() int {
+	if swift, ok := i["swift"].(map[string]interface{}); ok {
+		if val, ok := swift["container_listing_limit"].(float64); ok && val >= 1 {
+			return int(val)
+		}
+	}
+	return allObjectsLimit
+}
+
+// SLOMaxSegments returns the maximum number of segments the server
+// allows in a static large object manifest.
+func (i SwiftInfo) SLOMaxSegments() int64 {
+	if slo, ok := i["slo"].(map[string]interface{}); ok {
+		if val, ok := slo["max_manifest_segments"].(float64); ok && val >= 1 {
+			return int64(val)
+		}
+	}
+	return 1000
+}
+
 func (i SwiftInfo) SLOMinSegmentSize() int64 {
 	if slo, ok := i["slo"].(map[string]interface{}); ok {
 		val, _ := slo["min_segment_size"].(float64)
@@ -678,6 +1022,18 @@ type RequestOpts struct {
 	Retries    int
 	// if set this is called on re-authentication to refresh the targetUrl
 	OnReAuth func() (string, error)
+	// if set this is used for the request and any re-authentication
+	// it needs so they can be cancelled
+	Context context.Context
+	// if set this overrides the ConnectTimeout and Timeout of the
+	// Connection for this request
+	Timeout time.Duration
+	// if set object uploads are compressed and downloads
+	// decompressed - this is ignored by Call
+	Gzip bool
+	// if set object uploads are encrypted and downloads decrypted
+	// with this - this is ignored by Call
+	Encryption *Encryption
 }
 
 // Call runs a remote command on the targetUrl, returns a
@@ -701,6 +1057,7 @@ type RequestOpts struct {
 //
 // This method is exported so extensions can call it.
 func (c *Connection) Call(targetUrl string, p RequestOpts) (resp *http.Response, headers Headers, err error) {
+	c.initAuthLock()
 	c.authLock.Lock()
 	c.setDefaults()
 	c.authLock.Unlock()
@@ -708,10 +1065,35 @@ func (c *Connection) Call(targetUrl string, p RequestOpts) (resp *http.Response,
 	if retries == 0 {
 		retries = c.Retries
 	}
+	connectTimeout, timeout := c.ConnectTimeout, c.Timeout
+	if p.Timeout > 0 {
+		connectTimeout, timeout = p.Timeout, p.Timeout
+	}
+	if _, hasCL := p.Headers["Content-Length"]; c.Profile.NoChunkedPut && p.Body != nil && !hasCL {
+		var length int64
+		p.Body, length, err = fixedLengthBody(p.Body)
+		if err != nil {
+			return
+		}
+		headers := make(Headers, len(p.Headers)+1)
+		for k, v := range p.Headers {
+			headers[k] = v
+		}
+		headers["Content-Length"] = strconv.FormatInt(length, 10)
+		p.Headers = headers
+	}
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := c.startSpan(ctx, &p)
+	defer func() {
+		endSpan(span, resp, err)
+	}()
 	var req *http.Request
-	for {
+	for attempt := 1; ; attempt++ {
 		var authToken string
-		if targetUrl, authToken, err = c.getUrlAndAuthToken(targetUrl, p.OnReAuth); err != nil {
+		if targetUrl, authToken, err = c.getUrlAndAuthToken(ctx, targetUrl, p.OnReAuth); err != nil {
 			return //authentication failure
 		}
 		var URL *url.URL
@@ -728,54 +1110,108 @@ func (c *Connection) Call(targetUrl string, p RequestOpts) (resp *http.Response,
 		if p.Parameters != nil {
 			URL.RawQuery = p.Parameters.Encode()
 		}
-		timer := time.NewTimer(c.ConnectTimeout)
+		// Wait for a slot before starting the timer so the time
+		// spent queueing doesn't count towards the timeout
+		var acquired bool
+		if acquired, err = c.acquireSlot(ctx); err != nil {
+			return
+		}
+		timer := time.NewTimer(connectTimeout)
 		defer timer.Stop()
 		reader := p.Body
 		if reader != nil {
-			reader = newWatchdogReader(reader, c.Timeout, timer)
+			if c.Metrics != nil {
+				reader = &metricsReader{Reader: reader, fn: c.Metrics.BytesUploaded, operation: p.Operation}
+			}
+			reader = newWatchdogReader(reader, timeout, timer)
 		}
 		req, err = http.NewRequest(p.Operation, URL.String(), reader)
 		if err != nil {
+			c.releaseSlot(acquired)
 			return
 		}
+		if p.Context != nil {
+			req = req.WithContext(ctx)
+		}
 		if p.Headers != nil {
 			for k, v := range p.Headers {
 				// Set ContentLength in req if the user passed it in in the headers
 				if k == "Content-Length" {
 					req.ContentLength, err = strconv.ParseInt(v, 10, 64)
 					if err != nil {
-						err = fmt.Errorf("Invalid %q header %q: %v", k, v, err)
+						c.releaseSlot(acquired)
+						err = fmt.Errorf("Invalid %q header %q: %w", k, v, err)
 						return
 					}
 				} else {
-					req.Header.Add(k, v)
+					for _, value := range strings.Split(v, headerValueSeparator) {
+						req.Header.Add(k, value)
+					}
 				}
 			}
 		}
 		req.Header.Add("User-Agent", c.UserAgent)
-		req.Header.Add("X-Auth-Token", authToken)
+		if authToken != "" {
+			req.Header.Add("X-Auth-Token", authToken)
+		}
 
 		_, hasCL := p.Headers["Content-Length"]
 		AddExpectAndTransferEncoding(req, hasCL)
+		if c.ExpectContinueTimeout < 0 {
+			req.Header.Del("Expect")
+		}
+		c.Profile.applyRequest(req)
 
+		attemptCtx, attemptSpan := c.startAttempt(ctx, &p, attempt)
+		if attemptSpan != nil {
+			req = req.WithContext(attemptCtx)
+		}
+		dump := c.startDebug(req, false)
+		start := time.Now()
 		resp, err = c.doTimeoutRequest(timer, req)
 		if err != nil {
+			c.releaseSlot(acquired)
+		} else {
+			resp.Body = c.scheduleBody(resp.Body, acquired)
+		}
+		resp = dump.end(resp, err)
+		endSpan(attemptSpan, resp, err)
+		if c.Metrics != nil {
+			statusCode := 0
+			if err == nil {
+				statusCode = resp.StatusCode
+			}
+			c.Metrics.Request(p.Operation, statusCode, time.Since(start))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+				return
+			}
 			if (p.Operation == "HEAD" || p.Operation == "GET") && retries > 0 {
 				retries--
+				if c.Metrics != nil {
+					c.Metrics.Retry(p.Operation)
+				}
 				continue
 			}
 			return
 		}
-		// Check to see if token has expired
-		if resp.StatusCode == 401 && retries > 0 {
+		// Check to see if token has expired - anonymous
+		// connections have no token to renew
+		if resp.StatusCode == 401 && retries > 0 && authToken != "" {
 			drainAndClose(resp.Body, nil)
 			c.UnAuthenticate()
 			retries--
+			if c.Metrics != nil {
+				c.Metrics.Retry(p.Operation)
+			}
 		} else {
 			break
 		}
 	}
 
+	c.adoptNewToken(resp, req.Header.Get("X-Auth-Token"))
 	headers = readHeaders(resp)
 	if err = c.parseHeaders(resp, p.ErrorMap); err != nil {
 		return
@@ -788,10 +1224,16 @@ func (c *Connection) Call(targetUrl string, p RequestOpts) (resp *http.Response,
 	} else {
 		// Cancel the request on timeout
 		cancel := func() {
-			cancelRequest(c.Transport, req)
+			c.cancelRequest(req)
 		}
 		// Wrap resp.Body to make it obey an idle timeout
-		resp.Body = newTimeoutReader(resp.Body, c.Timeout, cancel)
+		resp.Body = newTimeoutReader(resp.Body, timeout, cancel)
+		if c.Metrics != nil {
+			resp.Body = &metricsReadCloser{
+				metricsReader: metricsReader{Reader: resp.Body, fn: c.Metrics.BytesDownloaded, operation: p.Operation},
+				closer:        resp.Body,
+			}
+		}
 	}
 	return
 }
@@ -809,7 +1251,17 @@ func (c *Connection) Call(targetUrl string, p RequestOpts) (resp *http.Response,
 //
 // This will Authenticate if necessary, and re-authenticate if it
 // receives a 401 error which means the token has expired
-func (c *Connection) storage(p RequestOpts) (resp *http.Response, headers Headers, err error) {
+//
+// Any options are applied to p before it is used.
+//
+// The container and object names are checked first and an error
+// wrapping InvalidName is returned if the server would reject them.
+func (c *Connection) storage(p RequestOpts, options ...RequestOption) (resp *http.Response, headers Headers, err error) {
+	p.apply(options)
+	c.initAuthLock()
+	if err = c.checkRequestNames(&p); err != nil {
+		return
+	}
 	p.OnReAuth = func() (string, error) {
 		return c.StorageUrl, nil
 	}
@@ -853,6 +1305,22 @@ func readJson(resp *http.Response, result interface{}) (err error) {
 	return decoder.Decode(result)
 }
 
+// canonicalJson marshals v to JSON in a stable form which is the same
+// byte for byte each time.
+//
+// encoding/json already writes struct fields in order and sorts map
+// keys.  This turns off the escaping of <, > and & which isn't needed
+// outside HTML and drops the trailing newline.
+func canonicalJson(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
 /* ------------------------------------------------------------ */
 
 // ContainersOpts is options for Containers() and ContainerNames()
@@ -861,7 +1329,9 @@ type ContainersOpts struct {
 	Prefix    string  // Given a string value x, return container names matching the specified prefix.
 	Marker    string  // Given a string value x, return container names greater in value than the specified marker.
 	EndMarker string  // Given a string value x, return container names less in value than the specified marker.
+	Delimiter rune    // For a character c, return container names up to and including the first c after the Prefix, rolled up into one
 	Headers   Headers // Any additional HTTP headers - can be nil
+	Reverse   bool    // Return container names in reverse order - Marker and EndMarker are reversed too
 }
 
 // parse the ContainerOpts
@@ -881,6 +1351,12 @@ func (opts *ContainersOpts) parse() (url.Values, Headers) {
 		if opts.EndMarker != "" {
 			v.Set("end_marker", opts.EndMarker)
 		}
+		if opts.Delimiter != 0 {
+			v.Set("delimiter", string(opts.Delimiter))
+		}
+		if opts.Reverse {
+			v.Set("reverse", "true")
+		}
 		h = opts.Headers
 	}
 	return v, h
@@ -888,13 +1364,19 @@ func (opts *ContainersOpts) parse() (url.Values, Headers) {
 
 // ContainerNames returns a slice of names of containers in this account.
 func (c *Connection) ContainerNames(opts *ContainersOpts) ([]string, error) {
+	return c.ContainerNamesWithOptions(opts)
+}
+
+// ContainerNamesWithOptions is ContainerNames with options applied to
+// its requests
+func (c *Connection) ContainerNamesWithOptions(opts *ContainersOpts, options ...RequestOption) ([]string, error) {
 	v, h := opts.parse()
 	resp, _, err := c.storage(RequestOpts{
 		Operation:  "GET",
 		Parameters: v,
 		ErrorMap:   ContainerErrorMap,
 		Headers:    h,
-	})
+	}, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -904,28 +1386,53 @@ func (c *Connection) ContainerNames(opts *ContainersOpts) ([]string, error) {
 
 // Container contains information about a container
 type Container struct {
-	Name  string // Name of the container
-	Count int64  // Number of objects in the container
-	Bytes int64  // Total number of bytes used in the container
+	Name            string             // Name of the container
+	Count           int64              // Number of objects in the container
+	Bytes           int64              // Total number of bytes used in the container
+	PseudoDirectory bool               // Set when using delimiter to show that this is a common prefix of container names rather than a container
+	SubDir          string             `json:"subdir"` // returned only when using delimiter to mark common prefixes
+	Settings        *ContainerSettings `json:"-"`      // Settings of the container - only set by Connection.Container
 }
 
 // Containers returns a slice of structures with full information as
 // described in Container.
+//
+// If Delimiter is set in the opts then PseudoDirectory may be set.
+// These are not containers but the common prefixes of the names of
+// the containers under them, eg "tenant1-" for containers named
+// "tenant1-photos" and "tenant1-backups" with a Delimiter of '-'.
 func (c *Connection) Containers(opts *ContainersOpts) ([]Container, error) {
+	return c.ContainersWithOptions(opts)
+}
+
+// ContainersWithOptions is Containers with options applied to its
+// requests
+func (c *Connection) ContainersWithOptions(opts *ContainersOpts, options ...RequestOption) ([]Container, error) {
+	var containers []Container
+	err := c.ContainersInto(opts, &containers, options...)
+	for i := range containers {
+		if containers[i].SubDir != "" {
+			containers[i].Name = containers[i].SubDir
+			containers[i].PseudoDirectory = true
+		}
+	}
+	return containers, err
+}
+
+// ContainersInto reads the JSON listing of containers into result
+// which should be a pointer to a slice of structs with json tags, or
+// anything else encoding/json can decode a list of objects into.
+//
+// Use this instead of Containers to read fields which Container
+// doesn't have, eg ones added by a proxy.  A struct which embeds
+// Container will get the standard fields too.
+func (c *Connection) ContainersInto(opts *ContainersOpts, result interface{}, options ...RequestOption) error {
 	v, h := opts.parse()
-	v.Set("format", "json")
-	resp, _, err := c.storage(RequestOpts{
-		Operation:  "GET",
-		Parameters: v,
-		ErrorMap:   ContainerErrorMap,
-		Headers:    h,
-	})
+	resp, err := c.jsonListing("", v, h, options...)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var containers []Container
-	err = readJson(resp, &containers)
-	return containers, err
+	return readJson(resp, result)
 }
 
 // containersAllOpts makes a copy of opts if set or makes a new one and
@@ -948,10 +1455,16 @@ func containersAllOpts(opts *ContainersOpts) *ContainersOpts {
 //
 // It has a default Limit parameter but you may pass in your own
 func (c *Connection) ContainersAll(opts *ContainersOpts) ([]Container, error) {
+	return c.ContainersAllWithOptions(opts)
+}
+
+// ContainersAllWithOptions is ContainersAll with options applied to its
+// requests
+func (c *Connection) ContainersAllWithOptions(opts *ContainersOpts, options ...RequestOption) ([]Container, error) {
 	opts = containersAllOpts(opts)
 	containers := make([]Container, 0)
 	for {
-		newContainers, err := c.Containers(opts)
+		newContainers, err := c.ContainersWithOptions(opts, options...)
 		if err != nil {
 			return nil, err
 		}
@@ -970,10 +1483,16 @@ func (c *Connection) ContainersAll(opts *ContainersOpts) ([]Container, error) {
 //
 // It has a default Limit parameter but you may pass in your own
 func (c *Connection) ContainerNamesAll(opts *ContainersOpts) ([]string, error) {
+	return c.ContainerNamesAllWithOptions(opts)
+}
+
+// ContainerNamesAllWithOptions is ContainerNamesAll with options
+// applied to its requests
+func (c *Connection) ContainerNamesAllWithOptions(opts *ContainersOpts, options ...RequestOption) ([]string, error) {
 	opts = containersAllOpts(opts)
 	containers := make([]string, 0)
 	for {
-		newContainers, err := c.ContainerNames(opts)
+		newContainers, err := c.ContainerNamesWithOptions(opts, options...)
 		if err != nil {
 			return nil, err
 		}
@@ -998,6 +1517,7 @@ type ObjectsOpts struct {
 	Delimiter  rune    // For a character c, return all the object names nested in the container
 	Headers    Headers // Any additional HTTP headers - can be nil
 	KeepMarker bool    // Do not reset Marker when using ObjectsAll or ObjectNamesAll
+	Reverse    bool    // Return object names in reverse order - Marker and EndMarker are reversed too
 }
 
 // parse reads values out of ObjectsOpts
@@ -1023,13 +1543,28 @@ func (opts *ObjectsOpts) parse() (url.Values, Headers) {
 		if opts.Delimiter != 0 {
 			v.Set("delimiter", string(opts.Delimiter))
 		}
+		if opts.Reverse {
+			v.Set("reverse", "true")
+		}
 		h = opts.Headers
 	}
 	return v, h
 }
 
 // ObjectNames returns a slice of names of objects in a given container.
+//
+// This returns at most one page of names, 10,000 by default, so use
+// ObjectNamesAll to read all of them.
 func (c *Connection) ObjectNames(container string, opts *ObjectsOpts) ([]string, error) {
+	return c.ObjectNamesWithOptions(container, opts)
+}
+
+// ObjectNamesWithOptions is ObjectNames with options applied to its
+// requests
+func (c *Connection) ObjectNamesWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]string, error) {
+	if err := requireContainerName(container); err != nil {
+		return nil, err
+	}
 	v, h := opts.parse()
 	resp, _, err := c.storage(RequestOpts{
 		Container:  container,
@@ -1037,7 +1572,7 @@ func (c *Connection) ObjectNames(container string, opts *ObjectsOpts) ([]string,
 		Parameters: v,
 		ErrorMap:   ContainerErrorMap,
 		Headers:    h,
-	})
+	}, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -1050,12 +1585,26 @@ type Object struct {
 	ContentType        string     `json:"content_type"`  // eg application/directory
 	Bytes              int64      `json:"bytes"`         // size in bytes
 	ServerLastModified string     `json:"last_modified"` // Last modified time, eg '2011-06-30T08:20:47.736680' as a string supplied by the server
-	LastModified       time.Time  // Last modified time converted to a time.Time
+	LastModified       time.Time  // Last modified time converted to a time.Time including any fractional seconds
+	Timestamp          time.Time  // Creation time read from X-Timestamp - only set by Connection.Object
 	Hash               string     `json:"hash"`     // MD5 hash, eg "d41d8cd98f00b204e9800998ecf8427e"
 	SLOHash            string     `json:"slo_etag"` // MD5 hash of all segments' MD5 hash, eg "d41d8cd98f00b204e9800998ecf8427e"
 	PseudoDirectory    bool       // Set when using delimiter to show that this directory object does not really exist
 	SubDir             string     `json:"subdir"` // returned only when using delimiter to mark "pseudo directories"
 	ObjectType         ObjectType // type of this object
+	SymlinkPath        string     `json:"symlink_path"`  // path of the target if this is a symlink, eg "/v1/AUTH_test/container/object"
+	SymlinkEtag        string     `json:"symlink_etag"`  // MD5 hash of the target if this is a static symlink
+	SymlinkBytes       int64      `json:"symlink_bytes"` // size of the target if this is a static symlink
+}
+
+// SymlinkTarget returns the account, container and object name of the
+// target of an Object read from a listing if it is a symlink.
+func (object *Object) SymlinkTarget() (account string, container string, objectName string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(object.SymlinkPath, "/"), "/", 4)
+	if len(parts) != 4 {
+		return "", "", "", false
+	}
+	return parts[1], parts[2], parts[3], true
 }
 
 // Objects returns a slice of Object with information about each
@@ -1065,50 +1614,126 @@ type Object struct {
 // with ContentType 'application/directory'.  These are not real
 // objects but represent directories of objects which haven't had an
 // object created for them.
+//
+// This returns at most one page of objects, 10,000 by default, so use
+// ObjectsAll to read all of them.
 func (c *Connection) Objects(container string, opts *ObjectsOpts) ([]Object, error) {
+	return c.ObjectsWithOptions(container, opts)
+}
+
+// ObjectsWithOptions is Objects with options applied to its requests
+func (c *Connection) ObjectsWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]Object, error) {
+	var objects []Object
+	err := c.ObjectsInto(container, opts, &objects, options...)
+	for i := range objects {
+		if parseErr := objects[i].parseListing(); parseErr != nil {
+			return nil, parseErr
+		}
+	}
+	return objects, err
+}
+
+// parseListing converts pseudo directories and dates in an Object
+// read from a JSON listing
+func (object *Object) parseListing() (err error) {
+	if object.SubDir != "" {
+		object.Name = object.SubDir
+		object.PseudoDirectory = true
+		object.ContentType = DirectoryContentType
+	}
+	if object.ServerLastModified != "" {
+		// 2012-11-11T14:49:47.887250
+		//
+		// Fractional seconds are kept so that updates
+		// close together in time can be told apart.
+		// Object uses X-Timestamp to get the same
+		// precision.
+		lastModified, err := ParseListingTime(object.ServerLastModified)
+		if err != nil {
+			return err
+		}
+		object.LastModified = lastModified
+	}
+	if object.SLOHash != "" {
+		object.ObjectType = StaticLargeObjectType
+	} else if object.SymlinkPath != "" {
+		object.ObjectType = SymlinkObjectType
+	}
+	return nil
+}
+
+// listingPage describes a page of a listing which was passed to a
+// callback as it was read rather than returned as a slice.  It can be
+// returned to ObjectsWalk.
+type listingPage struct {
+	n    int    // number of items in the page
+	last string // name of the last item
+}
+
+// objectsStream reads a page of the JSON listing of the objects in
+// container calling fn with each Object as soon as it has been
+// decoded, so the page is never held in memory all at once.
+//
+// If fn returns an error then reading stops and it is returned.
+func (c *Connection) objectsStream(container string, opts *ObjectsOpts, fn func(*Object) error, options ...RequestOption) (page listingPage, err error) {
+	if err := requireContainerName(container); err != nil {
+		return page, err
+	}
 	v, h := opts.parse()
-	v.Set("format", "json")
-	resp, _, err := c.storage(RequestOpts{
-		Container:  container,
-		Operation:  "GET",
-		Parameters: v,
-		ErrorMap:   ContainerErrorMap,
-		Headers:    h,
-	})
+	resp, err := c.jsonListing(container, v, h, options...)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
-	var objects []Object
-	err = readJson(resp, &objects)
-	// Convert Pseudo directories and dates
-	for i := range objects {
-		object := &objects[i]
-		if object.SubDir != "" {
-			object.Name = object.SubDir
-			object.PseudoDirectory = true
-			object.ContentType = "application/directory"
-		}
-		if object.ServerLastModified != "" {
-			// 2012-11-11T14:49:47.887250
-			//
-			// Remove fractional seconds if present. This
-			// then keeps it consistent with Object
-			// which can only return timestamps accurate
-			// to 1 second
-			//
-			// The TimeFormat will parse fractional
-			// seconds if desired though
-			datetime := strings.SplitN(object.ServerLastModified, ".", 2)[0]
-			object.LastModified, err = time.Parse(TimeFormat, datetime)
-			if err != nil {
-				return nil, err
-			}
+	defer drainAndClose(resp.Body, &err)
+	decoder := json.NewDecoder(resp.Body)
+	token, err := decoder.Token()
+	if err != nil {
+		return page, err
+	}
+	if token != json.Delim('[') {
+		return page, newErrorf(0, "Bad JSON listing: expecting '[' got %v", token)
+	}
+	for decoder.More() {
+		var object Object
+		err = decoder.Decode(&object)
+		if err != nil {
+			return page, err
+		}
+		err = object.parseListing()
+		if err != nil {
+			return page, err
 		}
-		if object.SLOHash != "" {
-			object.ObjectType = StaticLargeObjectType
+		page.n++
+		page.last = object.Name
+		err = fn(&object)
+		if err != nil {
+			return page, err
 		}
 	}
-	return objects, err
+	_, err = decoder.Token()
+	return page, err
+}
+
+// ObjectsInto reads the JSON listing of the objects in container into
+// result which should be a pointer to a slice of structs with json
+// tags, or anything else encoding/json can decode a list of objects
+// into.
+//
+// Use this instead of Objects to read fields which Object doesn't
+// have, eg ones added by a proxy, without parsing the listing twice.
+// A struct which embeds Object will get the fields with json tags
+// but, unlike Objects, LastModified, PseudoDirectory and ObjectType
+// aren't filled in.
+func (c *Connection) ObjectsInto(container string, opts *ObjectsOpts, result interface{}, options ...RequestOption) error {
+	if err := requireContainerName(container); err != nil {
+		return err
+	}
+	v, h := opts.parse()
+	resp, err := c.jsonListing(container, v, h, options...)
+	if err != nil {
+		return err
+	}
+	return readJson(resp, result)
 }
 
 // objectsAllOpts makes a copy of opts if set or makes a new one and
@@ -1135,20 +1760,34 @@ func objectsAllOpts(opts *ObjectsOpts, Limit int) *ObjectsOpts {
 // Do whatever is required with the results then return them
 type ObjectsWalkFn func(*ObjectsOpts) (interface{}, error)
 
+// StopWalk can be returned from the callbacks of ObjectsWalk,
+// ObjectsEach and ObjectNamesEach to stop the walk early.  It isn't
+// returned by the walk itself.
+var StopWalk = newError(0, "Stop walking objects")
+
 // ObjectsWalk is uses to iterate through all the objects in chunks as
 // returned by Objects or ObjectNames using the Marker and Limit
 // parameters in the ObjectsOpts.
 //
+// It keeps going until the listing is exhausted, even if the server
+// returns fewer objects than the Limit asked for because its own
+// listing limit is lower.
+//
 // Pass in a closure `walkFn` which calls Objects or ObjectNames with
 // the *ObjectsOpts passed to it and does something with the results.
 //
-// Errors will be returned from this function
+// Errors will be returned from this function, except for StopWalk
+// which `walkFn` can return to finish the walk early without an
+// error.
 //
 // It has a default Limit parameter but you may pass in your own
 func (c *Connection) ObjectsWalk(container string, opts *ObjectsOpts, walkFn ObjectsWalkFn) error {
 	opts = objectsAllOpts(opts, allObjectsChanLimit)
 	for {
 		objects, err := walkFn(opts)
+		if err == StopWalk {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
@@ -1165,10 +1804,12 @@ func (c *Connection) ObjectsWalk(container string, opts *ObjectsOpts, walkFn Obj
 			if n > 0 {
 				last = objects[len(objects)-1].Name
 			}
+		case listingPage:
+			n, last = objects.n, objects.last
 		default:
 			panic("Unknown type returned to ObjectsWalk")
 		}
-		if n < opts.Limit {
+		if n == 0 || (n < opts.Limit && !c.listingMaybeTruncated(n)) {
 			break
 		}
 		opts.Marker = last
@@ -1176,13 +1817,39 @@ func (c *Connection) ObjectsWalk(container string, opts *ObjectsOpts, walkFn Obj
 	return nil
 }
 
+// listingMaybeTruncated returns whether a listing of n items, fewer
+// than were asked for, could have been cut short by the server's
+// listing limit rather than being the end of the listing.
+//
+// The limit is read from /info the first time and remembered, as is
+// /info failing so it isn't asked for on every page.  If the limit
+// isn't known then it returns false and the listing is taken to be
+// complete.
+func (c *Connection) listingMaybeTruncated(n int) bool {
+	limit := atomic.LoadInt32(&c.listingLimit)
+	if limit == 0 {
+		limit = -1
+		if info, err := c.cachedQueryInfo(); err == nil {
+			limit = int32(info.ListingLimit())
+		}
+		atomic.StoreInt32(&c.listingLimit, limit)
+	}
+	return limit > 0 && n >= int(limit)
+}
+
 // ObjectsAll is like Objects but it returns an unlimited number of Objects in a slice
 //
 // It calls Objects multiple times using the Marker parameter
 func (c *Connection) ObjectsAll(container string, opts *ObjectsOpts) ([]Object, error) {
+	return c.ObjectsAllWithOptions(container, opts)
+}
+
+// ObjectsAllWithOptions is ObjectsAll with options applied to its
+// requests
+func (c *Connection) ObjectsAllWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]Object, error) {
 	objects := make([]Object, 0)
 	err := c.ObjectsWalk(container, opts, func(opts *ObjectsOpts) (interface{}, error) {
-		newObjects, err := c.Objects(container, opts)
+		newObjects, err := c.ObjectsWithOptions(container, opts, options...)
 		if err == nil {
 			objects = append(objects, newObjects...)
 		}
@@ -1198,9 +1865,15 @@ func (c *Connection) ObjectsAll(container string, opts *ObjectsOpts) ([]Object,
 //
 // It has a default Limit parameter but you may pass in your own
 func (c *Connection) ObjectNamesAll(container string, opts *ObjectsOpts) ([]string, error) {
+	return c.ObjectNamesAllWithOptions(container, opts)
+}
+
+// ObjectNamesAllWithOptions is ObjectNamesAll with options applied to
+// its requests
+func (c *Connection) ObjectNamesAllWithOptions(container string, opts *ObjectsOpts, options ...RequestOption) ([]string, error) {
 	objects := make([]string, 0)
 	err := c.ObjectsWalk(container, opts, func(opts *ObjectsOpts) (interface{}, error) {
-		newObjects, err := c.ObjectNames(container, opts)
+		newObjects, err := c.ObjectNamesWithOptions(container, opts, options...)
 		if err == nil {
 			objects = append(objects, newObjects...)
 		}
@@ -1209,11 +1882,51 @@ func (c *Connection) ObjectNamesAll(container string, opts *ObjectsOpts) ([]stri
 	return objects, err
 }
 
+// ObjectsEach calls fn for each object in the container as returned
+// by Objects, fetching them a page at a time so any number of objects
+// can be processed in constant memory.
+//
+// The JSON listing is decoded as it is read so fn sees the first
+// object of a page before the rest of the page has been received.
+//
+// If fn returns an error the walk stops and the error is returned,
+// unless it is StopWalk in which case nil is returned.
+//
+// It has a default Limit parameter but you may pass in your own
+func (c *Connection) ObjectsEach(container string, opts *ObjectsOpts, fn func(*Object) error, options ...RequestOption) error {
+	return c.ObjectsWalk(container, opts, func(opts *ObjectsOpts) (interface{}, error) {
+		page, err := c.objectsStream(container, opts, fn, options...)
+		if err != nil {
+			return nil, err
+		}
+		return page, nil
+	})
+}
+
+// ObjectNamesEach is like ObjectsEach but calls fn with each object
+// name as returned by ObjectNames.
+func (c *Connection) ObjectNamesEach(container string, opts *ObjectsOpts, fn func(string) error, options ...RequestOption) error {
+	return c.ObjectsWalk(container, opts, func(opts *ObjectsOpts) (interface{}, error) {
+		names, err := c.ObjectNamesWithOptions(container, opts, options...)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			err = fn(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return names, nil
+	})
+}
+
 // Account contains information about this account.
 type Account struct {
-	BytesUsed  int64 // total number of bytes used
-	Containers int64 // total number of containers
-	Objects    int64 // total number of objects
+	BytesUsed  int64            // total number of bytes used
+	Containers int64            // total number of containers
+	Objects    int64            // total number of objects
+	Settings   *AccountSettings // settings of the account
 }
 
 // getInt64FromHeader is a helper function to decode int64 from header.
@@ -1221,19 +1934,24 @@ func getInt64FromHeader(resp *http.Response, header string) (result int64, err e
 	value := resp.Header.Get(header)
 	result, err = strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		err = newErrorf(0, "Bad Header '%s': '%s': %s", header, value, err)
+		err = wrapErrorf(err, 0, "Bad Header '%s': '%s': %s", header, value, err)
 	}
 	return
 }
 
 // Account returns info about the account in an Account struct.
 func (c *Connection) Account() (info Account, headers Headers, err error) {
+	return c.AccountWithOptions()
+}
+
+// AccountWithOptions is Account with options applied to its requests
+func (c *Connection) AccountWithOptions(options ...RequestOption) (info Account, headers Headers, err error) {
 	var resp *http.Response
 	resp, headers, err = c.storage(RequestOpts{
 		Operation:  "HEAD",
 		ErrorMap:   ContainerErrorMap,
 		NoResponse: true,
-	})
+	}, options...)
 	if err != nil {
 		return
 	}
@@ -1254,6 +1972,7 @@ func (c *Connection) Account() (info Account, headers Headers, err error) {
 	if info.Objects, err = getInt64FromHeader(resp, "X-Account-Object-Count"); err != nil {
 		return
 	}
+	info.Settings = headers.AccountSettings()
 	return
 }
 
@@ -1261,15 +1980,22 @@ func (c *Connection) Account() (info Account, headers Headers, err error) {
 //
 // Add or update keys by mentioning them in the Headers.
 //
-// Remove keys by setting them to an empty string.
+// Remove keys by setting them to an empty string or with the
+// X-Remove-Account-Meta- headers from AccountRemoveHeaders.
+//
+// If there is more metadata than the server allows in one request it
+// is split into several, removing keys before adding them.  A
+// *MetadataLimitError is returned if an item is too big on its own,
+// and a *MetadataPartialError if a request fails after some of the
+// others were made.
 func (c *Connection) AccountUpdate(h Headers) error {
-	_, _, err := c.storage(RequestOpts{
-		Operation:  "POST",
-		ErrorMap:   ContainerErrorMap,
-		NoResponse: true,
-		Headers:    h,
-	})
-	return err
+	return c.AccountUpdateWithOptions(h)
+}
+
+// AccountUpdateWithOptions is AccountUpdate with options applied to its
+// requests
+func (c *Connection) AccountUpdateWithOptions(h Headers, options ...RequestOption) error {
+	return c.postMetadata("", "X-Account-Meta-", h, options...)
 }
 
 // ContainerCreate creates a container.
@@ -1278,13 +2004,22 @@ func (c *Connection) AccountUpdate(h Headers) error {
 //
 // No error is returned if it already exists but the metadata if any will be updated.
 func (c *Connection) ContainerCreate(container string, h Headers) error {
+	return c.ContainerCreateWithOptions(container, h)
+}
+
+// ContainerCreateWithOptions is ContainerCreate with options applied to
+// its requests
+func (c *Connection) ContainerCreateWithOptions(container string, h Headers, options ...RequestOption) error {
+	if err := requireContainerName(container); err != nil {
+		return err
+	}
 	_, _, err := c.storage(RequestOpts{
 		Container:  container,
 		Operation:  "PUT",
 		ErrorMap:   ContainerErrorMap,
 		NoResponse: true,
 		Headers:    h,
-	})
+	}, options...)
 	return err
 }
 
@@ -1292,25 +2027,46 @@ func (c *Connection) ContainerCreate(container string, h Headers) error {
 //
 // May return ContainerDoesNotExist or ContainerNotEmpty
 func (c *Connection) ContainerDelete(container string) error {
+	return c.ContainerDeleteWithOptions(container)
+}
+
+// ContainerDeleteWithOptions is ContainerDelete with options applied to
+// its requests
+func (c *Connection) ContainerDeleteWithOptions(container string, options ...RequestOption) error {
+	if err := requireContainerName(container); err != nil {
+		return err
+	}
 	_, _, err := c.storage(RequestOpts{
 		Container:  container,
 		Operation:  "DELETE",
 		ErrorMap:   ContainerErrorMap,
 		NoResponse: true,
-	})
+	}, options...)
 	return err
 }
 
 // Container returns info about a single container including any
 // metadata in the headers.
+//
+// The settings of the container are parsed from the headers into
+// info.Settings.
 func (c *Connection) Container(container string) (info Container, headers Headers, err error) {
+	return c.ContainerWithOptions(container)
+}
+
+// ContainerWithOptions is Container with options applied to its
+// requests
+func (c *Connection) ContainerWithOptions(container string, options ...RequestOption) (info Container, headers Headers, err error) {
+	if err = requireContainerName(container); err != nil {
+		return
+	}
 	var resp *http.Response
 	resp, headers, err = c.storage(RequestOpts{
 		Container:  container,
 		Operation:  "HEAD",
 		ErrorMap:   ContainerErrorMap,
 		NoResponse: true,
-	})
+	}, options...)
 	if err != nil {
 		return
 	}
@@ -1322,6 +2078,7 @@ func (c *Connection) Container(container string) (info Container, headers Header
 	if info.Count, err = getInt64FromHeader(resp, "X-Container-Object-Count"); err != nil {
 		return
 	}
+	info.Settings = headers.ContainerSettings()
 	return
 }
 
@@ -1329,32 +2086,50 @@ func (c *Connection) Container(container string) (info Container, headers Header
 //
 // Add or update keys by mentioning them in the Metadata.
 //
-// Remove keys by setting them to an empty string.
+// Remove keys by setting them to an empty string or with the
+// X-Remove-Container-Meta- headers from ContainerRemoveHeaders.
 //
 // Container metadata can only be read with Container() not with Containers().
+//
+// If there is more metadata than the server allows in one request it
+// is split into several, removing keys before adding them.  A
+// *MetadataLimitError is returned if an item is too big on its own,
+// and a *MetadataPartialError if a request fails after some of the
+// others were made.
 func (c *Connection) ContainerUpdate(container string, h Headers) error {
-	_, _, err := c.storage(RequestOpts{
-		Container:  container,
-		Operation:  "POST",
-		ErrorMap:   ContainerErrorMap,
-		NoResponse: true,
-		Headers:    h,
-	})
-	return err
+	return c.ContainerUpdateWithOptions(container, h)
+}
+
+// ContainerUpdateWithOptions is ContainerUpdate with options applied to
+// its requests
+func (c *Connection) ContainerUpdateWithOptions(container string, h Headers, options ...RequestOption) error {
+	if err := requireContainerName(container); err != nil {
+		return err
+	}
+	return c.postMetadata(container, "X-Container-Meta-", h, options...)
 }
 
 // ------------------------------------------------------------
 
 // ObjectCreateFile represents a swift object open for writing
 type ObjectCreateFile struct {
-	checkHash  bool           // whether we are checking the hash
-	pipeReader *io.PipeReader // pipe for the caller to use
-	pipeWriter *io.PipeWriter
-	hash       hash.Hash      // hash being build up as we go along
-	done       chan struct{}  // signals when the upload has finished
-	resp       *http.Response // valid when done has signalled
-	err        error          // ditto
-	headers    Headers        // ditto
+	connection  *Connection     // stored copy of Connection used in Create
+	container   string          // stored copy of container used in Create
+	objectName  string          // stored copy of objectName used in Create
+	putHeaders  Headers         // headers the object was created with
+	options     []RequestOption // options the object was created with
+	checkHash   bool            // whether we are checking the hash
+	storeSHA256 bool            // whether the SHA-256 needs storing after the upload
+	pipeReader  *io.PipeReader  // pipe for the caller to use
+	pipeWriter  *io.PipeWriter
+	hash        *checksummer   // hashes being build up as we go along
+	gzip        *gzipUpload    // set if compressing the upload
+	encrypt     *encryptUpload // set if encrypting the upload
+	done        chan struct{}  // signals when the upload has finished
+	resp        *http.Response // valid when done has signalled
+	err         error          // ditto
+	headers     Headers        // ditto
+	closed      func()         // if set called when the file has been closed
 }
 
 // Write bytes to the object - see io.Writer
@@ -1366,7 +2141,7 @@ func (file *ObjectCreateFile) Write(p []byte) (n int, err error) {
 		}
 		return 0, newError(500, "Write on closed file")
 	}
-	if err == nil && file.checkHash {
+	if err == nil && file.hash != nil && file.gzip == nil && file.encrypt == nil {
 		_, _ = file.hash.Write(p)
 	}
 	return
@@ -1376,6 +2151,9 @@ func (file *ObjectCreateFile) Write(p []byte) (n int, err error) {
 func (file *ObjectCreateFile) CloseWithError(err error) error {
 	_ = file.pipeWriter.CloseWithError(err)
 	<-file.done
+	if file.closed != nil {
+		file.closed()
+	}
 	return nil
 }
 
@@ -1384,6 +2162,15 @@ func (file *ObjectCreateFile) CloseWithError(err error) error {
 // Also returns any other errors from the server (eg container not
 // found) so it is very important to check the errors on this method.
 func (file *ObjectCreateFile) Close() error {
+	err := file.close()
+	if file.closed != nil {
+		file.closed()
+	}
+	return err
+}
+
+// close does the work for Close
+func (file *ObjectCreateFile) close() error {
 	// Close the body
 	err := file.pipeWriter.Close()
 	if err != nil {
@@ -1397,13 +2184,28 @@ func (file *ObjectCreateFile) Close() error {
 	if file.err != nil {
 		return file.err
 	}
-	if file.checkHash {
-		receivedMd5 := strings.ToLower(file.headers["Etag"])
-		calculatedMd5 := fmt.Sprintf("%x", file.hash.Sum(nil))
-		if receivedMd5 != calculatedMd5 {
-			return ObjectCorrupted
+	meta := Headers{}
+	if file.hash != nil {
+		if err = file.hash.check(file.headers); err != nil {
+			return err
+		}
+		if file.storeSHA256 {
+			meta[SHA256Header] = file.hash.sha256Sum()
 		}
 	}
+	if file.gzip != nil {
+		if err = file.gzip.metadata(meta); err != nil {
+			return err
+		}
+	}
+	if file.encrypt != nil {
+		if err = file.encrypt.check(); err != nil {
+			return err
+		}
+	}
+	if len(meta) > 0 {
+		return file.connection.objectSetMetadata(file.container, file.objectName, file.putHeaders, meta, file.options...)
+	}
 	return nil
 }
 
@@ -1423,17 +2225,60 @@ func (file *ObjectCreateFile) Headers() (Headers, error) {
 // Check it satisfies the interface
 var _ io.WriteCloser = &ObjectCreateFile{}
 
+// contentLengthFromHeaders returns the Content-Length set in h or -1
+// if it isn't set or can't be parsed.
+func contentLengthFromHeaders(h Headers) int64 {
+	if v, ok := h["Content-Length"]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// detectContentType guesses the content type of an object from the
+// extension of objectName, or failing that by sniffing the start of
+// contents if it is an io.ReadSeeker.  contents may be nil.
+func detectContentType(objectName string, contents io.Reader) string {
+	if contentType := mime.TypeByExtension(path.Ext(objectName)); contentType != "" {
+		return contentType
+	}
+	if rs, ok := contents.(io.ReadSeeker); ok {
+		if contentType := sniffContentType(rs); contentType != "" {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// sniffContentType uses http.DetectContentType on the first 512
+// bytes of rs, restoring its position afterwards.  It returns "" if
+// rs is empty or can't be read.
+func sniffContentType(rs io.ReadSeeker) string {
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return ""
+	}
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(rs, buf)
+	if _, seekErr := rs.Seek(pos, io.SeekStart); seekErr != nil {
+		return ""
+	}
+	if n == 0 || (err != nil && err != io.ErrUnexpectedEOF) {
+		return ""
+	}
+	return http.DetectContentType(buf[:n])
+}
+
 // objectPutHeaders create a set of headers for a PUT
 //
-// It guesses the contentType from the objectName if it isn't set
+// It guesses the contentType from the objectName and contents, which
+// may be nil, if it isn't set
 //
 // checkHash may be changed
-func objectPutHeaders(objectName string, checkHash *bool, Hash string, contentType string, h Headers) Headers {
+func objectPutHeaders(objectName string, contents io.Reader, checkHash *bool, Hash string, contentType string, h Headers) Headers {
 	if contentType == "" {
-		contentType = mime.TypeByExtension(path.Ext(objectName))
-		if contentType == "" {
-			contentType = "application/octet-stream"
-		}
+		contentType = detectContentType(objectName, contents)
 	}
 	// Meta stuff
 	extraHeaders := map[string]string{
@@ -1459,6 +2304,9 @@ func objectPutHeaders(objectName string, checkHash *bool, Hash string, contentTy
 // from the server.  If it is wrong then it will return
 // ObjectCorrupted on Close()
 //
+// If the Connection's Checksum is set to use SHA-256 then that will
+// be calculated too and stored in the object's metadata on Close()
+//
 // If you know the MD5 hash of the object ahead of time then set the
 // Hash parameter and it will be sent to the server (as an Etag
 // header) and the server will check the MD5 itself after the upload,
@@ -1469,28 +2317,96 @@ func objectPutHeaders(objectName string, checkHash *bool, Hash string, contentTy
 //
 // If contentType is set it will be used, otherwise one will be
 // guessed from objectName using mime.TypeByExtension
+//
+// ObjectCreateWithOpts takes the arguments in a struct instead.
 func (c *Connection) ObjectCreate(container string, objectName string, checkHash bool, Hash string, contentType string, h Headers) (file *ObjectCreateFile, err error) {
-	extraHeaders := objectPutHeaders(objectName, &checkHash, Hash, contentType, h)
+	return c.ObjectCreateWithOpts(container, objectName, &ObjectPutOpts{
+		CheckHash:   checkHash,
+		Hash:        Hash,
+		ContentType: contentType,
+		Headers:     h,
+	})
+}
+
+// objectCreate does the work for ObjectCreateWithOpts
+func (c *Connection) objectCreate(container string, objectName string, checkHash bool, Hash string, contentType string, h Headers, options ...RequestOption) (file *ObjectCreateFile, err error) {
+	if err := requireObjectName(container, objectName); err != nil {
+		return nil, err
+	}
+	if isManifestPut(h, nil, options) {
+		checkHash = false
+	}
+	gzipped := isGzipRequest(options)
+	enc := encryptionRequest(options)
+	if gzipped && enc != nil {
+		return nil, errEncryptionGzip
+	}
+	storeSHA256 := checkHash && c.Checksum != ChecksumMD5
+	extraHeaders := objectPutHeaders(objectName, nil, &checkHash, Hash, contentType, h)
+	requestHeaders := extraHeaders
+	var gz *gzipUpload
+	if gzipped {
+		gz = newGzipUpload(extraHeaders, Hash)
+		checkHash = checkHash || Hash != ""
+	}
+	var encrypt *encryptUpload
+	if enc != nil {
+		if encrypt, err = enc.newUpload(extraHeaders, Hash); err != nil {
+			return nil, err
+		}
+		checkHash = checkHash || Hash != ""
+		if requestHeaders, err = encrypt.encryptMetadata(extraHeaders); err != nil {
+			return nil, err
+		}
+	}
 	pipeReader, pipeWriter := io.Pipe()
 	file = &ObjectCreateFile{
-		hash:       md5.New(),
-		checkHash:  checkHash,
-		pipeReader: pipeReader,
-		pipeWriter: pipeWriter,
-		done:       make(chan struct{}),
+		connection:  c,
+		container:   container,
+		objectName:  objectName,
+		putHeaders:  extraHeaders,
+		options:     options,
+		checkHash:   checkHash,
+		storeSHA256: storeSHA256,
+		pipeReader:  pipeReader,
+		pipeWriter:  pipeWriter,
+		gzip:        gz,
+		encrypt:     encrypt,
+		done:        make(chan struct{}),
+	}
+	if checkHash || storeSHA256 {
+		file.hash = newChecksummer(c.Checksum)
+		if !checkHash {
+			file.hash.md5 = nil // the server will check it
+		}
 	}
 	// Run the PUT in the background piping it data
 	go func() {
+		var body io.Reader = pipeReader
+		if gz != nil {
+			compressed := gz.compress(pipeReader)
+			defer func() { _ = compressed.Close() }()
+			body = compressed
+			if file.hash != nil {
+				body = io.TeeReader(body, file.hash)
+			}
+		}
+		if encrypt != nil {
+			body = encrypt.encrypt(pipeReader)
+			if file.hash != nil {
+				body = io.TeeReader(body, file.hash)
+			}
+		}
 		opts := RequestOpts{
 			Container:  container,
 			ObjectName: objectName,
 			Operation:  "PUT",
-			Headers:    extraHeaders,
-			Body:       pipeReader,
+			Headers:    requestHeaders,
+			Body:       newProgressReader(body, c.Progress, container, objectName, contentLengthFromHeaders(extraHeaders)),
 			NoResponse: true,
-			ErrorMap:   objectErrorMap,
+			ErrorMap:   objectPutErrorMap(extraHeaders),
 		}
-		file.resp, file.headers, file.err = c.storage(opts)
+		file.resp, file.headers, file.err = c.storage(opts, options...)
 		// Signal finished
 		pipeReader.Close()
 		close(file.done)
@@ -1509,38 +2425,105 @@ func (c *Connection) ObjectSymlinkCreate(container string, symlink string, targe
 	if targetEtag != "" {
 		symHeaders["X-Symlink-Target-Etag"] = targetEtag
 	}
-	symHeaders["X-Symlink-Target"] = fmt.Sprintf("%s/%s", targetContainer, targetObject)
+	symHeaders["X-Symlink-Target"] = fmt.Sprintf("%s/%s", targetContainer, c.NormalizeName.normalize(targetObject))
 	_, err = c.ObjectPut(container, symlink, contents, true, EMPTY_MD5, "application/symlink", symHeaders)
 	return
 }
 
-func (c *Connection) objectPut(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers, parameters url.Values) (headers Headers, err error) {
-	extraHeaders := objectPutHeaders(objectName, &checkHash, Hash, contentType, h)
-	hash := md5.New()
+func (c *Connection) objectPut(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers, parameters url.Values, options ...RequestOption) (headers Headers, err error) {
+	if err := requireObjectName(container, objectName); err != nil {
+		return nil, err
+	}
+	if isManifestPut(h, parameters, options) {
+		checkHash = false
+	}
+	gzipped := isGzipRequest(options)
+	enc := encryptionRequest(options)
+	if gzipped && enc != nil {
+		return nil, errEncryptionGzip
+	}
+	storeSHA256 := checkHash && c.Checksum != ChecksumMD5
+	extraHeaders := objectPutHeaders(objectName, contents, &checkHash, Hash, contentType, h)
+	requestHeaders := extraHeaders
+	var hash *checksummer
 	var body io.Reader = contents
-	if checkHash {
-		body = io.TeeReader(contents, hash)
+	var gz *gzipUpload
+	if gzipped {
+		// The server checks the Etag against the compressed
+		// data so Hash is checked here instead
+		gz = newGzipUpload(extraHeaders, Hash)
+		checkHash = checkHash || Hash != ""
+		compressed := gz.compress(contents)
+		defer func() { _ = compressed.Close() }()
+		body = compressed
+	}
+	var encrypt *encryptUpload
+	if enc != nil {
+		// Likewise the Etag is of the encrypted data
+		if encrypt, err = enc.newUpload(extraHeaders, Hash); err != nil {
+			return
+		}
+		checkHash = checkHash || Hash != ""
+		if requestHeaders, err = encrypt.encryptMetadata(extraHeaders); err != nil {
+			return
+		}
+		body = encrypt.encrypt(contents)
 	}
+	if checkHash || storeSHA256 {
+		hash = newChecksummer(c.Checksum)
+		if !checkHash {
+			hash.md5 = nil // the server will check it
+		}
+		if storeSHA256 && gz == nil && encrypt == nil {
+			// Send the SHA-256 with the object if it can be found in advance
+			var sum string
+			var ok bool
+			sum, ok, err = seekableSHA256(contents)
+			if err != nil {
+				return
+			}
+			if ok {
+				extraHeaders[SHA256Header] = sum
+				storeSHA256 = false
+			}
+		}
+		body = io.TeeReader(body, hash)
+	}
+	body = newProgressReader(body, c.Progress, container, objectName, contentLengthFromHeaders(extraHeaders))
 	_, headers, err = c.storage(RequestOpts{
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "PUT",
-		Headers:    extraHeaders,
+		Headers:    requestHeaders,
 		Body:       body,
 		NoResponse: true,
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   objectPutErrorMap(extraHeaders),
 		Parameters: parameters,
-	})
+	}, options...)
 	if err != nil {
 		return
 	}
-	if checkHash {
-		receivedMd5 := strings.ToLower(headers["Etag"])
-		calculatedMd5 := fmt.Sprintf("%x", hash.Sum(nil))
-		if receivedMd5 != calculatedMd5 {
-			err = ObjectCorrupted
+	meta := Headers{}
+	if hash != nil {
+		if err = hash.check(headers); err != nil {
 			return
 		}
+		if storeSHA256 {
+			meta[SHA256Header] = hash.sha256Sum()
+		}
+	}
+	if gz != nil {
+		if err = gz.metadata(meta); err != nil {
+			return
+		}
+	}
+	if encrypt != nil {
+		if err = encrypt.check(); err != nil {
+			return
+		}
+	}
+	if len(meta) > 0 {
+		err = c.objectSetMetadata(container, objectName, extraHeaders, meta, options...)
 	}
 	return
 }
@@ -1556,6 +2539,11 @@ func (c *Connection) objectPut(container string, objectName string, contents io.
 // from the server.  If it is wrong then it will return
 // ObjectCorrupted.
 //
+// If the Connection's Checksum is set to use SHA-256 then that will
+// be calculated too and stored in the object's metadata.  If contents
+// is an io.ReadSeeker this is done before the upload, otherwise the
+// metadata is updated afterwards.
+//
 // If you know the MD5 hash of the object ahead of time then set the
 // Hash parameter and it will be sent to the server (as an Etag
 // header) and the server will check the MD5 itself after the upload,
@@ -1565,18 +2553,59 @@ func (c *Connection) objectPut(container string, objectName string, contents io.
 // checkHash to false and Hash to "".
 //
 // If contentType is set it will be used, otherwise one will be
-// guessed from objectName using mime.TypeByExtension
+// guessed from objectName using mime.TypeByExtension, or if that
+// fails and contents is an io.ReadSeeker, by sniffing its first 512
+// bytes with http.DetectContentType.
+//
+// To make the object expire use h.SetDeleteAt or h.SetDeleteAfter so
+// that it is created with its expiry time in the same request.
+//
+// ObjectPutWithOpts takes the arguments in a struct instead.
 func (c *Connection) ObjectPut(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers) (headers Headers, err error) {
-	return c.objectPut(container, objectName, contents, checkHash, Hash, contentType, h, nil)
+	return c.ObjectPutWithOpts(container, objectName, contents, &ObjectPutOpts{
+		CheckHash:   checkHash,
+		Hash:        Hash,
+		ContentType: contentType,
+		Headers:     h,
+	})
+}
+
+// ObjectPutIfNotExists creates the path in the container from
+// contents only if it doesn't already exist.
+//
+// This works like ObjectPut but sends an "If-None-Match: *" header so
+// that if several writers race to create the same object only the
+// first succeeds and the others get ObjectExists.
+//
+// You can get the same effect with ObjectPut or ObjectCreate by
+// setting "If-None-Match" to "*" in the headers.
+func (c *Connection) ObjectPutIfNotExists(container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	return c.ObjectPutWithOpts(container, objectName, contents, &ObjectPutOpts{
+		CheckHash:   checkHash,
+		Hash:        Hash,
+		ContentType: contentType,
+		IfNotExists: true,
+		Headers:     h,
+	}, options...)
 }
 
 // ObjectPutBytes creates an object from a []byte in a container.
 //
 // This is a simplified interface which checks the MD5.
 func (c *Connection) ObjectPutBytes(container string, objectName string, contents []byte, contentType string) (err error) {
+	return c.ObjectPutBytesWithOptions(container, objectName, contents, contentType)
+}
+
+// ObjectPutBytesWithOptions is ObjectPutBytes with options applied to
+// its requests
+func (c *Connection) ObjectPutBytesWithOptions(container string, objectName string, contents []byte, contentType string, options ...RequestOption) (err error) {
 	buf := bytes.NewBuffer(contents)
 	h := Headers{"Content-Length": strconv.Itoa(len(contents))}
-	_, err = c.ObjectPut(container, objectName, buf, true, "", contentType, h)
+	_, err = c.ObjectPutWithOpts(container, objectName, buf, &ObjectPutOpts{
+		CheckHash:   true,
+		ContentType: contentType,
+		Headers:     h,
+	}, options...)
 	return
 }
 
@@ -1584,29 +2613,45 @@ func (c *Connection) ObjectPutBytes(container string, objectName string, content
 //
 // This is a simplified interface which checks the MD5
 func (c *Connection) ObjectPutString(container string, objectName string, contents string, contentType string) (err error) {
+	return c.ObjectPutStringWithOptions(container, objectName, contents, contentType)
+}
+
+// ObjectPutStringWithOptions is ObjectPutString with options applied to
+// its requests
+func (c *Connection) ObjectPutStringWithOptions(container string, objectName string, contents string, contentType string, options ...RequestOption) (err error) {
 	buf := strings.NewReader(contents)
 	h := Headers{"Content-Length": strconv.Itoa(len(contents))}
-	_, err = c.ObjectPut(container, objectName, buf, true, "", contentType, h)
+	_, err = c.ObjectPutWithOpts(container, objectName, buf, &ObjectPutOpts{
+		CheckHash:   true,
+		ContentType: contentType,
+		Headers:     h,
+	}, options...)
 	return
 }
 
 // ObjectOpenFile represents a swift object open for reading
 type ObjectOpenFile struct {
-	connection *Connection    // stored copy of Connection used in Open
-	container  string         // stored copy of container used in Open
-	objectName string         // stored copy of objectName used in Open
-	headers    Headers        // stored copy of headers used in Open
-	resp       *http.Response // http connection
-	body       io.Reader      // read data from this
-	checkHash  bool           // true if checking MD5
-	hash       hash.Hash      // currently accumulating MD5
-	bytes      int64          // number of bytes read on this connection
-	eof        bool           // whether we have read end of file
-	pos        int64          // current position when reading
-	lengthOk   bool           // whether length is valid
-	length     int64          // length of the object if read
-	seeked     bool           // whether we have seeked this file or not
-	overSeeked bool           // set if we have seeked to the end or beyond
+	connection *Connection      // stored copy of Connection used in Open
+	container  string           // stored copy of container used in Open
+	objectName string           // stored copy of objectName used in Open
+	headers    Headers          // stored copy of headers used in Open
+	options    []RequestOption  // stored copy of options used in Open
+	resp       *http.Response   // http connection
+	body       io.Reader        // read data from this
+	checkHash  bool             // true if checking MD5
+	hash       *checksummer     // currently accumulating hashes
+	bytes      int64            // number of bytes read on this connection
+	eof        bool             // whether we have read end of file
+	pos        int64            // current position when reading
+	lengthOk   bool             // whether length is valid
+	length     int64            // length of the object if read
+	seeked     bool             // whether we have seeked this file or not
+	overSeeked bool             // set if we have seeked to the end or beyond
+	segments   *segmentVerifier // checks the segments of a large object if set
+	gzipped    bool             // set if decompressing the object
+	gzipMD5    hash.Hash        // MD5 of the decompressed data if checking it
+	gzipSum    string           // expected MD5 of the decompressed data
+	decrypted  bool             // set if decrypting the object
 }
 
 // Read bytes from the object - see io.Reader
@@ -1631,12 +2676,25 @@ func (file *ObjectOpenFile) Read(p []byte) (n int, err error) {
 // Seek uses HTTP Range headers which, if the file pointer is moved,
 // will involve reopening the HTTP connection.
 //
-// Note that you can't seek to the end of a file or beyond; HTTP Range
-// requests don't support the file pointer being outside the data,
-// unlike os.File
+// Seeking to the end of the file or beyond makes Read return io.EOF,
+// like os.File.  If the server says the Range can't be satisfied
+// because the new position is past the end of the object then that
+// is treated the same way rather than returning RangeNotSatisfiable.
 //
 // Seek(0, 1) will return the current file pointer.
 func (file *ObjectOpenFile) Seek(offset int64, whence int) (newPos int64, err error) {
+	if file.gzipped {
+		if whence == 1 && offset == 0 {
+			return file.pos, nil
+		}
+		return file.pos, newError(0, "Can't seek in a decompressed object")
+	}
+	if file.decrypted {
+		if whence == 1 && offset == 0 {
+			return file.pos, nil
+		}
+		return file.pos, newError(0, "Can't seek in a decrypted object")
+	}
 	file.overSeeked = false
 	switch whence {
 	case 0: // relative to start
@@ -1674,7 +2732,13 @@ func (file *ObjectOpenFile) Seek(offset int64, whence int) (newPos int64, err er
 	} else {
 		delete(file.headers, "Range")
 	}
-	newFile, _, err := file.connection.ObjectOpen(file.container, file.objectName, false, file.headers)
+	newFile, _, err := file.connection.ObjectOpenWithOpts(file.container, file.objectName, &ObjectGetOpts{Headers: file.headers}, file.options...)
+	if err == RangeNotSatisfiable {
+		// Seeked past the end of the object
+		file.overSeeked = true
+		file.pos = newPos
+		return newPos, nil
+	}
 	if err != nil {
 		return
 	}
@@ -1682,6 +2746,7 @@ func (file *ObjectOpenFile) Seek(offset int64, whence int) (newPos int64, err er
 	file.resp = newFile.resp
 	file.body = newFile.body
 	file.checkHash = false
+	file.segments = nil
 	file.pos = newPos
 	return
 }
@@ -1690,7 +2755,7 @@ func (file *ObjectOpenFile) Seek(offset int64, whence int) (newPos int64, err er
 // from the server.
 func (file *ObjectOpenFile) Length() (int64, error) {
 	if !file.lengthOk {
-		info, _, err := file.connection.Object(file.container, file.objectName)
+		info, _, err := file.connection.ObjectWithOptions(file.container, file.objectName, file.options...)
 		file.length = info.Bytes
 		file.lengthOk = (err == nil)
 		return file.length, err
@@ -1701,8 +2766,9 @@ func (file *ObjectOpenFile) Length() (int64, error) {
 // Close the object and checks the length and md5sum if it was
 // required and all the object was read
 func (file *ObjectOpenFile) Close() (err error) {
-	// Close the body at the end
-	defer checkClose(file.resp.Body, &err)
+	// Close the body at the end, draining any small remainder so
+	// the connection can be reused
+	defer drainAndClose(file.resp.Body, &err)
 
 	// If not end of file or seeked then can't check anything
 	if !file.eof || file.seeked {
@@ -1711,10 +2777,16 @@ func (file *ObjectOpenFile) Close() (err error) {
 
 	// Check the MD5 sum if requested
 	if file.checkHash {
-		receivedMd5 := strings.ToLower(file.resp.Header.Get("Etag"))
-		calculatedMd5 := fmt.Sprintf("%x", file.hash.Sum(nil))
-		if receivedMd5 != calculatedMd5 {
-			err = ObjectCorrupted
+		err = file.hash.check(readHeaders(file.resp))
+		if err != nil {
+			return
+		}
+	}
+
+	// Check the segments of a large object if requested
+	if file.segments != nil {
+		err = file.segments.check()
+		if err != nil {
 			return
 		}
 	}
@@ -1724,6 +2796,12 @@ func (file *ObjectOpenFile) Close() (err error) {
 		err = ObjectCorrupted
 		return
 	}
+
+	// Check the MD5 of decompressed data
+	if file.gzipMD5 != nil && !strings.EqualFold(hex.EncodeToString(file.gzipMD5.Sum(nil)), file.gzipSum) {
+		err = ObjectCorrupted
+		return
+	}
 	return
 }
 
@@ -1731,7 +2809,18 @@ func (file *ObjectOpenFile) Close() (err error) {
 var _ io.ReadCloser = &ObjectOpenFile{}
 var _ io.Seeker = &ObjectOpenFile{}
 
-func (c *Connection) objectOpenBase(container string, objectName string, checkHash bool, h Headers, parameters url.Values) (file *ObjectOpenFile, headers Headers, err error) {
+func (c *Connection) objectOpenBase(container string, objectName string, checkHash bool, h Headers, parameters url.Values, options ...RequestOption) (file *ObjectOpenFile, headers Headers, err error) {
+	if err := requireObjectName(container, objectName); err != nil {
+		return nil, nil, err
+	}
+	gzipped := isGzipRequest(options)
+	if gzipped {
+		h = gzipRequestHeaders(h)
+	}
+	enc := encryptionRequest(options)
+	if enc != nil && h["Range"] != "" {
+		return nil, nil, newError(0, "Can't read a Range of an encrypted object")
+	}
 	var resp *http.Response
 	opts := RequestOpts{
 		Container:  container,
@@ -1741,26 +2830,36 @@ func (c *Connection) objectOpenBase(container string, objectName string, checkHa
 		Headers:    h,
 		Parameters: parameters,
 	}
-	resp, headers, err = c.storage(opts)
+	resp, headers, err = c.storage(opts, options...)
 	if err != nil {
 		return
 	}
+	// If the transport decompressed the body then it doesn't match
+	// the MD5 or the length of the stored object
+	if resp.Uncompressed {
+		checkHash = false
+	}
 	// Can't check MD5 on an object with X-Object-Manifest or X-Static-Large-Object set
+	checkSegments := false
 	if checkHash && headers.IsLargeObject() {
-		// log.Printf("swift: turning off md5 checking on object with manifest %v", objectName)
+		if c.Debug != DebugOff {
+			c.logf("swift: turning off md5 checking on object with manifest %v", objectName)
+		}
 		checkHash = false
+		checkSegments = c.LargeObjectEtag == LargeObjectEtagSegments && parameters.Get("multipart-manifest") == "" && h["Range"] == ""
 	}
 	file = &ObjectOpenFile{
 		connection: c,
 		container:  container,
 		objectName: objectName,
 		headers:    h,
+		options:    options,
 		resp:       resp,
 		checkHash:  checkHash,
 		body:       resp.Body,
 	}
 	if checkHash {
-		file.hash = md5.New()
+		file.hash = newChecksummer(c.Checksum)
 		file.body = io.TeeReader(resp.Body, file.hash)
 	}
 	// Read Content-Length
@@ -1768,12 +2867,42 @@ func (c *Connection) objectOpenBase(container string, objectName string, checkHa
 		file.length, err = getInt64FromHeader(resp, "Content-Length")
 		file.lengthOk = (err == nil)
 	}
+	total := int64(-1)
+	if file.lengthOk {
+		total = file.length
+	}
+	if checkSegments {
+		file.segments, err = c.newSegmentVerifier(container, objectName, headers, total)
+		if err != nil {
+			drainAndClose(resp.Body, nil)
+			return nil, headers, err
+		}
+		if file.segments != nil {
+			file.segments.in = file.body
+			file.body = file.segments
+		}
+	}
+	file.body = newProgressReader(file.body, c.Progress, container, objectName, total)
+	if gzipped && headers.IsGzipped() {
+		err = file.decompress(headers)
+		if err != nil {
+			drainAndClose(resp.Body, nil)
+			return nil, headers, err
+		}
+	}
+	if enc != nil {
+		err = file.decrypt(enc, headers)
+		if err != nil {
+			drainAndClose(resp.Body, nil)
+			return nil, headers, err
+		}
+	}
 	return
 }
 
-func (c *Connection) objectOpen(container string, objectName string, checkHash bool, h Headers, parameters url.Values) (file *ObjectOpenFile, headers Headers, err error) {
+func (c *Connection) objectOpen(container string, objectName string, checkHash bool, h Headers, parameters url.Values, options ...RequestOption) (file *ObjectOpenFile, headers Headers, err error) {
 	err = withLORetry(0, func() (Headers, int64, error) {
-		file, headers, err = c.objectOpenBase(container, objectName, checkHash, h, parameters)
+		file, headers, err = c.objectOpenBase(container, objectName, checkHash, h, parameters, options...)
 		if err != nil {
 			return headers, 0, err
 		}
@@ -1796,17 +2925,26 @@ func (c *Connection) objectOpen(container string, objectName string, checkHash b
 // will also check the length returned. No checking will be done if
 // you don't read all the contents.
 //
+// If the Connection's Checksum is set to use SHA-256 and the object
+// has a SHA-256 stored in its metadata then that will be checked too.
+//
 // Note that objects with X-Object-Manifest or X-Static-Large-Object
-// set won't ever have their md5sum's checked as the md5sum reported
-// on the object is actually the md5sum of the md5sums of the
-// parts. This isn't very helpful to detect a corrupted download as
-// the size of the parts aren't known without doing more operations.
-// If you want to ensure integrity of an object with a manifest then
-// you will need to download everything in the manifest separately.
+// set won't have their md5sum's checked by default as the md5sum
+// reported on the object is actually the md5sum of the md5sums of
+// the parts. This isn't very helpful to detect a corrupted download
+// as the size of the parts aren't known without doing more
+// operations.  Set the Connection's LargeObjectEtag to
+// LargeObjectEtagSegments to read the segments from the manifest and
+// check the md5sum of each one as it is received.
 //
 // headers["Content-Type"] will give the content type if desired.
+//
+// ObjectOpenWithOpts takes the arguments in a struct instead.
 func (c *Connection) ObjectOpen(container string, objectName string, checkHash bool, h Headers) (file *ObjectOpenFile, headers Headers, err error) {
-	return c.objectOpen(container, objectName, checkHash, h, nil)
+	return c.ObjectOpenWithOpts(container, objectName, &ObjectGetOpts{
+		CheckHash: checkHash,
+		Headers:   h,
+	})
 }
 
 // ObjectGet gets the object into the io.Writer contents.
@@ -1818,22 +2956,27 @@ func (c *Connection) ObjectOpen(container string, objectName string, checkHash b
 // server.  If it is wrong then it will return ObjectCorrupted.
 //
 // headers["Content-Type"] will give the content type if desired.
+//
+// ObjectGetWithOpts takes the arguments in a struct instead.
 func (c *Connection) ObjectGet(container string, objectName string, contents io.Writer, checkHash bool, h Headers) (headers Headers, err error) {
-	file, headers, err := c.ObjectOpen(container, objectName, checkHash, h)
-	if err != nil {
-		return
-	}
-	defer checkClose(file, &err)
-	_, err = io.Copy(contents, file)
-	return
+	return c.ObjectGetWithOpts(container, objectName, contents, &ObjectGetOpts{
+		CheckHash: checkHash,
+		Headers:   h,
+	})
 }
 
 // ObjectGetBytes returns an object as a []byte.
 //
 // This is a simplified interface which checks the MD5
 func (c *Connection) ObjectGetBytes(container string, objectName string) (contents []byte, err error) {
+	return c.ObjectGetBytesWithOptions(container, objectName)
+}
+
+// ObjectGetBytesWithOptions is ObjectGetBytes with options applied to
+// its requests
+func (c *Connection) ObjectGetBytesWithOptions(container string, objectName string, options ...RequestOption) (contents []byte, err error) {
 	var buf bytes.Buffer
-	_, err = c.ObjectGet(container, objectName, &buf, true, nil)
+	_, err = c.ObjectGetWithOpts(container, objectName, &buf, &ObjectGetOpts{CheckHash: true}, options...)
 	contents = buf.Bytes()
 	return
 }
@@ -1842,8 +2985,14 @@ func (c *Connection) ObjectGetBytes(container string, objectName string) (conten
 //
 // This is a simplified interface which checks the MD5
 func (c *Connection) ObjectGetString(container string, objectName string) (contents string, err error) {
+	return c.ObjectGetStringWithOptions(container, objectName)
+}
+
+// ObjectGetStringWithOptions is ObjectGetString with options applied to
+// its requests
+func (c *Connection) ObjectGetStringWithOptions(container string, objectName string, options ...RequestOption) (contents string, err error) {
 	var buf bytes.Buffer
-	_, err = c.ObjectGet(container, objectName, &buf, true, nil)
+	_, err = c.ObjectGetWithOpts(container, objectName, &buf, &ObjectGetOpts{CheckHash: true}, options...)
 	contents = buf.String()
 	return
 }
@@ -1852,12 +3001,22 @@ func (c *Connection) ObjectGetString(container string, objectName string) (conte
 //
 // May return ObjectNotFound if the object isn't found
 func (c *Connection) ObjectDelete(container string, objectName string) error {
+	return c.ObjectDeleteWithOptions(container, objectName)
+}
+
+// ObjectDeleteWithOptions is ObjectDelete with options applied to its
+// requests
+func (c *Connection) ObjectDeleteWithOptions(container string, objectName string, options ...RequestOption) error {
+	if err := requireObjectName(container, objectName); err != nil {
+		return err
+	}
 	_, _, err := c.storage(RequestOpts{
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "DELETE",
 		ErrorMap:   objectErrorMap,
-	})
+		NoResponse: true,
+	}, options...)
 	return err
 }
 
@@ -1962,7 +3121,8 @@ func (c *Connection) doBulkDelete(objects []string, h Headers) (result BulkDelet
 // BulkDelete deletes multiple objectNames from container in one operation.
 //
 // Some servers may not accept bulk-delete requests since bulk-delete is
-// an optional feature of swift - these will return the Forbidden error.
+// an optional feature of swift - see BulkDeleteHeaders for what
+// happens then.
 //
 // See also:
 // * http://docs.openstack.org/trunk/openstack-object-storage/admin/content/object-storage-bulk-delete.html
@@ -1974,7 +3134,10 @@ func (c *Connection) BulkDelete(container string, objectNames []string) (result
 // BulkDeleteHeaders deletes multiple objectNames from container in one operation.
 //
 // Some servers may not accept bulk-delete requests since bulk-delete is
-// an optional feature of swift - these will return the Forbidden error.
+// an optional feature of swift.  If /info shows that the server
+// doesn't support it, or /info isn't available and the bulk-delete
+// request returns Forbidden, then the objects are deleted with one
+// DELETE request each instead.
 //
 // See also:
 // * http://docs.openstack.org/trunk/openstack-object-storage/admin/content/object-storage-bulk-delete.html
@@ -1984,11 +3147,56 @@ func (c *Connection) BulkDeleteHeaders(container string, objectNames []string, h
 		result.Errors = make(map[string]error)
 		return
 	}
+	if !c.Authenticated() {
+		if err = c.Authenticate(); err != nil {
+			return
+		}
+	}
+	info, infoErr := c.cachedQueryInfo()
+	if infoErr == nil && !info.SupportsBulkDelete() {
+		return c.individualDelete(container, objectNames, h)
+	}
 	fullPaths := make([]string, len(objectNames))
 	for i, name := range objectNames {
 		fullPaths[i] = fmt.Sprintf("/%s/%s", container, name)
 	}
-	return c.doBulkDelete(fullPaths, h)
+	result, err = c.doBulkDelete(fullPaths, h)
+	if err == Forbidden && infoErr != nil {
+		return c.individualDelete(container, objectNames, h)
+	}
+	return
+}
+
+// individualDelete deletes objectNames from container with one
+// DELETE request each, returning the results in the same form as
+// doBulkDelete.
+//
+// Objects which can't be deleted are recorded in the Errors of the
+// result and the first such error is returned.
+func (c *Connection) individualDelete(container string, objectNames []string, h Headers) (result BulkDeleteResult, err error) {
+	result.Errors = make(map[string]error)
+	for _, name := range objectNames {
+		_, _, deleteErr := c.storage(RequestOpts{
+			Container:  container,
+			ObjectName: name,
+			Operation:  "DELETE",
+			ErrorMap:   objectErrorMap,
+			NoResponse: true,
+			Headers:    h,
+		})
+		switch deleteErr {
+		case nil:
+			result.NumberDeleted++
+		case ObjectNotFound:
+			result.NumberNotFound++
+		default:
+			result.Errors[fmt.Sprintf("/%s/%s", container, name)] = deleteErr
+			if err == nil {
+				err = deleteErr
+			}
+		}
+	}
+	return
 }
 
 // BulkUploadResult stores results of BulkUpload().
@@ -2009,12 +3217,17 @@ type BulkUploadResult struct {
 // within a container.  If uploadPath is empty, new containers may be
 // automatically created.
 //
-// Files are read from dataStream.  The format of the stream is specified
-// by the format parameter.  Available formats are:
+// Files are read from dataStream.  The stream is sent as it is read so
+// it doesn't need to be held in memory and can be made on the fly, eg
+// with an io.Pipe.  The format of the stream is specified by the
+// format parameter.  Available formats are:
 // * UploadTar       - Plain tar stream.
 // * UploadTarGzip   - Gzip compressed tar stream.
 // * UploadTarBzip2  - Bzip2 compressed tar stream.
 //
+// The result has the number of objects created and an error for each
+// file which couldn't be created.
+//
 // Some servers may not accept bulk-upload requests since bulk-upload is
 // an optional feature of swift - these will return the Forbidden error.
 //
@@ -2026,10 +3239,15 @@ func (c *Connection) BulkUpload(uploadPath string, dataStream io.Reader, format
 	for key, value := range h {
 		extraHeaders[key] = value
 	}
-	// The following code abuses Container parameter intentionally.
-	// The best fix might be to rename Container to UploadPath.
+	// The upload path may be "", a container or a container and a
+	// pseudo directory within it
+	container, objectName := uploadPath, ""
+	if i := strings.IndexRune(uploadPath, '/'); i >= 0 {
+		container, objectName = uploadPath[:i], uploadPath[i+1:]
+	}
 	resp, headers, err := c.storage(RequestOpts{
-		Container:  uploadPath,
+		Container:  container,
+		ObjectName: objectName,
 		Operation:  "PUT",
 		Parameters: url.Values{"extract-archive": []string{format}},
 		Headers:    extraHeaders,
@@ -2041,6 +3259,7 @@ func (c *Connection) BulkUpload(uploadPath string, dataStream io.Reader, format
 	}
 	// Detect old servers which don't support this feature
 	if headers["Content-Type"] != "application/json" {
+		drainAndClose(resp.Body, nil)
 		err = Forbidden
 		return
 	}
@@ -2074,8 +3293,13 @@ func (c *Connection) BulkUpload(uploadPath string, dataStream io.Reader, format
 //
 // Use headers.ObjectMetadata() to read the metadata in the Headers.
 func (c *Connection) Object(container string, objectName string) (info Object, headers Headers, err error) {
+	return c.ObjectWithOptions(container, objectName)
+}
+
+// ObjectWithOptions is Object with options applied to its requests
+func (c *Connection) ObjectWithOptions(container string, objectName string, options ...RequestOption) (info Object, headers Headers, err error) {
 	err = withLORetry(0, func() (Headers, int64, error) {
-		info, headers, err = c.objectBase(container, objectName)
+		info, headers, err = c.objectBase(container, objectName, nil, options...)
 		if err != nil {
 			return headers, 0, err
 		}
@@ -2084,7 +3308,10 @@ func (c *Connection) Object(container string, objectName string) (info Object, h
 	return
 }
 
-func (c *Connection) objectBase(container string, objectName string) (info Object, headers Headers, err error) {
+func (c *Connection) objectBase(container string, objectName string, h Headers, options ...RequestOption) (info Object, headers Headers, err error) {
+	if err := requireObjectName(container, objectName); err != nil {
+		return info, nil, err
+	}
 	var resp *http.Response
 	resp, headers, err = c.storage(RequestOpts{
 		Container:  container,
@@ -2092,7 +3319,8 @@ func (c *Connection) objectBase(container string, objectName string) (info Objec
 		Operation:  "HEAD",
 		ErrorMap:   objectErrorMap,
 		NoResponse: true,
-	})
+		Headers:    h,
+	}, options...)
 	if err != nil {
 		return
 	}
@@ -2119,18 +3347,38 @@ func (c *Connection) objectBase(container string, objectName string) (info Objec
 	// See ceph http://tracker.ceph.com/issues/15812
 	if resp.Header.Get("Last-Modified") != "" {
 		info.ServerLastModified = resp.Header.Get("Last-Modified")
-		if info.LastModified, err = time.Parse(http.TimeFormat, info.ServerLastModified); err != nil {
+		if info.LastModified, err = ParseHTTPTime(info.ServerLastModified); err != nil {
 			return
 		}
 	}
+	if timestamp, ok := headers.Timestamp(); ok {
+		info.Timestamp = timestamp
+		// Last-Modified is only accurate to the second so use
+		// X-Timestamp for the fractional seconds if it agrees
+		if timestamp.Truncate(time.Second).Equal(info.LastModified) {
+			info.LastModified = timestamp
+		}
+	}
 
-	info.Hash = resp.Header.Get("Etag")
+	info.Hash = strings.Trim(resp.Header.Get("Etag"), `"`)
 	if resp.Header.Get("X-Object-Manifest") != "" {
 		info.ObjectType = DynamicLargeObjectType
 	} else if resp.Header.Get("X-Static-Large-Object") != "" {
 		info.ObjectType = StaticLargeObjectType
 	}
 
+	if enc := encryptionRequest(options); enc != nil {
+		var aead cipher.AEAD
+		if aead, _, err = enc.objectKey(headers); err != nil {
+			return
+		}
+		if err = decryptMetadata(aead, headers); err != nil {
+			return
+		}
+		if size, ok := decryptedLength(info.Bytes); ok {
+			info.Bytes = size
+		}
+	}
 	return
 }
 
@@ -2155,19 +3403,106 @@ func (c *Connection) objectBase(container string, objectName string) (info Objec
 // Refer to copying an object when you need to update metadata or
 // other headers such as Content-Type or CORS headers.
 //
+// The metadata replaces all the existing metadata so it can't be
+// split into several requests like ContainerUpdate.  A
+// *MetadataLimitError is returned if it is over the server's limits.
+//
 // May return ObjectNotFound.
 func (c *Connection) ObjectUpdate(container string, objectName string, h Headers) error {
-	_, _, err := c.storage(RequestOpts{
+	return c.ObjectUpdateWithOptions(container, objectName, h)
+}
+
+// ObjectUpdateWithOptions is ObjectUpdate with options applied to its
+// requests
+func (c *Connection) ObjectUpdateWithOptions(container string, objectName string, h Headers, options ...RequestOption) error {
+	if err := requireObjectName(container, objectName); err != nil {
+		return err
+	}
+	batches, err := c.splitMetadata(h, "X-Object-Meta-")
+	if err != nil {
+		return err
+	}
+	if len(batches) > 1 {
+		return &MetadataLimitError{Reason: "too many items or overall size too big for one request"}
+	}
+	if enc := encryptionRequest(options); enc != nil {
+		if h, err = c.encryptHeaders(container, objectName, h, enc, options...); err != nil {
+			return err
+		}
+	}
+	_, _, err = c.storage(RequestOpts{
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "POST",
 		ErrorMap:   objectErrorMap,
 		NoResponse: true,
 		Headers:    h,
-	})
+	}, options...)
 	return err
 }
 
+// AccountRemoveMetadata removes the account metadata keys.
+//
+// This uses the X-Remove-Account-Meta- headers from
+// AccountRemoveHeaders rather than setting the keys to empty values.
+func (c *Connection) AccountRemoveMetadata(keys ...string) error {
+	return c.AccountUpdate(AccountRemoveHeaders(keys...))
+}
+
+// ContainerRemoveMetadata removes the container metadata keys.
+//
+// This uses the X-Remove-Container-Meta- headers from
+// ContainerRemoveHeaders rather than setting the keys to empty
+// values.
+func (c *Connection) ContainerRemoveMetadata(container string, keys ...string) error {
+	return c.ContainerUpdate(container, ContainerRemoveHeaders(keys...))
+}
+
+// objectUpdateHeaders are the headers other than metadata which are
+// replaced by ObjectUpdate so need to be kept by ObjectRemoveMetadata
+// and LargeObjectUpdate
+var objectUpdateHeaders = []string{
+	"Content-Disposition",
+	"Content-Encoding",
+	"X-Delete-At",
+	"X-Object-Manifest",
+}
+
+// keepObjectHeaders returns a copy of h with the objectUpdateHeaders
+// from the object's current headers which h doesn't set
+func keepObjectHeaders(h Headers, headers Headers) Headers {
+	newHeaders := Headers{}
+	for _, key := range objectUpdateHeaders {
+		if value, ok := headers[key]; ok {
+			newHeaders[key] = value
+		}
+	}
+	for key, value := range h {
+		newHeaders[key] = value
+	}
+	return newHeaders
+}
+
+// ObjectRemoveMetadata removes the object metadata keys.
+//
+// Swift doesn't support X-Remove- headers for objects, as a POST to
+// an object replaces all of its metadata.  This reads the object's
+// headers and does an ObjectUpdate with all the metadata other than
+// keys, so it isn't atomic.
+//
+// May return ObjectNotFound.
+func (c *Connection) ObjectRemoveMetadata(container string, objectName string, keys ...string) error {
+	_, headers, err := c.Object(container, objectName)
+	if err != nil {
+		return err
+	}
+	m := headers.ObjectMetadata()
+	for _, key := range keys {
+		delete(m, strings.ToLower(key))
+	}
+	return c.ObjectUpdate(container, objectName, keepObjectHeaders(m.ObjectHeaders(), headers))
+}
+
 // urlPathEscape escapes URL path the in string using URL escaping rules
 //
 // This mimics url.PathEscape which only available from go 1.8
@@ -2177,6 +3512,79 @@ func urlPathEscape(in string) string {
 	return u.String()
 }
 
+// CopyMethod selects how server side copies are done
+type CopyMethod int
+
+// Values that CopyMethod can take
+const (
+	// Choose the method using the capabilities from /info - COPY if
+	// the server reports it is Swift, otherwise PUT
+	CopyMethodAuto CopyMethod = iota
+	// Use the COPY verb with a Destination header
+	CopyMethodCOPY
+	// Use PUT on the destination with an X-Copy-From header
+	CopyMethodPUT
+)
+
+// copyMethod returns the method to use for server side copies,
+// resolving CopyMethodAuto.
+//
+// The method chosen for CopyMethodAuto is remembered, including
+// CopyMethodPUT if /info couldn't be read, so /info isn't asked for
+// on every copy.
+func (c *Connection) copyMethod() (CopyMethod, error) {
+	if c.CopyMethod != CopyMethodAuto {
+		return c.CopyMethod, nil
+	}
+	if method := CopyMethod(atomic.LoadInt32(&c.autoCopyMethod)); method != CopyMethodAuto {
+		return method, nil
+	}
+	if !c.Authenticated() {
+		if err := c.Authenticate(); err != nil {
+			return CopyMethodAuto, err
+		}
+	}
+	method := CopyMethodCOPY
+	info, err := c.cachedQueryInfo()
+	if err != nil || !info.SupportsCopyVerb() {
+		method = CopyMethodPUT
+	}
+	atomic.StoreInt32(&c.autoCopyMethod, int32(method))
+	return method, nil
+}
+
+// accountStorageUrl returns the storage URL of account, which should
+// be the last element of a storage URL, eg "AUTH_1234".
+//
+// If account is empty the StorageUrl of the connection is returned.
+func (c *Connection) accountStorageUrl(account string) (string, error) {
+	c.authLock.Lock()
+	storageUrl := c.StorageUrl
+	c.authLock.Unlock()
+	if account == "" {
+		return storageUrl, nil
+	}
+	u, err := url.Parse(storageUrl)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(path.Dir(strings.TrimSuffix(u.Path, "/")), account)
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// storageAccount returns the account of the StorageUrl, eg "AUTH_1234"
+func (c *Connection) storageAccount() (string, error) {
+	c.authLock.Lock()
+	storageUrl := c.StorageUrl
+	c.authLock.Unlock()
+	u, err := url.Parse(storageUrl)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(u.Path), nil
+}
+
 // ObjectCopy does a server side copy of an object to a new position
 //
 // All metadata is preserved.  If metadata is set in the headers then
@@ -2186,11 +3594,49 @@ func urlPathEscape(in string) string {
 //
 // You can use this to copy an object to itself - this is the only way
 // to update the content type of an object.
+//
+// The copy is done with the COPY verb or with PUT and X-Copy-From as
+// selected by the CopyMethod of the Connection.
 func (c *Connection) ObjectCopy(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
+	return c.ObjectCopyWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName, h)
+}
+
+// ObjectCopyWithOptions is ObjectCopy with options applied to its
+// requests
+func (c *Connection) ObjectCopyWithOptions(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	return c.ObjectCopyAccount(srcContainer, srcObjectName, "", dstContainer, dstObjectName, h, options...)
+}
+
+// ObjectCopyAccount does a server side copy of an object to a new
+// position in the account dstAccount, eg "AUTH_1234".
+//
+// If dstAccount is empty then this is the same as ObjectCopy.
+// Otherwise the user must have permission to write to the destination
+// container in the other account.
+func (c *Connection) ObjectCopyAccount(srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	c.initAuthLock()
+	srcObjectName = c.NormalizeName.normalize(srcObjectName)
+	if err = c.checkObjectName(srcContainer, srcObjectName); err != nil {
+		return nil, err
+	}
+	dstObjectName = c.NormalizeName.normalize(dstObjectName)
+	if err = c.checkObjectName(dstContainer, dstObjectName); err != nil {
+		return nil, err
+	}
+	method, err := c.copyMethod()
+	if err != nil {
+		return nil, err
+	}
+	if method == CopyMethodPUT {
+		return c.objectCopyPut(srcContainer, srcObjectName, dstAccount, dstContainer, dstObjectName, h, options...)
+	}
 	// Meta stuff
 	extraHeaders := map[string]string{
 		"Destination": urlPathEscape(dstContainer + "/" + dstObjectName),
 	}
+	if dstAccount != "" {
+		extraHeaders["Destination-Account"] = dstAccount
+	}
 	for key, value := range h {
 		extraHeaders[key] = value
 	}
@@ -2201,7 +3647,42 @@ func (c *Connection) ObjectCopy(srcContainer string, srcObjectName string, dstCo
 		ErrorMap:   objectErrorMap,
 		NoResponse: true,
 		Headers:    extraHeaders,
-	})
+	}, options...)
+	return
+}
+
+// objectCopyPut does a server side copy with PUT and X-Copy-From
+func (c *Connection) objectCopyPut(srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string, h Headers, options ...RequestOption) (headers Headers, err error) {
+	extraHeaders := map[string]string{
+		"X-Copy-From": urlPathEscape(srcContainer + "/" + srcObjectName),
+	}
+	if dstAccount != "" {
+		srcAccount, err := c.storageAccount()
+		if err != nil {
+			return nil, err
+		}
+		extraHeaders["X-Copy-From-Account"] = srcAccount
+	}
+	for key, value := range h {
+		extraHeaders[key] = value
+	}
+	targetUrl, err := c.accountStorageUrl(dstAccount)
+	if err != nil {
+		return nil, err
+	}
+	p := RequestOpts{
+		Container:  dstContainer,
+		ObjectName: dstObjectName,
+		Operation:  "PUT",
+		ErrorMap:   objectErrorMap,
+		NoResponse: true,
+		Headers:    extraHeaders,
+		OnReAuth: func() (string, error) {
+			return c.accountStorageUrl(dstAccount)
+		},
+	}
+	p.apply(options)
+	_, headers, err = c.Call(targetUrl, p)
 	return
 }
 
@@ -2212,12 +3693,32 @@ func (c *Connection) ObjectCopy(srcContainer string, srcObjectName string, dstCo
 // All metadata is preserved.
 //
 // The destination container must exist before the copy.
+//
+// The source is only deleted if the copy succeeded.  Moving an object
+// onto itself, including to a name which is the same after
+// NormalizeName, does nothing.
 func (c *Connection) ObjectMove(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) (err error) {
-	_, err = c.ObjectCopy(srcContainer, srcObjectName, dstContainer, dstObjectName, nil)
+	return c.ObjectMoveWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName)
+}
+
+// ObjectMoveWithOptions is ObjectMove with options applied to its
+// requests
+func (c *Connection) ObjectMoveWithOptions(srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, options ...RequestOption) (err error) {
+	if srcContainer == dstContainer && c.NormalizeName.normalize(srcObjectName) == c.NormalizeName.normalize(dstObjectName) {
+		return nil
+	}
+	_, err = c.ObjectCopyWithOptions(srcContainer, srcObjectName, dstContainer, dstObjectName, nil, options...)
 	if err != nil {
 		return
 	}
-	return c.ObjectDelete(srcContainer, srcObjectName)
+	return c.ObjectDeleteWithOptions(srcContainer, srcObjectName, options...)
+}
+
+// ObjectRename renames an object within a container
+//
+// This is a convenience method which calls ObjectMove
+func (c *Connection) ObjectRename(container string, srcObjectName string, dstObjectName string) error {
+	return c.ObjectMove(container, srcObjectName, container, dstObjectName)
 }
 
 // ObjectUpdateContentType updates the content type of an object
@@ -2231,6 +3732,28 @@ func (c *Connection) ObjectUpdateContentType(container string, objectName string
 	return
 }
 
+// ObjectRewriteHeaders replaces headers of an object which can't be
+// changed with ObjectUpdate, such as Content-Type, Content-Encoding
+// or Content-Disposition.
+//
+// This is a convenience method which calls ObjectCopy to copy the
+// object onto itself with the headers h.
+//
+// If freshMetadata is set then the existing metadata of the object is
+// removed and only the metadata in h is kept, otherwise metadata in h
+// is added to the existing metadata.
+func (c *Connection) ObjectRewriteHeaders(container string, objectName string, h Headers, freshMetadata bool) (err error) {
+	headers := Headers{}
+	for key, value := range h {
+		headers[key] = value
+	}
+	if freshMetadata {
+		headers["X-Fresh-Metadata"] = "true"
+	}
+	_, err = c.ObjectCopy(container, objectName, container, objectName, headers)
+	return
+}
+
 // ------------------------------------------------------------
 
 // VersionContainerCreate is a helper method for creating and enabling version controlled containers.
@@ -2291,3 +3814,190 @@ func (c *Connection) VersionObjectList(version, object string) ([]string, error)
 	}
 	return c.ObjectNames(version, opts)
 }
+
+// HistoryEnable enables versioning in history mode on the current
+// container with history as the tracking container.
+//
+// In history mode deleting an object copies it to the history
+// container as well as removing it, whereas with VersionEnable
+// deleting an object restores the previous version.
+//
+// May return Forbidden if this isn't supported by the server
+func (c *Connection) HistoryEnable(current, history string) error {
+	h := Headers{"X-History-Location": history}
+	if err := c.ContainerUpdate(current, h); err != nil {
+		return err
+	}
+	// Check to see if the header was set properly
+	_, headers, err := c.Container(current)
+	if err != nil {
+		return err
+	}
+	if headers["X-History-Location"] != history {
+		return Forbidden
+	}
+	return nil
+}
+
+// HistoryDisable disables history mode versioning on the current
+// container.
+func (c *Connection) HistoryDisable(current string) error {
+	h := Headers{"X-History-Location": ""}
+	return c.ContainerUpdate(current, h)
+}
+
+// VersionObjectName returns the name of the object that the old
+// version versionName as returned by VersionObjectList was made from.
+func VersionObjectName(versionName string) (string, error) {
+	if len(versionName) < 3 {
+		return "", newErrorf(0, "invalid version name %q", versionName)
+	}
+	n, err := strconv.ParseUint(versionName[:3], 16, 32)
+	if err != nil || 3+int(n) >= len(versionName) || versionName[3+n] != '/' {
+		return "", newErrorf(0, "invalid version name %q", versionName)
+	}
+	return versionName[3 : 3+n], nil
+}
+
+// VersionObjectRestore copies the old version versionName as
+// returned by VersionObjectList from the version container back over
+// the object it was made from in the current container.
+//
+// The version being replaced is itself archived by the server, so
+// this can be used to undelete or roll back objects.
+func (c *Connection) VersionObjectRestore(current, version, versionName string) error {
+	object, err := VersionObjectName(versionName)
+	if err != nil {
+		return err
+	}
+	_, err = c.ObjectCopy(version, versionName, current, object, nil)
+	return err
+}
+
+// ------------------------------------------------------------
+
+// ObjectVersion is an object as returned by ObjectVersions for
+// containers using X-Versions-Enabled versioning.
+type ObjectVersion struct {
+	Object
+	VersionId string `json:"version_id"` // Id of the version, "null" for objects created before versioning was enabled
+	IsLatest  bool   `json:"is_latest"`  // Set if this is the current version of the object
+}
+
+// ContainerVersioningEnable turns on object versioning with the
+// X-Versions-Enabled header.  This is the versioning introduced in
+// Swift 2.24 which keeps the old versions out of sight in the same
+// container.
+//
+// May return Forbidden if this isn't supported by the server
+func (c *Connection) ContainerVersioningEnable(container string) error {
+	if err := c.ContainerUpdate(container, Headers{"X-Versions-Enabled": "true"}); err != nil {
+		return err
+	}
+	// Check to see if the header was set properly
+	_, headers, err := c.Container(container)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(headers["X-Versions-Enabled"], "true") {
+		return Forbidden
+	}
+	return nil
+}
+
+// ContainerVersioningDisable stops new versions being made in a
+// container with X-Versions-Enabled versioning.  Existing versions
+// are kept.
+func (c *Connection) ContainerVersioningDisable(container string) error {
+	return c.ContainerUpdate(container, Headers{"X-Versions-Enabled": "false"})
+}
+
+// ObjectVersions returns all the versions of objectName in a
+// container with X-Versions-Enabled versioning, newest first.
+//
+// It lists as many pages as needed using the marker and
+// version_marker parameters.
+func (c *Connection) ObjectVersions(container string, objectName string) ([]ObjectVersion, error) {
+	versions := make([]ObjectVersion, 0)
+	v := url.Values{}
+	v.Set("versions", "")
+	v.Set("prefix", objectName)
+	v.Set("limit", strconv.Itoa(allObjectsLimit))
+	v.Set("format", "json")
+	for {
+		resp, _, err := c.storage(RequestOpts{
+			Container:  container,
+			Operation:  "GET",
+			Parameters: v,
+			ErrorMap:   ContainerErrorMap,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var listing []ObjectVersion
+		err = readJson(resp, &listing)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range listing {
+			// The prefix matches other objects too, which are
+			// listed after all the versions of objectName
+			if version.Name != objectName {
+				return versions, nil
+			}
+			if version.ServerLastModified != "" {
+				version.LastModified, err = ParseListingTime(version.ServerLastModified)
+				if err != nil {
+					return nil, err
+				}
+			}
+			versions = append(versions, version)
+		}
+		n := len(listing)
+		if n == 0 || (n < allObjectsLimit && !c.listingMaybeTruncated(n)) {
+			return versions, nil
+		}
+		last := listing[n-1]
+		v.Set("marker", last.Name)
+		v.Set("version_marker", last.VersionId)
+	}
+}
+
+// ObjectGetVersion gets the version versionId of the object into the
+// io.Writer contents.
+//
+// The version ids can be read with ObjectVersions.
+//
+// Returns the headers of the response.
+func (c *Connection) ObjectGetVersion(container string, objectName string, versionId string, contents io.Writer, checkHash bool) (headers Headers, err error) {
+	v := url.Values{}
+	v.Set("version-id", versionId)
+	file, headers, err := c.objectOpen(container, objectName, checkHash, nil, v)
+	if err != nil {
+		return
+	}
+	defer checkClose(file, &err)
+	_, err = io.Copy(contents, file)
+	return
+}
+
+// ObjectRestoreVersion makes the version versionId of the object the
+// current version by copying it over the object.  The version which
+// was current is kept as a version.
+func (c *Connection) ObjectRestoreVersion(container string, objectName string, versionId string) error {
+	objectName = c.NormalizeName.normalize(objectName)
+	v := url.Values{}
+	v.Set("version-id", versionId)
+	_, _, err := c.storage(RequestOpts{
+		Container:  container,
+		ObjectName: objectName,
+		Operation:  "COPY",
+		Parameters: v,
+		ErrorMap:   objectErrorMap,
+		NoResponse: true,
+		Headers: Headers{
+			"Destination": urlPathEscape(container + "/" + objectName),
+		},
+	})
+	return err
+}
@@ -15,18 +15,27 @@ package swift_test
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -482,6 +491,75 @@ func TestAuthenticateRace(t *testing.T) {
 	wg.Wait()
 }
 
+func TestAuthenticateContext(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+	c := swift.Connection{
+		UserName:       "user",
+		ApiKey:         "key",
+		AuthUrl:        server.URL + "/v1.0",
+		ConnectTimeout: time.Minute,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.AuthenticateContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expecting DeadlineExceeded got %v", err)
+	}
+
+	// Check re-authentication from a request is cancelled too
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, _, err = c.Call("", swift.RequestOpts{
+		Operation:  "HEAD",
+		NoResponse: true,
+		Context:    ctx,
+	})
+	if err != context.Canceled {
+		t.Errorf("Expecting Canceled got %v", err)
+	}
+}
+
+func TestAuthTokenIntrospection(t *testing.T) {
+	c, rollback := makeConnection(t)
+	defer rollback()
+	if c.CurrentAuthToken() != "" || c.CurrentStorageUrl() != "" || c.ServiceCatalog() != nil {
+		t.Error("Expecting no token, storage URL or catalog before auth")
+	}
+	err := c.Authenticate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CurrentAuthToken() != c.AuthToken || c.CurrentAuthToken() == "" {
+		t.Errorf("Bad auth token %q", c.CurrentAuthToken())
+	}
+	if c.CurrentStorageUrl() != c.StorageUrl || c.CurrentStorageUrl() == "" {
+		t.Errorf("Bad storage URL %q", c.CurrentStorageUrl())
+	}
+	if !c.AuthTokenExpiry().Equal(c.Expires) {
+		t.Errorf("Bad expiry %v", c.AuthTokenExpiry())
+	}
+	if _, ok := c.Auth.(swift.Cataloger); ok {
+		found := false
+		for _, service := range c.ServiceCatalog() {
+			if service.Type == "object-store" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("object-store not found in service catalog")
+		}
+	}
+}
+
 // Test a connection can be serialized and unserialized with JSON
 func TestSerializeConnectionJson(t *testing.T) {
 	c, rollback := makeConnectionAuth(t)
@@ -601,6 +679,220 @@ func TestAccountUpdate(t *testing.T) {
 	compareMaps(t, m, map[string]string{})
 }
 
+func TestAccountRemoveMetadata(t *testing.T) {
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	err := c.AccountUpdate(m1.AccountHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.AccountRemoveMetadata("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := headers.AccountMetadata()
+	delete(m, "temp-url-key") // remove X-Account-Meta-Temp-URL-Key if set
+	compareMaps(t, m, map[string]string{"potato-salad": "2"})
+	err = c.AccountRemoveMetadata("potato-salad")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountConfigure(t *testing.T) {
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	info, _, err := c.Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := *info.Settings
+	defer func() {
+		if err := c.AccountConfigure(&old); err != nil {
+			t.Error(err)
+		}
+	}()
+	settings := swift.AccountSettings{
+		QuotaBytes:  1 << 30,
+		TempUrlKeys: swift.TempUrlKeys{Key: "key1", Key2: "key2"},
+	}
+	if err = c.AccountConfigure(&settings); err != nil {
+		t.Fatal(err)
+	}
+	info, headers, err := c.Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info.Settings != settings {
+		t.Errorf("Expecting %+v got %+v", settings, info.Settings)
+	}
+	if headers["X-Account-Meta-Quota-Bytes"] != "1073741824" {
+		t.Errorf("Bad quota header %q", headers["X-Account-Meta-Quota-Bytes"])
+	}
+
+	// Unset settings are removed
+	if err = c.AccountConfigure(&swift.AccountSettings{QuotaBytes: 1 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	info, _, err = c.Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info.Settings != (swift.AccountSettings{QuotaBytes: 1 << 30}) {
+		t.Errorf("Bad settings %+v", info.Settings)
+	}
+}
+
+func TestAccountACL(t *testing.T) {
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	acl := &swift.AccountACL{ReadOnly: []string{"project:reader"}}
+	if err := c.AccountSetACL(acl); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.AccountACL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, acl) {
+		t.Errorf("Expecting %+v got %+v", acl, got)
+	}
+	if err = c.AccountSetACL(&swift.AccountACL{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = c.AccountACL(); err != nil {
+		t.Fatal(err)
+	} else if !got.Empty() {
+		t.Errorf("Expecting empty ACL got %+v", got)
+	}
+}
+
+func TestAccountExportImport(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	snapshot, err := c.AccountExport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, ok := snapshot.Containers[CONTAINER]
+	if !ok {
+		t.Fatalf("Container %q not found in export", CONTAINER)
+	}
+	compareMaps(t, headers.ContainerMetadata(), swift.Metadata{"hello": "1", "potato-salad": "2"})
+
+	// Round trip through JSON and import to a new container
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var newSnapshot swift.AccountSnapshot
+	err = json.Unmarshal(data, &newSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const newContainer = CONTAINER + "Import"
+	newSnapshot.Containers = map[string]swift.Headers{newContainer: newSnapshot.Containers[CONTAINER]}
+	err = c.AccountImport(&newSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ContainerDelete(newContainer)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	_, headers, err = c.Container(newContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ContainerMetadata(), swift.Metadata{"hello": "1", "potato-salad": "2"})
+}
+
+func TestDiffHeaders(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	_, headers, err := c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := headers.ContainerMetadata()
+	to := swift.Metadata{"hello": "1", "potato-salad": "3", "new": "4"}
+	diff := swift.DiffMetadata("X-Container-Meta-", from, to)
+	compareMaps(t, diff, swift.Headers{
+		"X-Container-Meta-Potato-Salad": "3",
+		"X-Container-Meta-New":          "4",
+	})
+	to = swift.Metadata{"new": "4"}
+	diff = swift.DiffMetadata("X-Container-Meta-", from, to)
+	compareMaps(t, diff, swift.Headers{
+		"X-Container-Meta-New":                 "4",
+		"X-Remove-Container-Meta-Hello":        "x",
+		"X-Remove-Container-Meta-Potato-Salad": "x",
+	})
+	err = c.ContainerUpdate(CONTAINER, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ContainerMetadata(), to)
+	if diff := swift.DiffMetadata("X-Container-Meta-", headers.ContainerMetadata(), to); len(diff) != 0 {
+		t.Errorf("Expecting no changes got %v", diff)
+	}
+
+	diff = swift.DiffHeaders(swift.Headers{"x-versions-location": "old", "Content-Type": "text/plain"}, swift.Headers{})
+	compareMaps(t, diff, swift.Headers{"X-Remove-Versions-Location": "x", "Content-Type": ""})
+}
+
+func TestMetadataLimits(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate metadata limits.")
+		return
+	}
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		w.Write([]byte(`{"swift": {"max_meta_count": 2}}`))
+	})
+	defer srv.UnsetOverride("/info")
+	_, err := c.QueryInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := swift.Metadata{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}
+	err = c.ContainerUpdate(CONTAINER, m.ContainerHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := headers.ContainerMetadata()
+	for key, value := range m {
+		if got[key] != value {
+			t.Errorf("Container metadata %q: expecting %q got %q", key, value, got[key])
+		}
+	}
+
+	err = c.ObjectUpdate(CONTAINER, OBJECT, m.ObjectHeaders())
+	if _, ok := err.(*swift.MetadataLimitError); !ok {
+		t.Errorf("Expecting MetadataLimitError got %v", err)
+	}
+	err = c.ContainerUpdate(CONTAINER, swift.Metadata{"big": strings.Repeat("x", 257)}.ContainerHeaders())
+	if limitErr, ok := err.(*swift.MetadataLimitError); !ok || limitErr.Key != "X-Container-Meta-Big" {
+		t.Errorf("Expecting MetadataLimitError for X-Container-Meta-Big got %v", err)
+	}
+}
+
 func TestContainerCreate(t *testing.T) {
 	c, rollback := makeConnectionAuth(t)
 	defer rollback()
@@ -689,122 +981,306 @@ func TestContainerUpdate(t *testing.T) {
 	compareMaps(t, headers.ContainerMetadata(), map[string]string{})
 }
 
-func TestContainerNames(t *testing.T) {
+func TestContainerRemoveMetadata(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	containers, err := c.ContainerNames(nil)
+	err := c.ContainerRemoveMetadata(CONTAINER, "potato-salad")
 	if err != nil {
 		t.Fatal(err)
 	}
-	ok := false
-	for _, container := range containers {
-		if container == CONTAINER {
-			ok = true
-			break
-		}
-	}
-	if !ok {
-		t.Errorf("Didn't find container %q in listing %q", CONTAINER, containers)
+	_, headers, err := c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
 	}
+	compareMaps(t, headers.ContainerMetadata(), map[string]string{"hello": "1"})
 }
 
-func TestContainerNamesAll(t *testing.T) {
+func TestContainerConfigure(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	containers1, err := c.ContainerNamesAll(nil)
+	info, _, err := c.Container(CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	containers2, err := c.ContainerNames(nil)
+	if *info.Settings != (swift.ContainerSettings{}) {
+		t.Errorf("Expecting no settings got %+v", info.Settings)
+	}
+	settings := swift.ContainerSettings{
+		ReadACL:          ".r:*",
+		QuotaBytes:       1000,
+		QuotaCount:       10,
+		VersionsLocation: "versions",
+		WebIndex:         "index.html",
+		WebListings:      true,
+	}
+	if err = c.ContainerConfigure(CONTAINER, &settings); err != nil {
+		t.Fatal(err)
+	}
+	info, headers, err := c.Container(CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(containers1) != len(containers2) {
-		t.Fatal("Wrong length")
+	if *info.Settings != settings {
+		t.Errorf("Expecting %+v got %+v", settings, info.Settings)
 	}
-	for i := range containers1 {
-		if containers1[i] != containers2[i] {
-			t.Fatal("Not the same")
-		}
+	if headers["X-Container-Meta-Quota-Bytes"] != "1000" || headers["X-Container-Meta-Web-Listings"] != "true" {
+		t.Errorf("Bad headers %v", headers)
+	}
+
+	// Unset settings are removed and other metadata is left alone
+	if err = c.ContainerConfigure(CONTAINER, &swift.ContainerSettings{QuotaCount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	info, headers, err = c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
 	}
+	if *info.Settings != (swift.ContainerSettings{QuotaCount: 5}) {
+		t.Errorf("Bad settings %+v", info.Settings)
+	}
+	compareMaps(t, headers.ContainerMetadata(), map[string]string{"hello": "1", "potato-salad": "2", "quota-count": "5"})
 }
 
-func TestContainerNamesAllWithLimit(t *testing.T) {
+func TestContainerSetQuota(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	containers1, err := c.ContainerNamesAll(&swift.ContainersOpts{Limit: 1})
-	if err != nil {
+	if err := c.ContainerSetQuota(CONTAINER, 1<<20, 100); err != nil {
 		t.Fatal(err)
 	}
-	containers2, err := c.ContainerNames(nil)
+	info, _, err := c.Container(CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(containers1) != len(containers2) {
-		t.Fatal("Wrong length")
+	if info.Settings.QuotaBytes != 1<<20 || info.Settings.QuotaCount != 100 {
+		t.Errorf("Bad quotas %+v", info.Settings)
 	}
-	for i := range containers1 {
-		if containers1[i] != containers2[i] {
-			t.Fatal("Not the same")
-		}
+	if err = c.ContainerSetQuota(CONTAINER, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if info, _, err = c.Container(CONTAINER); err != nil {
+		t.Fatal(err)
+	} else if info.Settings.QuotaBytes != 0 || info.Settings.QuotaCount != 0 {
+		t.Errorf("Expecting no quotas %+v", info.Settings)
 	}
 }
 
-func TestObjectPutString(t *testing.T) {
+func TestContainerACLs(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, "")
-	if err != nil {
+	if err := c.ContainerMakePublic(CONTAINER, true); err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	info, _, err := c.Object(CONTAINER, OBJECT)
+	if err := c.ContainerGrantWrite(CONTAINER, "project:user"); err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Container(CONTAINER)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if info.ContentType != "application/octet-stream" {
-		t.Error("Bad content type", info.ContentType)
+	if headers["X-Container-Read"] != ".r:*,.rlistings" || headers["X-Container-Write"] != "project:user" {
+		t.Errorf("Bad ACLs %q %q", headers["X-Container-Read"], headers["X-Container-Write"])
 	}
-	if info.Bytes != CONTENT_SIZE {
-		t.Error("Bad length")
+	if err = c.ContainerMakePrivate(CONTAINER); err != nil {
+		t.Fatal(err)
 	}
-	if info.Hash != CONTENT_MD5 {
-		t.Error("Bad length")
+	if err = c.ContainerRevoke(CONTAINER, "project:user"); err != nil {
+		t.Fatal(err)
+	}
+	read, write, err := c.ContainerACLs(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.String() != "" || write.String() != "" {
+		t.Errorf("Expecting empty ACLs got %q %q", read, write)
 	}
 }
 
-func TestObjectPut(t *testing.T) {
+func TestContainerCORS(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-
-	headers := swift.Headers{}
-
-	// Set content size incorrectly - should produce an error
-	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE-1, 10)
-	contents := bytes.NewBufferString(CONTENTS)
-	h, err := c.ObjectPut(CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
-	if err == nil {
-		t.Fatal("Expecting error but didn't get one")
+	config := &swift.CORSConfig{
+		AllowOrigins:  []string{"https://example.com"},
+		ExposeHeaders: []string{"Etag", "Content-Length"},
+		MaxAge:        time.Hour,
 	}
-
-	// Now set content size correctly
-	contents = bytes.NewBufferString(CONTENTS)
-	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE, 10)
-	h, err = c.ObjectPut(CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
+	if err := c.ContainerSetCORS(CONTAINER, config); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ContainerCORS(CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("Expecting %+v got %+v", config, got)
+	}
+	if err = c.ContainerSetCORS(CONTAINER, &swift.CORSConfig{AllowOrigins: []string{"example.com"}}); err == nil {
+		t.Error("Expecting validation error")
+	}
+	if err = c.ContainerSetCORS(CONTAINER, &swift.CORSConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ContainerMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+}
+
+func TestContainerWebsite(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	config := &swift.WebsiteConfig{
+		Index:       "index.html",
+		Error:       "error.html",
+		Listings:    true,
+		ListingsCSS: "listings.css",
+	}
+	if err := c.ContainerHostWebsite(CONTAINER, config); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ContainerWebsite(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *config || !got.Enabled() {
+		t.Errorf("Expecting %+v got %+v", config, got)
+	}
+	read, _, err := c.ContainerACLs(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.IsPublic() || !read.Listings {
+		t.Errorf("Expecting public ACL got %q", read)
+	}
+	if err = c.ContainerSetWebsite(CONTAINER, &swift.WebsiteConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = c.ContainerWebsite(CONTAINER); err != nil {
+		t.Fatal(err)
+	} else if got.Enabled() || *got != (swift.WebsiteConfig{}) {
+		t.Errorf("Expecting no config got %+v", got)
+	}
+	if err = c.ContainerMakePrivate(CONTAINER); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContainerNames(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	containers, err := c.ContainerNames(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := false
+	for _, container := range containers {
+		if container == CONTAINER {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		t.Errorf("Didn't find container %q in listing %q", CONTAINER, containers)
+	}
+}
+
+func TestContainerNamesAll(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	containers1, err := c.ContainerNamesAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers2, err := c.ContainerNames(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers1) != len(containers2) {
+		t.Fatal("Wrong length")
+	}
+	for i := range containers1 {
+		if containers1[i] != containers2[i] {
+			t.Fatal("Not the same")
+		}
+	}
+}
+
+func TestContainerNamesAllWithLimit(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	containers1, err := c.ContainerNamesAll(&swift.ContainersOpts{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers2, err := c.ContainerNames(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers1) != len(containers2) {
+		t.Fatal("Wrong length")
+	}
+	for i := range containers1 {
+		if containers1[i] != containers2[i] {
+			t.Fatal("Not the same")
+		}
+	}
+}
+
+func TestObjectPutString(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	err := c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	info, _, err := c.Object(CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if info.ContentType != "text/plain; charset=utf-8" {
+		t.Error("Bad content type", info.ContentType)
+	}
+	if info.Bytes != CONTENT_SIZE {
+		t.Error("Bad length")
+	}
+	if info.Hash != CONTENT_MD5 {
+		t.Error("Bad length")
+	}
+}
+
+func TestObjectPut(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	headers := swift.Headers{}
+
+	// Set content size incorrectly - should produce an error
+	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE-1, 10)
+	contents := bytes.NewBufferString(CONTENTS)
+	h, err := c.ObjectPut(CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
+	if err == nil {
+		t.Fatal("Expecting error but didn't get one")
+	}
+
+	// Now set content size correctly
+	contents = bytes.NewBufferString(CONTENTS)
+	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE, 10)
+	h, err = c.ObjectPut(CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
 	}()
 
 	if h["Etag"] != CONTENT_MD5 {
@@ -877,7 +1353,7 @@ func TestObjectPutStringWithReauth(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if info.ContentType != "application/octet-stream" {
+	if info.ContentType != "text/plain; charset=utf-8" {
 		t.Error("Bad content type", info.ContentType)
 	}
 	if info.Bytes != CONTENT_SIZE {
@@ -1033,6 +1509,87 @@ func TestStaticSymlinkObject(t *testing.T) {
 	}
 }
 
+func TestObjectsSymlinkAndSLO(t *testing.T) {
+	info, err := getSwinftInfo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info["symlink"]; !ok {
+		t.Skip("skip, symlink not supported")
+		return
+	}
+	c, rollback := makeConnectionWithSLO(t)
+	defer rollback()
+	_, err = c.ObjectSymlinkCreate(CONTAINER, SYMLINK_OBJECT, "", CONTAINER, OBJECT, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, SYMLINK_OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	objects, err := c.ObjectsAll(CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]swift.ObjectType{}
+	for _, object := range objects {
+		types[object.Name] = object.ObjectType
+		switch object.Name {
+		case OBJECT:
+			if object.SLOHash == "" {
+				t.Errorf("SLO without slo_etag %+v", object)
+			}
+		case SYMLINK_OBJECT:
+			_, container, objectName, ok := object.SymlinkTarget()
+			if !ok || container != CONTAINER || objectName != OBJECT {
+				t.Errorf("Bad symlink target %q %q %v from %+v", container, objectName, ok, object)
+			}
+		}
+	}
+	if types[OBJECT] != swift.StaticLargeObjectType || types[SYMLINK_OBJECT] != swift.SymlinkObjectType {
+		t.Errorf("Bad object types %v", types)
+	}
+}
+
+func TestObjectPutContentType(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	for _, test := range []struct {
+		name     string
+		contents io.Reader
+		size     int64
+		want     string
+	}{
+		{"test.html", strings.NewReader(CONTENTS), CONTENT_SIZE, "text/html; charset=utf-8"},
+		{OBJECT, strings.NewReader("<html><body>hello</body></html>"), 31, "text/html; charset=utf-8"},
+		{OBJECT, strings.NewReader("\x89PNG\r\n\x1a\n"), 8, "image/png"},
+		{OBJECT, bytes.NewBufferString("<html><body>hello</body></html>"), 31, "application/octet-stream"},
+		{OBJECT, strings.NewReader(""), 0, "application/octet-stream"},
+	} {
+		_, err := c.ObjectPut(CONTAINER, test.name, test.contents, true, "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, _, err := c.Object(CONTAINER, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ContentType != test.want {
+			t.Errorf("%s: want content type %q got %q", test.name, test.want, info.ContentType)
+		}
+		if info.Bytes != test.size {
+			t.Errorf("%s: want size %d got %d", test.name, test.size, info.Bytes)
+		}
+		err = c.ObjectDelete(CONTAINER, test.name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestObjectPutBytes(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
@@ -1062,276 +1619,727 @@ func TestObjectPutBytes(t *testing.T) {
 	}
 }
 
-func TestObjectPutMimeType(t *testing.T) {
+func TestObjectPutGetProgress(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutString(CONTAINER, "test.jpg", CONTENTS, "")
+	var transferred, total int64
+	c.Progress = func(container, objectName string, n, size int64) {
+		if container != CONTAINER || objectName != OBJECT {
+			t.Errorf("Bad progress names %q %q", container, objectName)
+		}
+		transferred, total = n, size
+	}
+	defer func() { c.Progress = nil }()
+	err := c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.ObjectDelete(CONTAINER, "test.jpg")
+		err = c.ObjectDelete(CONTAINER, OBJECT)
 		if err != nil {
 			t.Error(err)
 		}
 	}()
-
-	info, _, err := c.Object(CONTAINER, "test.jpg")
+	if transferred != CONTENT_SIZE || total != CONTENT_SIZE {
+		t.Errorf("Bad upload progress %d/%d", transferred, total)
+	}
+	transferred, total = 0, 0
+	_, err = c.ObjectGetString(CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if info.ContentType != "image/jpeg" {
-		t.Error("Bad content type", info.ContentType)
+	if transferred != CONTENT_SIZE || total != CONTENT_SIZE {
+		t.Errorf("Bad download progress %d/%d", transferred, total)
 	}
 }
 
-func TestObjectCreate(t *testing.T) {
+func TestObjectPutGetSHA256(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	out, err := c.ObjectCreate(CONTAINER, OBJECT2, true, "", "", nil)
+	const CONTENT_SHA256 = "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5"
+	c.Checksum = swift.ChecksumSHA256
+	defer func() { c.Checksum = swift.ChecksumMD5 }()
+
+	checkSHA256 := func() {
+		_, headers, err := c.Object(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers[swift.SHA256Header] != CONTENT_SHA256 {
+			t.Errorf("Bad SHA-256 %q", headers[swift.SHA256Header])
+		}
+		contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != CONTENTS {
+			t.Errorf("Bad contents %q", contents)
+		}
+	}
+
+	// Seekable upload
+	err := c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.ObjectDelete(CONTAINER, OBJECT2)
+		err = c.ObjectDelete(CONTAINER, OBJECT)
 		if err != nil {
 			t.Error(err)
 		}
 	}()
-	buf := &bytes.Buffer{}
-	hash := md5.New()
-	out2 := io.MultiWriter(out, buf, hash)
-	for i := 0; i < 100; i++ {
-		fmt.Fprintf(out2, "%d %s\n", i, CONTENTS)
+	checkSHA256()
+
+	// Streaming upload
+	out, err := c.ObjectCreate(CONTAINER, OBJECT, true, "", "", m1.ObjectHeaders())
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Ensure Headers fails if called prematurely
-	_, err = out.Headers()
-	if err == nil {
-		t.Error("Headers should fail if called before Close()")
+	_, err = out.Write([]byte(CONTENTS))
+	if err != nil {
+		t.Fatal(err)
 	}
 	err = out.Close()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(CONTAINER, OBJECT2)
+	checkSHA256()
+
+	// Check corruption is detected
+	err = c.ObjectUpdate(CONTAINER, OBJECT, swift.Headers{swift.SHA256Header: EMPTY_MD5})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	_, err = c.ObjectGetString(CONTAINER, OBJECT)
+	if err != swift.ObjectCorrupted {
+		t.Errorf("Expecting ObjectCorrupted got %v", err)
 	}
+}
 
-	// Ensure Headers succeeds when called after a good upload
-	headers, err := out.Headers()
+func TestObjectGetConditional(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	info, _, err := c.Object(CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if len(headers) < 1 {
-		t.Error("The Headers returned by Headers() should not be empty")
+	for _, test := range []struct {
+		cond swift.Conditions
+		err  error
+	}{
+		{swift.Conditions{}, nil},
+		{swift.Conditions{IfMatch: CONTENT_MD5}, nil},
+		{swift.Conditions{IfMatch: CONTENT2_MD5}, swift.PreconditionFailed},
+		{swift.Conditions{IfNoneMatch: CONTENT2_MD5}, nil},
+		{swift.Conditions{IfNoneMatch: CONTENT_MD5}, swift.NotModified},
+		{swift.Conditions{IfModifiedSince: info.LastModified.Add(-time.Hour)}, nil},
+		{swift.Conditions{IfModifiedSince: info.LastModified}, swift.NotModified},
+		{swift.Conditions{IfUnmodifiedSince: info.LastModified}, nil},
+		{swift.Conditions{IfUnmodifiedSince: info.LastModified.Add(-time.Hour)}, swift.PreconditionFailed},
+	} {
+		var buf bytes.Buffer
+		_, err := c.ObjectGetConditional(CONTAINER, OBJECT, &buf, true, &test.cond, nil)
+		if err != test.err {
+			t.Errorf("%+v: GET want %v got %v", test.cond, test.err, err)
+		}
+		if err == nil && buf.String() != CONTENTS {
+			t.Errorf("%+v: GET contents wrong %q", test.cond, buf.String())
+		}
+		_, _, err = c.ObjectConditional(CONTAINER, OBJECT, &test.cond)
+		if err != test.err {
+			t.Errorf("%+v: HEAD want %v got %v", test.cond, test.err, err)
+		}
 	}
+}
 
-	// Test writing on closed file
-	n, err := out.Write([]byte{0})
-	if err == nil || n != 0 {
-		t.Error("Expecting error and n == 0 writing on closed file", err, n)
+func TestObjectPutDeleteAfter(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	for _, setExpiry := range []func(swift.Headers){
+		func(h swift.Headers) { h.SetDeleteAfter(time.Hour) },
+		func(h swift.Headers) { h.SetDeleteAt(time.Now().Add(time.Hour)) },
+	} {
+		h := swift.Headers{}
+		setExpiry(h)
+		_, err := c.ObjectPut(CONTAINER, OBJECT, strings.NewReader(CONTENTS), true, "", "", h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, headers, err := c.Object(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deleteAt, ok := headers.DeleteAt()
+		if !ok {
+			t.Errorf("X-Delete-At not set: %v", headers)
+		} else if d := time.Until(deleteAt); d < 59*time.Minute || d > 61*time.Minute {
+			t.Errorf("X-Delete-At wrong: %v", deleteAt)
+		}
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
+}
 
-	// Now with hash instead
-	out, err = c.ObjectCreate(CONTAINER, OBJECT2, false, fmt.Sprintf("%x", hash.Sum(nil)), "", nil)
+func TestObjectPutIfNotExists(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	_, err := c.ObjectPutIfNotExists(CONTAINER, OBJECT, strings.NewReader(CONTENTS), true, "", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = out.Write(buf.Bytes())
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	_, err = c.ObjectPutIfNotExists(CONTAINER, OBJECT, strings.NewReader(CONTENTS2), true, "", "", nil)
+	if err != swift.ObjectExists {
+		t.Fatalf("Expecting ObjectExists got %v", err)
+	}
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	err = out.Close()
+	if contents != CONTENTS {
+		t.Errorf("Object was overwritten: %q", contents)
+	}
+}
+
+func TestObjectPutGetWithOpts(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	_, err := c.ObjectPutWithOpts(CONTAINER, OBJECT, strings.NewReader(CONTENTS), &swift.ObjectPutOpts{
+		CheckHash:   true,
+		ContentType: "text/potato",
+		Size:        CONTENT_SIZE,
+		Metadata:    swift.Metadata{"hello": "world"},
+		DeleteAfter: time.Hour,
+		IfNotExists: true,
+	})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	contents, err = c.ObjectGetString(CONTAINER, OBJECT2)
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	info, headers, err := c.Object(CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if info.ContentType != "text/potato" || info.Bytes != CONTENT_SIZE {
+		t.Errorf("Bad object %+v", info)
+	}
+	if got := headers.ObjectMetadata()["hello"]; got != "world" {
+		t.Errorf("Bad metadata %q", got)
+	}
+	if _, ok := headers.DeleteAt(); !ok {
+		t.Errorf("X-Delete-At not set: %v", headers)
+	}
+	_, err = c.ObjectPutWithOpts(CONTAINER, OBJECT, strings.NewReader(CONTENTS2), &swift.ObjectPutOpts{IfNotExists: true})
+	if err != swift.ObjectExists {
+		t.Fatalf("Expecting ObjectExists got %v", err)
 	}
 
-	// Now with bad hash
-	out, err = c.ObjectCreate(CONTAINER, OBJECT2, false, CONTENT_MD5, "", nil)
+	var buf bytes.Buffer
+	_, err = c.ObjectGetWithOpts(CONTAINER, OBJECT, &buf, &swift.ObjectGetOpts{CheckHash: true, Offset: 1, Length: 2})
 	if err != nil {
 		t.Fatal(err)
 	}
-	// FIXME: work around bug which produces 503 not 422 for empty corrupted files
-	fmt.Fprintf(out, "Sausage")
-	err = out.Close()
-	if err != swift.ObjectCorrupted {
-		t.Error("Expecting object corrupted not", err)
+	if buf.String() != CONTENTS[1:3] {
+		t.Errorf("Bad range %q", buf.String())
+	}
+	buf.Reset()
+	_, err = c.ObjectGetWithOpts(CONTAINER, OBJECT, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != CONTENTS {
+		t.Errorf("Bad contents %q", buf.String())
+	}
+	_, err = c.ObjectGetWithOpts(CONTAINER, OBJECT, &buf, &swift.ObjectGetOpts{Conditions: &swift.Conditions{IfNoneMatch: CONTENT_MD5}})
+	if err != swift.NotModified {
+		t.Errorf("Expecting NotModified got %v", err)
 	}
 }
 
-func TestObjectCreateAbort(t *testing.T) {
+func TestObjectGetGzipped(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-
-	out, err := c.ObjectCreate(CONTAINER, OBJECT2, true, "", "", nil)
+	var gzBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzBuf)
+	_, _ = gz.Write([]byte(CONTENTS))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err := c.ObjectPutBytes(CONTAINER, OBJECT, gzBuf.Bytes(), "text/plain")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		_ = c.ObjectDelete(CONTAINER, OBJECT2) // Ignore error
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
 	}()
-
-	expectedContents := "foo"
-	_, err = out.Write([]byte(expectedContents))
+	err = c.ObjectUpdate(CONTAINER, OBJECT, swift.Headers{"Content-Encoding": "gzip"})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	errAbort := fmt.Errorf("abort")
-	err = out.CloseWithError(errAbort)
+	// The transport decompresses this without being asked
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
 	if err != nil {
-		t.Errorf("Unexpected error %#v", err)
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Bad contents %q", contents)
 	}
 
-	_, err = c.ObjectGetString(CONTAINER, OBJECT2)
-	if err != swift.ObjectNotFound {
-		t.Errorf("Unexpected error: %#v", err)
+	var buf bytes.Buffer
+	headers, err := c.ObjectGetWithOpts(CONTAINER, OBJECT, &buf, &swift.ObjectGetOpts{CheckHash: true, Decompress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !headers.IsGzipped() {
+		t.Errorf("Expecting gzipped headers %v", headers)
+	}
+	if buf.String() != CONTENTS {
+		t.Errorf("Bad decompressed contents %q", buf.String())
 	}
-}
 
-func TestObjectGetString(t *testing.T) {
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	file, _, err := c.ObjectOpenWithOpts(CONTAINER, OBJECT, &swift.ObjectGetOpts{Decompress: true})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if contents != CONTENTS {
-		t.Error("Contents wrong")
+	if _, err = file.Seek(1, 0); err == nil {
+		t.Error("Expecting error seeking decompressed object")
+	}
+	if err = file.Close(); err != nil {
+		t.Error(err)
 	}
-}
 
-func TestObjectGetBytes(t *testing.T) {
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	contents, err := c.ObjectGetBytes(CONTAINER, OBJECT)
+	// Asking for gzip explicitly returns the stored bytes
+	buf.Reset()
+	_, err = c.ObjectGetWithOpts(CONTAINER, OBJECT, &buf, &swift.ObjectGetOpts{CheckHash: true, Headers: swift.Headers{"Accept-Encoding": "gzip"}})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(contents) != CONTENTS {
-		t.Error("Contents wrong")
+	if !bytes.Equal(buf.Bytes(), gzBuf.Bytes()) {
+		t.Errorf("Expecting compressed contents")
 	}
 }
 
-func TestObjectOpen(t *testing.T) {
-	c, rollback := makeConnectionWithObject(t)
+func TestObjectPutWithGzip(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+	contents := strings.Repeat(CONTENTS, 100)
+	err := c.ObjectPutStringWithOptions(CONTAINER, OBJECT, contents, "text/plain", swift.WithGzip())
 	if err != nil {
 		t.Fatal(err)
 	}
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, file)
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	info, headers, err := c.Object(CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != CONTENT_SIZE {
-		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	if !headers.IsGzipped() || info.Bytes >= int64(len(contents)) {
+		t.Errorf("Expecting compressed object got %d bytes %v", info.Bytes, headers)
 	}
-	if buf.String() != CONTENTS {
-		t.Error("Contents wrong")
+	if got := headers[swift.UncompressedSizeHeader]; got != strconv.Itoa(len(contents)) {
+		t.Errorf("Bad uncompressed size %q", got)
 	}
-	err = file.Close()
+	if got := headers[swift.UncompressedMD5Header]; got != fmt.Sprintf("%x", md5.Sum([]byte(contents))) {
+		t.Errorf("Bad uncompressed md5 %q", got)
+	}
+
+	got, err := c.ObjectGetStringWithOptions(CONTAINER, OBJECT, swift.WithGzip())
 	if err != nil {
 		t.Fatal(err)
 	}
-}
+	if got != contents {
+		t.Errorf("Bad contents %q", got)
+	}
 
-func TestObjectOpenPartial(t *testing.T) {
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+	// Check the uncompressed metadata is checked
+	err = c.ObjectUpdate(CONTAINER, OBJECT, swift.Headers{swift.UncompressedMD5Header: CONTENT_MD5})
 	if err != nil {
 		t.Fatal(err)
 	}
-	var buf bytes.Buffer
-	n, err := io.CopyN(&buf, file, 1)
+	_, err = c.ObjectGetStringWithOptions(CONTAINER, OBJECT, swift.WithGzip())
+	if err != swift.ObjectCorrupted {
+		t.Errorf("Expecting ObjectCorrupted got %v", err)
+	}
+
+	// A wrong Hash is checked against the uncompressed data
+	_, err = c.ObjectPutWithOpts(CONTAINER, OBJECT, strings.NewReader(contents), &swift.ObjectPutOpts{CheckHash: true, Hash: CONTENT_MD5}, swift.WithGzip())
+	if err != swift.ObjectCorrupted {
+		t.Errorf("Expecting ObjectCorrupted got %v", err)
+	}
+
+	out, err := c.ObjectCreateWithOpts(CONTAINER, OBJECT, &swift.ObjectPutOpts{CheckHash: true}, swift.WithGzip())
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != 1 {
-		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	_, err = io.WriteString(out, contents)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if buf.String() != CONTENTS[:1] {
-		t.Error("Contents wrong")
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
 	}
-	err = file.Close()
+	var buf bytes.Buffer
+	_, err = c.ObjectGetWithOpts(CONTAINER, OBJECT, &buf, &swift.ObjectGetOpts{CheckHash: true, Decompress: true})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if buf.String() != contents {
+		t.Errorf("Bad contents from ObjectCreate %q", buf.String())
+	}
 }
 
-func TestObjectOpenLength(t *testing.T) {
-	c, rollback := makeConnectionWithObject(t)
+func TestObjectPutNormalizeName(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+	c.NormalizeName = func(name string) string {
+		return strings.Replace(name, "e\u0301", "\u00e9", -1)
+	}
+	defer func() { c.NormalizeName = nil }()
+	err := c.ObjectPutString(CONTAINER, "cafe\u0301", CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	// FIXME ideally this would check both branches of the Length() code
-	n, err := file.Length()
+	names, err := c.ObjectNamesAll(CONTAINER, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != CONTENT_SIZE {
-		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	if len(names) != 1 || names[0] != "caf\u00e9" {
+		t.Errorf("Name not normalized %q", names)
 	}
-	err = file.Close()
+	// The object can be read and deleted with the original name
+	contents, err := c.ObjectGetString(CONTAINER, "cafe\u0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Bad contents %q", contents)
+	}
+	err = c.ObjectDelete(CONTAINER, "cafe\u0301")
 	if err != nil {
 		t.Fatal(err)
 	}
+	names, err = c.ObjectNamesAll(CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("Object not deleted %q", names)
+	}
 }
 
-func TestObjectOpenNotModified(t *testing.T) {
+func TestObjectLastModifiedPrecision(t *testing.T) {
 	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	_, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, swift.Headers{
-		"If-None-Match": CONTENT_MD5,
-	})
-	if err != swift.NotModified {
+	objects, err := c.Objects(CONTAINER, &swift.ObjectsOpts{Prefix: OBJECT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("Expecting 1 object got %d", len(objects))
+	}
+	info, _, err := c.Object(CONTAINER, OBJECT)
+	if err != nil {
 		t.Fatal(err)
 	}
+	// Both keep the fractional seconds so they agree exactly
+	if !info.LastModified.Equal(objects[0].LastModified) {
+		t.Errorf("HEAD %v and listing %v disagree", info.LastModified, objects[0].LastModified)
+	}
+	if !info.Timestamp.Equal(info.LastModified) {
+		t.Errorf("Bad Timestamp %v", info.Timestamp)
+	}
 }
 
-func TestObjectOpenSeek(t *testing.T) {
-	c, rollback := makeConnectionWithObject(t)
+func TestObjectPutMimeType(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
+	err := c.ObjectPutString(CONTAINER, "test.jpg", CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, "test.jpg")
+		if err != nil {
+			t.Error(err)
+		}
+	}()
 
-	plan := []struct {
-		whence int
-		offset int64
-		result int64
-	}{
-		{-1, 0, 0},
-		{-1, 0, 1},
-		{-1, 0, 2},
-		{0, 0, 0},
-		{0, 0, 0},
-		{0, 1, 1},
-		{0, 2, 2},
-		{1, 0, 3},
-		{1, -2, 2},
-		{1, 1, 4},
-		{2, -1, 4},
-		{2, -3, 2},
-		{2, -2, 3},
-		{2, -5, 0},
-		{2, -4, 1},
+	info, _, err := c.Object(CONTAINER, "test.jpg")
+	if err != nil {
+		t.Error(err)
+	}
+	if info.ContentType != "image/jpeg" {
+		t.Error("Bad content type", info.ContentType)
 	}
+}
 
-	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+func TestObjectCreate(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	out, err := c.ObjectCreate(CONTAINER, OBJECT2, true, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	buf := &bytes.Buffer{}
+	hash := md5.New()
+	out2 := io.MultiWriter(out, buf, hash)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(out2, "%d %s\n", i, CONTENTS)
+	}
+	// Ensure Headers fails if called prematurely
+	_, err = out.Headers()
+	if err == nil {
+		t.Error("Headers should fail if called before Close()")
+	}
+	err = out.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+
+	// Ensure Headers succeeds when called after a good upload
+	headers, err := out.Headers()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(headers) < 1 {
+		t.Error("The Headers returned by Headers() should not be empty")
+	}
+
+	// Test writing on closed file
+	n, err := out.Write([]byte{0})
+	if err == nil || n != 0 {
+		t.Error("Expecting error and n == 0 writing on closed file", err, n)
+	}
+
+	// Now with hash instead
+	out, err = c.ObjectCreate(CONTAINER, OBJECT2, false, fmt.Sprintf("%x", hash.Sum(nil)), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = out.Write(buf.Bytes())
+	if err != nil {
+		t.Error(err)
+	}
+	err = out.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	contents, err = c.ObjectGetString(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+
+	// Now with bad hash
+	out, err = c.ObjectCreate(CONTAINER, OBJECT2, false, CONTENT_MD5, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FIXME: work around bug which produces 503 not 422 for empty corrupted files
+	fmt.Fprintf(out, "Sausage")
+	err = out.Close()
+	if err != swift.ObjectCorrupted {
+		t.Error("Expecting object corrupted not", err)
+	}
+}
+
+func TestObjectCreateAbort(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	out, err := c.ObjectCreate(CONTAINER, OBJECT2, true, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(CONTAINER, OBJECT2) // Ignore error
+	}()
+
+	expectedContents := "foo"
+	_, err = out.Write([]byte(expectedContents))
+	if err != nil {
+		t.Error(err)
+	}
+
+	errAbort := fmt.Errorf("abort")
+	err = out.CloseWithError(errAbort)
+	if err != nil {
+		t.Errorf("Unexpected error %#v", err)
+	}
+
+	_, err = c.ObjectGetString(CONTAINER, OBJECT2)
+	if err != swift.ObjectNotFound {
+		t.Errorf("Unexpected error: %#v", err)
+	}
+}
+
+func TestObjectGetString(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
+}
+
+func TestObjectGetBytes(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	contents, err := c.ObjectGetBytes(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != CONTENTS {
+		t.Error("Contents wrong")
+	}
+}
+
+func TestObjectOpen(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != CONTENT_SIZE {
+		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	}
+	if buf.String() != CONTENTS {
+		t.Error("Contents wrong")
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectOpenPartial(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	}
+	if buf.String() != CONTENTS[:1] {
+		t.Error("Contents wrong")
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectOpenLength(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FIXME ideally this would check both branches of the Length() code
+	n, err := file.Length()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != CONTENT_SIZE {
+		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectOpenNotModified(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	_, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, swift.Headers{
+		"If-None-Match": CONTENT_MD5,
+	})
+	if err != swift.NotModified {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectOpenSeek(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	plan := []struct {
+		whence int
+		offset int64
+		result int64
+	}{
+		{-1, 0, 0},
+		{-1, 0, 1},
+		{-1, 0, 2},
+		{0, 0, 0},
+		{0, 0, 0},
+		{0, 1, 1},
+		{0, 2, 2},
+		{1, 0, 3},
+		{1, -2, 2},
+		{1, 1, 4},
+		{2, -1, 4},
+		{2, -3, 2},
+		{2, -2, 3},
+		{2, -5, 0},
+		{2, -4, 1},
+	}
+
+	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1415,21 +2423,83 @@ func TestObjectOpenSeekEnd(t *testing.T) {
 	}
 }
 
-func TestObjectUpdate(t *testing.T) {
+func TestObjectOpenSeekPastEnd(t *testing.T) {
 	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	err := c.ObjectUpdate(CONTAINER, OBJECT, m1.ObjectHeaders())
+	_, _, err := c.ObjectOpen(CONTAINER, OBJECT, false, swift.Headers{"Range": fmt.Sprintf("bytes=%d-", CONTENT_SIZE+5)})
+	if err != swift.RangeNotSatisfiable {
+		t.Fatal("Expecting RangeNotSatisfiable got", err)
+	}
+	file, _, err := c.ObjectOpen(CONTAINER, OBJECT, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-}
-
-func checkTime(t *testing.T, when time.Time, low, high int) {
-	dt := time.Now().Sub(when)
-	if dt < time.Duration(low)*time.Second || dt > time.Duration(high)*time.Second {
-		t.Errorf("Time is wrong: dt=%q, when=%q", dt, when)
+	n, err := file.Seek(CONTENT_SIZE+5, 0)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
+	if n != CONTENT_SIZE+5 {
+		t.Fatal("Wrong offset", n)
+	}
+	nn, err := file.Read(make([]byte, 16))
+	if err != io.EOF || nn != 0 {
+		t.Fatal("Expecting EOF got", nn, err)
+	}
+	n, err = file.Seek(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != CONTENTS[1:] {
+		t.Fatal("wrong contents", string(buf))
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectUpdate(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	err := c.ObjectUpdate(CONTAINER, OBJECT, m1.ObjectHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectRemoveMetadata(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	h := m1.ObjectHeaders()
+	h["Content-Disposition"] = "inline"
+	err := c.ObjectUpdate(CONTAINER, OBJECT, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectRemoveMetadata(CONTAINER, OBJECT, "Potato-Salad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Object(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1"})
+	if headers["Content-Disposition"] != "inline" {
+		t.Errorf("Content-Disposition lost %q", headers["Content-Disposition"])
+	}
+}
+
+func checkTime(t *testing.T, when time.Time, low, high int) {
+	dt := time.Now().Sub(when)
+	if dt < time.Duration(low)*time.Second || dt > time.Duration(high)*time.Second {
+		t.Errorf("Time is wrong: dt=%q, when=%q", dt, when)
+	}
+}
 
 func TestObject(t *testing.T) {
 	c, rollback := makeConnectionWithObjectHeaders(t)
@@ -1439,7 +2509,7 @@ func TestObject(t *testing.T) {
 		t.Fatal(err)
 	}
 	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
-	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "application/octet-stream" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
+	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "text/plain; charset=utf-8" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
 		t.Error("Bad object info", object)
 	}
 	checkTime(t, object.LastModified, -10, 10)
@@ -1478,12 +2548,56 @@ func TestContainers(t *testing.T) {
 			if container.Count == 1 && container.Bytes == CONTENT_SIZE {
 				break
 			}
-			t.Errorf("Bad size of Container %q: %q", CONTAINER, container)
+			t.Errorf("Bad size of Container %q: %+v", CONTAINER, container)
 			break
 		}
 	}
 	if !ok {
-		t.Errorf("Didn't find container %q in listing %q", CONTAINER, containers)
+		t.Errorf("Didn't find container %q in listing %+v", CONTAINER, containers)
+	}
+}
+
+func TestContainersPrefixDelimiter(t *testing.T) {
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	names := []string{"tenant1-backups", "tenant1-photos", "tenant2-photos"}
+	for _, name := range names {
+		if err := c.ContainerCreate(name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ContainerDelete(name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	tenant1, err := c.ContainerNames(&swift.ContainersOpts{Prefix: "tenant1-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tenant1, names[:2]) {
+		t.Errorf("expecting %q got %q", names[:2], tenant1)
+	}
+	containers, err := c.Containers(&swift.ContainersOpts{Prefix: "tenant", Delimiter: '-'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expecting 2 containers got %+v", containers)
+	}
+	for i, want := range []string{"tenant1-", "tenant2-"} {
+		if containers[i].Name != want || !containers[i].PseudoDirectory {
+			t.Errorf("expecting pseudo directory %q got %+v", want, containers[i])
+		}
+	}
+	tenants, err := c.ContainerNamesAll(&swift.ContainersOpts{Prefix: "tenant", Delimiter: '-', Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tenants, []string{"tenant1-", "tenant2-"}) {
+		t.Errorf("expecting tenant prefixes got %q", tenants)
 	}
 }
 
@@ -1494,69 +2608,549 @@ func TestObjectNames(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectNamesAll(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectNamesAll(CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectNamesAllWithLimit(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectNamesAll(CONTAINER, &swift.ObjectsOpts{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectsWalk(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects := make([]string, 0)
+	err := c.ObjectsWalk(container, nil, func(opts *swift.ObjectsOpts) (interface{}, error) {
+		newObjects, err := c.ObjectNames(CONTAINER, opts)
+		if err == nil {
+			objects = append(objects, newObjects...)
+		}
+		return newObjects, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectsEach(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("each%d", i)
+		names = append(names, name)
+		if err := c.ObjectPutString(CONTAINER, name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	var got []string
+	err := c.ObjectsEach(CONTAINER, &swift.ObjectsOpts{Limit: 2}, func(object *swift.Object) error {
+		if object.Bytes != CONTENT_SIZE {
+			t.Error("Bad object info", object)
+		}
+		got = append(got, object.Name)
+		if len(got) == 3 {
+			return swift.StopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, names[:3]) {
+		t.Errorf("expecting %q got %q", names[:3], got)
+	}
+	got = nil
+	err = c.ObjectNamesEach(CONTAINER, &swift.ObjectsOpts{Limit: 2}, func(name string) error {
+		got = append(got, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("expecting %q got %q", names, got)
+	}
+	err = c.ObjectNamesEach(CONTAINER, nil, func(name string) error {
+		return swift.ObjectCorrupted
+	})
+	if err != swift.ObjectCorrupted {
+		t.Error("Expecting ObjectCorrupted got", err)
+	}
+}
+
+func TestObjectsFilter(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var names, ready []string
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("filter%d", i)
+		names = append(names, name)
+		h := swift.Headers{"X-Object-Meta-State": "pending"}
+		if i%2 == 0 {
+			h["X-Object-Meta-State"] = "ready"
+			ready = append(ready, name)
+		}
+		if _, err := c.ObjectPut(CONTAINER, name, strings.NewReader(CONTENTS), true, "", "", h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	var got []string
+	filter := &swift.MetadataFilterOpts{Workers: 3, Match: swift.MetadataEquals("State", "ready")}
+	err := c.ObjectsFilter(CONTAINER, &swift.ObjectsOpts{Limit: 2}, filter, func(object *swift.Object, headers swift.Headers) error {
+		if headers["X-Object-Meta-State"] != "ready" {
+			t.Errorf("%s: bad headers %v", object.Name, headers)
+		}
+		got = append(got, object.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ready) {
+		t.Errorf("expecting %q got %q", ready, got)
+	}
+}
+
+func TestObjectIterator(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("iterator%d", i)
+		names = append(names, name)
+		if err := c.ObjectPutString(CONTAINER, name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	var got []string
+	it := c.NewObjectIterator(CONTAINER, &swift.ObjectsOpts{Limit: 2})
+	for {
+		object, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, object.Name)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("expecting %q got %q", names, got)
+	}
+	if _, err := it.Next(); err != io.EOF {
+		t.Errorf("expecting io.EOF got %v", err)
+	}
+
+	found := false
+	containers := c.NewContainerIterator(&swift.ContainersOpts{Limit: 1})
+	for {
+		container, err := containers.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if container.Name == CONTAINER {
+			found = true
+			if container.Count != int64(len(names)) {
+				t.Errorf("Bad container %+v", container)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("container %q not found", CONTAINER)
+	}
+
+	_, err := c.NewObjectIterator("not-a-container", nil).Next()
+	if err != swift.ContainerNotFound {
+		t.Errorf("expecting ContainerNotFound got %v", err)
+	}
+}
+
+func TestObjectsReverse(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("backup-2019-01-%02d", i+1)
+		names = append(names, name)
+		if err := c.ObjectPutString(CONTAINER, name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	reversed := make([]string, len(names))
+	for i, name := range names {
+		reversed[len(names)-1-i] = name
+	}
+	latest, err := c.ObjectNames(CONTAINER, &swift.ObjectsOpts{Reverse: true, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(latest, reversed[:2]) {
+		t.Errorf("expecting %q got %q", reversed[:2], latest)
+	}
+	all, err := c.ObjectNamesAll(CONTAINER, &swift.ObjectsOpts{Reverse: true, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all, reversed) {
+		t.Errorf("expecting %q got %q", reversed, all)
+	}
+	objects, err := c.Objects(CONTAINER, &swift.ObjectsOpts{Reverse: true, Marker: names[3], EndMarker: names[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Name != names[2] || objects[1].Name != names[1] {
+		t.Errorf("expecting %q got %+v", []string{names[2], names[1]}, objects)
+	}
+
+	containers, err := c.ContainerNamesAll(&swift.ContainersOpts{Reverse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sort.IsSorted(sort.Reverse(sort.StringSlice(containers))) {
+		t.Errorf("containers not in reverse order %q", containers)
+	}
+}
+
+func TestObjects(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.Objects(CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatal("Should only be 1 object")
+	}
+	object := objects[0]
+	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "text/plain; charset=utf-8" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
+		t.Error("Bad object info", object)
+	}
+	checkTime(t, object.LastModified, -10, 10)
+}
+
+func TestContainerDiff(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := c.ObjectPutString(CONTAINER, name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range []string{"b", "c", "d"} {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	diff, from, err := c.ContainerDiff(CONTAINER, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 3 || len(diff.Removed) != 0 || len(diff.Modified) != 0 {
+		t.Errorf("Bad initial diff %+v", diff)
+	}
+
+	// Save and restore the listing like a sync tool would
+	data, err := json.Marshal(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from = nil
+	if err = json.Unmarshal(data, &from); err != nil {
+		t.Fatal(err)
+	}
+	if diff, _, err = c.ContainerDiff(CONTAINER, from, nil); err != nil {
+		t.Fatal(err)
+	} else if !diff.Empty() {
+		t.Errorf("Expecting no changes got %+v", diff)
+	}
+
+	if err = c.ObjectDelete(CONTAINER, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.ObjectPutString(CONTAINER, "b", CONTENTS2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.ObjectPutString(CONTAINER, "d", CONTENTS, ""); err != nil {
+		t.Fatal(err)
+	}
+	diff, _, err = c.ContainerDiff(CONTAINER, from, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(objects []swift.Object) (names []string) {
+		for _, object := range objects {
+			names = append(names, object.Name)
+		}
+		return names
+	}
+	if got := names(diff.Added); !reflect.DeepEqual(got, []string{"d"}) {
+		t.Errorf("Bad added %q", got)
+	}
+	if got := names(diff.Removed); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Bad removed %q", got)
+	}
+	if got := names(diff.Modified); !reflect.DeepEqual(got, []string{"b"}) || diff.Modified[0].Hash != CONTENT2_MD5 {
+		t.Errorf("Bad modified %+v", diff.Modified)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	w := c.Watch(CONTAINER, 10*time.Millisecond, &swift.WatchOpts{Initial: true})
+	defer w.Stop()
+	next := func() swift.WatchEvent {
+		select {
+		case event := <-w.Events:
+			if event.Err != nil {
+				t.Fatal(event.Err)
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout waiting for event")
+		}
+		return swift.WatchEvent{}
+	}
+	if event := next(); event.Type != swift.ObjectAdded || event.Object.Name != OBJECT {
+		t.Errorf("Expecting initial object got %+v", event)
+	}
+	err := c.ObjectPutString(CONTAINER, OBJECT2, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Type != swift.ObjectAdded || event.Object.Name != OBJECT2 {
+		t.Errorf("Expecting %q added got %+v", OBJECT2, event)
+	}
+	err = c.ObjectDelete(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Type != swift.ObjectRemoved || event.Object.Name != OBJECT2 {
+		t.Errorf("Expecting %q removed got %+v", OBJECT2, event)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	ns := c.Namespace(CONTAINER, "tenant")
+	if ns.Prefix() != "tenant/" || ns.Container() != CONTAINER {
+		t.Errorf("Bad namespace %q %q", ns.Container(), ns.Prefix())
+	}
+	for _, name := range []string{"a", "dir/b"} {
+		if err := ns.ObjectPutString(name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.ObjectPutString(CONTAINER, "tenant10/c", CONTENTS, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, name := range []string{"tenant/a", "tenant/dir/b", "tenant10/c"} {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	names, err := ns.ObjectNamesAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "dir/b"}) {
+		t.Errorf("Bad names %q", names)
+	}
+	objects, err := ns.Objects(&swift.ObjectsOpts{Delimiter: '/'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Name != "a" || objects[1].Name != "dir/" || !objects[1].PseudoDirectory {
+		t.Errorf("Bad objects %+v", objects)
+	}
+	info, _, err := ns.Object("dir/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "dir/b" {
+		t.Errorf("Bad name %q", info.Name)
+	}
+	contents, err := ns.ObjectGetString("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Bad contents %q", contents)
+	}
+
+	for _, name := range []string{"", "../tenant10/c", "dir/../../x", "./a"} {
+		if _, err := ns.ObjectGetString(name); err != swift.NamespaceEscape {
+			t.Errorf("%q: expecting NamespaceEscape got %v", name, err)
+		}
+	}
+}
+
+func TestObjectsInto(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	var objects []struct {
+		swift.Object
+		Raw string `json:"last_modified"`
+	}
+	err := c.ObjectsInto(CONTAINER, nil, &objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatal("Should only be 1 object")
+	}
+	object := objects[0]
+	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.Hash != CONTENT_MD5 || object.Raw == "" {
+		t.Error("Bad object info", object)
+	}
+	var containers []map[string]interface{}
+	err = c.ContainersInto(&swift.ContainersOpts{Prefix: CONTAINER}, &containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) < 1 || containers[0]["name"] != CONTAINER || containers[0]["count"] != float64(1) {
+		t.Error("Bad container info", containers)
+	}
+}
+
+func TestDirHelpers(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	for _, dir := range []string{"top", "top/empty/"} {
+		if err := c.MkDir(CONTAINER, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"top/file", "top/sub/file"} {
+		if err := c.ObjectPutString(CONTAINER, name, CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range []string{"top/file", "top/sub/file", "top"} {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	for dir, want := range map[string]bool{"top": true, "top/empty": true, "top/sub/": true, "top/file": false, "missing": false} {
+		isDir, err := c.IsDir(CONTAINER, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isDir != want {
+			t.Errorf("IsDir(%q) expecting %v got %v", dir, want, isDir)
+		}
+	}
+
+	objects, dirs, err := c.ListDir(CONTAINER, "top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != "top/file" {
+		t.Errorf("Bad objects %+v", objects)
 	}
-}
-
-func TestObjectNamesAll(t *testing.T) {
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectNamesAll(CONTAINER, nil)
+	if !reflect.DeepEqual(dirs, []string{"top/empty", "top/sub"}) {
+		t.Errorf("Bad dirs %q", dirs)
+	}
+	_, dirs, err = c.ListDir(CONTAINER, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	if !reflect.DeepEqual(dirs, []string{"top"}) {
+		t.Errorf("Bad top level dirs %q", dirs)
 	}
-}
 
-func TestObjectNamesAllWithLimit(t *testing.T) {
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectNamesAll(CONTAINER, &swift.ObjectsOpts{Limit: 1})
+	result, err := c.ObjectsListDirAll(CONTAINER, &swift.ObjectsOpts{Prefix: "top/", Limit: 1})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	if len(result.Objects) != 2 || result.Objects[0].Name != "top/empty" || result.Objects[1].Name != "top/file" {
+		t.Errorf("Bad objects %+v", result.Objects)
 	}
-}
-
-func TestObjectsWalk(t *testing.T) {
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects := make([]string, 0)
-	err := c.ObjectsWalk(container, nil, func(opts *swift.ObjectsOpts) (interface{}, error) {
-		newObjects, err := c.ObjectNames(CONTAINER, opts)
-		if err == nil {
-			objects = append(objects, newObjects...)
-		}
-		return newObjects, err
-	})
+	if !reflect.DeepEqual(result.SubDirs, []string{"top/sub/"}) {
+		t.Errorf("Bad sub dirs %q", result.SubDirs)
+	}
+	result, err = c.ObjectsListDir(CONTAINER, &swift.ObjectsOpts{Prefix: "top/", Marker: "top/file"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	if len(result.Objects) != 0 || !reflect.DeepEqual(result.SubDirs, []string{"top/sub/"}) {
+		t.Errorf("Bad first page %+v", result)
 	}
-}
 
-func TestObjects(t *testing.T) {
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.Objects(CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
-	if err != nil {
-		t.Fatal(err)
+	if err = c.RmDir(CONTAINER, "top"); err != swift.DirNotEmpty {
+		t.Errorf("Expecting DirNotEmpty got %v", err)
 	}
-	if len(objects) != 1 {
-		t.Fatal("Should only be 1 object")
+	if err = c.RmDir(CONTAINER, "top/empty"); err != nil {
+		t.Error(err)
 	}
-	object := objects[0]
-	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "application/octet-stream" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
-		t.Error("Bad object info", object)
+	if err = c.RmDir(CONTAINER, "top/empty"); err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
 	}
-	checkTime(t, object.LastModified, -10, 10)
 }
 
 func TestObjectsDirectory(t *testing.T) {
@@ -1663,6 +3257,45 @@ func TestObjectsAllWithLimit(t *testing.T) {
 	}
 }
 
+func TestObjectsAllServerLimit(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate the listing limit.")
+		return
+	}
+	srv.ListingLimit = 3
+	defer func() { srv.ListingLimit = 0 }()
+	const n = 7
+	for i := 0; i < n; i++ {
+		if err := c.ObjectPutString(CONTAINER, fmt.Sprintf("%s%d", OBJECT, i), CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for i := 0; i < n; i++ {
+			if err := c.ObjectDelete(CONTAINER, fmt.Sprintf("%s%d", OBJECT, i)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	// The server returns fewer objects than asked for on each page
+	objects, err := c.ObjectsAll(CONTAINER, &swift.ObjectsOpts{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != n {
+		t.Errorf("Expecting %d objects got %d", n, len(objects))
+	}
+	names, err := c.ObjectNamesAll(CONTAINER, &swift.ObjectsOpts{Prefix: OBJECT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != n {
+		t.Errorf("Expecting %d names got %d", n, len(names))
+	}
+}
+
 func TestObjectNamesWithPath(t *testing.T) {
 	c, rollback := makeConnectionWithObjectHeaders(t)
 	defer rollback()
@@ -1683,6 +3316,58 @@ func TestObjectNamesWithPath(t *testing.T) {
 	}
 }
 
+func TestPrefixStats(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{"a/1", "a/b/2", "a/b/c/3", "d/4", "5"}
+	for _, name := range names {
+		err := c.ObjectPutString(CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			err := c.ObjectDelete(CONTAINER, name)
+			if err != nil {
+				t.Error(err)
+			}
+		}(name)
+	}
+	stats, err := c.PrefixStats(CONTAINER, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []swift.PrefixStat{
+		{Prefix: "", Objects: 5, Bytes: 5 * CONTENT_SIZE},
+		{Prefix: "a/", Objects: 3, Bytes: 3 * CONTENT_SIZE},
+		{Prefix: "a/b/", Objects: 2, Bytes: 2 * CONTENT_SIZE},
+		{Prefix: "d/", Objects: 1, Bytes: CONTENT_SIZE},
+	}
+	if !reflect.DeepEqual(stats.Prefixes, expected) {
+		t.Errorf("Bad stats, expecting %+v got %+v", expected, stats.Prefixes)
+	}
+	if stat, ok := stats.Get("a/b/"); !ok || stat.Objects != 2 {
+		t.Errorf("Bad Get %+v", stat)
+	}
+
+	err = c.PrefixStatsSave(CONTAINER, ".stats", stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ObjectDelete(CONTAINER, ".stats")
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	loaded, err := c.PrefixStatsLoad(CONTAINER, ".stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Prefixes, expected) || loaded.Depth != 2 {
+		t.Errorf("Bad loaded stats %+v", loaded)
+	}
+}
+
 func TestObjectCopy(t *testing.T) {
 	c, rollback := makeConnectionWithObjectHeaders(t)
 	defer rollback()
@@ -1690,69 +3375,277 @@ func TestObjectCopy(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = c.ObjectDelete(CONTAINER, OBJECT2)
+	err = c.ObjectDelete(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectCopyDifficultName(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	const dest = OBJECT + "?param %30%31%32 £100"
+	_, err := c.ObjectCopy(CONTAINER, OBJECT, CONTAINER, dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectDelete(CONTAINER, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectCopyWithMetadata(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	m := swift.Metadata{}
+	m["copy-special-metadata"] = "hello"
+	m["hello"] = "9"
+	h := m.ObjectHeaders()
+	h["Content-Type"] = "image/jpeg"
+	_, err := c.ObjectCopy(CONTAINER, OBJECT, CONTAINER, OBJECT2, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Re-read the metadata to see if it is correct
+	_, headers, err := c.Object(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["Content-Type"] != "image/jpeg" {
+		t.Error("Didn't change content type")
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "9", "potato-salad": "2", "copy-special-metadata": "hello"})
+}
+
+func TestObjectCopyMethods(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	defer func() {
+		c.CopyMethod = swift.CopyMethodAuto
+	}()
+	storageUrl, _ := url.Parse(c.StorageUrl)
+	account := path.Base(storageUrl.Path)
+	for _, method := range []swift.CopyMethod{swift.CopyMethodAuto, swift.CopyMethodCOPY, swift.CopyMethodPUT} {
+		for _, dstAccount := range []string{"", account} {
+			c.CopyMethod = method
+			h := swift.Metadata{"copy-special-metadata": "hello"}.ObjectHeaders()
+			_, err := c.ObjectCopyAccount(CONTAINER, OBJECT, dstAccount, CONTAINER, OBJECT2, h)
+			if err != nil {
+				t.Fatalf("method %d account %q: %v", method, dstAccount, err)
+			}
+			contents, err := c.ObjectGetString(CONTAINER, OBJECT2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contents != CONTENTS {
+				t.Errorf("method %d account %q: contents wrong %q", method, dstAccount, contents)
+			}
+			_, headers, err := c.Object(CONTAINER, OBJECT2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2", "copy-special-metadata": "hello"})
+			err = c.ObjectDelete(CONTAINER, OBJECT2)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestObjectCopyInfoFails(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate /info failing.")
+		return
+	}
+	infos := 0
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		infos++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer srv.UnsetOverride("/info")
+
+	for i := 0; i < 2; i++ {
+		_, err := c.ObjectCopy(CONTAINER, OBJECT, CONTAINER, OBJECT2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := c.ObjectDelete(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if infos != 1 {
+		t.Errorf("Expecting /info to be read once got %d", infos)
+	}
+}
+
+func TestObjectNamesAllInfoFails(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate /info failing.")
+		return
+	}
+	names := []string{"a", "b", "c"}
+	for _, name := range names {
+		err := c.ObjectPutString(CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}(name)
+	}
+	infos := 0
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		infos++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer srv.UnsetOverride("/info")
+
+	// The short last page is taken as the end of the listing
+	for i := 0; i < 2; i++ {
+		got, err := c.ObjectNamesAll(CONTAINER, &swift.ObjectsOpts{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, names) {
+			t.Errorf("Expecting %q got %q", names, got)
+		}
+	}
+	if infos != 1 {
+		t.Errorf("Expecting /info to be read once got %d", infos)
+	}
+}
+
+func TestObjectRewriteHeaders(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	h := swift.Headers{
+		"Content-Type":        "text/potato",
+		"Content-Disposition": "attachment",
+		"X-Object-Meta-New":   "1",
+	}
+	err := c.ObjectRewriteHeaders(CONTAINER, OBJECT, h, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Object(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["Content-Type"] != "text/potato" || headers["Content-Disposition"] != "attachment" {
+		t.Errorf("Headers not rewritten %v", headers)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2", "new": "1"})
+
+	err = c.ObjectRewriteHeaders(CONTAINER, OBJECT, swift.Headers{"X-Object-Meta-Fresh": "2"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.Object(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"fresh": "2"})
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Contents changed %q", contents)
+	}
+}
+
+func TestObjectMove(t *testing.T) {
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectMove(CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
+	_, _, err = c.Object(CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ObjectMove(CONTAINER, OBJECT2, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT2)
+	_, headers, err := c.Object(CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
 }
 
-func TestObjectCopyDifficultName(t *testing.T) {
-	c, rollback := makeConnectionWithObjectHeaders(t)
+func TestObjectMoveToItself(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	const dest = OBJECT + "?param %30%31%32 £100"
-	_, err := c.ObjectCopy(CONTAINER, OBJECT, CONTAINER, dest, nil)
+	err := c.ObjectMove(CONTAINER, OBJECT, CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = c.ObjectDelete(CONTAINER, dest)
+	_, _, err = c.Object(CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func TestObjectCopyWithMetadata(t *testing.T) {
-	c, rollback := makeConnectionWithObjectHeaders(t)
+func TestObjectMoveToItselfNormalizeName(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	m := swift.Metadata{}
-	m["copy-special-metadata"] = "hello"
-	m["hello"] = "9"
-	h := m.ObjectHeaders()
-	h["Content-Type"] = "image/jpeg"
-	_, err := c.ObjectCopy(CONTAINER, OBJECT, CONTAINER, OBJECT2, h)
+	c.NormalizeName = func(name string) string {
+		return strings.Replace(name, "e\u0301", "\u00e9", -1)
+	}
+	defer func() { c.NormalizeName = nil }()
+	err := c.ObjectPutString(CONTAINER, "caf\u00e9", CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.ObjectDelete(CONTAINER, OBJECT2)
+		err = c.ObjectDelete(CONTAINER, "caf\u00e9")
 		if err != nil {
 			t.Fatal(err)
 		}
 	}()
-	// Re-read the metadata to see if it is correct
-	_, headers, err := c.Object(CONTAINER, OBJECT2)
+	err = c.ObjectMove(CONTAINER, "cafe\u0301", CONTAINER, "caf\u00e9")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if headers["Content-Type"] != "image/jpeg" {
-		t.Error("Didn't change content type")
+	contents, err := c.ObjectGetString(CONTAINER, "caf\u00e9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Bad contents %q", contents)
 	}
-	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "9", "potato-salad": "2", "copy-special-metadata": "hello"})
 }
 
-func TestObjectMove(t *testing.T) {
+func TestObjectRename(t *testing.T) {
 	c, rollback := makeConnectionWithObjectHeaders(t)
 	defer rollback()
-	err := c.ObjectMove(CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	err := c.ObjectRename(CONTAINER, OBJECT, OBJECT2)
 	if err != nil {
 		t.Fatal(err)
 	}
 	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
-	_, _, err = c.Object(CONTAINER, OBJECT2)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	err = c.ObjectMove(CONTAINER, OBJECT2, CONTAINER, OBJECT)
+	err = c.ObjectRename(CONTAINER, OBJECT2, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1916,6 +3809,177 @@ func TestVersionDeleteContent(t *testing.T) {
 	}
 }
 
+func TestVersionObjectRestore(t *testing.T) {
+	c, rollback := makeConnectionWithVersionsObject(t)
+	defer rollback()
+	if skipVersionTests {
+		t.Log("Server doesn't support Versions - skipping test")
+		return
+	}
+	list, err := c.VersionObjectList(VERSIONS_CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Expecting 2 versions got %d", len(list))
+	}
+	name, err := swift.VersionObjectName(list[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != OBJECT {
+		t.Errorf("Expecting version of %q got %q", OBJECT, name)
+	}
+	if err := c.VersionObjectRestore(CURRENT_CONTAINER, VERSIONS_CONTAINER, list[0]); err != nil {
+		t.Fatal(err)
+	}
+	if contents, err := c.ObjectGetString(CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	} else if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
+	// The overwritten version is archived too
+	list, err = c.VersionObjectList(VERSIONS_CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Errorf("Expecting 3 versions got %d", len(list))
+	}
+	for _, name := range list {
+		if err := c.ObjectDelete(VERSIONS_CONTAINER, name); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := swift.VersionObjectName("00fshort/1"); err == nil {
+		t.Error("Expecting error from invalid version name")
+	}
+}
+
+func TestHistoryEnable(t *testing.T) {
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	for _, container := range []string{CURRENT_CONTAINER, VERSIONS_CONTAINER} {
+		if err := c.ContainerCreate(container, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		c.ContainerDelete(CURRENT_CONTAINER)
+		c.ContainerDelete(VERSIONS_CONTAINER)
+	}()
+	err := c.HistoryEnable(CURRENT_CONTAINER, VERSIONS_CONTAINER)
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support History - skipping test")
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ObjectPutString(CURRENT_CONTAINER, OBJECT, CONTENTS, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ObjectDelete(CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	}
+	// Deleted in the current container but kept in the history
+	if _, _, err := c.Object(CURRENT_CONTAINER, OBJECT); err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
+	}
+	list, err := c.VersionObjectList(VERSIONS_CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("Expecting 1 version got %d", len(list))
+	}
+	if err := c.VersionObjectRestore(CURRENT_CONTAINER, VERSIONS_CONTAINER, list[0]); err != nil {
+		t.Fatal(err)
+	}
+	if contents, err := c.ObjectGetString(CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	} else if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
+	if err := c.HistoryDisable(CURRENT_CONTAINER); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range list {
+		if err := c.ObjectDelete(VERSIONS_CONTAINER, name); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := c.ObjectDelete(CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestObjectVersions(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	err := c.ContainerVersioningEnable(CONTAINER)
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support X-Versions-Enabled - skipping test")
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ContainerVersioningDisable(CONTAINER)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	for _, contents := range []string{CONTENTS, CONTENTS2} {
+		if err := c.ObjectPutString(CONTAINER, OBJECT, contents, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := c.ObjectVersions(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, version := range versions {
+			_, _, _ = c.Call(c.StorageUrl, swift.RequestOpts{
+				Container:  CONTAINER,
+				ObjectName: OBJECT,
+				Operation:  "DELETE",
+				Parameters: url.Values{"version-id": {version.VersionId}},
+				NoResponse: true,
+			})
+		}
+	}()
+	if len(versions) != 2 {
+		t.Fatalf("Expecting 2 versions got %d", len(versions))
+	}
+	if !versions[0].IsLatest || versions[1].IsLatest {
+		t.Error("IsLatest wrong")
+	}
+	var buf bytes.Buffer
+	if _, err := c.ObjectGetVersion(CONTAINER, OBJECT, versions[1].VersionId, &buf, true); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != CONTENTS {
+		t.Error("Contents wrong")
+	}
+	if err := c.ObjectRestoreVersion(CONTAINER, OBJECT, versions[1].VersionId); err != nil {
+		t.Fatal(err)
+	}
+	if contents, err := c.ObjectGetString(CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	} else if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
+	// Refresh the versions so they all get cleaned up
+	versions, err = c.ObjectVersions(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Errorf("Expecting 3 versions got %d", len(versions))
+	}
+}
+
 // Check for non existence after delete
 // May have to do it a few times to wait for swift to be consistent.
 func testExistenceAfterDelete(t *testing.T, c *swift.Connection, container, object string) {
@@ -1945,38 +4009,253 @@ func TestObjectDelete(t *testing.T) {
 	}
 }
 
-func TestBulkDelete(t *testing.T) {
+func TestInvalidNames(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	// An empty object name mustn't delete the container
+	err := c.ObjectDelete(CONTAINER, "")
+	if !errors.Is(err, swift.InvalidName) {
+		t.Fatalf("Expecting InvalidName got %v", err)
+	}
+	_, _, err = c.Container(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ContainerCreate("a/b", nil)
+	if !errors.Is(err, swift.InvalidName) {
+		t.Errorf("Expecting InvalidName got %v", err)
+	}
+	err = c.ObjectPutString(CONTAINER, strings.Repeat("a", 1025), CONTENTS, "")
+	if !errors.Is(err, swift.InvalidName) {
+		t.Errorf("Expecting InvalidName got %v", err)
+	}
+}
+
+func TestBulkDelete(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	result, err := c.BulkDelete(CONTAINER, []string{OBJECT})
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support BulkDelete - skipping test")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+	if result.NumberDeleted != 0 {
+		t.Error("Expected 0, actual:", result.NumberDeleted)
+	}
+	err = c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = c.BulkDelete(CONTAINER, []string{OBJECT2, OBJECT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+	if result.NumberDeleted != 1 {
+		t.Error("Expected 1, actual:", result.NumberDeleted)
+	}
+	t.Log("Errors:", result.Errors)
+}
+
+func TestObjectsDelete(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	const n = 7
+	for i := 0; i < n; i++ {
+		if err := c.ObjectPutString(CONTAINER, fmt.Sprintf("dir/%d", i), CONTENTS, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	result, err := c.ObjectsDelete(CONTAINER, "dir/", &swift.ObjectsDeleteOpts{Workers: 3, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberDeleted != n {
+		t.Errorf("Expecting %d deleted got %d", n, result.NumberDeleted)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Unexpected errors %v", result.Errors)
+	}
+	names, err := c.ObjectNamesAll(CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != OBJECT {
+		t.Errorf("Expecting only %q left got %q", OBJECT, names)
+	}
+}
+
+func TestExpiredObjectsDelete(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as Swift won't accept an X-Delete-At in the past.")
+		return
+	}
+	past := swift.Headers{}
+	past.SetDeleteAt(time.Now().Add(-time.Minute))
+	future := swift.Headers{}
+	future.SetDeleteAt(time.Now().Add(time.Hour))
+	objects := []struct {
+		name    string
+		h       swift.Headers
+		expired bool
+	}{
+		{"expired1", past, true},
+		{"expired2", past, true},
+		{"future", future, false},
+		{"forever", nil, false},
+		{"missing", nil, false},
+	}
+	// An object which returns 404 without an X-Delete-At, like one
+	// deleted but still in the listings
+	missingURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/missing"
+	srv.SetOverride(missingURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range recorder.HeaderMap {
+			w.Header().Set(k, v[0])
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(missingURL)
+	for _, o := range objects {
+		_, err := c.ObjectPut(CONTAINER, o.name, strings.NewReader(CONTENTS), true, "", "", o.h)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, o := range objects {
+			if !o.expired {
+				if err := c.ObjectDelete(CONTAINER, o.name); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}()
+	want := swift.ExpiredObjectsResult{
+		Expired:  []string{"expired1", "expired2"},
+		NotFound: []string{"missing"},
+	}
+	for _, dryRun := range []bool{true, false} {
+		result, err := c.ExpiredObjectsDelete(CONTAINER, &swift.ExpiredObjectsOpts{DryRun: dryRun, Workers: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("dryRun=%v: expecting %q got %q", dryRun, want, result)
+		}
+		names, err := c.ObjectNamesAll(CONTAINER, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantNames := 3
+		if dryRun {
+			wantNames = 5
+		}
+		if len(names) != wantNames {
+			t.Errorf("dryRun=%v: expecting %d objects listed got %q", dryRun, wantNames, names)
+		}
+	}
+}
+
+func TestObjectsRewrite(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	result, err := c.BulkDelete(CONTAINER, []string{OBJECT})
-	if err == swift.Forbidden {
-		t.Log("Server doesn't support BulkDelete - skipping test")
-		return
+	names := []string{"rewrite/1", "rewrite/2", "rewrite/3"}
+	for _, name := range append(names, "other") {
+		err := c.ObjectPutString(CONTAINER, name, CONTENTS, "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			if err := c.ObjectDelete(CONTAINER, name); err != nil {
+				t.Error(err)
+			}
+		}(name)
 	}
-	if err != nil {
-		t.Fatal(err)
+	failed := fmt.Errorf("failed")
+	double := func(object *swift.Object, headers swift.Headers, contents io.Reader) (io.Reader, swift.Headers, error) {
+		if object.Name == names[1] && headers["X-Object-Meta-Key"] == "" {
+			return nil, nil, failed
+		}
+		data, err := ioutil.ReadAll(contents)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewReader(bytes.Repeat(data, 2)), swift.Headers{"X-Object-Meta-Key": "2"}, nil
+	}
+	var done []string
+	opts := &swift.RewriteOpts{
+		Prefix: "rewrite/",
+		Checkpoint: func(name string) error {
+			done = append(done, name)
+			return nil
+		},
 	}
-	if result.NumberNotFound != 1 {
-		t.Error("Expected 1, actual:", result.NumberNotFound)
+
+	// Stop part way through then change the metadata only of the
+	// object that failed and resume
+	err := c.ObjectsRewrite(CONTAINER, opts, double)
+	if err != failed {
+		t.Fatalf("Expecting failed got %v", err)
 	}
-	if result.NumberDeleted != 0 {
-		t.Error("Expected 0, actual:", result.NumberDeleted)
+	if !reflect.DeepEqual(done, names[:1]) {
+		t.Fatalf("Expecting %q done got %q", names[:1], done)
 	}
-	err = c.ObjectPutString(CONTAINER, OBJECT, CONTENTS, "")
+	err = c.ObjectsRewrite(CONTAINER, &swift.RewriteOpts{Prefix: names[1]}, func(object *swift.Object, headers swift.Headers, contents io.Reader) (io.Reader, swift.Headers, error) {
+		return nil, swift.Headers{"X-Object-Meta-Key": "1"}, nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	result, err = c.BulkDelete(CONTAINER, []string{OBJECT2, OBJECT})
+	opts.Marker = done[len(done)-1]
+	err = c.ObjectsRewrite(CONTAINER, opts, double)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if result.NumberNotFound != 1 {
-		t.Error("Expected 1, actual:", result.NumberNotFound)
+	if !reflect.DeepEqual(done, names) {
+		t.Errorf("Expecting %q done got %q", names, done)
 	}
-	if result.NumberDeleted != 1 {
-		t.Error("Expected 1, actual:", result.NumberDeleted)
+
+	for _, name := range append(names, "other") {
+		contents, err := c.ObjectGetString(CONTAINER, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, headers, err := c.Object(CONTAINER, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantKey := CONTENTS+CONTENTS, "2"
+		if name == "other" {
+			want, wantKey = CONTENTS, ""
+		}
+		if contents != want || headers["X-Object-Meta-Key"] != wantKey || headers["Content-Type"] != "text/plain" {
+			t.Errorf("%s: bad contents %q or headers %v", name, contents, headers)
+		}
 	}
-	t.Log("Errors:", result.Errors)
 }
 
 func TestBulkUpload(t *testing.T) {
@@ -2037,6 +4316,65 @@ func TestBulkUpload(t *testing.T) {
 	}
 }
 
+func TestBulkUploadTarGzip(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var files = []struct{ Name, Body string }{
+		{OBJECT, CONTENTS},
+		{OBJECT2, CONTENTS2},
+	}
+	// Stream the archive as it is made
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		ds := tar.NewWriter(gz)
+		for _, file := range files {
+			hdr := &tar.Header{
+				Name: file.Name,
+				Size: int64(len(file.Body)),
+			}
+			if err := ds.WriteHeader(hdr); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+			if _, err := ds.Write([]byte(file.Body)); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		err := ds.Close()
+		if err == nil {
+			err = gz.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	result, err := c.BulkUpload(CONTAINER+"/dir", pr, swift.UploadTarGzip, nil)
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support BulkUpload - skipping test")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberCreated != 2 {
+		t.Error("Expected 2, actual:", result.NumberCreated)
+	}
+	for _, file := range files {
+		name := "dir/" + file.Name
+		contents, err := c.ObjectGetString(CONTAINER, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if contents != file.Body {
+			t.Errorf("%s: contents wrong %q", name, contents)
+		}
+		err = c.ObjectDelete(CONTAINER, name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestObjectDifficultName(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
@@ -2067,6 +4405,193 @@ func TestObjectDifficultName(t *testing.T) {
 	}
 }
 
+func TestTempUrlPrefix(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	objects := []string{"dir/a", "dir/sub/b £"}
+	for _, objectName := range append(objects, "other") {
+		err := c.ObjectPutString(CONTAINER, objectName, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(objectName string) {
+			err := c.ObjectDelete(CONTAINER, objectName)
+			if err != nil {
+				t.Error(err)
+			}
+		}(objectName)
+	}
+	err := c.AccountSetTempUrlKeys(swift.TempUrlKeys{Key: SECRET_KEY})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := c.TempUrlPrefix(CONTAINER, "dir/", SECRET_KEY, "GET", time.Now().Add(20*time.Minute))
+	for _, objectName := range objects {
+		tempUrl, err := p.ObjectUrl(objectName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Get(tempUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == 401 {
+			t.Log("Server doesn't support prefix tempurl")
+			return
+		} else if resp.StatusCode != 200 {
+			t.Errorf("%q: HTTP Error retrieving file from temporary url: %d", objectName, resp.StatusCode)
+		} else if err != nil || string(content) != CONTENTS {
+			t.Errorf("%q: Bad content %q: %v", objectName, content, err)
+		}
+	}
+	_, err = p.ObjectUrl("other")
+	if err == nil {
+		t.Error("Expecting error for object outside prefix")
+	}
+}
+
+func TestVerifyTempUrl(t *testing.T) {
+	c := &swift.Connection{StorageUrl: "https://example.com/v1/AUTH_test"}
+	expires := time.Now().Add(20 * time.Minute)
+	tempUrl := c.ObjectTempUrl(CONTAINER, OBJECT, SECRET_KEY, "PUT", expires)
+	prefixUrl, err := c.TempUrlPrefix(CONTAINER, "dir/", SECRET_KEY, "GET", expires).ObjectUrl("dir/sub/b £")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make a SHA-256 signed URL with an ISO 8601 expiry
+	mac := hmac.New(sha256.New, []byte(SECRET_KEY))
+	fmt.Fprintf(mac, "GET\n%d\n/v1/AUTH_test/%s/%s", expires.Unix(), CONTAINER, OBJECT)
+	sha256Url := fmt.Sprintf("%s/%s/%s?temp_url_sig=sha256:%s&temp_url_expires=%s", c.StorageUrl, CONTAINER, OBJECT,
+		base64.URLEncoding.EncodeToString(mac.Sum(nil)), expires.UTC().Format(time.RFC3339))
+	for _, test := range []struct {
+		tempUrl string
+		key     string
+		method  string
+		err     error
+	}{
+		{tempUrl, SECRET_KEY, "PUT", nil},
+		{prefixUrl, SECRET_KEY, "GET", nil},
+		{sha256Url, SECRET_KEY, "GET", nil},
+		{tempUrl, "wrong key", "", swift.TempUrlInvalid},
+		{strings.Replace(tempUrl, OBJECT, "other", 1), SECRET_KEY, "", swift.TempUrlInvalid},
+		{strings.Replace(prefixUrl, "dir/sub", "other", 1), SECRET_KEY, "", swift.TempUrlInvalid},
+		{c.StorageUrl + "/" + CONTAINER + "/" + OBJECT, SECRET_KEY, "", swift.TempUrlInvalid},
+		{c.ObjectTempUrl(CONTAINER, OBJECT, SECRET_KEY, "GET", time.Now().Add(-time.Minute)), SECRET_KEY, "GET", swift.TempUrlExpired},
+	} {
+		method, err := swift.VerifyTempUrl(test.tempUrl, test.key)
+		if method != test.method || err != test.err {
+			t.Errorf("%q: expecting %q, %v got %q, %v", test.tempUrl, test.method, test.err, method, err)
+		}
+	}
+}
+
+func TestTempUrlKeyRotation(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	err := c.AccountSetTempUrlKeys(swift.TempUrlKeys{Key: SECRET_KEY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.AccountSetTempUrlKeys(swift.TempUrlKeys{})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	const newKey = "new" + SECRET_KEY
+	oldKey, err := c.AccountRotateTempUrlKey(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldKey != SECRET_KEY {
+		t.Errorf("Wrong old key %q", oldKey)
+	}
+	keys, err := c.AccountTempUrlKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys != (swift.TempUrlKeys{Key: newKey, Key2: SECRET_KEY}) {
+		t.Errorf("Wrong keys after rotation %+v", keys)
+	}
+	for _, key := range []string{SECRET_KEY, newKey} {
+		tempUrl := c.ObjectTempUrl(CONTAINER, OBJECT, key, "GET", time.Now().Add(20*time.Minute))
+		resp, err := http.Get(tempUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == 401 {
+			t.Log("Server doesn't support tempurl")
+		} else if resp.StatusCode != 200 {
+			t.Errorf("key %q: HTTP Error retrieving file from temporary url: %d", key, resp.StatusCode)
+		}
+	}
+
+	keys, err = c.ContainerTempUrlKeys(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys != (swift.TempUrlKeys{}) {
+		t.Errorf("Expecting no container keys got %+v", keys)
+	}
+	_, err = c.ContainerRotateTempUrlKey(CONTAINER, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err = c.ContainerTempUrlKeys(CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys != (swift.TempUrlKeys{Key: newKey}) {
+		t.Errorf("Wrong container keys after rotation %+v", keys)
+	}
+}
+
+func TestAnonymousConnection(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	anon := swift.NewAnonymousConnection(c.StorageUrl)
+	if _, err := anon.ObjectGetString(CONTAINER, OBJECT); err == nil {
+		t.Error("Expecting access to private container to fail")
+	}
+
+	err := c.ContainerUpdate(CONTAINER, swift.Headers{"X-Container-Read": ".r:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := anon.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Contents wrong, expected %q, got %q", CONTENTS, contents)
+	}
+	if _, _, err = anon.Object(CONTAINER, OBJECT); err != nil {
+		t.Error(err)
+	}
+	if _, err = anon.ObjectNames(CONTAINER, nil); err == nil {
+		t.Error("Expecting listing to fail without .rlistings")
+	}
+	if err = anon.ObjectPutString(CONTAINER, OBJECT2, CONTENTS, ""); err == nil {
+		t.Error("Expecting upload to fail")
+	}
+
+	err = c.ContainerUpdate(CONTAINER, swift.Headers{"X-Container-Read": ".r:*,.rlistings"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := anon.ObjectNamesAll(CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{OBJECT}) {
+		t.Errorf("expecting %q got %q", []string{OBJECT}, names)
+	}
+}
+
 func TestTempUrl(t *testing.T) {
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
@@ -2116,6 +4641,97 @@ func TestTempUrl(t *testing.T) {
 	}
 }
 
+func TestFormPost(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	m := swift.Metadata{}
+	m["temp-url-key"] = SECRET_KEY
+	err := c.ContainerUpdate(CONTAINER, m.ContainerHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &swift.FormPostOpts{
+		MaxFileSize:  CONTENT_SIZE,
+		MaxFileCount: 1,
+		Expires:      time.Now().Add(20 * time.Minute),
+	}
+	files := []swift.FormPostFile{{Name: OBJECT, Contents: strings.NewReader(CONTENTS)}}
+	// The StorageUrl is found by authenticating
+	c.UnAuthenticate()
+	err = c.FormPost(CONTAINER, "upload/", SECRET_KEY, opts, files)
+	if err != nil {
+		if srv == nil {
+			t.Skipf("Server doesn't support formpost: %v", err)
+		}
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, "upload/"+OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	contents, err := c.ObjectGetString(CONTAINER, "upload/"+OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Contents wrong, expecting %q got %q", CONTENTS, contents)
+	}
+
+	// Check redirect status is returned
+	opts.Redirect = "http://example.com/done"
+	files = []swift.FormPostFile{{Name: OBJECT, Contents: strings.NewReader(CONTENTS)}}
+	err = c.FormPost(CONTAINER, "upload/", "bad key", opts, files)
+	if serr, ok := err.(*swift.Error); !ok || serr.StatusCode != 401 {
+		t.Errorf("Expecting 401 error with bad key, got %v", err)
+	}
+
+	// Check size limit
+	files = []swift.FormPostFile{{Name: OBJECT, Contents: strings.NewReader(CONTENTS + CONTENTS)}}
+	err = c.FormPost(CONTAINER, "upload/", SECRET_KEY, opts, files)
+	if err != swift.TooLargeObject {
+		t.Errorf("Expecting TooLargeObject, got %v", err)
+	}
+}
+
+func TestFormPostTimeout(t *testing.T) {
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate a slow server.")
+		return
+	}
+	m := swift.Metadata{}
+	m["temp-url-key"] = SECRET_KEY
+	err := c.ContainerUpdate(CONTAINER, m.ContainerHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	postURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/upload/"
+	srv.SetOverride(postURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		time.Sleep(500 * time.Millisecond)
+		w.WriteHeader(recorder.Code)
+	})
+	defer srv.UnsetOverride(postURL)
+	connectTimeout, timeout := c.ConnectTimeout, c.Timeout
+	c.ConnectTimeout, c.Timeout = 50*time.Millisecond, 50*time.Millisecond
+	defer func() {
+		c.ConnectTimeout, c.Timeout = connectTimeout, timeout
+	}()
+	opts := &swift.FormPostOpts{
+		MaxFileSize:  CONTENT_SIZE,
+		MaxFileCount: 1,
+		Expires:      time.Now().Add(20 * time.Minute),
+	}
+	files := []swift.FormPostFile{{Name: OBJECT, Contents: strings.NewReader(CONTENTS)}}
+	err = c.FormPost(CONTAINER, "upload/", SECRET_KEY, opts, files)
+	if err != swift.TimeoutError {
+		t.Errorf("Expecting TimeoutError got %v", err)
+	}
+	_ = c.ObjectDelete(CONTAINER, "upload/"+OBJECT)
+}
+
 func TestQueryInfo(t *testing.T) {
 	c, rollback := makeConnectionAuth(t)
 	defer rollback()
@@ -2327,30 +4943,90 @@ func TestDLONoSegmentContainer(t *testing.T) {
 		ContentType:      "image/jpeg",
 		SegmentContainer: CONTAINER,
 	}
-	out, err := c.DynamicLargeObjectCreate(&opts)
+	out, err := c.DynamicLargeObjectCreate(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	for i := 0; i < 2; i++ {
+		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = out.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestPlainListingFallback(t *testing.T) {
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate a proxy without JSON listings.")
+		return
+	}
+
+	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER
+	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		if r.URL.Query().Get("format") == "json" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		for k, v := range recorder.HeaderMap {
+			w.Header().Set(k, v[0])
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(listURL)
+
+	err := c.ObjectPutString(CONTAINER, "dir/"+OBJECT, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	for i := 0; i < 2; i++ {
-		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+	defer func() {
+		err = c.ObjectDelete(CONTAINER, "dir/"+OBJECT)
 		if err != nil {
 			t.Fatal(err)
 		}
+	}()
+
+	if c.PlainListings() {
+		t.Error("PlainListings set before listing")
 	}
-	err = out.Close()
+	objects, err := c.Objects(CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if len(objects) != 2 || objects[0].Name != "dir/" || !objects[0].PseudoDirectory || objects[1].Name != OBJECT || objects[1].PseudoDirectory {
+		t.Errorf("Bad listing %+v", objects)
+	}
+	if !c.PlainListings() {
+		t.Error("PlainListings not set after fallback")
+	}
+	var names []string
+	err = c.ObjectsEach(CONTAINER, nil, func(object *swift.Object) error {
+		names = append(names, object.Name)
+		return nil
+	})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if !reflect.DeepEqual(names, []string{"dir/" + OBJECT, OBJECT}) {
+		t.Errorf("Bad names %q", names)
 	}
 }
 
@@ -2734,6 +5410,188 @@ func TestSLOCreate(t *testing.T) {
 	}
 }
 
+func TestSLOManifestPut(t *testing.T) {
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	var segments []swift.SLOSegment
+	var offset int64
+	for i, contents := range []string{"segment one ", "segment two ", "end"} {
+		name := fmt.Sprintf("%s/%d", OBJECT, i)
+		headers, err := c.ObjectPut(SEGMENTS_CONTAINER, name, strings.NewReader(contents), true, "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, swift.SLOSegment{
+			Container: SEGMENTS_CONTAINER,
+			Name:      name,
+			Etag:      headers["Etag"],
+			Offset:    offset,
+			Bytes:     int64(len(contents)),
+		})
+		offset += int64(len(contents))
+	}
+	err := c.StaticLargeObjectManifestPut(CONTAINER, OBJECT, "text/plain", segments, nil)
+	if err == swift.SLONotSupported {
+		t.Skip("SLO not supported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := c.StaticLargeObjectDelete(CONTAINER, OBJECT); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != "segment one segment two end" {
+		t.Errorf("Contents wrong, got %q", contents)
+	}
+
+	// A segment in the wrong place is rejected before the manifest is sent
+	gap := append([]swift.SLOSegment(nil), segments...)
+	gap[2].Offset++
+	err = c.StaticLargeObjectManifestPut(CONTAINER, OBJECT+"_gap", "", gap, nil)
+	if !errors.Is(err, swift.InvalidSLOSegment) || !strings.Contains(err.Error(), "segment 2 ") {
+		t.Errorf("Expecting error for segment 2 got %v", err)
+	}
+}
+
+func TestSLOManifestPutInfoFails(t *testing.T) {
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate /info failing.")
+		return
+	}
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer srv.UnsetOverride("/info")
+	segments := []swift.SLOSegment{{Container: SEGMENTS_CONTAINER, Name: OBJECT, Bytes: 1}}
+	err := c.StaticLargeObjectManifestPut(CONTAINER, OBJECT, "", segments, nil)
+	if err == nil || err == swift.SLONotSupported {
+		t.Errorf("Expecting the /info error got %v", err)
+	}
+}
+
+func TestSLOManifest(t *testing.T) {
+	segments := []swift.SLOSegment{
+		{Container: SEGMENTS_CONTAINER, Name: "a&b<c>/0", Etag: CONTENT_MD5, Bytes: CONTENT_SIZE},
+		{Container: SEGMENTS_CONTAINER, Name: "a&b<c>/1", Bytes: 1},
+	}
+	manifest, err := swift.SLOManifest(segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"etag":"` + CONTENT_MD5 + `","path":"` + SEGMENTS_CONTAINER + `/a&b<c>/0","size_bytes":5},{"path":"` + SEGMENTS_CONTAINER + `/a&b<c>/1","size_bytes":1}]`
+	if string(manifest) != want {
+		t.Errorf("Bad manifest\nwant %s\ngot  %s", want, manifest)
+	}
+	again, err := swift.SLOManifest(segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(manifest, again) {
+		t.Error("Manifest not stable")
+	}
+}
+
+func TestCheckSLOSegments(t *testing.T) {
+	info := swift.SwiftInfo{
+		"slo": map[string]interface{}{
+			"max_manifest_segments": float64(3),
+			"min_segment_size":      float64(10),
+		},
+	}
+	segment := func(offset, bytes int64) swift.SLOSegment {
+		return swift.SLOSegment{Container: SEGMENTS_CONTAINER, Name: OBJECT, Offset: offset, Bytes: bytes}
+	}
+	for _, test := range []struct {
+		segments []swift.SLOSegment
+		index    int // index of the bad segment or -1 for valid
+	}{
+		{[]swift.SLOSegment{segment(0, 10), segment(10, 10), segment(20, 1)}, -1},
+		{[]swift.SLOSegment{segment(0, 3)}, -1},
+		{[]swift.SLOSegment{segment(0, 10), segment(10, 9), segment(19, 10)}, 1},
+		{[]swift.SLOSegment{segment(0, 10), segment(11, 10)}, 1},
+		{[]swift.SLOSegment{segment(5, 10)}, 0},
+		{[]swift.SLOSegment{segment(0, 10), segment(10, -1)}, 1},
+		{[]swift.SLOSegment{segment(0, 10), {Offset: 10, Bytes: 10}}, 1},
+		{[]swift.SLOSegment{segment(0, 10), segment(10, 10), segment(20, 10), segment(30, 10)}, 3},
+	} {
+		err := info.CheckSLOSegments(test.segments)
+		if test.index < 0 {
+			if err != nil {
+				t.Errorf("%+v: unexpected error %v", test.segments, err)
+			}
+			continue
+		}
+		if !errors.Is(err, swift.InvalidSLOSegment) || !strings.Contains(err.Error(), fmt.Sprintf("segment %d ", test.index)) {
+			t.Errorf("%+v: expecting error for segment %d got %v", test.segments, test.index, err)
+		}
+	}
+	if err := info.CheckSLOSegments(nil); err != swift.SLONoSegments {
+		t.Errorf("Expecting SLONoSegments got %v", err)
+	}
+}
+
+func TestLargeObjectCreate(t *testing.T) {
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	info, err := c.QueryInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.SupportsSLO() {
+		t.Skip("SLO not supported")
+	}
+	tooManySegments := (info.SLOMaxSegments() + 1) * info.SLOMinSegmentSize()
+	for _, test := range []struct {
+		objectType swift.ObjectType
+		size       int64
+		want       swift.ObjectType
+	}{
+		{swift.RegularObjectType, 0, swift.StaticLargeObjectType},
+		{swift.RegularObjectType, tooManySegments, swift.DynamicLargeObjectType},
+		{swift.DynamicLargeObjectType, 0, swift.DynamicLargeObjectType},
+		{swift.StaticLargeObjectType, tooManySegments, swift.StaticLargeObjectType},
+	} {
+		opts := swift.LargeObjectOpts{
+			Container:  CONTAINER,
+			ObjectName: OBJECT,
+			ChunkSize:  info.SLOMinSegmentSize(),
+			ObjectType: test.objectType,
+			Size:       test.size,
+		}
+		out, err := c.LargeObjectCreate(&opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(out, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = out.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		object, _, err := c.Object(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if object.ObjectType != test.want {
+			t.Errorf("%+v: wrong ObjectType got %d", test, object.ObjectType)
+		}
+		err = c.LargeObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestSLOInsert(t *testing.T) {
 	c, rollback := makeConnectionWithSLO(t)
 	defer rollback()
@@ -2835,6 +5693,108 @@ func TestSLOMove(t *testing.T) {
 	}
 }
 
+func TestSLOUpdate(t *testing.T) {
+	c, rollback := makeConnectionWithSLO(t)
+	defer rollback()
+	h := swift.Headers{
+		"Content-Encoding":    "br",
+		"X-Object-Meta-Hello": "world",
+	}
+	ignored, err := c.LargeObjectUpdate(CONTAINER, OBJECT, h, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ignored, []string{"Content-Encoding"}) {
+		t.Errorf("Expecting Content-Encoding to be ignored got %v", ignored)
+	}
+
+	ignored, err = c.LargeObjectUpdate(CONTAINER, OBJECT, h, []string{"Content-Encoding"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ignored) != 0 {
+		t.Errorf("Expecting nothing ignored got %v", ignored)
+	}
+	_, headers, err := c.Object(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Object-Meta-Hello"] != "world" {
+		t.Error("Metadata not set on manifest")
+	}
+	segmentContainer, segments, err := c.LargeObjectGetSegments(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, segment := range segments {
+		_, headers, err := c.Object(segmentContainer, segment.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers["Content-Encoding"] != "br" {
+			t.Errorf("Content-Encoding not set on segment %q", segment.Name)
+		}
+		if _, ok := headers["X-Object-Meta-Hello"]; ok {
+			t.Errorf("Metadata set on segment %q", segment.Name)
+		}
+	}
+}
+
+func TestSLOCreateDeleteAfter(t *testing.T) {
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+		ChunkSize:   6,
+		DeleteAfter: time.Hour,
+	}
+	out, err := c.StaticLargeObjectCreate(&opts)
+	if err != nil {
+		if err == swift.SLONotSupported {
+			t.Skip("SLO not supported")
+			return
+		}
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.StaticLargeObjectDelete(CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, err = fmt.Fprintf(out, "%s", strings.Repeat("0123456789", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	_, headers, err := c.Object(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteAt := headers["X-Delete-At"]
+	if deleteAt == "" {
+		t.Fatal("X-Delete-At not set on manifest")
+	}
+	segmentContainer, segments, err := c.LargeObjectGetSegments(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, segment := range segments {
+		_, headers, err := c.Object(segmentContainer, segment.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers["X-Delete-At"] != deleteAt {
+			t.Errorf("segment %q: want X-Delete-At %q got %q", segment.Name, deleteAt, headers["X-Delete-At"])
+		}
+	}
+}
+
 func TestSLONoSegmentContainer(t *testing.T) {
 	c, rollback := makeConnectionWithSLO(t)
 	defer rollback()
@@ -2877,6 +5837,117 @@ func TestSLONoSegmentContainer(t *testing.T) {
 	}
 }
 
+func TestSLOLargeObjectEtagSegments(t *testing.T) {
+	c, rollback := makeConnectionWithSLO(t)
+	defer rollback()
+	c.LargeObjectEtag = swift.LargeObjectEtagSegments
+	defer func() { c.LargeObjectEtag = swift.LargeObjectEtagSkip }()
+	expected := fmt.Sprintf("0 %s\n1 %s\n", CONTENTS, CONTENTS)
+	contents, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong %q", contents)
+	}
+
+	// Corrupt the first segment without changing its size
+	segmentContainer, segments, err := c.LargeObjectGetSegments(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment, err := c.ObjectGetBytes(segmentContainer, segments[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment[0] ^= 1
+	err = c.ObjectPutBytes(segmentContainer, segments[0].Name, segment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ObjectGetString(CONTAINER, OBJECT)
+	if err != swift.ObjectCorrupted {
+		t.Errorf("Expecting ObjectCorrupted got %v", err)
+	}
+
+	// Not checked unless asked for
+	var buf bytes.Buffer
+	_, err = c.ObjectGet(CONTAINER, OBJECT, &buf, false, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	c.LargeObjectEtag = swift.LargeObjectEtagSkip
+	_, err = c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// bufferAt is an io.WriterAt writing into a fixed size buffer
+type bufferAt []byte
+
+func (b bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(b)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(b[off:], p), nil
+}
+
+func TestObjectGetParallel(t *testing.T) {
+	c, rollback := makeConnectionWithSLO(t)
+	defer rollback()
+	expected := fmt.Sprintf("0 %s\n1 %s\n", CONTENTS, CONTENTS)
+	for _, opts := range []*swift.ParallelOpts{
+		nil,
+		{ChunkSize: 3, Workers: 2},
+		{VerifySegments: true},
+	} {
+		buf := make(bufferAt, len(expected))
+		_, err := c.ObjectGetParallel(CONTAINER, OBJECT, buf, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != expected {
+			t.Errorf("%+v: contents wrong %q", opts, buf)
+		}
+	}
+
+	// Corrupt the last segment without changing its size
+	segmentContainer, segments, err := c.LargeObjectGetSegments(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment, err := c.ObjectGetBytes(segmentContainer, segments[len(segments)-1].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment[0] ^= 1
+	err = c.ObjectPutBytes(segmentContainer, segments[len(segments)-1].Name, segment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make(bufferAt, len(expected))
+	_, err = c.ObjectGetParallel(CONTAINER, OBJECT, buf, &swift.ParallelOpts{VerifySegments: true})
+	if err != swift.ObjectCorrupted {
+		t.Errorf("Expecting ObjectCorrupted got %v", err)
+	}
+	_, err = c.ObjectGetParallel(CONTAINER, OBJECT, buf, nil)
+	if err != nil {
+		t.Errorf("Not verifying: %v", err)
+	}
+}
+
+func TestDLOLargeObjectEtagSegments(t *testing.T) {
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	c.LargeObjectEtag = swift.LargeObjectEtagSegments
+	defer func() { c.LargeObjectEtag = swift.LargeObjectEtagSkip }()
+	_, err := c.ObjectGetString(CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSLOMinChunkSize(t *testing.T) {
 	c, rollback := makeConnectionWithSegmentsContainer(t)
 	defer rollback()
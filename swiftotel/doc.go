@@ -0,0 +1,18 @@
+// Package swiftotel traces the requests a swift.Connection makes with
+// OpenTelemetry.
+//
+// Set the Tracer of the Connection to one made from your
+// TracerProvider
+//
+//	c.Tracer = swiftotel.NewTracer(otel.GetTracerProvider())
+//
+// The spans are client spans with the names and attributes described
+// in swift.Tracer.  Spans which end with an error record it and have
+// their status set to Error.
+//
+// So the swift package doesn't depend on OpenTelemetry this package
+// is only built with the otel build tag, eg
+//
+//	go get go.opentelemetry.io/otel
+//	go build -tags otel
+package swiftotel
@@ -0,0 +1,64 @@
+//go:build otel
+// +build otel
+
+package swiftotel
+
+import (
+	"context"
+
+	"github.com/ncw/swift"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the Tracer NewTracer gets from
+// the TracerProvider
+const InstrumentationName = "github.com/ncw/swift"
+
+// NewTracer returns a swift.Tracer which starts its spans with a
+// Tracer from tp
+func NewTracer(tp trace.TracerProvider) swift.Tracer {
+	return tracer{tp.Tracer(InstrumentationName)}
+}
+
+// tracer adapts a trace.Tracer to a swift.Tracer
+type tracer struct {
+	trace.Tracer
+}
+
+// Start a client span - see swift.Tracer
+func (t tracer) Start(ctx context.Context, name string) (context.Context, swift.Span) {
+	ctx, s := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, span{s}
+}
+
+// span adapts a trace.Span to a swift.Span
+type span struct {
+	trace.Span
+}
+
+// SetAttribute sets an int or string attribute - see swift.Span
+func (s span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case int:
+		s.Span.SetAttributes(attribute.Int(key, v))
+	case string:
+		s.Span.SetAttributes(attribute.String(key, v))
+	}
+}
+
+// End the span recording err if set - see swift.Span
+func (s span) End(err error) {
+	if err != nil {
+		s.Span.RecordError(err)
+		s.Span.SetStatus(codes.Error, err.Error())
+	}
+	s.Span.End()
+}
+
+// Check it satisfies the interfaces
+var (
+	_ swift.Tracer = tracer{}
+	_ swift.Span   = span{}
+)
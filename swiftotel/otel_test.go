@@ -0,0 +1,79 @@
+//go:build otel
+// +build otel
+
+// Tests for the OpenTelemetry tracer
+package swiftotel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ncw/swift"
+	"github.com/ncw/swift/swiftotel"
+	"github.com/ncw/swift/swifttest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewTracer(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c := &swift.Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+		Tracer:   swiftotel.NewTracer(provider),
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "root")
+	before := len(recorder.Ended())
+	_, _, err = c.ObjectWithOptions("container", "missing", swift.WithContext(ctx))
+	if !errors.Is(err, swift.ObjectNotFound) {
+		t.Fatalf("expecting ObjectNotFound got %v", err)
+	}
+	root.End()
+
+	spans := recorder.Ended()[before:]
+	if len(spans) != 3 {
+		t.Fatalf("expecting 3 spans got %d", len(spans))
+	}
+	attempt, span := spans[0], spans[1]
+	if span.Name() != "swift HEAD" || span.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("bad span %q parent %v", span.Name(), span.Parent())
+	}
+	if attempt.Name() != "swift HEAD attempt" || attempt.Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("bad attempt span %q parent %v", attempt.Name(), attempt.Parent())
+	}
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("want client span got %v", span.SpanKind())
+	}
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	for key, want := range map[attribute.Key]attribute.Value{
+		"http.method":      attribute.StringValue("HEAD"),
+		"swift.container":  attribute.StringValue("container"),
+		"swift.object":     attribute.StringValue("missing"),
+		"http.status_code": attribute.IntValue(404),
+	} {
+		if got := attributes[key]; got != want {
+			t.Errorf("span attribute %q: want %v got %v", key, want.Emit(), got.Emit())
+		}
+	}
+	if span.Status().Code != codes.Error || len(span.Events()) != 1 {
+		t.Errorf("error not recorded: status %v events %v", span.Status(), span.Events())
+	}
+	if attempt.Status().Code == codes.Error {
+		t.Errorf("attempt has error status %v", attempt.Status())
+	}
+}
@@ -1,9 +1,22 @@
-// This implements a very basic Swift server
-// Everything is stored in memory
+// Package swifttest implements a very basic Swift server so code
+// using the swift package can be tested without a real cluster.
+//
+// Everything is stored in memory.  It supports v1 auth, accounts,
+// containers and objects with their metadata, listings with markers
+// and prefixes, and enough of the middleware (large objects, bulk
+// operations, temp URLs, versioning...) to test this package.
+//
+// Use it in tests like this
+//
+//	srv := swifttest.NewTestServer(t)
+//	c := &swift.Connection{
+//		UserName: swifttest.TEST_ACCOUNT,
+//		ApiKey:   swifttest.TEST_ACCOUNT,
+//		AuthUrl:  srv.AuthURL,
+//	}
 //
 // This comes from the https://github.com/mitchellh/goamz
 // and was adapted for Swift
-//
 package swifttest
 
 import (
@@ -59,6 +72,20 @@ type SwiftServer struct {
 	Accounts map[string]*account
 	Sessions map[string]*session
 	override map[string]HandlerOverrideFunc
+	// httpServer is set if started by NewTestServer
+	httpServer *httptest.Server
+	// ListingLimit is the maximum number of objects returned in a
+	// listing - 0 for the Swift default of 10000
+	ListingLimit int
+}
+
+// listingLimit returns the maximum number of objects returned in a
+// listing
+func (srv *SwiftServer) listingLimit() int {
+	if srv.ListingLimit > 0 {
+		return srv.ListingLimit
+	}
+	return 10000
 }
 
 // The Folder type represents a container stored in an account
@@ -75,8 +102,12 @@ type Key struct {
 	Size         int64  `json:"bytes"`
 	// ETag gives the hex-encoded MD5 sum of the contents,
 	// surrounded with double-quotes.
-	ETag        string `json:"hash"`
-	ContentType string `json:"content_type"`
+	ETag         string `json:"hash"`
+	ContentType  string `json:"content_type"`
+	SLOEtag      string `json:"slo_etag,omitempty"`
+	SymlinkPath  string `json:"symlink_path,omitempty"`
+	SymlinkEtag  string `json:"symlink_etag,omitempty"`
+	SymlinkBytes int64  `json:"symlink_bytes,omitempty"`
 	// Owner        Owner
 }
 
@@ -135,6 +166,10 @@ type object struct {
 	checksum     []byte // also held as ETag in meta.
 	data         []byte
 	content_type string
+	sloEtag      string // etag of the segments if this is an SLO manifest
+	symlinkPath  string // path of the target if this is a symlink
+	symlinkEtag  string // etag of the target if this is a static symlink
+	symlinkBytes int64  // size of the target if this is a static symlink
 }
 
 type container struct {
@@ -208,6 +243,10 @@ func fatalf(code int, codeStr string, errf string, a ...interface{}) {
 func (m metadata) setMetadata(a *action, resource string) {
 	for key, values := range a.req.Header {
 		key = http.CanonicalHeaderKey(key)
+		if strings.HasPrefix(key, "X-Remove-") && resource != "object" {
+			m.meta.Del("X-" + key[len("X-Remove-"):])
+			continue
+		}
 		if metaHeaders[key] || strings.HasPrefix(key, "X-"+strings.Title(resource)+"-Meta-") {
 			if values[0] != "" || resource == "object" {
 				m.meta[key] = values
@@ -216,6 +255,11 @@ func (m metadata) setMetadata(a *action, resource string) {
 			}
 		}
 	}
+	if after := a.req.Header.Get("X-Delete-After"); after != "" && resource == "object" {
+		if seconds, err := strconv.ParseInt(after, 10, 64); err == nil {
+			m.meta.Set("X-Delete-At", strconv.FormatInt(time.Now().Unix()+seconds, 10))
+		}
+	}
 }
 
 func (m metadata) getMetadata(a *action) {
@@ -225,7 +269,7 @@ func (m metadata) getMetadata(a *action) {
 	}
 }
 
-func (c *container) list(delimiter string, marker string, prefix string, parent string) (resp []interface{}) {
+func (c *container) list(delimiter string, marker string, endMarker string, prefix string, parent string, limit int, reverse bool) (resp []interface{}) {
 	var tmp orderedObjects
 
 	c.RLock()
@@ -237,7 +281,11 @@ func (c *container) list(delimiter string, marker string, prefix string, parent
 			tmp = append(tmp, obj)
 		}
 	}
-	sort.Sort(tmp)
+	if reverse {
+		sort.Sort(sort.Reverse(tmp))
+	} else {
+		sort.Sort(tmp)
+	}
 
 	var prefixes []string
 	for _, obj := range tmp {
@@ -261,9 +309,16 @@ func (c *container) list(delimiter string, marker string, prefix string, parent
 			}
 		}
 
-		if name <= marker {
+		skip, stop := checkMarkers(name, marker, endMarker, reverse)
+		if stop {
+			break
+		}
+		if skip {
 			continue
 		}
+		if limit > 0 && len(resp) >= limit {
+			break
+		}
 
 		if isPrefix {
 			prefixes = append(prefixes, name)
@@ -279,6 +334,16 @@ func (c *container) list(delimiter string, marker string, prefix string, parent
 	return
 }
 
+// checkMarkers returns whether name should be skipped because it is
+// before marker, or the listing stopped because it is at or after
+// endMarker.  If reverse is set the listing is in descending order.
+func checkMarkers(name string, marker string, endMarker string, reverse bool) (skip bool, stop bool) {
+	if reverse {
+		return marker != "" && name >= marker, endMarker != "" && name <= endMarker
+	}
+	return name <= marker, endMarker != "" && name >= endMarker
+}
+
 // GET on a container lists the objects in the container.
 func (r containerResource) get(a *action) interface{} {
 	if r.container == nil {
@@ -289,9 +354,14 @@ func (r containerResource) get(a *action) interface{} {
 
 	delimiter := a.req.Form.Get("delimiter")
 	marker := a.req.Form.Get("marker")
+	endMarker := a.req.Form.Get("end_marker")
 	prefix := a.req.Form.Get("prefix")
 	format := a.req.URL.Query().Get("format")
 	parent := a.req.Form.Get("path")
+	limit, _ := strconv.Atoi(a.req.Form.Get("limit"))
+	if max := a.srv.listingLimit(); limit <= 0 || limit > max {
+		limit = max
+	}
 
 	a.w.Header().Set("X-Container-Bytes-Used", strconv.Itoa(int(r.container.bytes)))
 	a.w.Header().Set("X-Container-Object-Count", strconv.Itoa(len(r.container.objects)))
@@ -303,7 +373,8 @@ func (r containerResource) get(a *action) interface{} {
 	}
 	r.container.RUnlock()
 
-	objects := r.container.list(delimiter, marker, prefix, parent)
+	reverse, _ := strconv.ParseBool(a.req.Form.Get("reverse"))
+	objects := r.container.list(delimiter, marker, endMarker, prefix, parent, limit, reverse)
 
 	if format == "json" {
 		a.w.Header().Set("Content-Type", "application/json")
@@ -477,7 +548,7 @@ func (r containerResource) put(a *action) interface{} {
 
 			obj.data = objData
 			obj.checksum = gotHash
-			obj.mtime = time.Now().UTC()
+			obj.mtime = timestampNow()
 			r.container.Lock()
 			r.container.objects[fullPath] = obj
 			r.container.bytes += header.Size
@@ -575,23 +646,66 @@ func (s orderedObjects) Less(i, j int) bool {
 func (obj *object) Key() Key {
 	return Key{
 		Key:          obj.name,
-		LastModified: obj.mtime.Format("2006-01-02T15:04:05"),
+		LastModified: obj.mtime.Format("2006-01-02T15:04:05.000000"),
 		Size:         int64(len(obj.data)),
 		ETag:         fmt.Sprintf("%x", obj.checksum),
 		ContentType:  obj.content_type,
+		SLOEtag:      obj.sloEtag,
+		SymlinkPath:  obj.symlinkPath,
+		SymlinkEtag:  obj.symlinkEtag,
+		SymlinkBytes: obj.symlinkBytes,
 	}
 }
 
 var metaHeaders = map[string]bool{
-	"Content-Type":          true,
-	"Content-Encoding":      true,
-	"Content-Disposition":   true,
-	"X-Object-Manifest":     true,
-	"X-Static-Large-Object": true,
+	"Content-Type":             true,
+	"Content-Encoding":         true,
+	"Content-Disposition":      true,
+	"X-Object-Manifest":        true,
+	"X-Static-Large-Object":    true,
+	"X-Delete-At":              true,
+	"X-Versions-Location":      true,
+	"X-History-Location":       true,
+	"X-Container-Read":         true,
+	"X-Container-Write":        true,
+	"X-Account-Access-Control": true,
+}
+
+// timestampNow returns the current time in UTC at the 10µs
+// resolution of the timestamps Swift uses
+func timestampNow() time.Time {
+	return time.Now().UTC().Truncate(10 * time.Microsecond)
+}
+
+// checkConditions checks the conditional headers of req against the
+// etag and modification time of an object.
+func checkConditions(req *http.Request, etag string, mtime time.Time) {
+	mtime = mtime.Truncate(time.Second)
+	if match := req.Header.Get("If-Match"); match != "" && match != "*" && strings.Trim(match, `"`) != etag {
+		fatalf(412, "PreconditionFailed", "If-Match precondition failed")
+	}
+	if t, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil && mtime.After(t) {
+		fatalf(412, "PreconditionFailed", "If-Unmodified-Since precondition failed")
+	}
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		if match == "*" || strings.Trim(match, `"`) == etag {
+			fatalf(304, "NotModified", "Not Modified")
+		}
+	} else if t, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && !mtime.After(t) {
+		fatalf(304, "NotModified", "Not Modified")
+	}
 }
 
 var rangeRegexp = regexp.MustCompile("(bytes=)?([0-9]*)-([0-9]*)")
 
+// checkRange returns 416 if a range starting at start is past the end
+// of an object of size bytes.
+func checkRange(start, size int) {
+	if start > 0 && start >= size {
+		fatalf(416, "RangeNotSatisfiable", "The Range requested is not available.")
+	}
+}
+
 // GET on an object gets the contents of the object.
 func (objr objectResource) get(a *action) interface{} {
 	var (
@@ -605,9 +719,22 @@ func (objr objectResource) get(a *action) interface{} {
 		fatalf(404, "Not Found", "The resource could not be found.")
 	}
 
+	obj.RLock()
+	symlinkPath := obj.symlinkPath
+	obj.RUnlock()
+	if symlinkPath != "" && a.req.URL.Query().Get("symlink") != "get" {
+		return symlinkGet(a, symlinkPath)
+	}
+
 	obj.RLock()
 	defer obj.RUnlock()
 
+	// Like Swift with allow_open_expired set, X-Open-Expired reads
+	// expired objects which haven't been reaped yet
+	if obj.expired() && !strings.EqualFold(a.req.Header.Get("X-Open-Expired"), "true") {
+		fatalf(404, "Not Found", "The resource could not be found.")
+	}
+
 	h := a.w.Header()
 	// add metadata
 	obj.getMetadata(a)
@@ -636,7 +763,7 @@ func (objr objectResource) get(a *action) interface{} {
 		segContainer := a.user.Containers[components[0]]
 		a.user.RUnlock()
 		prefix := components[1]
-		resp := segContainer.list("", "", prefix, "")
+		resp := segContainer.list("", "", "", prefix, "", 0, false)
 		sum := md5.New()
 		cursor := 0
 		size := 0
@@ -653,6 +780,7 @@ func (objr objectResource) get(a *action) interface{} {
 			}
 		}
 		etag = sum.Sum(nil)
+		checkRange(start, size)
 		if end == -1 {
 			end = size - 1
 		}
@@ -681,11 +809,13 @@ func (objr objectResource) get(a *action) interface{} {
 			cursor += length
 		}
 		etag = sum.Sum(nil)
+		checkRange(start, size)
 		if end == -1 {
 			end = size - 1
 		}
 		reader = io.LimitReader(io.MultiReader(segments...), int64(end-start+1))
 	} else {
+		checkRange(start, len(obj.data))
 		if end == -1 {
 			end = len(obj.data) - 1
 		}
@@ -695,6 +825,10 @@ func (objr objectResource) get(a *action) interface{} {
 
 	etagHex := hex.EncodeToString(etag)
 
+	// Large objects are checked against their own Etag rather than
+	// that of their manifest
+	checkConditions(a.req, etagHex, obj.mtime)
+
 	if a.req.Header.Get("If-None-Match") == etagHex {
 		a.w.WriteHeader(http.StatusNotModified)
 		return nil
@@ -703,6 +837,7 @@ func (objr objectResource) get(a *action) interface{} {
 	h.Set("Content-Length", fmt.Sprint(end-start+1))
 	h.Set("ETag", etagHex)
 	h.Set("Last-Modified", obj.mtime.Format(http.TimeFormat))
+	h.Set("X-Timestamp", fmt.Sprintf("%d.%05d", obj.mtime.Unix(), obj.mtime.Nanosecond()/1e4))
 
 	if a.req.Method == "HEAD" {
 		return nil
@@ -727,6 +862,12 @@ func (objr objectResource) put(a *action) interface{} {
 			fatalf(400, "InvalidDigest", "The ETag you specified was invalid")
 		}
 	}
+	if a.req.Header.Get("If-None-Match") == "*" && objr.object != nil {
+		fatalf(412, "PreconditionFailed", "The object already exists")
+	}
+	if source := a.req.Header.Get("X-Copy-From"); source != "" {
+		return objr.copyFrom(a, source)
+	}
 	sum := md5.New()
 	// TODO avoid holding lock while reading data.
 	data, err := ioutil.ReadAll(io.TeeReader(a.req.Body, sum))
@@ -741,6 +882,12 @@ func (objr objectResource) put(a *action) interface{} {
 		fatalf(400, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
 	}
 
+	if objr.object != nil {
+		if versions, _ := versionsContainer(a, objr.container); versions != nil {
+			archiveObject(a, versions, objr.object)
+		}
+	}
+
 	// TODO is this correct, or should we erase all previous metadata?
 	obj := objr.object
 	if obj == nil {
@@ -764,13 +911,18 @@ func (objr objectResource) put(a *action) interface{} {
 		}
 	}
 
+	obj.sloEtag = ""
+	obj.symlinkPath, obj.symlinkEtag, obj.symlinkBytes = symlinkTarget(a)
+
 	if a.req.URL.Query().Get("multipart-manifest") == "put" {
 		// TODO: check the content of the SLO
 		a.req.Header.Set("X-Static-Large-Object", "True")
 
 		var segments []segment
 		json.Unmarshal(data, &segments)
+		sloSum := md5.New()
 		for i := range segments {
+			sloSum.Write([]byte(segments[i].Etag))
 			segments[i].Name = "/" + segments[i].Path
 			segments[i].Path = ""
 			segments[i].Hash = segments[i].Etag
@@ -779,6 +931,8 @@ func (objr objectResource) put(a *action) interface{} {
 			segments[i].Size = 0
 		}
 
+		obj.sloEtag = `"` + hex.EncodeToString(sloSum.Sum(nil)) + `"`
+
 		data, _ = json.Marshal(segments)
 		sum = md5.New()
 		sum.Write(data)
@@ -790,7 +944,7 @@ func (objr objectResource) put(a *action) interface{} {
 	obj.content_type = content_type
 	obj.data = data
 	obj.checksum = gotHash
-	obj.mtime = time.Now().UTC()
+	obj.mtime = timestampNow()
 	objr.container.Lock()
 	objr.container.objects[objr.name] = obj
 	objr.container.bytes += int64(len(data))
@@ -804,11 +958,84 @@ func (objr objectResource) put(a *action) interface{} {
 	return nil
 }
 
+// symlinkTarget returns the path of the target of the symlink being
+// PUT, or "" if it isn't a symlink.  If the target etag is set then
+// the target is checked and its etag and size are returned too.
+func symlinkTarget(a *action) (path string, etag string, size int64) {
+	target := a.req.Header.Get("X-Symlink-Target")
+	if target == "" {
+		return "", "", 0
+	}
+	account := a.req.Header.Get("X-Symlink-Target-Account")
+	if account == "" {
+		accountName, _, _, _ := a.srv.parseURL(a.req.URL)
+		account = "AUTH_" + accountName
+	}
+	path = "/v1/" + account + "/" + target
+	etag = strings.Trim(a.req.Header.Get("X-Symlink-Target-Etag"), `"`)
+	if etag == "" {
+		return path, "", 0
+	}
+	targetr, ok := a.srv.resourceForURL(&url.URL{Path: path}).(objectResource)
+	if !ok || targetr.object == nil {
+		fatalf(409, "Conflict", "X-Symlink-Target does not exist")
+	}
+	targetr.object.RLock()
+	defer targetr.object.RUnlock()
+	if hex.EncodeToString(targetr.object.checksum) != etag {
+		fatalf(409, "Conflict", "Object Etag %q does not match X-Symlink-Target-Etag header %q", hex.EncodeToString(targetr.object.checksum), etag)
+	}
+	return path, etag, int64(len(targetr.object.data))
+}
+
+// symlinkGet serves a GET or HEAD on the target of the symlink at
+// path.
+func symlinkGet(a *action, path string) interface{} {
+	targetr, ok := a.srv.resourceForURL(&url.URL{Path: path}).(objectResource)
+	if !ok || targetr.object == nil {
+		fatalf(404, "Not Found", "The resource could not be found.")
+	}
+	return targetr.get(a)
+}
+
 func (objr objectResource) delete(a *action) interface{} {
 	if objr.object == nil {
 		fatalf(404, "NoSuchKey", "The specified key does not exist.")
 	}
 
+	versions, history := versionsContainer(a, objr.container)
+	if versions != nil && history {
+		archiveObject(a, versions, objr.object)
+	}
+
+	objr.object.RLock()
+	expired := objr.object.expired()
+	objr.object.RUnlock()
+
+	objr.removeObject(a)
+
+	if versions != nil && !history {
+		restoreVersion(a, versions, objr)
+	}
+
+	// Like Swift, expired objects which haven't been reaped yet
+	// are deleted but return 404
+	if expired {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+
+	return nil
+}
+
+// expired returns whether the X-Delete-At time of the object has
+// passed.  Expired objects are still listed until they are deleted,
+// as they are in Swift until the object expirer gets to them.
+func (obj *object) expired() bool {
+	deleteAt, err := strconv.ParseInt(obj.meta.Get("X-Delete-At"), 10, 64)
+	return err == nil && deleteAt <= time.Now().Unix()
+}
+
+func (objr objectResource) removeObject(a *action) {
 	objr.container.Lock()
 	defer objr.container.Unlock()
 
@@ -820,8 +1047,96 @@ func (objr objectResource) delete(a *action) interface{} {
 
 	atomic.AddInt64(&a.user.BytesUsed, -int64(len(objr.object.data)))
 	atomic.AddInt64(&a.user.Objects, -1)
+}
 
-	return nil
+// versionsContainer returns the container that old versions of the
+// objects in c are archived to, and whether it is in history mode
+// (X-History-Location) rather than stack mode (X-Versions-Location).
+//
+// It returns nil if versioning isn't enabled on c.
+func versionsContainer(a *action, c *container) (versions *container, history bool) {
+	c.RLock()
+	name := c.meta.Get("X-Versions-Location")
+	if name == "" {
+		name = c.meta.Get("X-History-Location")
+		history = true
+	}
+	c.RUnlock()
+	if name == "" || a.user == nil {
+		return nil, false
+	}
+	a.user.RLock()
+	versions = a.user.Containers[name]
+	a.user.RUnlock()
+	if versions == nil || versions == c {
+		return nil, false
+	}
+	return versions, history
+}
+
+// versionPrefix returns the prefix of the names of the archived
+// versions of objectName
+func versionPrefix(objectName string) string {
+	return fmt.Sprintf("%03x%s/", len(objectName), objectName)
+}
+
+// archiveObject copies obj into the versions container
+func archiveObject(a *action, versions *container, obj *object) {
+	name := versionPrefix(obj.name) + fmt.Sprintf("%010d.%09d", obj.mtime.Unix(), obj.mtime.Nanosecond())
+	archived := &object{
+		name: name,
+		metadata: metadata{
+			meta: make(http.Header),
+		},
+		mtime:        obj.mtime,
+		checksum:     obj.checksum,
+		data:         obj.data,
+		content_type: obj.content_type,
+	}
+	for key, values := range obj.meta {
+		archived.meta[key] = values
+	}
+
+	versions.Lock()
+	if old := versions.objects[name]; old != nil {
+		versions.bytes -= int64(len(old.data))
+		atomic.AddInt64(&a.user.BytesUsed, -int64(len(old.data)))
+		atomic.AddInt64(&a.user.Objects, -1)
+	}
+	versions.objects[name] = archived
+	versions.bytes += int64(len(obj.data))
+	versions.Unlock()
+
+	atomic.AddInt64(&a.user.BytesUsed, int64(len(obj.data)))
+	atomic.AddInt64(&a.user.Objects, 1)
+}
+
+// restoreVersion moves the newest archived version of the object
+// from the versions container back into place, if there is one
+func restoreVersion(a *action, versions *container, objr objectResource) {
+	prefix := versionPrefix(objr.name)
+
+	versions.Lock()
+	var latest string
+	for name := range versions.objects {
+		if strings.HasPrefix(name, prefix) && name > latest {
+			latest = name
+		}
+	}
+	if latest == "" {
+		versions.Unlock()
+		return
+	}
+	obj := versions.objects[latest]
+	delete(versions.objects, latest)
+	versions.bytes -= int64(len(obj.data))
+	versions.Unlock()
+
+	obj.name = objr.name
+	objr.container.Lock()
+	objr.container.objects[objr.name] = obj
+	objr.container.bytes += int64(len(obj.data))
+	objr.container.Unlock()
 }
 
 func (objr objectResource) post(a *action) interface{} {
@@ -829,6 +1144,12 @@ func (objr objectResource) post(a *action) interface{} {
 	defer objr.object.Unlock()
 
 	obj := objr.object
+	// POST replaces all the metadata of an object
+	for key := range obj.meta {
+		if strings.HasPrefix(key, "X-Object-Meta-") {
+			obj.meta.Del(key)
+		}
+	}
 	obj.setMetadata(a, "object")
 	return nil
 }
@@ -838,40 +1159,69 @@ func (objr objectResource) copy(a *action) interface{} {
 		fatalf(404, "NoSuchKey", "The specified key does not exist.")
 	}
 
-	obj := objr.object
-	obj.RLock()
-	defer obj.RUnlock()
-
 	destination := a.req.Header.Get("Destination")
 	if destination == "" {
 		fatalf(400, "Bad Request", "You must provide a Destination header")
 	}
 
-	var (
-		obj2  *object
-		objr2 objectResource
-	)
+	destURL, _ := url.Parse(accountPath(a, "Destination-Account") + "/" + destination)
+	switch objr2 := a.srv.resourceForURL(destURL).(type) {
+	case objectResource:
+		copyObject(a, objr.object, objr2)
+	default:
+		fatalf(400, "Bad Request", "Destination must point to a valid object path")
+	}
+
+	return nil
+}
 
-	destURL, _ := url.Parse("/v1/AUTH_" + TEST_ACCOUNT + "/" + destination)
-	r := a.srv.resourceForURL(destURL)
-	switch t := r.(type) {
+// accountPath returns the path of the account named in the header
+// accountHeader or of the account in the request if it isn't set.
+func accountPath(a *action, accountHeader string) string {
+	account := a.req.Header.Get(accountHeader)
+	if account == "" {
+		name, _, _, _ := a.srv.parseURL(a.req.URL)
+		account = "AUTH_" + name
+	}
+	return "/v1/" + account
+}
+
+// copyFrom does a PUT with X-Copy-From into objr
+func (objr objectResource) copyFrom(a *action, source string) interface{} {
+	srcURL, _ := url.Parse(accountPath(a, "X-Copy-From-Account") + "/" + strings.TrimPrefix(source, "/"))
+	switch srcr := a.srv.resourceForURL(srcURL).(type) {
 	case objectResource:
-		objr2 = t
-		if objr2.object == nil {
-			obj2 = &object{
-				name: objr2.name,
-				metadata: metadata{
-					meta: make(http.Header),
-				},
-			}
-			atomic.AddInt64(&a.user.Objects, 1)
-		} else {
-			obj2 = objr2.object
-			atomic.AddInt64(&objr2.container.bytes, -int64(len(obj2.data)))
-			atomic.AddInt64(&a.user.BytesUsed, -int64(len(obj2.data)))
+		if srcr.object == nil {
+			fatalf(404, "NoSuchKey", "The specified key does not exist.")
 		}
+		copyObject(a, srcr.object, objr)
 	default:
-		fatalf(400, "Bad Request", "Destination must point to a valid object path")
+		fatalf(400, "Bad Request", "X-Copy-From must point to a valid object path")
+	}
+	return nil
+}
+
+// copyObject copies obj to objr2 applying the metadata in the request
+func copyObject(a *action, obj *object, objr2 objectResource) {
+	obj.RLock()
+	defer obj.RUnlock()
+
+	var obj2 *object
+	if objr2.object == nil {
+		obj2 = &object{
+			name: objr2.name,
+			metadata: metadata{
+				meta: make(http.Header),
+			},
+		}
+		atomic.AddInt64(&a.user.Objects, 1)
+	} else {
+		obj2 = objr2.object
+		if versions, _ := versionsContainer(a, objr2.container); versions != nil {
+			archiveObject(a, versions, obj2)
+		}
+		atomic.AddInt64(&objr2.container.bytes, -int64(len(obj2.data)))
+		atomic.AddInt64(&a.user.BytesUsed, -int64(len(obj2.data)))
 	}
 
 	if objr2.container.name != objr2.container.name && obj2.name != obj.name {
@@ -882,11 +1232,16 @@ func (objr objectResource) copy(a *action) interface{} {
 	obj2.content_type = obj.content_type
 	obj2.data = obj.data
 	obj2.checksum = obj.checksum
-	obj2.mtime = time.Now()
+	obj2.mtime = timestampNow()
 
+	fresh, _ := strconv.ParseBool(a.req.Header.Get("X-Fresh-Metadata"))
+	meta := make(http.Header)
 	for key, values := range obj.metadata.meta {
-		obj2.metadata.meta[key] = values
+		if !fresh || metaHeaders[key] {
+			meta[key] = values
+		}
 	}
+	obj2.metadata.meta = meta
 	obj2.setMetadata(a, "object")
 
 	objr2.container.Lock()
@@ -895,8 +1250,6 @@ func (objr objectResource) copy(a *action) interface{} {
 	objr2.container.Unlock()
 
 	atomic.AddInt64(&a.user.BytesUsed, int64(len(obj.data)))
-
-	return nil
 }
 
 func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
@@ -968,11 +1321,22 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	if req.URL.String() == "/info" {
 		jsonMarshal(w, &map[string]interface{}{
 			"swift": map[string]interface{}{
-				"version": "1.2",
+				"version":                 "1.2",
+				"container_listing_limit": s.listingLimit(),
+			},
+			"bulk_delete": map[string]interface{}{
+				"max_deletes_per_request": 10000,
+			},
+			"bulk_upload": map[string]interface{}{
+				"max_containers_per_extraction": 10000,
 			},
 			"tempurl": map[string]interface{}{
 				"methods": []string{"GET", "HEAD", "PUT"},
 			},
+			"symlink": map[string]interface{}{
+				"symloop_max":  2,
+				"static_links": true,
+			},
 			"slo": map[string]interface{}{
 				"max_manifest_segments": 1000,
 				"max_manifest_size":     2097152,
@@ -985,32 +1349,56 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	r = s.resourceForURL(req.URL)
 
 	key := req.Header.Get("x-auth-token")
+	if key == "" && req.Method == "POST" && strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		s.formPost(a)
+		return
+	}
 	signature := req.URL.Query().Get("temp_url_sig")
 	expires := req.URL.Query().Get("temp_url_expires")
 	if key == "" && signature != "" && expires != "" {
-		accountName, _, _, _ := s.parseURL(req.URL)
-		secretKey := ""
-		s.RLock()
-		if account, ok := s.Accounts[accountName]; ok {
-			secretKey = account.meta.Get("X-Account-Meta-Temp-Url-Key")
+		accountName, containerName, _, _ := s.parseURL(req.URL)
+		keys := s.tempUrlKeys(accountName, containerName)
+
+		signedPath := req.URL.Path
+		if prefix, ok := req.URL.Query()["temp_url_prefix"]; ok {
+			containerPath := strings.SplitN(req.URL.Path, "/", 5)
+			if len(containerPath) < 5 || !strings.HasPrefix(containerPath[4], prefix[0]) {
+				panic(notAuthorized())
+			}
+			signedPath = "prefix:" + strings.Join(containerPath[:4], "/") + "/" + prefix[0]
 		}
-		s.RUnlock()
 
-		get_hmac := func(method string) string {
+		get_hmac := func(secretKey string, method string) string {
 			mac := hmac.New(sha1.New, []byte(secretKey))
-			body := fmt.Sprintf("%s\n%s\n%s", method, expires, req.URL.Path)
+			body := fmt.Sprintf("%s\n%s\n%s", method, expires, signedPath)
 			mac.Write([]byte(body))
 			return hex.EncodeToString(mac.Sum(nil))
 		}
 
+		methods := []string{req.Method}
 		if req.Method == "HEAD" {
-			if signature != get_hmac("GET") && signature != get_hmac("POST") && signature != get_hmac("PUT") {
-				panic(notAuthorized())
+			methods = []string{"GET", "POST", "PUT"}
+		}
+		valid := false
+		for _, secretKey := range keys {
+			for _, method := range methods {
+				if signature == get_hmac(secretKey, method) {
+					valid = true
+				}
 			}
-		} else if signature != get_hmac(req.Method) {
+		}
+		if !valid {
 			panic(notAuthorized())
 		}
+	} else if key == "" && (req.Method == "GET" || req.Method == "HEAD") && s.publicRead(req.URL) {
+		accountName, _, _, _ := s.parseURL(req.URL)
+		s.RLock()
+		a.user = s.Accounts[accountName]
+		s.RUnlock()
 	} else {
+		if len(key) < 7 {
+			panic(notAuthorized())
+		}
 		s.RLock()
 		session, ok := s.Sessions[key[7:]]
 		if !ok {
@@ -1054,6 +1442,187 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// formPostStatus reports the status of a form post either directly
+// or through the redirect if one was supplied.
+func formPostStatus(a *action, redirect string, status int, message string) {
+	if redirect != "" {
+		v := url.Values{}
+		v.Set("status", strconv.Itoa(status))
+		v.Set("message", message)
+		http.Redirect(a.w, a.req, redirect+"?"+v.Encode(), http.StatusSeeOther)
+		return
+	}
+	http.Error(a.w, message, status)
+}
+
+// tempUrlKeys returns the keys which may be used to sign temporary
+// URLs and forms for the container in the account.
+func (s *SwiftServer) tempUrlKeys(accountName string, containerName string) (keys []string) {
+	add := func(m metadata, resource string) {
+		for _, suffix := range []string{"", "-2"} {
+			if key := m.meta.Get("X-" + resource + "-Meta-Temp-Url-Key" + suffix); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	s.RLock()
+	account, ok := s.Accounts[accountName]
+	s.RUnlock()
+	if !ok {
+		return nil
+	}
+	account.RLock()
+	add(account.metadata, "Account")
+	c := account.Containers[containerName]
+	account.RUnlock()
+	if c != nil {
+		c.RLock()
+		add(c.metadata, "Container")
+		c.RUnlock()
+	}
+	return keys
+}
+
+// publicRead returns whether the container in u has a read ACL which
+// allows anonymous access - ".r:*" for objects and ".rlistings" as
+// well for listings.
+func (s *SwiftServer) publicRead(u *url.URL) bool {
+	accountName, containerName, objectName, err := s.parseURL(u)
+	if err != nil || containerName == "" {
+		return false
+	}
+	s.RLock()
+	account, ok := s.Accounts[accountName]
+	s.RUnlock()
+	if !ok {
+		return false
+	}
+	account.RLock()
+	c := account.Containers[containerName]
+	account.RUnlock()
+	if c == nil {
+		return false
+	}
+	c.RLock()
+	acl := c.metadata.meta.Get("X-Container-Read")
+	c.RUnlock()
+	referrer, listings := false, false
+	for _, item := range strings.Split(acl, ",") {
+		switch strings.TrimSpace(item) {
+		case ".r:*":
+			referrer = true
+		case ".rlistings":
+			listings = true
+		}
+	}
+	return referrer && (objectName != "" || listings)
+}
+
+// formPost implements the formpost middleware, uploading the files
+// in a multipart/form-data POST without an auth token.
+func (s *SwiftServer) formPost(a *action) {
+	accountName, containerName, prefix, _ := s.parseURL(a.req.URL)
+	s.RLock()
+	account, ok := s.Accounts[accountName]
+	s.RUnlock()
+	if !ok || containerName == "" {
+		panic(notAuthorized())
+	}
+	account.RLock()
+	c := account.Containers[containerName]
+	account.RUnlock()
+	if c == nil {
+		fatalf(404, "NoSuchContainer", "The specified container does not exist")
+	}
+	keys := s.tempUrlKeys(accountName, containerName)
+
+	mr, err := a.req.MultipartReader()
+	if err != nil {
+		fatalf(400, "Bad Request", "Invalid multipart form: %v", err)
+	}
+	fields := map[string]string{}
+	count := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			fatalf(400, "Bad Request", "Invalid multipart form: %v", err)
+		}
+		if part.FileName() == "" {
+			value, _ := ioutil.ReadAll(part)
+			fields[part.FormName()] = string(value)
+			continue
+		}
+		redirect := fields["redirect"]
+		if count == 0 {
+			expires, _ := strconv.ParseInt(fields["expires"], 10, 64)
+			if expires < time.Now().Unix() {
+				formPostStatus(a, redirect, 401, "FormPost: Form Expired")
+				return
+			}
+			body := fmt.Sprintf("%s\n%s\n%s\n%s\n%s", a.req.URL.Path, redirect, fields["max_file_size"], fields["max_file_count"], fields["expires"])
+			valid := false
+			for _, key := range keys {
+				mac := hmac.New(sha1.New, []byte(key))
+				mac.Write([]byte(body))
+				if hex.EncodeToString(mac.Sum(nil)) == fields["signature"] {
+					valid = true
+				}
+			}
+			if !valid {
+				formPostStatus(a, redirect, 401, "FormPost: Invalid Signature")
+				return
+			}
+		}
+		count++
+		maxFileCount, _ := strconv.Atoi(fields["max_file_count"])
+		if count > maxFileCount {
+			formPostStatus(a, redirect, 400, "max_file_count exceeded")
+			return
+		}
+		maxFileSize, _ := strconv.ParseInt(fields["max_file_size"], 10, 64)
+		data, err := ioutil.ReadAll(io.LimitReader(part, maxFileSize+1))
+		if err != nil {
+			fatalf(400, "Bad Request", "read error")
+		}
+		if int64(len(data)) > maxFileSize {
+			formPostStatus(a, redirect, 400, "max_file_size exceeded")
+			return
+		}
+
+		name := prefix + part.FileName()
+		sum := md5.Sum(data)
+		c.Lock()
+		obj := c.objects[name]
+		if obj == nil {
+			obj = &object{
+				name: name,
+				metadata: metadata{
+					meta: make(http.Header),
+				},
+			}
+			c.objects[name] = obj
+			atomic.AddInt64(&account.Objects, 1)
+		} else {
+			c.bytes -= int64(len(obj.data))
+			atomic.AddInt64(&account.BytesUsed, -int64(len(obj.data)))
+		}
+		obj.content_type = part.Header.Get("Content-Type")
+		obj.data = data
+		obj.checksum = sum[:]
+		obj.mtime = timestampNow()
+		c.bytes += int64(len(data))
+		c.Unlock()
+		atomic.AddInt64(&account.BytesUsed, int64(len(data)))
+	}
+	if redirect := fields["redirect"]; redirect != "" {
+		formPostStatus(a, redirect, 201, "")
+		return
+	}
+	a.w.WriteHeader(201)
+}
+
 func (s *SwiftServer) SetOverride(path string, fn HandlerOverrideFunc) {
 	s.override[path] = fn
 }
@@ -1155,8 +1724,15 @@ type rootResource struct{}
 func (rootResource) put(a *action) interface{} { return notAllowed() }
 func (rootResource) get(a *action) interface{} {
 	marker := a.req.Form.Get("marker")
+	endMarker := a.req.Form.Get("end_marker")
 	prefix := a.req.Form.Get("prefix")
+	delimiter := a.req.Form.Get("delimiter")
 	format := a.req.URL.Query().Get("format")
+	reverse, _ := strconv.ParseBool(a.req.Form.Get("reverse"))
+	limit, _ := strconv.Atoi(a.req.Form.Get("limit"))
+	if max := a.srv.listingLimit(); limit <= 0 || limit > max {
+		limit = max
+	}
 
 	h := a.w.Header()
 
@@ -1181,21 +1757,50 @@ func (rootResource) get(a *action) interface{} {
 			tmp = append(tmp, container)
 		}
 	}
-	sort.Sort(tmp)
+	if reverse {
+		sort.Sort(sort.Reverse(tmp))
+	} else {
+		sort.Sort(tmp)
+	}
 
-	resp := make([]Folder, 0)
+	resp := make([]interface{}, 0)
+	n := 0
+	lastPrefix := ""
 	for _, container := range tmp {
-		if container.name <= marker {
+		name := container.name
+		isPrefix := false
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				name = name[:len(prefix)+i+len(delimiter)]
+				if name == lastPrefix {
+					continue
+				}
+				isPrefix = true
+			}
+		}
+		skip, stop := checkMarkers(name, marker, endMarker, reverse)
+		if stop || n >= limit {
+			break
+		}
+		if skip {
 			continue
 		}
-		if format == "json" {
+		n++
+		if isPrefix {
+			lastPrefix = name
+		}
+		if format != "json" {
+			a.w.Write([]byte(name + "\n"))
+		} else if isPrefix {
+			resp = append(resp, Subdir{
+				Subdir: name,
+			})
+		} else {
 			resp = append(resp, Folder{
 				Count: int64(len(container.objects)),
 				Bytes: container.bytes,
 				Name:  container.name,
 			})
-		} else {
-			a.w.Write([]byte(container.name + "\n"))
 		}
 	}
 
@@ -1221,13 +1826,17 @@ func (r rootResource) delete(a *action) interface{} {
 		}
 		var nb, notFound int
 		for _, obj := range strings.Fields(string(data)) {
-			parts := strings.SplitN(obj, "/", 3)
-			if len(parts) < 3 {
+			// Names are URL encoded with an optional leading "/"
+			if unescaped, err := url.PathUnescape(obj); err == nil {
+				obj = unescaped
+			}
+			parts := strings.SplitN(strings.TrimPrefix(obj, "/"), "/", 2)
+			if len(parts) < 2 {
 				fatalf(403, "Operation forbidden", "Bulk delete is not supported for containers")
 			}
 			b := containerResource{
-				name:      parts[1],
-				container: a.user.Containers[parts[1]],
+				name:      parts[0],
+				container: a.user.Containers[parts[0]],
 			}
 			if b.container == nil {
 				notFound++
@@ -1235,7 +1844,7 @@ func (r rootResource) delete(a *action) interface{} {
 			}
 
 			objr := objectResource{
-				name:      parts[2],
+				name:      parts[1],
 				container: b.container,
 			}
 			objr.container.RLock()
@@ -1248,15 +1857,7 @@ func (r rootResource) delete(a *action) interface{} {
 				continue
 			}
 
-			objr.container.Lock()
-			objr.object.Lock()
-			objr.container.bytes -= int64(len(objr.object.data))
-			delete(objr.container.objects, objr.name)
-			objr.object.Unlock()
-			objr.container.Unlock()
-
-			atomic.AddInt64(&a.user.BytesUsed, -int64(len(objr.object.data)))
-			atomic.AddInt64(&a.user.Objects, -1)
+			objr.removeObject(a)
 			nb++
 		}
 
@@ -1284,15 +1885,9 @@ func (r rootResource) delete(a *action) interface{} {
 
 func (rootResource) copy(a *action) interface{} { return notAllowed() }
 
-func NewSwiftServer(address string) (*SwiftServer, error) {
-	if strings.Index(address, ":") == -1 {
-		address += ":0"
-	}
-	l, err := net.Listen("tcp", address)
-	if err != nil {
-		return nil, fmt.Errorf("cannot listen on %s: %v", address, err)
-	}
-
+// newSwiftServer makes a SwiftServer with the TEST_ACCOUNT account
+// listening on l
+func newSwiftServer(l net.Listener) *SwiftServer {
 	server := &SwiftServer{
 		Listener: l,
 		AuthURL:  "http://" + l.Addr().String() + "/v1.0",
@@ -1309,6 +1904,19 @@ func NewSwiftServer(address string) (*SwiftServer, error) {
 		},
 		Containers: make(map[string]*container),
 	}
+	return server
+}
+
+func NewSwiftServer(address string) (*SwiftServer, error) {
+	if strings.Index(address, ":") == -1 {
+		address += ":0"
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s: %v", address, err)
+	}
+
+	server := newSwiftServer(l)
 
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		server.serveHTTP(w, req)
@@ -1317,6 +1925,27 @@ func NewSwiftServer(address string) (*SwiftServer, error) {
 	return server, nil
 }
 
+// NewTestServer starts a SwiftServer on an httptest.Server on a local
+// port.  It is closed when the test tb and its subtests finish.
+//
+// Authenticate with TEST_ACCOUNT as the user name and key and AuthURL
+// as the v1 auth URL.
+func NewTestServer(tb testing.TB) *SwiftServer {
+	var server *SwiftServer
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		server.serveHTTP(w, req)
+	}))
+	server = newSwiftServer(ts.Listener)
+	server.httpServer = ts
+	ts.Start()
+	tb.Cleanup(server.Close)
+	return server
+}
+
 func (srv *SwiftServer) Close() {
+	if srv.httpServer != nil {
+		srv.httpServer.Close()
+		return
+	}
 	srv.Listener.Close()
 }
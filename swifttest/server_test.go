@@ -0,0 +1,42 @@
+// Tests for the in-memory Swift server
+package swifttest_test
+
+import (
+	"testing"
+
+	"github.com/ncw/swift"
+	"github.com/ncw/swift/swifttest"
+)
+
+func TestNewTestServer(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	c := &swift.Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		err = c.ObjectPutString("container", name, name, "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := c.ObjectNames("container", &swift.ObjectsOpts{Marker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Errorf("Bad listing %q", names)
+	}
+	contents, err := c.ObjectGetString("container", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != "b" {
+		t.Errorf("Bad contents %q", contents)
+	}
+}
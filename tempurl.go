@@ -0,0 +1,165 @@
+// Temporary URLs for all the objects under a prefix and checking
+// temporary URLs
+
+package swift
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// TempUrlInvalid is returned by VerifyTempUrl if the URL isn't a
+	// temporary URL or its signature doesn't match
+	TempUrlInvalid = newError(401, "Temporary URL Invalid")
+	// TempUrlExpired is returned by VerifyTempUrl if the URL has expired
+	TempUrlExpired = newError(401, "Temporary URL Expired")
+)
+
+// TempUrlPrefix is a signature which allows access to every object in
+// a container whose name starts with Prefix, as made by
+// Connection.TempUrlPrefix.
+//
+// Use ObjectUrl to make the temporary URL for each object.
+type TempUrlPrefix struct {
+	StorageUrl string    // Storage URL of the account
+	Container  string    // Name of the container
+	Prefix     string    // Prefix of the object names, eg "dir/" for a pseudo-directory
+	Signature  string    // Signature of the prefix
+	Expires    time.Time // Time the URLs expire
+}
+
+// TempUrlPrefix returns a signature which allows method (eg "GET")
+// on every object in container whose name starts with prefix until
+// expires.
+//
+// secretKey should be one of the temporary URL keys of the account or
+// container.  The server must support prefix based temporary URLs,
+// which Swift has done since version 2.12.
+func (c *Connection) TempUrlPrefix(container string, prefix string, secretKey string, method string, expires time.Time) *TempUrlPrefix {
+	storageUrl, _ := url.Parse(c.StorageUrl)
+	mac := hmac.New(sha1.New, []byte(secretKey))
+	body := fmt.Sprintf("%s\n%d\nprefix:%s/%s/%s", method, expires.Unix(), storageUrl.Path, container, prefix)
+	mac.Write([]byte(body))
+	return &TempUrlPrefix{
+		StorageUrl: c.StorageUrl,
+		Container:  container,
+		Prefix:     prefix,
+		Signature:  hex.EncodeToString(mac.Sum(nil)),
+		Expires:    expires,
+	}
+}
+
+// ObjectUrl returns the temporary URL for objectName, which must
+// start with the Prefix.
+func (p *TempUrlPrefix) ObjectUrl(objectName string) (string, error) {
+	if !strings.HasPrefix(objectName, p.Prefix) {
+		return "", newErrorf(0, "object %q is not under prefix %q", objectName, p.Prefix)
+	}
+	query := url.Values{
+		"temp_url_sig":     {p.Signature},
+		"temp_url_expires": {fmt.Sprintf("%d", p.Expires.Unix())},
+		"temp_url_prefix":  {p.Prefix},
+	}
+	return p.StorageUrl + "/" + urlPathEscape(p.Container+"/"+objectName) + "?" + query.Encode(), nil
+}
+
+// tempUrlMethods are the methods VerifyTempUrl tries
+var tempUrlMethods = []string{"GET", "HEAD", "PUT", "POST", "DELETE"}
+
+// tempUrlSignature decodes a temp_url_sig into the digest and the
+// hash it was made with.  Hex signatures are identified by their
+// length, others are "sha1:", "sha256:" or "sha512:" followed by
+// base64.
+func tempUrlSignature(sig string) (digest []byte, newHash func() hash.Hash, err error) {
+	hashes := map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+	if i := strings.Index(sig, ":"); i >= 0 {
+		newHash = hashes[sig[:i]]
+		if newHash == nil {
+			return nil, nil, TempUrlInvalid
+		}
+		encoded := strings.TrimRight(sig[i+1:], "=")
+		digest, err = base64.RawURLEncoding.DecodeString(strings.NewReplacer("+", "-", "/", "_").Replace(encoded))
+		if err != nil {
+			return nil, nil, TempUrlInvalid
+		}
+		return digest, newHash, nil
+	}
+	digest, err = hex.DecodeString(sig)
+	if err != nil {
+		return nil, nil, TempUrlInvalid
+	}
+	for _, newHash := range hashes {
+		if newHash().Size() == len(digest) {
+			return digest, newHash, nil
+		}
+	}
+	return nil, nil, TempUrlInvalid
+}
+
+// VerifyTempUrl checks the signature and expiry of a temporary URL
+// made with key, as made by ObjectTempUrl or TempUrlPrefix.ObjectUrl,
+// without contacting the server.
+//
+// It returns the method the URL was signed for.  URLs signed for GET
+// are returned as "GET" though Swift will accept them for HEAD too.
+//
+// It returns TempUrlInvalid if the signature doesn't match or the URL
+// isn't a temporary URL and TempUrlExpired if it has expired.
+// Signatures made with SHA-1, SHA-256 and SHA-512 are accepted, and
+// expiry times as Unix times or in ISO 8601 format.
+func VerifyTempUrl(tempUrl string, key string) (method string, err error) {
+	u, err := url.Parse(tempUrl)
+	if err != nil {
+		return "", TempUrlInvalid
+	}
+	query := u.Query()
+	sig, expiresString := query.Get("temp_url_sig"), query.Get("temp_url_expires")
+	if sig == "" || expiresString == "" {
+		return "", TempUrlInvalid
+	}
+	var expires int64
+	if t, err := time.Parse(time.RFC3339, expiresString); err == nil {
+		expires = t.Unix()
+	} else if expires, err = strconv.ParseInt(expiresString, 10, 64); err != nil {
+		return "", TempUrlInvalid
+	}
+	digest, newHash, err := tempUrlSignature(sig)
+	if err != nil {
+		return "", err
+	}
+	path := u.Path
+	if prefix, ok := query["temp_url_prefix"]; ok {
+		// The object must be under the prefix of a prefix URL
+		parts := strings.SplitN(path, "/", 5)
+		if len(parts) != 5 || !strings.HasPrefix(parts[4], prefix[0]) {
+			return "", TempUrlInvalid
+		}
+		path = "prefix:" + strings.Join(parts[:4], "/") + "/" + prefix[0]
+	}
+	for _, method := range tempUrlMethods {
+		mac := hmac.New(newHash, []byte(key))
+		fmt.Fprintf(mac, "%s\n%d\n%s", method, expires, path)
+		if hmac.Equal(mac.Sum(nil), digest) {
+			if time.Now().Unix() >= expires {
+				return method, TempUrlExpired
+			}
+			return method, nil
+		}
+	}
+	return "", TempUrlInvalid
+}
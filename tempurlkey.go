@@ -0,0 +1,117 @@
+// Management of the keys used to sign temporary URLs
+
+package swift
+
+// TempUrlKeys holds the two keys which can be used to sign temporary
+// URLs and form posts.  URLs signed with either key are accepted.
+type TempUrlKeys struct {
+	Key  string // Primary key - X-Account-Meta-Temp-Url-Key or X-Container-Meta-Temp-Url-Key
+	Key2 string // Secondary key - X-Account-Meta-Temp-Url-Key-2 or X-Container-Meta-Temp-Url-Key-2
+}
+
+// tempUrlKeyHeaders returns the names of the headers the keys are
+// stored in for resource "Account" or "Container"
+func tempUrlKeyHeaders(resource string) (key string, key2 string) {
+	key = "X-" + resource + "-Meta-Temp-Url-Key"
+	return key, key + "-2"
+}
+
+// readTempUrlKeys reads the keys for resource from the headers h
+func readTempUrlKeys(resource string, h Headers) TempUrlKeys {
+	key, key2 := tempUrlKeyHeaders(resource)
+	return TempUrlKeys{
+		Key:  h[key],
+		Key2: h[key2],
+	}
+}
+
+// headers returns the headers to set the keys for resource.  Empty
+// keys are removed.
+func (keys TempUrlKeys) headers(resource string) Headers {
+	key, key2 := tempUrlKeyHeaders(resource)
+	return Headers{
+		key:  keys.Key,
+		key2: keys.Key2,
+	}
+}
+
+// AccountTempUrlKeys reads the temporary URL keys of the account.
+func (c *Connection) AccountTempUrlKeys() (TempUrlKeys, error) {
+	_, headers, err := c.Account()
+	if err != nil {
+		return TempUrlKeys{}, err
+	}
+	return readTempUrlKeys("Account", headers), nil
+}
+
+// AccountSetTempUrlKeys sets the temporary URL keys of the account.
+//
+// Empty keys are removed.
+func (c *Connection) AccountSetTempUrlKeys(keys TempUrlKeys) error {
+	return c.AccountUpdate(keys.headers("Account"))
+}
+
+// AccountRotateTempUrlKey replaces the primary temporary URL key of
+// the account with newKey without invalidating URLs signed with
+// either key.
+//
+// newKey is first installed as the secondary key, then promoted to be
+// the primary key with the old primary key becoming the secondary
+// key.  This means URLs signed with the old key carry on working
+// until the next rotation, and newKey is accepted before anything
+// starts signing with it.
+//
+// It returns the old primary key.
+func (c *Connection) AccountRotateTempUrlKey(newKey string) (oldKey string, err error) {
+	keys, err := c.AccountTempUrlKeys()
+	if err != nil {
+		return "", err
+	}
+	return rotateTempUrlKey(keys, newKey, c.AccountSetTempUrlKeys)
+}
+
+// ContainerTempUrlKeys reads the temporary URL keys of container.
+func (c *Connection) ContainerTempUrlKeys(container string) (TempUrlKeys, error) {
+	_, headers, err := c.Container(container)
+	if err != nil {
+		return TempUrlKeys{}, err
+	}
+	return readTempUrlKeys("Container", headers), nil
+}
+
+// ContainerSetTempUrlKeys sets the temporary URL keys of container.
+//
+// Empty keys are removed.
+func (c *Connection) ContainerSetTempUrlKeys(container string, keys TempUrlKeys) error {
+	return c.ContainerUpdate(container, keys.headers("Container"))
+}
+
+// ContainerRotateTempUrlKey replaces the primary temporary URL key of
+// container with newKey without invalidating URLs signed with either
+// key.
+//
+// This works in the same way as AccountRotateTempUrlKey.
+func (c *Connection) ContainerRotateTempUrlKey(container string, newKey string) (oldKey string, err error) {
+	keys, err := c.ContainerTempUrlKeys(container)
+	if err != nil {
+		return "", err
+	}
+	return rotateTempUrlKey(keys, newKey, func(keys TempUrlKeys) error {
+		return c.ContainerSetTempUrlKeys(container, keys)
+	})
+}
+
+// rotateTempUrlKey installs newKey in the secondary slot then
+// promotes it to the primary slot using set to write the keys.
+func rotateTempUrlKey(keys TempUrlKeys, newKey string, set func(TempUrlKeys) error) (oldKey string, err error) {
+	oldKey = keys.Key
+	err = set(TempUrlKeys{Key: oldKey, Key2: newKey})
+	if err != nil {
+		return "", err
+	}
+	err = set(TempUrlKeys{Key: newKey, Key2: oldKey})
+	if err != nil {
+		return "", err
+	}
+	return oldKey, nil
+}
@@ -0,0 +1,100 @@
+// Hooks for tracing the requests a Connection makes
+
+package swift
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer starts the spans which trace the requests a Connection makes
+// - set it as the Tracer of the Connection.
+//
+// Each request to storage gets a span named "swift <operation>", eg
+// "swift GET", with a child span "swift <operation> attempt" for each
+// time it is tried, so retries show up in the trace.  The spans have
+// these attributes when they are known
+//
+//	swift.container      name of the container
+//	swift.object         name of the object
+//	swift.attempt        number of the attempt starting at 1 (attempt spans only)
+//	http.method          the operation
+//	http.status_code     status code of the response (int)
+//	swift.trans_id       transaction ID of the response
+//
+// The contexts passed to Start are from the context of the request
+// (see WithContext) so the spans join the caller's trace, and the
+// HTTP request is made with the context of the attempt span so an
+// instrumented Transport can continue the trace.
+//
+// To use OpenTelemetry use the Tracer from the swiftotel package, eg
+//
+//	c.Tracer = swiftotel.NewTracer(provider)
+type Tracer interface {
+	// Start a span called name as a child of any span in ctx,
+	// returning a context containing the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttribute sets the attribute key to value which is a
+	// string or an int
+	SetAttribute(key string, value interface{})
+
+	// End the span with the error of the operation if it failed
+	End(err error)
+}
+
+// responseTransId returns the transaction ID in the response headers
+// h or "" if there isn't one
+func responseTransId(h http.Header) string {
+	if transId := h.Get("X-Trans-Id"); transId != "" {
+		return transId
+	}
+	return h.Get("X-Openstack-Request-Id")
+}
+
+// startSpan starts the span of the request p if the Connection has a
+// Tracer, returning nil otherwise
+func (c *Connection) startSpan(ctx context.Context, p *RequestOpts) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := c.Tracer.Start(ctx, "swift "+p.Operation)
+	span.SetAttribute("http.method", p.Operation)
+	if p.Container != "" {
+		span.SetAttribute("swift.container", p.Container)
+	}
+	if p.ObjectName != "" {
+		span.SetAttribute("swift.object", p.ObjectName)
+	}
+	return ctx, span
+}
+
+// startAttempt starts the span of attempt number attempt of the
+// request p if the Connection has a Tracer, returning nil otherwise
+func (c *Connection) startAttempt(ctx context.Context, p *RequestOpts, attempt int) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := c.Tracer.Start(ctx, "swift "+p.Operation+" attempt")
+	span.SetAttribute("http.method", p.Operation)
+	span.SetAttribute("swift.attempt", attempt)
+	return ctx, span
+}
+
+// endSpan records the response, if any, and ends span unless it is
+// nil
+func endSpan(span Span, resp *http.Response, err error) {
+	if span == nil {
+		return
+	}
+	if resp != nil {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if transId := responseTransId(resp.Header); transId != "" {
+			span.SetAttribute("swift.trans_id", transId)
+		}
+	}
+	span.End(err)
+}
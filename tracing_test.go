@@ -0,0 +1,149 @@
+// Tests for the tracing hooks
+package swift
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ncw/swift/swifttest"
+)
+
+// testSpan is a span recorded by testTracer
+type testSpan struct {
+	name       string
+	parent     *testSpan
+	attributes map[string]interface{}
+	ended      bool
+	err        error
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+type testSpanKey struct{}
+
+// testTracer records the spans started
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent, attributes: map[string]interface{}{}}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+// transIdTransport adds a transaction ID to the responses and records
+// the span in the context of the last request
+type transIdTransport struct {
+	span *testSpan
+}
+
+func (t *transIdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.span, _ = req.Context().Value(testSpanKey{}).(*testSpan)
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		resp.Header.Set("X-Trans-Id", "tx1234")
+	}
+	return resp, err
+}
+
+func TestTracer(t *testing.T) {
+	srv := swifttest.NewTestServer(t)
+	tracer := &testTracer{}
+	transport := &transIdTransport{}
+	c := &Connection{
+		UserName:  swifttest.TEST_ACCOUNT,
+		ApiKey:    swifttest.TEST_ACCOUNT,
+		AuthUrl:   srv.AuthURL,
+		Transport: transport,
+		Tracer:    tracer,
+	}
+	err := c.ContainerCreate("container", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := &testSpan{name: "root"}
+	ctx := context.WithValue(context.Background(), testSpanKey{}, root)
+	tracer.spans = nil
+	_, _, err = c.ObjectWithOptions("container", "missing", WithContext(ctx))
+	if err != ObjectNotFound {
+		t.Fatalf("expecting ObjectNotFound got %v", err)
+	}
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expecting 2 spans got %d", len(tracer.spans))
+	}
+	span, attempt := tracer.spans[0], tracer.spans[1]
+	if span.name != "swift HEAD" || span.parent != root {
+		t.Errorf("bad span %q parent %v", span.name, span.parent)
+	}
+	if attempt.name != "swift HEAD attempt" || attempt.parent != span {
+		t.Errorf("bad attempt span %q parent %v", attempt.name, attempt.parent)
+	}
+	if transport.span != attempt {
+		t.Errorf("request not made with the context of the attempt span")
+	}
+	for key, want := range map[string]interface{}{
+		"http.method":      "HEAD",
+		"swift.container":  "container",
+		"swift.object":     "missing",
+		"http.status_code": 404,
+		"swift.trans_id":   "tx1234",
+	} {
+		if got := span.attributes[key]; got != want {
+			t.Errorf("span attribute %q: want %v got %v", key, want, got)
+		}
+	}
+	if !span.ended || span.err != ObjectNotFound {
+		t.Errorf("span ended %v with %v", span.ended, span.err)
+	}
+	for key, want := range map[string]interface{}{
+		"swift.attempt":    1,
+		"http.status_code": 404,
+		"swift.trans_id":   "tx1234",
+	} {
+		if got := attempt.attributes[key]; got != want {
+			t.Errorf("attempt attribute %q: want %v got %v", key, want, got)
+		}
+	}
+	if !attempt.ended || attempt.err != nil {
+		t.Errorf("attempt ended %v with %v", attempt.ended, attempt.err)
+	}
+
+	// An expired token is retried in a second attempt
+	tracer.spans = nil
+	c.AuthToken = "expired"
+	err = c.ObjectPutString("container", "object", "12345", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, span := range tracer.spans {
+		names = append(names, span.name)
+	}
+	if len(tracer.spans) != 3 || names[0] != "swift PUT" || names[1] != "swift PUT attempt" || names[2] != "swift PUT attempt" {
+		t.Fatalf("bad spans %q", names)
+	}
+	if got := tracer.spans[1].attributes["http.status_code"]; got != 401 {
+		t.Errorf("first attempt status want 401 got %v", got)
+	}
+	if got := tracer.spans[2].attributes["swift.attempt"]; got != 2 {
+		t.Errorf("second attempt number want 2 got %v", got)
+	}
+	if got := tracer.spans[0].attributes["http.status_code"]; got != 200 {
+		t.Errorf("span status got %v", got)
+	}
+}
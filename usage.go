@@ -0,0 +1,124 @@
+// Totting up the objects and bytes used in each container of an account
+
+package swift
+
+import (
+	"sync"
+)
+
+// DefaultUsageWorkers is the number of containers AccountUsage lists
+// at once if AccountUsageOpts.Workers isn't set
+const DefaultUsageWorkers = 4
+
+// ContainerUsage holds the usage of one container
+type ContainerUsage struct {
+	Name     string       `json:"name"`               // Name of the container
+	Objects  int64        `json:"objects"`            // Number of objects in the container
+	Bytes    int64        `json:"bytes"`              // Number of bytes used by the objects
+	Prefixes []PrefixStat `json:"prefixes,omitempty"` // Usage under each prefix, sorted, if AccountUsageOpts.Depth was set
+}
+
+// Usage holds the usage of an account as returned by AccountUsage
+type Usage struct {
+	Containers []ContainerUsage `json:"containers"` // Usage of each container sorted by name
+	Objects    int64            `json:"objects"`    // Number of objects in the account
+	Bytes      int64            `json:"bytes"`      // Number of bytes used by the account
+}
+
+// UsageProgressFunc is called by AccountUsage after each container
+// has been counted with the number of containers done so far, the
+// total number of containers and the usage of the container.
+type UsageProgressFunc func(done int, total int, container *ContainerUsage)
+
+// AccountUsageOpts is options for AccountUsage
+type AccountUsageOpts struct {
+	Workers    int               // Number of containers to list at once - 0 for DefaultUsageWorkers
+	Depth      int               // Number of directory levels to count the usage of prefixes for - 0 for none
+	Containers *ContainersOpts   // Options for listing the containers, eg a Prefix - can be nil
+	Progress   UsageProgressFunc // Called after each container is counted - can be nil
+}
+
+// AccountUsage lists every object in every container of the account
+// and adds up the number of objects and bytes used in each, Workers
+// containers at a time.
+//
+// Account and Containers only return the totals the server keeps,
+// which are updated asynchronously and don't say what is using the
+// space.  If Depth is set the usage of each prefix down to Depth
+// directory levels is counted too as with PrefixStats.
+//
+// The Progress function, if set, is called from one go routine at a
+// time.  opts may be nil for the defaults.
+func (c *Connection) AccountUsage(opts *AccountUsageOpts) (*Usage, error) {
+	var options AccountUsageOpts
+	if opts != nil {
+		options = *opts
+	}
+	if options.Workers <= 0 {
+		options.Workers = DefaultUsageWorkers
+	}
+	names, err := c.ContainerNamesAll(options.Containers)
+	if err != nil {
+		return nil, err
+	}
+	usage := &Usage{
+		Containers: make([]ContainerUsage, len(names)),
+	}
+	type result struct {
+		index int
+		stats *PrefixStats
+		err   error
+	}
+	in := make(chan int)
+	out := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range in {
+				stats, err := c.PrefixStats(names[index], options.Depth)
+				out <- result{index: index, stats: stats, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(in)
+		for i := range names {
+			in <- i
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	done := 0
+	for result := range out {
+		done++
+		if result.err != nil {
+			if err == nil {
+				err = result.err
+			}
+			continue
+		}
+		container := &usage.Containers[result.index]
+		container.Name = names[result.index]
+		for _, stat := range result.stats.Prefixes {
+			if stat.Prefix == "" {
+				container.Objects, container.Bytes = stat.Objects, stat.Bytes
+			} else {
+				container.Prefixes = append(container.Prefixes, stat)
+			}
+		}
+		usage.Objects += container.Objects
+		usage.Bytes += container.Bytes
+		if options.Progress != nil {
+			options.Progress(done, len(names), container)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
@@ -0,0 +1,148 @@
+// Watching a container for changes by polling it
+
+package swift
+
+import (
+	"time"
+)
+
+// DefaultWatchInterval is the time between listings if the interval
+// passed to Watch isn't positive
+const DefaultWatchInterval = time.Minute
+
+// DefaultWatchMaxBackoff is the longest Watch waits between listings
+// after errors if WatchOpts.MaxBackoff isn't set
+const DefaultWatchMaxBackoff = 5 * time.Minute
+
+// WatchEventType says what happened to an object
+type WatchEventType int
+
+// Types of WatchEvent
+const (
+	ObjectAdded    WatchEventType = iota // The object is new
+	ObjectModified                       // The object has changed
+	ObjectRemoved                        // The object has been deleted
+)
+
+// WatchEvent is a change to a container found by Watch
+type WatchEvent struct {
+	Type   WatchEventType // What happened
+	Object Object         // The object as listed, or as last listed if it was removed
+	Err    error          // If set the listing failed and Type and Object aren't used
+}
+
+// WatchOpts is options for Watch
+type WatchOpts struct {
+	Prefix     string        // Only watch objects whose names start with this
+	Initial    bool          // Send ObjectAdded events for the objects there when watching starts
+	MaxBackoff time.Duration // Longest time to wait between listings after errors - 0 for DefaultWatchMaxBackoff
+}
+
+// Watcher sends the changes to a container made by Watch
+type Watcher struct {
+	Events <-chan WatchEvent // The changes - closed after Stop
+	events chan WatchEvent
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Watch lists container every interval and sends a WatchEvent on the
+// Events channel of the Watcher for each object added, modified or
+// removed since the previous listing, as found by ContainerDiff.
+//
+// Each listing reads the whole container (or prefix), a page at a
+// time, so don't make interval too short on big containers.  If a
+// listing fails a WatchEvent with Err set is sent and the listing is
+// tried again, waiting twice as long each time up to MaxBackoff.
+//
+// Call Stop when finished with the Watcher.  opts may be nil.
+func (c *Connection) Watch(container string, interval time.Duration, opts *WatchOpts) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	var options WatchOpts
+	if opts != nil {
+		options = *opts
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = DefaultWatchMaxBackoff
+	}
+	if options.MaxBackoff < interval {
+		options.MaxBackoff = interval
+	}
+	events := make(chan WatchEvent)
+	w := &Watcher{
+		Events: events,
+		events: events,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go w.run(c, container, interval, &options)
+	return w
+}
+
+// send sends event returning false if the Watcher was stopped first
+func (w *Watcher) send(event WatchEvent) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
+
+// sendAll sends an event of type eventType for each of objects
+// returning false if the Watcher was stopped first
+func (w *Watcher) sendAll(eventType WatchEventType, objects []Object) bool {
+	for _, object := range objects {
+		if !w.send(WatchEvent{Type: eventType, Object: object}) {
+			return false
+		}
+	}
+	return true
+}
+
+// run does the listings until Stop is called
+func (w *Watcher) run(c *Connection, container string, interval time.Duration, opts *WatchOpts) {
+	defer close(w.done)
+	defer close(w.events)
+	var last []Object
+	first := true
+	wait := interval
+	for {
+		diff, listing, err := c.ContainerDiff(container, last, &ObjectsOpts{Prefix: opts.Prefix})
+		if err != nil {
+			if !w.send(WatchEvent{Err: err}) {
+				return
+			}
+			if wait *= 2; wait > opts.MaxBackoff {
+				wait = opts.MaxBackoff
+			}
+		} else {
+			if !first || opts.Initial {
+				if !w.sendAll(ObjectAdded, diff.Added) ||
+					!w.sendAll(ObjectModified, diff.Modified) ||
+					!w.sendAll(ObjectRemoved, diff.Removed) {
+					return
+				}
+			}
+			first = false
+			last = listing
+			wait = interval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-w.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Stop stops watching and closes the Events channel.  It waits for
+// any listing in progress to finish.
+func (w *Watcher) Stop() {
+	close(w.stop)
+	<-w.done
+}
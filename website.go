@@ -0,0 +1,99 @@
+// Configuring containers to be served as websites by staticweb
+
+package swift
+
+import (
+	"strconv"
+)
+
+// Headers of a container read by the staticweb middleware
+const (
+	webIndex         = "X-Container-Meta-Web-Index"
+	webError         = "X-Container-Meta-Web-Error"
+	webListings      = "X-Container-Meta-Web-Listings"
+	webListingsCSS   = "X-Container-Meta-Web-Listings-Css"
+	webListingsLabel = "X-Container-Meta-Web-Listings-Label"
+)
+
+// WebsiteConfig is the staticweb configuration of a container which
+// makes Swift serve it like a web site.
+type WebsiteConfig struct {
+	Index         string // Object served for the container and pseudo directories, eg "index.html"
+	Error         string // Suffix of the objects served for errors, eg "error.html" serves "404error.html" for a 404
+	Listings      bool   // Serve listings of pseudo directories without an Index object
+	ListingsCSS   string // Style sheet for listings, eg "listings.css" - relative to the container or an absolute URL
+	ListingsLabel string // Label used in the listings instead of the storage URL
+}
+
+// WebsiteConfig reads the staticweb configuration of a container from
+// the headers returned by Container.
+func (h Headers) WebsiteConfig() *WebsiteConfig {
+	listings, _ := strconv.ParseBool(h[webListings])
+	return &WebsiteConfig{
+		Index:         h[webIndex],
+		Error:         h[webError],
+		Listings:      listings,
+		ListingsCSS:   h[webListingsCSS],
+		ListingsLabel: h[webListingsLabel],
+	}
+}
+
+// Enabled returns whether staticweb will serve anything for the
+// container.
+func (config *WebsiteConfig) Enabled() bool {
+	return config.Index != "" || config.Listings
+}
+
+// Headers returns the headers which set the staticweb configuration
+// of a container to config.  Unset values are empty so the headers
+// remove them.
+func (config *WebsiteConfig) Headers() Headers {
+	h := Headers{
+		webIndex:         config.Index,
+		webError:         config.Error,
+		webListings:      "",
+		webListingsCSS:   config.ListingsCSS,
+		webListingsLabel: config.ListingsLabel,
+	}
+	if config.Listings {
+		h[webListings] = "true"
+	}
+	return h
+}
+
+// ContainerWebsite reads the staticweb configuration of container.
+func (c *Connection) ContainerWebsite(container string) (*WebsiteConfig, error) {
+	_, headers, err := c.Container(container)
+	if err != nil {
+		return nil, err
+	}
+	return headers.WebsiteConfig(), nil
+}
+
+// ContainerSetWebsite sets the staticweb configuration of container,
+// replacing the existing one.  Pass an empty WebsiteConfig to turn it
+// off.
+//
+// staticweb only serves containers which can be read without
+// authenticating - use ContainerHostWebsite to set that too.
+func (c *Connection) ContainerSetWebsite(container string, config *WebsiteConfig) error {
+	return c.ContainerUpdate(container, config.Headers())
+}
+
+// ContainerHostWebsite serves container as a web site with config.
+//
+// The staticweb configuration is set and the container is made
+// readable by anyone with ContainerMakePublic, allowing listings if
+// config.Listings is set.  The container is created if it doesn't
+// exist.
+func (c *Connection) ContainerHostWebsite(container string, config *WebsiteConfig) error {
+	err := c.ContainerCreate(container, nil)
+	if err != nil {
+		return err
+	}
+	err = c.ContainerSetWebsite(container, config)
+	if err != nil {
+		return err
+	}
+	return c.ContainerMakePublic(container, config.Listings)
+}